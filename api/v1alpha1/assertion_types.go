@@ -0,0 +1,185 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PassedCondition is true once the expected messages were found
+	PassedCondition = "Passed"
+
+	// FailedCondition is true once the expectation can not be met anymore
+	FailedCondition = "Failed"
+
+	// WaitingReason the assertion is evaluated again until its timeout
+	WaitingReason = "Waiting"
+
+	// MatchedReason the expected count of messages matched
+	MatchedReason = "Matched"
+
+	// TimeoutReason the expected count of messages did not match before the timeout
+	TimeoutReason = "Timeout"
+
+	// UnavailableReason the messages of the instance could still not be read at the timeout
+	UnavailableReason = "Unavailable"
+
+	// InvalidReason the assertion can not be evaluated, e.g. because of an invalid regular expression
+	InvalidReason = "Invalid"
+)
+
+// MailhogAssertionSpec defines the expected messages of a MailhogInstance
+// all given expectations have to match the same message
+type MailhogAssertionSpec struct {
+	// Instance is the name of the MailhogInstance (in the same namespace) whose messages are checked
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Mailhog Instance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Instance string `json:"instance"`
+
+	// Recipient is an address the message is sent to (envelope, To or Cc, case insensitive)
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Recipient",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Recipient string `json:"recipient,omitempty"`
+
+	// SubjectRegex is a regular expression (RE2 syntax) the decoded subject has to match
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Subject Regex",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	SubjectRegex string `json:"subjectRegex,omitempty"`
+
+	// BodyContains is a text the decoded body (any text part) has to contain
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Body Contains",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	BodyContains string `json:"bodyContains,omitempty"`
+
+	// Headers maps header names to a value one of the decoded header values has to equal
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Headers"
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Count is the number of matching messages expected at least, 0 expects no matching message until the timeout
+	// an assertion whose instance can not be read at the timeout fails for every count
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:default:=1
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Count",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	Count *int32 `json:"count,omitempty"`
+
+	// Timeout after the first evaluation until the assertion fails (or passes for a count of 0 if no message matched)
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="5m"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Timeout",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// MailhogAssertionStatus defines the observed state of MailhogAssertion
+type MailhogAssertionStatus struct {
+	// MatchCount is the count of matching messages at the last evaluation
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	MatchCount int `json:"matchCount,omitempty"`
+
+	// Matches are the ids of the matching messages at the last evaluation
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Matches []string `json:"matches,omitempty"`
+
+	// StartTime is when the messages were checked first, the timeout starts then
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// LastEvaluationTime is when the messages were last checked
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	LastEvaluationTime *metav1.Time `json:"lastEvaluationTime,omitempty"`
+
+	// CompletionTime is when the assertion passed or failed
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Conditions contain the Passed and Failed conditions
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// MailhogAssertion checks that a MailhogInstance received the expected messages
+//
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Instance",type=string,JSONPath=`.spec.instance`
+//+kubebuilder:printcolumn:name="Matches",type=integer,JSONPath=`.status.matchCount`
+//+kubebuilder:printcolumn:name="Passed",type=string,JSONPath=`.status.conditions[?(@.type=="Passed")].status`
+//+kubebuilder:printcolumn:name="Failed",type=string,JSONPath=`.status.conditions[?(@.type=="Failed")].status`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:subresource:status
+//+operator-sdk:csv:customresourcedefinitions:displayName="Mailhog Assertion"
+type MailhogAssertion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MailhogAssertionSpec `json:"spec,omitempty"`
+
+	// Status last observed status
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Status MailhogAssertionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MailhogAssertionList contains a list of MailhogAssertion
+type MailhogAssertionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MailhogAssertion `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MailhogAssertion{}, &MailhogAssertionList{})
+}
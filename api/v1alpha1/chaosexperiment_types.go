@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MailhogChaosExperimentSpec defines when and how jim is invited into a MailhogInstance
+type MailhogChaosExperimentSpec struct {
+	// Instance is the name of the MailhogInstance (in the same namespace) jim is invited into
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Mailhog Instance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Instance string `json:"instance"`
+
+	// Jim are the chaos monkey settings applied while the experiment runs, invite is implied
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Jim / ChaosMonkey Config"
+	Jim MailhogJimSpec `json:"jim,omitempty"`
+
+	// Duration is how long jim stays in the instance for every run
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="10m"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Duration",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Schedule is a cron expression (minute hour day-of-month month day-of-week, UTC) starting a run
+	// without a schedule the experiment runs once right away
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Schedule",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// ChaosExperimentPhase is the state of a MailhogChaosExperiment
+//
+//+kubebuilder:validation:Enum=Waiting;Running;Completed;Failed
+type ChaosExperimentPhase string
+
+const (
+	// WaitingChaosPhase the experiment waits for its schedule or its instance
+	WaitingChaosPhase ChaosExperimentPhase = "Waiting"
+
+	// RunningChaosPhase jim is applied to the instance
+	RunningChaosPhase ChaosExperimentPhase = "Running"
+
+	// CompletedChaosPhase the experiment without schedule ran and the original settings were restored
+	CompletedChaosPhase ChaosExperimentPhase = "Completed"
+
+	// FailedChaosPhase the experiment has invalid settings and is not run
+	FailedChaosPhase ChaosExperimentPhase = "Failed"
+)
+
+// MailhogChaosExperimentStatus defines the observed state of MailhogChaosExperiment
+type MailhogChaosExperimentStatus struct {
+	// Phase is the state of the experiment
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Phase ChaosExperimentPhase `json:"phase,omitempty"`
+
+	// Message explains the phase
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Message string `json:"message,omitempty"`
+
+	// Runs is the count of started runs
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Runs int32 `json:"runs,omitempty"`
+
+	// StartTime is when jim was applied for the current or last run
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// EndTime is when the current run ends or when the original settings were restored after the last run
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+
+	// NextRunTime is the next scheduled start
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	NextRunTime *metav1.Time `json:"nextRunTime,omitempty"`
+
+	// Applied are the chaos monkey values of the current or last run
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Applied *JimStatus `json:"applied,omitempty"`
+}
+
+// MailhogChaosExperiment invites jim into a MailhogInstance for a fixed duration or on a schedule
+// and restores the original settings of the instance afterwards
+//
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Instance",type=string,JSONPath=`.spec.instance`
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Runs",type=integer,JSONPath=`.status.runs`
+//+kubebuilder:printcolumn:name="Next Run",type="date",JSONPath=`.status.nextRunTime`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:subresource:status
+//+operator-sdk:csv:customresourcedefinitions:displayName="Mailhog Chaos Experiment"
+type MailhogChaosExperiment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MailhogChaosExperimentSpec `json:"spec,omitempty"`
+
+	// Status last observed status
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Status MailhogChaosExperimentStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MailhogChaosExperimentList contains a list of MailhogChaosExperiment
+type MailhogChaosExperimentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MailhogChaosExperiment `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MailhogChaosExperiment{}, &MailhogChaosExperimentList{})
+}
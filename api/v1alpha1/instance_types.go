@@ -24,8 +24,15 @@ import (
 type (
 	StorageSetting       string
 	TrafficInletResource string
+	PreservePhase        string
+	JimPreset            string
 )
 
+// JimChance is a probability between 0 and 1 as decimal string, eg "0.05"
+//
+//+kubebuilder:validation:Pattern=`^(0(\.[0-9]+)?|1(\.0+)?)$`
+type JimChance string
+
 const (
 	// MemoryStorage incoming mails will be stored in process memory
 	MemoryStorage StorageSetting = "memory"
@@ -46,6 +53,94 @@ const (
 	IngressTrafficInlet TrafficInletResource = "ingress"
 )
 
+const (
+	// ExportedPreservePhase the messages were exported and wait to be replayed into the new pods
+	ExportedPreservePhase PreservePhase = "Exported"
+
+	// RestoredPreservePhase all new pods received the exported messages
+	RestoredPreservePhase PreservePhase = "Restored"
+
+	// FailedPreservePhase the messages could not be exported before the rollout
+	FailedPreservePhase PreservePhase = "Failed"
+)
+
+const (
+	// FlakyNetworkJimPreset drops and slows down connections, but never rejects commands
+	FlakyNetworkJimPreset JimPreset = "flaky-network"
+
+	// StrictRelayJimPreset rejects senders, recipients and authentication, but keeps connections intact
+	StrictRelayJimPreset JimPreset = "strict-relay"
+
+	// SlowLinkJimPreset rate limits every connection to a slow link speed
+	SlowLinkJimPreset JimPreset = "slow-link"
+)
+
+const (
+	// SeededCondition is true once all ready pods received the seed messages
+	SeededCondition = "Seeded"
+
+	// StorageMigrationCondition is true once the messages of the old storage backend were imported into the new one
+	StorageMigrationCondition = "StorageMigration"
+
+	// SmtpVerifiedCondition is true once a test message sent through the Service after the last rollout was stored
+	// if the smtp proxy intercepts, the operator authenticates so only connect stage faults apply to the message
+	SmtpVerifiedCondition = "SmtpVerified"
+
+	// UpstreamsReachableCondition is true if the last check reached and authenticated at every smtp upstream
+	UpstreamsReachableCondition = "UpstreamsReachable"
+
+	// OidcIssuerDiscoveredCondition is true if the discovery document of the oidc issuer names the configured issuer
+	// the issuer is checked once per generation of the instance
+	OidcIssuerDiscoveredCondition = "OidcIssuerDiscovered"
+)
+
+const (
+	// MigratingReason the messages were exported from the old storage backend and wait for the new pods
+	MigratingReason = "Migrating"
+
+	// MigratedReason the messages were imported into the new storage backend
+	MigratedReason = "Migrated"
+
+	// MigrationFailedReason the messages could not be exported or imported
+	MigrationFailedReason = "MigrationFailed"
+)
+
+const (
+	// RolloutPendingReason the deployment is still rolling out, the smtp verification waits for it to complete
+	RolloutPendingReason = "RolloutPending"
+
+	// VerifiedReason the test message was accepted by the Service and stored by a pod
+	VerifiedReason = "Verified"
+
+	// SendFailedReason the test message was not accepted by the Service
+	SendFailedReason = "SendFailed"
+
+	// NotStoredReason the test message was accepted but not found through the api
+	NotStoredReason = "NotStored"
+)
+
+const (
+	// ReachableReason every upstream answered and accepted its credentials
+	ReachableReason = "Reachable"
+
+	// UnreachableReason at least one upstream could not be connected to or did not answer EHLO
+	UnreachableReason = "Unreachable"
+
+	// RejectedReason every upstream answered, but at least one failed the tls handshake or rejected its credentials
+	RejectedReason = "Rejected"
+)
+
+const (
+	// DiscoveredReason the discovery document of the oidc issuer was fetched and names the configured issuer
+	DiscoveredReason = "Discovered"
+
+	// DiscoveryFailedReason the discovery document of the oidc issuer could not be fetched
+	DiscoveryFailedReason = "DiscoveryFailed"
+
+	// IssuerMismatchReason the discovery document of the oidc issuer names another issuer
+	IssuerMismatchReason = "IssuerMismatch"
+)
+
 // MailhogInstanceSpec defines the desired state of MailhogInstance
 type MailhogInstanceSpec struct {
 	// Image is the mailhog image to be used
@@ -79,6 +174,122 @@ type MailhogInstanceSpec struct {
 	//+kubebuilder:validation:Enum=none;route;ingress
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Expose Mailhog with",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:route","urn:alm:descriptor:com.tectonic.ui:select:none","urn:alm:descriptor:com.tectonic.ui:select:ingress"}
 	WebTrafficInlet TrafficInletResource `json:"webTrafficInlet,omitempty"`
+
+	// Access grants users, groups or service accounts the per instance viewer / admin roles
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Access"
+	Access *MailhogAccessSpec `json:"access,omitempty"`
+
+	// Seed messages are delivered via smtp to every pod once it becomes ready
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Seed Messages"
+	Seed *MailhogSeedSpec `json:"seed,omitempty"`
+}
+
+// MailhogSeedSpec lists the sources of RFC 5322 (.eml) messages an instance is seeded with
+type MailhogSeedSpec struct {
+	// ConfigMaps every key of these ConfigMaps (in the CRs namespace) is delivered as one message
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="ConfigMaps",xDescriptors={"urn:alm:descriptor:io.kubernetes:ConfigMap"}
+	ConfigMaps []string `json:"configMaps,omitempty"`
+
+	// Secrets every key of these Secrets (in the CRs namespace) is delivered as one message
+	// a Secret has to opt in with the label mailhog.operators.patrick.mx/seed-source=true, others are refused
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Secrets",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	Secrets []string `json:"secrets,omitempty"`
+
+	// Messages are inline messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Inline Messages"
+	Messages []MailhogSeedMessage `json:"messages,omitempty"`
+}
+
+// MailhogSeedMessage is an inline seed message
+type MailhogSeedMessage struct {
+	// Name identifies the message
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Name string `json:"name"`
+
+	// Eml is the RFC 5322 message including headers
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Message",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Eml string `json:"eml"`
+}
+
+// AccessRole is one of the roles the operator creates for every instance
+type AccessRole string
+
+const (
+	// ViewerAccessRole may get the CR and proxy to the web ui of its service
+	ViewerAccessRole AccessRole = "viewer"
+
+	// AdminAccessRole may additionally edit and scale the CR
+	AdminAccessRole AccessRole = "admin"
+)
+
+// MailhogAccessSpec lists the subjects bound to the per instance roles
+type MailhogAccessSpec struct {
+	// Subjects are bound to the viewer or admin role of this instance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Subjects"
+	Subjects []MailhogAccessSubject `json:"subjects,omitempty"`
+}
+
+// MailhogAccessSubject is a user, group or service account bound to one of the instance roles
+type MailhogAccessSubject struct {
+	// Kind of the subject
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum=User;Group;ServiceAccount
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Kind",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:User","urn:alm:descriptor:com.tectonic.ui:select:Group","urn:alm:descriptor:com.tectonic.ui:select:ServiceAccount"}
+	Kind string `json:"kind"`
+
+	// Name of the subject
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Name string `json:"name"`
+
+	// Namespace of a ServiceAccount subject, defaults to the namespace of the instance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Namespace",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Namespace string `json:"namespace,omitempty"`
+
+	// Role the subject is bound to
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="viewer"
+	//+kubebuilder:validation:Enum=viewer;admin
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Role",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:viewer","urn:alm:descriptor:com.tectonic.ui:select:admin"}
+	Role AccessRole `json:"role,omitempty"`
 }
 
 // MailhogInstanceSettingsSpec are settings related to the mailhog instance
@@ -101,6 +312,8 @@ type MailhogInstanceSettingsSpec struct {
 	CorsOrigin string `json:"corsOrigin,omitempty"`
 
 	// Storage which storage backend to use, eg memory
+	// changing it migrates the messages of the running pods into the new backend (limited to the size of a ConfigMap),
+	// the rollout waits while the export of the messages fails, which the StorageMigration condition reports
 	//
 	//+kubebuilder:validation:Enum=memory;maildir;mongodb
 	//+kubebuilder:validation:Optional
@@ -174,352 +387,1565 @@ type MailhogInstanceSettingsSpec struct {
 	//+nullable
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Ingress Settings",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:webTrafficInlet:ingress"}
 	Ingress IngressSpec `json:"ingress,omitempty"`
-}
 
-// IngressSpec allows for k8s ingress related configuration
-type IngressSpec struct {
-	// Class will set the kubernetes.io/ingress.class of created k8s ingresses
-	// leaving empty will use the default class
+	// SmtpTls adds a tls terminating sidecar in front of mailhog's smtp port, offering STARTTLS and implicit TLS
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Ingress Class",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:webTrafficInlet:ingress"}
-	Class string `json:"class,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP TLS Settings"
+	SmtpTls *MailhogSmtpTlsSpec `json:"smtpTls,omitempty"`
 
-	// Host used for mailhog's ingress rule
+	// SmtpFaults are deterministic faults injected by the smtp proxy sidecar, which then takes over the smtp port of the Service
+	// the first matching fault is applied, mailhog then only listens on the loopback interface so the pods can not be reached around it
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Hostname",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:webTrafficInlet:ingress"}
-	Host string `json:"ingressClass,omitempty"`
+	//+listType=map
+	//+listMapKey=name
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP Fault Injection"
+	SmtpFaults []MailhogSmtpFaultSpec `json:"smtpFaults,omitempty"`
 
-	// TlsSecret which will be used for this ingress
+	// SmtpPolicy lets the smtp proxy sidecar reject mail like a strict mail server would, it then takes over the smtp port of the Service
+	// messages sent to the pods directly are not affected
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="TLS Secret",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:webTrafficInlet:ingress"}
-	TlsSecret string `json:"tlsSecret,omitempty"`
-}
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP Policy"
+	SmtpPolicy *MailhogSmtpPolicySpec `json:"smtpPolicy,omitempty"`
 
-// AffinitySpec offers pod placement configuration
-type AffinitySpec struct {
-	// PodAffinity is used to get placed together with certain pods
+	// SmtpTranscripts lets the smtp proxy sidecar record the last smtp sessions of every pod, it then takes over the smtp port of the Service
+	// the transcripts are served as json on /transcripts of the proxy-admin port of every pod, credentials and message bodies are not recorded
+	// the admin port is not part of the Service and answers the probe user only, its password is probe-password of the Secret <name>-generated
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Pod Affinity",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podAffinity"}
-	PodAffinity *corev1.PodAffinity `json:"podAffinity,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP Session Transcripts"
+	SmtpTranscripts *MailhogSmtpTranscriptsSpec `json:"smtpTranscripts,omitempty"`
 
-	// PodAffinity is used to get placed apart from certain pods
+	// SmtpRelay lets the smtp proxy sidecar forward captured messages to the upstreams of files.smtpUpstreams,
+	// mailhog still stores every message, the proxy then takes over the smtp port of the Service
+	// messages waiting for an upstream may take a quarter of the sidecar's memory limit, further ones are not relayed
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Pod Anti Affinity",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podAntiAffinity"}
-	PodAntiAffinity *corev1.PodAntiAffinity `json:"podAntiAffinity,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP Catch and Relay"
+	SmtpRelay *MailhogSmtpRelaySpec `json:"smtpRelay,omitempty"`
 
-	// NodeAffinity configures which nodes are preferred
+	// Auth configures an authenticating reverse proxy in front of the web ui and api
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Node Affinity",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:nodeAffinity"}
-	NodeAffinity *corev1.NodeAffinity `json:"nodeAffinity,omitempty"`
-}
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Web Authentication"
+	Auth *MailhogAuthSpec `json:"auth,omitempty"`
 
-// MailhogJimSpec invites jim into mailhog, the builtin chaos monkey
-// they are added as args to the container's cmd
-// see https://github.com/mailhog/MailHog/blob/master/docs/JIM.md
-type MailhogJimSpec struct {
-	// Invite set to true activates jim using the default values (see mh doc)
+	// SidecarImage is the image used for sidecars provided by the operator (e.g. the smtp proxy)
+	// empty = use the operator's default sidecar image
 	//
 	//+kubebuilder:validation:Optional
-	//+kubebuilder:default:=false
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Activate Chaosmonkey",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
-	Invite bool `json:"invite,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Sidecar Image",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	SidecarImage string `json:"sidecarImage,omitempty"`
 
-	// Disconnect Chance of randomly disconnecting a session (float, eg "0.005")
+	// SidecarResources allows to override the default resources of the sidecars provided by the operator
+	// (smtp proxy and web auth proxy), the default limits suit light traffic only
+	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers/
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Disconnect Chance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
-	Disconnect string `json:"disconnect,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Sidecar resources reservations and limits",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements"}
+	SidecarResources *corev1.ResourceRequirements `json:"sidecarResources,omitempty"`
 
-	// Accept Chance of accepting an incoming connection (float, eg "0.99")
+	// Retention limits how many / how old messages are kept, the operator purges older messages periodically
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Accept Chance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
-	Accept string `json:"accept,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Message Retention"
+	Retention *MailhogRetentionSpec `json:"retention,omitempty"`
 
-	// LinkspeedAffect Chance of applying a rate limit (float, eg "0.1")
+	// Archive uploads a copy of every captured message to an s3 compatible object storage periodically
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Chance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
-	LinkspeedAffect string `json:"linkspeedAffect,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Message Archive"
+	Archive *MailhogArchiveSpec `json:"archive,omitempty"`
 
-	// LinkspeedMin Minimum link speed (in bytes per second, eg "1024")
+	// PreserveOnRollout exports the messages before the operator rolls out new pods and replays them into the new pods
+	// once they are ready, only supported for memory storage. Preserved messages are limited to the size of a ConfigMap,
+	// the rollout waits while the export fails (eg too many messages), messages received by old pods after the export are lost
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
-	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Minimum bytes/sec",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
-	LinkspeedMin string `json:"linkspeedMin,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Preserve Messages on Rollout",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	PreserveOnRollout bool `json:"preserveOnRollout,omitempty"`
 
-	// LinkspeedMax Maximum link speed (in bytes per second, eg "10240")
+	// DisableSmtpVerification turns off the test message the operator sends through the Service after each rollout
+	// to verify that messages are accepted and stored, if the smtp proxy intercepts (smtpFaults, smtpPolicy,
+	// smtpTranscripts or smtpRelay) the operator authenticates, which exempts the message from the policy, the relay
+	// and all faults except those of the connect stage
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
-	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Maximum bytes/sec",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
-	LinkspeedMax string `json:"linkspeedMax,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Disable SMTP Verification",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	DisableSmtpVerification bool `json:"disableSmtpVerification,omitempty"`
 
-	// RejectSender Chance of rejecting a MAIL FROM command (float, eg "0.05")
+	// DisableUpstreamChecks turns off the periodic connection checks the operator runs against the smtp upstreams
+	// of files.smtpUpstreams, reported in status.upstreams
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
-	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the sender is rejected",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
-	RejectSender string `json:"rejectSender,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Disable Upstream Checks",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	DisableUpstreamChecks bool `json:"disableUpstreamChecks,omitempty"`
 
-	// RejectRecipient Chance of rejecting a RCPT TO command (float, eg "0.05")
+	// TrustedCa is mounted into the mailhog container and replaces the system certificates used to verify upstreams
+	// it has to contain the public ca certificates as well if mail is released to public relays
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the recipient is rejected",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
-	RejectRecipient string `json:"rejectRecipient,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Trusted CA Bundle"
+	TrustedCa *MailhogTrustedCaSpec `json:"trustedCa,omitempty"`
+}
 
-	// RejectAuth Chance of rejecting an AUTH command (float, eg "0.05")
+// MailhogRetentionSpec defines which messages are purged by the operator
+type MailhogRetentionSpec struct {
+	// MaxAge messages older than this are deleted (go duration, e.g. "24h")
+	// for mongodb storage a ttl index on the message creation time is created additionally
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the authentication is rejected",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
-	RejectAuth string `json:"rejectAuth,omitempty"`
-}
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Max Message Age",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
 
-// MailhogFilesSpec is used to define settings that need to be passed as file (in a configmap)
-type MailhogFilesSpec struct {
-	// SmtpUpstreams Intercepted emails can be forwarded to upstreams via the UI
+	// MaxMessages only the newest messages up to this count are kept (per pod for memory / maildir storage)
 	//
 	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
 	//+optional
-	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP Upstreams for release"
-	SmtpUpstreams []MailhogUpstreamSpec `json:"smtpUpstreams,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Max Message Count",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	MaxMessages int32 `json:"maxMessages,omitempty"`
 
-	// WebUsers If WebUsers are defined, UI/API Access will be protected with basic auth
+	// Interval between two purges
 	//
 	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="5m"
 	//+optional
-	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="HTTP Basic auth user restrictions"
-	WebUsers []MailhogWebUserSpec `json:"webUsers,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Purge Interval",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// MongoImage is the image of the job creating the ttl index for mongodb storage, it needs to contain mongosh
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="mongo:5.0"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="MongoDB Shell Image",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	MongoImage string `json:"mongoImage,omitempty"`
 }
 
-// MailhogUpstreamSpec are upstream smtp servers a message can be release to that mailhog has intercepted (via gui/api)
-// https://github.com/mailhog/MailHog-Server/blob/50f74a1aa2991b96313144d1ac718ce4d6739dfd/config/config.go#L55
-type MailhogUpstreamSpec struct {
-	// Name the Name this server will be shown under in the UI
+// MailhogArchiveSpec defines where the operator uploads copies of the captured messages
+// every message is uploaded once as <prefix><yyyy>/<mm>/<dd>/<id>.eml, every upload run adds <prefix>index/<time>.json
+// a run uploads at most 500 messages, the next run follows without waiting for the interval if more are waiting
+type MailhogArchiveSpec struct {
+	// Endpoint is the base url of the object storage, buckets are addressed path style (e.g. http://minio:9000)
 	//
 	//+kubebuilder:validation:Required
-	//+kubebuilder:validation:MinLength=2
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Server Name / Label",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:Pattern=`^https?://`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Object Storage Endpoint",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Endpoint string `json:"endpoint"`
 
-	// Save is an option provided for compat reasons with mailhogs struct, just set it to true
+	// Region the requests are signed for
 	//
-	//+kubebuilder:validation:Required
-	//+kubebuilder:default:=true
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Server Name / Label",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
-	Save bool `json:"save,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="us-east-1"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Object Storage Region",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Region string `json:"region,omitempty"`
 
-	// Email the target Email address where the mail will be resent to
+	// Bucket the messages are uploaded to
 	//
 	//+kubebuilder:validation:Required
-	//+kubebuilder:validation:MinLength=4
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Destination Email on release",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Email string `json:"email,omitempty"`
+	//+kubebuilder:validation:MinLength=3
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Bucket",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Bucket string `json:"bucket"`
 
-	// Host SMTP target Host hostname
+	// Prefix is prepended to all object keys, e.g. "staging/"
 	//
-	//+kubebuilder:validation:Required
-	//+kubebuilder:validation:MinLength=2
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server hostname",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Host string `json:"host,omitempty"`
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Object Key Prefix",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Prefix string `json:"prefix,omitempty"`
 
-	// Port SMTP target Port
+	// CredentialsSecret is the name of a Secret in the CRs namespace with the keys accessKeyId and secretAccessKey
 	//
 	//+kubebuilder:validation:Required
-	//+kubebuilder:validation:MinLength=2
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server port",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Port string `json:"port,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credentials Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	CredentialsSecret string `json:"credentialsSecret"`
 
-	// Username the Username used for SMTP authentication
+	// Interval between two uploads
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="15m"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upload Interval",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// MailhogAuthSpec selects how access to the web ui and api is authenticated
+// if a mode is set, an authenticating reverse proxy is injected and all web traffic is routed through it,
+// mailhog's ui / api then only listen on the loopback interface of the pod and the operator itself authenticates
+// at the proxy as internal probe user
+type MailhogAuthSpec struct {
+	// Oidc authenticates users with an OpenID Connect provider
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server username",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Username string `json:"username,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="OIDC Single Sign-On"
+	Oidc *MailhogOidcSpec `json:"oidc,omitempty"`
 
-	// Password the Password used for SMTP authentication
+	// Rbac lets users in who are allowed to access this MailhogInstance according to the cluster's RBAC rules
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server password",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Password string `json:"password,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Cluster RBAC"
+	Rbac *MailhogRbacAuthSpec `json:"rbac,omitempty"`
+}
 
-	// Mechanism the SMTP login Mechanism used. This is _required_ when providing upstream user / password credentials
+// MailhogRbacAuthSpec configures the openshift oauth-proxy sidecar
+// users log in with OpenShift OAuth or send a bearer token, then a SubjectAccessReview
+// checks if they may perform Verb on this mailhoginstance
+// see https://github.com/openshift/oauth-proxy
+type MailhogRbacAuthSpec struct {
+	// Verb users need to be allowed on mailhoginstances/<name> in this namespace
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=get;update;patch;delete
+	//+kubebuilder:default:="get"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Required Verb",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:get","urn:alm:descriptor:com.tectonic.ui:select:update","urn:alm:descriptor:com.tectonic.ui:select:patch","urn:alm:descriptor:com.tectonic.ui:select:delete"}
+	Verb string `json:"verb,omitempty"`
+
+	// Image is the oauth-proxy image to be used
 	//
 	//+kubebuilder:validation:Optional
 	//+kubebuilder:validation:MinLength=4
-	//+kubebuilder:validation:Enum=PLAIN;CRAMMD5
+	//+kubebuilder:default:="quay.io/openshift/origin-oauth-proxy:4.10"
 	//+optional
-	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server auth mechanism",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:PLAIN","urn:alm:descriptor:com.tectonic.ui:select:CRAMMD5"}
-	Mechanism string `json:"mechanism,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="OAuth Proxy Image",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Image string `json:"image,omitempty"`
 }
 
-// MailhogWebUserSpec configures UI and API HTTP basic auth.
-// see https://github.com/mailhog/MailHog/blob/master/docs/Auth.md for more information
-type MailhogWebUserSpec struct {
-	// Name is the username
+// MailhogOidcSpec configures the oauth2-proxy sidecar used for OpenID Connect authentication
+// see https://oauth2-proxy.github.io/oauth2-proxy/docs/configuration/oauth_provider#openid-connect-provider
+type MailhogOidcSpec struct {
+	// IssuerURL is the OpenID Connect issuer, the operator checks its discovery document once per generation
+	// and reports the result in the OidcIssuerDiscovered condition
 	//
 	//+kubebuilder:validation:Required
-	//+kubebuilder:validation:MinLength=2
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="HTTP Basic Auth Username",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Name string `json:"name,omitempty"`
+	//+kubebuilder:validation:MinLength=8
+	//+kubebuilder:validation:Format=uri
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Issuer URL",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	IssuerURL string `json:"issuerUrl,omitempty"`
 
-	// PasswordHash is the bcrypt hash of the user's password
+	// ClientID is the OAuth client id registered at the issuer
 	//
 	//+kubebuilder:validation:Required
-	//+kubebuilder:validation:MinLength=3
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Password bcrypt hash",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	PasswordHash string `json:"passwordHash,omitempty"`
-}
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Client ID",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	ClientID string `json:"clientId,omitempty"`
 
-// MailhogStorageMaildirSpec are settings applicable if the storage backend is maildir
-// see https://github.com/mailhog/storage/blob/master/maildir.go for the implementation
-type MailhogStorageMaildirSpec struct {
-	// Path Maildir path (for maildir storage backend)
+	// ClientSecret references the secret key holding the OAuth client secret
+	//
+	//+kubebuilder:validation:Required
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Client Secret"
+	ClientSecret corev1.SecretKeySelector `json:"clientSecret"`
+
+	// AllowedGroups if set, only users in one of these groups (groups claim) are allowed
 	//
 	//+kubebuilder:validation:Optional
-	//+kubebuilder:validation:MinLength:=3
-	//+kubebuilder:validation:Pattern:=`^(/)([\S]+(/)?)+$`
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Maildir path",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Path string `json:"path,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Allowed Groups"
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
 
-	// PvName if a PV name is given it will be used for maildir storage the pv needs to preexist, it will not be created
-	// without a pv name an emptydir will be used which could lead to inconsistencies when multiple replicas are used
+	// AllowedEmails if set, only users with one of these email addresses are allowed
+	// if neither groups nor emails are set, every user the issuer authenticates is allowed
 	//
 	//+kubebuilder:validation:Optional
-	//+kubebuilder:validation:MinLength:=3
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="PV Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	PvName string `json:"pvName"`
-}
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Allowed Emails"
+	AllowedEmails []string `json:"allowedEmails,omitempty"`
 
-// MailhogStorageMongoDbSpec are settings applicable if the storage backend is mongodb
-// see https://github.com/mailhog/storage/blob/master/mongodb.go for the implementation
-type MailhogStorageMongoDbSpec struct {
-	// URI MongoDB host and port [mongodb://][user:pass@]host1[:port1][,host2[:port2],...][/database][?options]
-	// for details about the URI format see https://pkg.go.dev/gopkg.in/mgo.v2#Dial
+	// Image is the oauth2-proxy image to be used
 	//
 	//+kubebuilder:validation:Optional
-	//+kubebuilder:validation:MinLength:=3
-	//+kubebuilder:validation:Pattern:=`^(mongodb:(?:\/{2})?)((\w+?):(\w+?)@|:?@?)(\w+?):(\d+).*$`
+	//+kubebuilder:validation:MinLength=4
+	//+kubebuilder:default:="quay.io/oauth2-proxy/oauth2-proxy:v7.2.1"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="OAuth2 Proxy Image",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Image string `json:"image,omitempty"`
+}
+
+// MailhogSmtpTlsSpec configures the tls terminating smtp sidecar
+// the sidecar forwards all sessions to mailhog's plaintext smtp listener
+type MailhogSmtpTlsSpec struct {
+	// SecretName is the name of a kubernetes.io/tls secret containing tls.crt and tls.key
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="TLS Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	SecretName string `json:"secretName,omitempty"`
+
+	// StartTlsPort is the port on which smtp with STARTTLS is offered
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1024
+	//+kubebuilder:validation:Maximum=65535
+	//+kubebuilder:default:=1587
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="STARTTLS Port",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	StartTlsPort int32 `json:"startTlsPort,omitempty"`
+
+	// ImplicitTlsPort is the port on which smtp over implicit tls (smtps) is offered
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1024
+	//+kubebuilder:validation:Maximum=65535
+	//+kubebuilder:default:=1465
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Implicit TLS Port",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	ImplicitTlsPort int32 `json:"implicitTlsPort,omitempty"`
+}
+
+// SmtpFaultStage is the smtp command a fault is evaluated for
+//
+//+kubebuilder:validation:Enum=connect;mailFrom;rcptTo;data
+type SmtpFaultStage string
+
+const (
+	// ConnectSmtpFaultStage is evaluated before the greeting of a session
+	ConnectSmtpFaultStage SmtpFaultStage = "connect"
+
+	// MailFromSmtpFaultStage is evaluated for MAIL FROM, the address is the sender
+	MailFromSmtpFaultStage SmtpFaultStage = "mailFrom"
+
+	// RcptToSmtpFaultStage is evaluated for every RCPT TO, the address is the recipient
+	RcptToSmtpFaultStage SmtpFaultStage = "rcptTo"
+
+	// DataSmtpFaultStage is evaluated for DATA, the address matches if any recipient matches
+	DataSmtpFaultStage SmtpFaultStage = "data"
+)
+
+// SmtpFaultAction is what happens to a command matching a fault
+//
+//+kubebuilder:validation:Enum=reject;greylist;delay;disconnect
+type SmtpFaultAction string
+
+const (
+	// RejectSmtpFaultAction answers with the code and message of the fault
+	RejectSmtpFaultAction SmtpFaultAction = "reject"
+
+	// GreylistSmtpFaultAction answers 451 the first time a sender hits the fault (per pod), senders are remembered for an hour
+	GreylistSmtpFaultAction SmtpFaultAction = "greylist"
+
+	// DelaySmtpFaultAction waits before the command is passed on to mailhog
+	DelaySmtpFaultAction SmtpFaultAction = "delay"
+
+	// DisconnectSmtpFaultAction closes the connection without an answer
+	DisconnectSmtpFaultAction SmtpFaultAction = "disconnect"
+)
+
+// MailhogSmtpPolicySpec are the limits enforced on smtp sessions, violations are rejected with the codes of a strict mail server
+type MailhogSmtpPolicySpec struct {
+	// AuthSecretName requires AUTH PLAIN or LOGIN before MAIL FROM, every key of the secret is a user name and its value the password
+	// failures are answered with 535, MAIL FROM without AUTH with 530
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credentials Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	AuthSecretName string `json:"authSecretName,omitempty"`
+
+	// AllowedSenderDomains are the domains MAIL FROM may use, other senders are answered with 553, every domain is allowed if empty
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Allowed Sender Domains"
+	AllowedSenderDomains []string `json:"allowedSenderDomains,omitempty"`
+
+	// MaxMessageBytes is the maximum message size, larger messages are answered with 552 and not delivered, unlimited if 0
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Max Message Bytes",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	MaxMessageBytes int64 `json:"maxMessageBytes,omitempty"`
+
+	// MaxRecipients is the maximum count of recipients of a message, further recipients are answered with 452, unlimited if 0
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Max Recipients",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	MaxRecipients int32 `json:"maxRecipients,omitempty"`
+}
+
+// MailhogSmtpTranscriptsSpec configures the recording of smtp session transcripts
+type MailhogSmtpTranscriptsSpec struct {
+	// Keep is the count of transcripts every pod keeps, older ones are dropped
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=1000
+	//+kubebuilder:default:=100
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Kept Transcripts",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	Keep int32 `json:"keep,omitempty"`
+}
+
+// MailhogSmtpRelaySpec configures the automatic relay of captured messages
+type MailhogSmtpRelaySpec struct {
+	// Rules select the recipients to relay, the first matching rule of a recipient is applied
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinItems=1
+	//+listType=map
+	//+listMapKey=name
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Relay Rules"
+	Rules []MailhogSmtpRelayRuleSpec `json:"rules"`
+
+	// MaxAttempts is how often a message is sent before it is given up, only temporary (4xx or connection) failures are retried
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=100
+	//+kubebuilder:default:=5
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Max Attempts",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// RetryInterval is the wait before a failed message is sent again
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="1m"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Retry Interval",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
+
+	// RatePerMinute limits the messages every pod relays, unlimited if 0
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:default:=60
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Rate per Minute",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	RatePerMinute int32 `json:"ratePerMinute,omitempty"`
+}
+
+// MailhogSmtpRelayRuleSpec relays the matching recipients of captured messages to an upstream
+type MailhogSmtpRelayRuleSpec struct {
+	// Name identifies the rule in metrics and status
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MaxLength=63
+	//+kubebuilder:validation:Pattern:=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Name string `json:"name"`
+
+	// Recipients are address patterns like the address of smtp faults, eg @ourcompany.com
+	// only the matching recipients of a message are relayed, the message gets an X-Mailhog-Relayed header
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinItems=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Recipient Patterns"
+	Recipients []string `json:"recipients"`
+
+	// Upstream is the name of an entry of files.smtpUpstreams, its host, port and credentials are used,
+	// its email is not, messages are relayed to their original recipients
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=2
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Upstream string `json:"upstream"`
+}
+
+// MailhogSmtpFaultSpec is a deterministic fault injected into matching smtp sessions
+type MailhogSmtpFaultSpec struct {
+	// Name identifies the fault in metrics and status
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MaxLength=63
+	//+kubebuilder:validation:Pattern:=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Name string `json:"name"`
+
+	// Stage is the smtp command the fault is evaluated for
+	//
+	//+kubebuilder:validation:Required
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Stage",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:connect","urn:alm:descriptor:com.tectonic.ui:select:mailFrom","urn:alm:descriptor:com.tectonic.ui:select:rcptTo","urn:alm:descriptor:com.tectonic.ui:select:data"}
+	Stage SmtpFaultStage `json:"stage"`
+
+	// Address is a pattern for the envelope address, * matches any characters,
+	// a trailing @ matches any domain (bounce-*@) and a leading @ any local part (@example.com), empty matches every address
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Address Pattern",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Address string `json:"address,omitempty"`
+
+	// AfterMessages lets the fault only match once the same connection delivered this many messages
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="After Messages",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	AfterMessages int32 `json:"afterMessages,omitempty"`
+
+	// Action is what happens to a matching command
+	//
+	//+kubebuilder:validation:Required
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Action",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:reject","urn:alm:descriptor:com.tectonic.ui:select:greylist","urn:alm:descriptor:com.tectonic.ui:select:delay","urn:alm:descriptor:com.tectonic.ui:select:disconnect"}
+	Action SmtpFaultAction `json:"action"`
+
+	// Code is the reply code of the reject action
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=400
+	//+kubebuilder:validation:Maximum=599
+	//+kubebuilder:default:=550
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Reply Code",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	Code int32 `json:"code,omitempty"`
+
+	// Message is the reply text of the reject and greylist actions, including the enhanced status code (5.1.1 Mailbox does not exist)
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Reply Message",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Message string `json:"message,omitempty"`
+
+	// Delay is the wait of the delay action
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Delay",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Delay *metav1.Duration `json:"delay,omitempty"`
+}
+
+// IngressSpec allows for k8s ingress related configuration
+type IngressSpec struct {
+	// Class will set the kubernetes.io/ingress.class of created k8s ingresses
+	// leaving empty will use the default class
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Ingress Class",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:webTrafficInlet:ingress"}
+	Class string `json:"class,omitempty"`
+
+	// Host used for mailhog's ingress rule
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Hostname",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:webTrafficInlet:ingress"}
+	Host string `json:"ingressClass,omitempty"`
+
+	// TlsSecret which will be used for this ingress
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="TLS Secret",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:webTrafficInlet:ingress"}
+	TlsSecret string `json:"tlsSecret,omitempty"`
+}
+
+// AffinitySpec offers pod placement configuration
+type AffinitySpec struct {
+	// PodAffinity is used to get placed together with certain pods
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Pod Affinity",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podAffinity"}
+	PodAffinity *corev1.PodAffinity `json:"podAffinity,omitempty"`
+
+	// PodAffinity is used to get placed apart from certain pods
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Pod Anti Affinity",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podAntiAffinity"}
+	PodAntiAffinity *corev1.PodAntiAffinity `json:"podAntiAffinity,omitempty"`
+
+	// NodeAffinity configures which nodes are preferred
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Node Affinity",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:nodeAffinity"}
+	NodeAffinity *corev1.NodeAffinity `json:"nodeAffinity,omitempty"`
+}
+
+// MailhogJimSpec invites jim into mailhog, the builtin chaos monkey
+// they are added as args to the container's cmd
+// see https://github.com/mailhog/MailHog/blob/master/docs/JIM.md
+type MailhogJimSpec struct {
+	// Invite set to true activates jim using the default values (see mh doc), unless a preset or values are given
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:=false
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Activate Chaosmonkey",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	Invite bool `json:"invite,omitempty"`
+
+	// Disconnect Chance of randomly disconnecting a session (float, eg "0.005"), deprecated in favor of DisconnectChance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Disconnect Chance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
+	Disconnect string `json:"disconnect,omitempty"`
+
+	// Accept Chance of accepting an incoming connection (float, eg "0.99"), deprecated in favor of AcceptChance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Accept Chance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
+	Accept string `json:"accept,omitempty"`
+
+	// LinkspeedAffect Chance of applying a rate limit (float, eg "0.1"), deprecated in favor of LinkspeedAffectChance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Chance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
+	LinkspeedAffect string `json:"linkspeedAffect,omitempty"`
+
+	// LinkspeedMin Minimum link speed (in bytes per second, eg "1024"), deprecated in favor of LinkspeedMinBytes
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Minimum bytes/sec",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
+	LinkspeedMin string `json:"linkspeedMin,omitempty"`
+
+	// LinkspeedMax Maximum link speed (in bytes per second, eg "10240"), deprecated in favor of LinkspeedMaxBytes
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Maximum bytes/sec",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
+	LinkspeedMax string `json:"linkspeedMax,omitempty"`
+
+	// RejectSender Chance of rejecting a MAIL FROM command (float, eg "0.05"), deprecated in favor of RejectSenderChance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the sender is rejected",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
+	RejectSender string `json:"rejectSender,omitempty"`
+
+	// RejectRecipient Chance of rejecting a RCPT TO command (float, eg "0.05"), deprecated in favor of RejectRecipientChance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the recipient is rejected",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
+	RejectRecipient string `json:"rejectRecipient,omitempty"`
+
+	// RejectAuth Chance of rejecting an AUTH command (float, eg "0.05"), deprecated in favor of RejectAuthChance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the authentication is rejected",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:com.tectonic.ui:fieldDependency:settings.jim.invite:true"}
+	RejectAuth string `json:"rejectAuth,omitempty"`
+
+	// Preset activates jim with a named set of values, single values given below override the preset
+	// flaky-network = dropped and slow connections, strict-relay = rejected senders, recipients and auth, slow-link = every connection rate limited
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=flaky-network;strict-relay;slow-link
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chaosmonkey Preset",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:flaky-network","urn:alm:descriptor:com.tectonic.ui:select:strict-relay","urn:alm:descriptor:com.tectonic.ui:select:slow-link"}
+	Preset JimPreset `json:"preset,omitempty"`
+
+	// DisconnectChance Chance of randomly disconnecting a session (0..1, eg "0.05"), overrides Disconnect
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Disconnect Chance (0..1)",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	DisconnectChance JimChance `json:"disconnectChance,omitempty"`
+
+	// AcceptChance Chance of accepting an incoming connection (0..1, eg "0.05"), overrides Accept
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Accept Chance (0..1)",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	AcceptChance JimChance `json:"acceptChance,omitempty"`
+
+	// LinkspeedAffectChance Chance of applying a rate limit (0..1, eg "0.05"), overrides LinkspeedAffect
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Chance (0..1)",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	LinkspeedAffectChance JimChance `json:"linkspeedAffectChance,omitempty"`
+
+	// LinkspeedMinBytes Minimum link speed in bytes per second, overrides LinkspeedMin, must not exceed the maximum
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Minimum bytes/sec",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	LinkspeedMinBytes *int64 `json:"linkspeedMinBytes,omitempty"`
+
+	// LinkspeedMaxBytes Maximum link speed in bytes per second, overrides LinkspeedMax
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Connection Slow LinkSpeed Maximum bytes/sec",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	LinkspeedMaxBytes *int64 `json:"linkspeedMaxBytes,omitempty"`
+
+	// RejectSenderChance Chance of rejecting a MAIL FROM command (0..1, eg "0.05"), overrides RejectSender
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the sender is rejected (0..1)",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	RejectSenderChance JimChance `json:"rejectSenderChance,omitempty"`
+
+	// RejectRecipientChance Chance of rejecting a RCPT TO command (0..1, eg "0.05"), overrides RejectRecipient
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the recipient is rejected (0..1)",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	RejectRecipientChance JimChance `json:"rejectRecipientChance,omitempty"`
+
+	// RejectAuthChance Chance of rejecting an AUTH command (0..1, eg "0.05"), overrides RejectAuth
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Chance the authentication is rejected (0..1)",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	RejectAuthChance JimChance `json:"rejectAuthChance,omitempty"`
+}
+
+// MailhogFilesSpec is used to define settings that need to be passed as file (in a secret)
+type MailhogFilesSpec struct {
+	// SmtpUpstreams Intercepted emails can be forwarded to upstreams via the UI
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP Upstreams for release"
+	SmtpUpstreams []MailhogUpstreamSpec `json:"smtpUpstreams,omitempty"`
+
+	// UpstreamRefs reference MailhogUpstream or ClusterMailhogUpstream catalog entries, which are rendered like smtpUpstreams
+	// their names must not collide with the names of smtpUpstreams, smtpRelay rules can only use smtpUpstreams
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP Upstream Catalog References"
+	UpstreamRefs []MailhogUpstreamRefSpec `json:"upstreamRefs,omitempty"`
+
+	// WebUsers If WebUsers are defined, UI/API Access will be protected with basic auth
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="HTTP Basic auth user restrictions"
+	WebUsers []MailhogWebUserSpec `json:"webUsers,omitempty"`
+
+	// UpstreamsFrom references an existing upstream.servers.json (a JSON object of upstreams keyed by name)
+	// mailhog reads the key directly if it is the only source of upstreams, otherwise its upstreams are merged with
+	// smtpUpstreams and upstreamRefs, names must not collide
+	// the merged file is rendered into the Secret <name>-upstreams, never into the settings ConfigMap
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SMTP Upstreams from ConfigMap / Secret"
+	UpstreamsFrom *MailhogFileSourceSpec `json:"upstreamsFrom,omitempty"`
+
+	// UsersFrom references an existing bcrypt users file (one name:hash per line), which protects the UI/API like webUsers
+	// the users are merged with webUsers and the operator's probe user, names must not collide
+	// the merged file is rendered into the Secret <name>-users, never into the settings ConfigMap
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="HTTP Basic auth users from ConfigMap / Secret"
+	UsersFrom *MailhogFileSourceSpec `json:"usersFrom,omitempty"`
+}
+
+// MailhogFileSourceSpec references the key of an existing ConfigMap or Secret holding a settings file
+type MailhogFileSourceSpec struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap in the namespace of the instance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="ConfigMap Key"
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret in the namespace of the instance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Secret Key",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// MailhogUpstreamSpec are upstream smtp servers a message can be release to that mailhog has intercepted (via gui/api)
+// https://github.com/mailhog/MailHog-Server/blob/50f74a1aa2991b96313144d1ac718ce4d6739dfd/config/config.go#L55
+type MailhogUpstreamSpec struct {
+	// Name the Name this server will be shown under in the UI
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=2
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Server Name / Label",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Name string `json:"name,omitempty"`
+
+	// Save is an option provided for compat reasons with mailhogs struct, just set it to true
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:default:=true
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Server Name / Label",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
+	Save bool `json:"save,omitempty"`
+
+	// Email the target Email address where the mail will be resent to
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=4
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Destination Email on release",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Email string `json:"email,omitempty"`
+
+	// Host SMTP target Host hostname
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=2
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server hostname",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Host string `json:"host,omitempty"`
+
+	// Port SMTP target Port
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=2
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server port",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Port string `json:"port,omitempty"`
+
+	// Username the Username used for SMTP authentication
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server username",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Username string `json:"username,omitempty"`
+
+	// Password the Password used for SMTP authentication
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server password",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Password string `json:"password,omitempty"`
+
+	// Mechanism the SMTP login Mechanism used. This is _required_ when providing upstream user / password credentials
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength=4
+	//+kubebuilder:validation:Enum=PLAIN;CRAMMD5
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server auth mechanism",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:PLAIN","urn:alm:descriptor:com.tectonic.ui:select:CRAMMD5"}
+	Mechanism string `json:"mechanism,omitempty"`
+
+	// Tls configures how the certificate of the upstream is verified if it offers STARTTLS
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server TLS"
+	Tls *MailhogUpstreamTlsSpec `json:"tls,omitempty"`
+}
+
+// TlsVersion is a tls protocol version
+//
+//+kubebuilder:validation:Enum="1.0";"1.1";"1.2";"1.3"
+type TlsVersion string
+
+// MailhogUpstreamTlsSpec configures the verification of an smtp upstream's certificate
+// mailhog trusts the ca bundle for releases, it always verifies the certificate against the host and uses go's default
+// minimum version. ServerName and MinVersion apply to the smtp proxy's relay rules only, the operator's upstream checks ignore them
+type MailhogUpstreamTlsSpec struct {
+	// CaConfigMapKeyRef references a ConfigMap key holding PEM encoded ca certificates the upstream's certificate is signed by
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="CA Bundle ConfigMap"
+	CaConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"caConfigMapKeyRef,omitempty"`
+
+	// CaSecretKeyRef references a Secret key holding PEM encoded ca certificates, instead of a ConfigMap
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="CA Bundle Secret"
+	CaSecretKeyRef *corev1.SecretKeySelector `json:"caSecretKeyRef,omitempty"`
+
+	// ServerName is the name the certificate is verified against, if it differs from the host
+	// mailhog does not know it, it applies to the smtp proxy's relay rules only and is not used by the upstream check
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="TLS Server Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	ServerName string `json:"serverName,omitempty"`
+
+	// MinVersion is the minimum tls version accepted from the upstream
+	// mailhog does not know it, it applies to the smtp proxy's relay rules only and is not used by the upstream check
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Minimum TLS Version",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:1.0","urn:alm:descriptor:com.tectonic.ui:select:1.1","urn:alm:descriptor:com.tectonic.ui:select:1.2","urn:alm:descriptor:com.tectonic.ui:select:1.3"}
+	MinVersion TlsVersion `json:"minVersion,omitempty"`
+}
+
+// MailhogTrustedCaSpec references a ca bundle mailhog trusts instead of the system certificates of its image
+type MailhogTrustedCaSpec struct {
+	// ConfigMapName is the name of the ConfigMap holding the bundle, e.g. one labeled
+	// config.openshift.io/inject-trusted-cabundle=true to receive the cluster wide trusted bundle
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Trusted CA ConfigMap",xDescriptors={"urn:alm:descriptor:io.kubernetes:ConfigMap"}
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// Key is the key of the PEM encoded bundle in the ConfigMap
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength=1
+	//+kubebuilder:default:="ca-bundle.crt"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Trusted CA Key",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Key string `json:"key,omitempty"`
+}
+
+// MailhogWebUserSpec configures UI and API HTTP basic auth.
+// see https://github.com/mailhog/MailHog/blob/master/docs/Auth.md for more information
+type MailhogWebUserSpec struct {
+	// Name is the username
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=2
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="HTTP Basic Auth Username",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Name string `json:"name,omitempty"`
+
+	// PasswordHash is the bcrypt hash of the user's password
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=3
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Password bcrypt hash",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	PasswordHash string `json:"passwordHash,omitempty"`
+}
+
+// MailhogStorageMaildirSpec are settings applicable if the storage backend is maildir
+// see https://github.com/mailhog/storage/blob/master/maildir.go for the implementation
+type MailhogStorageMaildirSpec struct {
+	// Path Maildir path (for maildir storage backend)
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength:=3
+	//+kubebuilder:validation:Pattern:=`^(/)([\S]+(/)?)+$`
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Maildir path",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Path string `json:"path,omitempty"`
+
+	// PvName if a PV name is given it will be used for maildir storage the pv needs to preexist, it will not be created
+	// without a pv name an emptydir will be used which could lead to inconsistencies when multiple replicas are used
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength:=3
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="PV Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	PvName string `json:"pvName"`
+}
+
+// MailhogStorageMongoDbSpec are settings applicable if the storage backend is mongodb
+// see https://github.com/mailhog/storage/blob/master/mongodb.go for the implementation
+type MailhogStorageMongoDbSpec struct {
+	// URI MongoDB host and port [mongodb://][user:pass@]host1[:port1][,host2[:port2],...][/database][?options]
+	// for details about the URI format see https://pkg.go.dev/gopkg.in/mgo.v2#Dial
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength:=3
+	//+kubebuilder:validation:Pattern:=`^(mongodb:(?:\/{2})?)((\w+?):(\w+?)@|:?@?)(\w+?):(\d+).*$`
 	//+kubebuilder:validation:Format=uri
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="MongoDB URI",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	URI string `json:"uri,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="MongoDB URI",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	URI string `json:"uri,omitempty"`
+
+	// Db MongoDB database name for message storage
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength:=2
+	//+kubebuilder:validation:Pattern:=`^[\w-_]+$`
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="MongoDB DB",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Db string `json:"db,omitempty"`
+
+	// Collection MongoDB collection name for message storage
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength:=2
+	//+kubebuilder:validation:Pattern:=`^[\w-_]+$`
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="MongoDB Collection",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Collection string `json:"collection,omitempty"`
+}
+
+// MailhogInstanceStatus defines the observed state of MailhogInstance
+type MailhogInstanceStatus struct {
+	// Pods all the podnames owned by the cr
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Pod Status",xDescriptors="urn:alm:descriptor:com.tectonic.ui:podStatuses"
+	Pods PodStatus `json:"pods,omitempty"`
+
+	// PodCount is the amount of last seen pods belonging to this cr
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Pod Count",xDescriptors="urn:alm:descriptor:com.tectonic.ui:podCount"
+	PodCount int `json:"podCount,omitempty"`
+
+	// ReadyPodCount is the amount of pods last seen ready
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Ready Pod Count"
+	ReadyPodCount int `json:"readyPodCount,omitempty"`
+
+	// LabelSelector is the labelselector which can be used by HPA
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Label Selector"
+	LabelSelector string `json:"labelSelector,omitempty"`
+
+	// Error is used to signal illegal CR specs
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Error Message"
+	Error string `json:"error,omitempty"`
+
+	// RouteURL will be set to the path under which mailhog is reachable if openshift Route is enabled
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Mailhog Web UI",xDescriptors="urn:alm:descriptor:org.w3:link"
+	RouteURL string `json:"routeUrl,omitempty"`
+
+	// Retention shows the results of the last message purge
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Message Retention"
+	Retention *RetentionStatus `json:"retention,omitempty"`
+
+	// Archive shows the progress of uploading messages to the object storage
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Message Archive"
+	Archive *ArchiveStatus `json:"archive,omitempty"`
 
-	// Db MongoDB database name for message storage
+	// Messages summarizes the messages captured by the instance, as reported by the mailhog api
 	//
 	//+kubebuilder:validation:Optional
-	//+kubebuilder:validation:MinLength:=2
-	//+kubebuilder:validation:Pattern:=`^[\w-_]+$`
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="MongoDB DB",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Db string `json:"db,omitempty"`
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Messages"
+	Messages *MessagesStatus `json:"messages,omitempty"`
 
-	// Collection MongoDB collection name for message storage
+	// SmtpFaults are the hits of every smtp fault, summed over the reachable pods since they started
 	//
 	//+kubebuilder:validation:Optional
-	//+kubebuilder:validation:MinLength:=2
-	//+kubebuilder:validation:Pattern:=`^[\w-_]+$`
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="MongoDB Collection",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
-	Collection string `json:"collection,omitempty"`
+	SmtpFaults []SmtpFaultStatus `json:"smtpFaults,omitempty"`
+
+	// SmtpPolicyViolations counts the rejected violations of the smtp policy, summed over the reachable pods since they started
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	SmtpPolicyViolations *SmtpPolicyViolationsStatus `json:"smtpPolicyViolations,omitempty"`
+
+	// SmtpRelay are the counters of every relay rule, summed over the reachable pods since they started
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	SmtpRelay []SmtpRelayStatus `json:"smtpRelay,omitempty"`
+
+	// LastPodRefreshTime is when messages, smtpFaults, smtpPolicyViolations and smtpRelay were last asked from the pods,
+	// they are refreshed periodically instead of on every reconcile
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	LastPodRefreshTime *metav1.Time `json:"lastPodRefreshTime,omitempty"`
+
+	// Seed records which pods received the seed messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Seed *SeedStatus `json:"seed,omitempty"`
+
+	// Preserve shows the outcome of preserving messages across the last rollout or storage migration
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Preserved Messages"
+	Preserve *PreserveStatus `json:"preserve,omitempty"`
+
+	// SmtpVerification records the rollout the last smtp verification was done for
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	SmtpVerification *SmtpVerificationStatus `json:"smtpVerification,omitempty"`
+
+	// Upstreams are the results of the last connection check of every smtp upstream
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+listType=map
+	//+listMapKey=name
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="SMTP Upstreams"
+	Upstreams []UpstreamStatus `json:"upstreams,omitempty"`
+
+	// Jim shows the effective chaos monkey configuration if jim is active, values that are not set show mailhog's defaults
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Chaosmonkey Configuration"
+	Jim *JimStatus `json:"jim,omitempty"`
+
+	// Chaos is the running MailhogChaosExperiment, its jim settings replace the ones of the spec until it ends
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Chaos Experiment"
+	Chaos *ChaosStatus `json:"chaos,omitempty"`
+
+	// Conditions are the latest observations of the instance's state
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+listType=map
+	//+listMapKey=type
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Conditions",xDescriptors="urn:alm:descriptor:io.kubernetes.conditions"
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
-// MailhogInstanceStatus defines the observed state of MailhogInstance
-type MailhogInstanceStatus struct {
-	// Pods all the podnames owned by the cr
+// JimStatus is the effective chaos monkey configuration, after applying the preset and single values
+type JimStatus struct {
+	// Preset is the preset the values are based on
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Preset JimPreset `json:"preset,omitempty"`
+
+	// Disconnect Chance of randomly disconnecting a session
+	Disconnect string `json:"disconnect"`
+
+	// Accept Chance of accepting an incoming connection
+	Accept string `json:"accept"`
+
+	// LinkspeedAffect Chance of applying a rate limit
+	LinkspeedAffect string `json:"linkspeedAffect"`
+
+	// LinkspeedMin Minimum link speed in bytes per second
+	LinkspeedMin string `json:"linkspeedMin"`
+
+	// LinkspeedMax Maximum link speed in bytes per second
+	LinkspeedMax string `json:"linkspeedMax"`
+
+	// RejectSender Chance of rejecting a MAIL FROM command
+	RejectSender string `json:"rejectSender"`
+
+	// RejectRecipient Chance of rejecting a RCPT TO command
+	RejectRecipient string `json:"rejectRecipient"`
+
+	// RejectAuth Chance of rejecting an AUTH command
+	RejectAuth string `json:"rejectAuth"`
+}
+
+// ChaosStatus is the window of a MailhogChaosExperiment applied to the instance
+type ChaosStatus struct {
+	// Experiment is the name of the MailhogChaosExperiment
+	Experiment string `json:"experiment"`
+
+	// Jim are the chaos monkey settings of the experiment
+	Jim MailhogJimSpec `json:"jim"`
+
+	// Until is when the experiment restores the settings of the spec
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Pod Status",xDescriptors="urn:alm:descriptor:com.tectonic.ui:podStatuses"
-	Pods PodStatus `json:"pods,omitempty"`
+	Until *metav1.Time `json:"until,omitempty"`
+}
 
-	// PodCount is the amount of last seen pods belonging to this cr
+// SmtpVerificationStatus records the last verification of the smtp delivery
+type SmtpVerificationStatus struct {
+	// Revision is the deployment revision the last verification was done for
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Revision string `json:"revision,omitempty"`
+
+	// LastVerificationTime is when the last test message was sent
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Pod Count",xDescriptors="urn:alm:descriptor:com.tectonic.ui:podCount"
-	PodCount int `json:"podCount,omitempty"`
+	LastVerificationTime *metav1.Time `json:"lastVerificationTime,omitempty"`
+}
 
-	// ReadyPodCount is the amount of pods last seen ready
+// UpstreamAuthResult is the result of the authentication at an smtp upstream
+type UpstreamAuthResult string
+
+const (
+	// AuthSucceededResult the upstream accepted the credentials
+	AuthSucceededResult UpstreamAuthResult = "Succeeded"
+
+	// AuthFailedResult the upstream rejected the credentials or the mechanism
+	AuthFailedResult UpstreamAuthResult = "Failed"
+
+	// AuthSkippedResult the upstream has no credentials or the check did not get to the authentication
+	AuthSkippedResult UpstreamAuthResult = "Skipped"
+)
+
+// UpstreamStatus is the result of the last connection check of an smtp upstream
+type UpstreamStatus struct {
+	// Name of the upstream in files.smtpUpstreams
+	//
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Address is the host and port that was connected to
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Address string `json:"address,omitempty"`
+
+	// Reachable is true if the upstream answered EHLO
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Reachable bool `json:"reachable"`
+
+	// Tls is true if the upstream offered STARTTLS and the handshake succeeded
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Tls bool `json:"tls"`
+
+	// Auth is the result of the authentication with the upstream's mechanism
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Auth UpstreamAuthResult `json:"auth,omitempty"`
+
+	// Error is the reason the check failed, empty if it succeeded
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Error string `json:"error,omitempty"`
+
+	// LastCheckTime is when the upstream was checked
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Ready Pod Count"
-	ReadyPodCount int `json:"readyPodCount,omitempty"`
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+}
 
-	// LabelSelector is the labelselector which can be used by HPA
+// SeedStatus records the delivery of the seed messages
+type SeedStatus struct {
+	// Hash identifies the seed messages that were delivered
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Hash string `json:"hash,omitempty"`
+
+	// Pods are the pods (uid and restart count) the seed messages were delivered to
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Label Selector"
-	LabelSelector string `json:"labelSelector,omitempty"`
+	Pods []string `json:"pods,omitempty"`
+}
 
-	// Error is used to signal illegal CR specs
+// PreserveStatus contains the progress of preserving messages across a rollout or a storage migration
+type PreserveStatus struct {
+	// MigrateFrom is the storage backend the messages are migrated from, empty if they are preserved across a rollout
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=memory;maildir;mongodb
+	//+optional
+	MigrateFrom StorageSetting `json:"migrateFrom,omitempty"`
+
+	// Phase is Exported while the messages wait for new pods, Restored once all new pods received them,
+	// and Failed if the export failed (the rollout waits until a later export succeeds)
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=Exported;Restored;Failed
+	//+optional
+	Phase PreservePhase `json:"phase,omitempty"`
+
+	// Messages is the count of exported messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Messages int `json:"messages,omitempty"`
+
+	// ExportTime is when the messages were exported
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Error Message"
+	ExportTime *metav1.Time `json:"exportTime,omitempty"`
+
+	// CompletionTime is when all new pods received the messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// SourcePods are the uids of the pods the messages were exported from
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	SourcePods []string `json:"sourcePods,omitempty"`
+
+	// RestoredPods are the uids of the pods the messages were replayed to
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	RestoredPods []string `json:"restoredPods,omitempty"`
+
+	// Error is the error of the export or the last replay, if any
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
 	Error string `json:"error,omitempty"`
+}
 
-	// RouteURL will be set to the path under which mailhog is reachable if openshift Route is enabled
+// MessagesStatus contains the message count and the time of the latest message
+type MessagesStatus struct {
+	// Total is the count of stored messages (summed over all pods for memory / maildir storage)
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Total int `json:"total"`
+
+	// LastReceived is the creation time of the newest message
 	//
 	//+kubebuilder:validation:Optional
 	//+optional
 	//+nullable
-	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Mailhog Web UI",xDescriptors="urn:alm:descriptor:org.w3:link"
-	RouteURL string `json:"routeUrl,omitempty"`
+	LastReceived *metav1.Time `json:"lastReceived,omitempty"`
+}
+
+// SmtpRelayStatus are the counters of a single relay rule
+type SmtpRelayStatus struct {
+	// Name of the rule
+	Name string `json:"name"`
+
+	// Relayed counts the messages accepted by the upstream
+	Relayed int64 `json:"relayed"`
+
+	// Failed counts the messages given up after a permanent failure or the last attempt
+	Failed int64 `json:"failed"`
+
+	// Retries counts the attempts that failed temporarily
+	Retries int64 `json:"retries"`
+
+	// Pending counts the messages waiting to be sent or retried
+	Pending int64 `json:"pending"`
+}
+
+// SmtpPolicyViolationsStatus is the count of every kind of smtp policy violation
+type SmtpPolicyViolationsStatus struct {
+	// Auth counts failed authentications and MAIL FROM without authentication
+	Auth int64 `json:"auth"`
+
+	// Sender counts senders outside of the allowed domains
+	Sender int64 `json:"sender"`
+
+	// Size counts messages exceeding the maximum size
+	Size int64 `json:"size"`
+
+	// Recipients counts recipients exceeding the maximum count
+	Recipients int64 `json:"recipients"`
+}
+
+// SmtpFaultStatus is the hit count of a single smtp fault
+type SmtpFaultStatus struct {
+	// Name of the fault
+	Name string `json:"name"`
+
+	// Hits is the count of commands the fault was applied to
+	Hits int64 `json:"hits"`
+}
+
+// RetentionStatus contains the results of the message purges
+type RetentionStatus struct {
+	// LastPurgeTime is the time the operator last purged messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	LastPurgeTime *metav1.Time `json:"lastPurgeTime,omitempty"`
+
+	// LastPurgeDeleted is the count of messages deleted by the last purge
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	LastPurgeDeleted int64 `json:"lastPurgeDeleted,omitempty"`
+
+	// TotalDeleted is the count of messages deleted by all purges
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	TotalDeleted int64 `json:"totalDeleted,omitempty"`
+
+	// Error is the error of the last purge, if any
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Error string `json:"error,omitempty"`
+}
+
+// ArchiveStatus contains the results of the message uploads
+type ArchiveStatus struct {
+	// Cursors are the positions of the newest uploaded messages per pod, or of the storage if the pods share it
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Cursors []ArchiveCursor `json:"cursors,omitempty"`
+
+	// Pending is true if the last run reached its message limit, the next run follows without waiting for the interval
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Pending bool `json:"pending,omitempty"`
+
+	// LastUploadTime is the time the operator last uploaded messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	LastUploadTime *metav1.Time `json:"lastUploadTime,omitempty"`
+
+	// LastUploaded is the count of messages uploaded by the last run
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	LastUploaded int64 `json:"lastUploaded,omitempty"`
+
+	// TotalUploaded is the count of messages uploaded by all runs
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	TotalUploaded int64 `json:"totalUploaded,omitempty"`
+
+	// LastIndex is the key of the index uploaded by the last run that uploaded messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	LastIndex string `json:"lastIndex,omitempty"`
+
+	// Error is the error of the last run, if any
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Error string `json:"error,omitempty"`
+}
+
+// ArchiveCursor is the position of the newest uploaded message of a message source
+type ArchiveCursor struct {
+	// Source is the name of the pod, or the storage if the pods share it
+	//
+	//+kubebuilder:validation:Required
+	Source string `json:"source"`
+
+	// Cursor is the creation time (RFC 3339 with nanoseconds) of the newest uploaded message,
+	// only newer messages of the source are fetched and uploaded by the next run
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Cursor string `json:"cursor,omitempty"`
+
+	// CursorIDs are the ids of the uploaded messages created exactly at the cursor time
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	CursorIDs []string `json:"cursorIds,omitempty"`
 }
 
 // PodStatus will divide the child pods into a grouping
@@ -565,11 +1991,13 @@ type PodStatus struct {
 //+kubebuilder:object:root=true
 //+kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.image`
 //+kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.spec.replicas`
+//+kubebuilder:printcolumn:name="Messages",type=integer,JSONPath=`.status.messages.total`
+//+kubebuilder:printcolumn:name="Last Received",type="date",JSONPath=`.status.messages.lastReceived`
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 //+kubebuilder:subresource:status
 //+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.podCount,selectorpath=.status.labelSelector
 //+operator-sdk:csv:customresourcedefinitions:displayName="Mailhog Instance"
-//+operator-sdk:csv:customresourcedefinitions:resources={{Service,v1},{Deployment,v1},{Route,v1},{ConfigMap,v1},{Ingress,v1}}
+//+operator-sdk:csv:customresourcedefinitions:resources={{Service,v1},{Deployment,v1},{Route,v1},{ConfigMap,v1},{Ingress,v1},{Secret,v1},{ServiceAccount,v1},{Role,v1},{RoleBinding,v1},{Job,v1}}
 type MailhogInstance struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,192 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MailhogLoadTestSpec defines the smtp load generated against a MailhogInstance
+type MailhogLoadTestSpec struct {
+	// Instance is the name of the MailhogInstance (in the same namespace) the messages are sent to through its Service
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Mailhog Instance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Instance string `json:"instance"`
+
+	// MessagesPerSecond is the rate messages are sent with over all connections, it is not exceeded if the instance is slower
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=1000
+	//+kubebuilder:default:=10
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Messages per Second",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	MessagesPerSecond int32 `json:"messagesPerSecond,omitempty"`
+
+	// Duration is how long messages are sent
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="1m"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Duration",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Concurrency is the count of parallel smtp connections
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=100
+	//+kubebuilder:default:=4
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Concurrency",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	Concurrency int32 `json:"concurrency,omitempty"`
+
+	// MessageSize is the approximate size of every message in bytes
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=10485760
+	//+kubebuilder:default:=1024
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Message Size",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	MessageSize int32 `json:"messageSize,omitempty"`
+
+	// SenderPattern is the envelope and header sender, {n} is replaced with the message number and {worker} with the connection number
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength=3
+	//+kubebuilder:default:="loadtest-{worker}@sender.example"
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Sender Pattern",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	SenderPattern string `json:"senderPattern,omitempty"`
+
+	// RecipientPattern is the envelope and header recipient, {n} is replaced with the message number and {worker} with the connection number
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MinLength=3
+	//+kubebuilder:default:="loadtest-{n}@receiver.example"
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Recipient Pattern",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	RecipientPattern string `json:"recipientPattern,omitempty"`
+}
+
+// MailhogLoadTestStatus defines the observed state of MailhogLoadTest
+type MailhogLoadTestStatus struct {
+	// Job is the name of the Job generating the load
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Job string `json:"job,omitempty"`
+
+	// Sent is the count of messages accepted by the instance
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Sent int64 `json:"sent,omitempty"`
+
+	// Errors is the count of messages that could not be sent
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Errors int64 `json:"errors,omitempty"`
+
+	// LastError is the last error the load generator encountered
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	LastError string `json:"lastError,omitempty"`
+
+	// Throughput is the count of accepted messages per second
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Throughput string `json:"throughput,omitempty"`
+
+	// Latency summarizes the time from MAIL FROM until a message was accepted
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Latency *LoadTestLatency `json:"latency,omitempty"`
+
+	// StartTime is when the load test was picked up by the operator
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the load test finished
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Conditions contain the Completed condition
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// LoadTestLatency summarizes the latencies of the accepted messages
+type LoadTestLatency struct {
+	Average metav1.Duration `json:"average"`
+	P50     metav1.Duration `json:"p50"`
+	P95     metav1.Duration `json:"p95"`
+	P99     metav1.Duration `json:"p99"`
+	Max     metav1.Duration `json:"max"`
+}
+
+// MailhogLoadTest sends smtp load to a MailhogInstance from a Job and records throughput, latency and errors
+//
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Instance",type=string,JSONPath=`.spec.instance`
+//+kubebuilder:printcolumn:name="Rate",type=integer,JSONPath=`.spec.messagesPerSecond`
+//+kubebuilder:printcolumn:name="Sent",type=integer,JSONPath=`.status.sent`
+//+kubebuilder:printcolumn:name="Errors",type=integer,JSONPath=`.status.errors`
+//+kubebuilder:printcolumn:name="Throughput",type=string,JSONPath=`.status.throughput`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.conditions[?(@.type=="Completed")].reason`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:subresource:status
+//+operator-sdk:csv:customresourcedefinitions:displayName="Mailhog Load Test"
+type MailhogLoadTest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MailhogLoadTestSpec `json:"spec,omitempty"`
+
+	// Status last observed status
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Status MailhogLoadTestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MailhogLoadTestList contains a list of MailhogLoadTest
+type MailhogLoadTestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MailhogLoadTest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MailhogLoadTest{}, &MailhogLoadTestList{})
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MailhogRestoreSpec defines the desired state of MailhogRestore
+type MailhogRestoreSpec struct {
+	// Snapshot is the name of the MailhogSnapshot (in the same namespace) whose messages are replayed
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Mailhog Snapshot",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Snapshot string `json:"snapshot"`
+
+	// Instance is the name of the MailhogInstance (in the same namespace) the messages are delivered to
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Mailhog Instance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Instance string `json:"instance"`
+}
+
+// MailhogRestoreStatus defines the observed state of MailhogRestore
+type MailhogRestoreStatus struct {
+	// Messages is the count of replayed messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Messages int `json:"messages,omitempty"`
+
+	// Pods are the pods the messages were delivered to
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Pods []string `json:"pods,omitempty"`
+
+	// Job is the name of the Job replaying a snapshot stored in a PVC
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Job string `json:"job,omitempty"`
+
+	// StartTime is when the operator started the replay
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the replay finished successfully
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Conditions contain the progress of the replay
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// MailhogRestore replays the messages of a MailhogSnapshot into a MailhogInstance
+//
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Snapshot",type=string,JSONPath=`.spec.snapshot`
+//+kubebuilder:printcolumn:name="Instance",type=string,JSONPath=`.spec.instance`
+//+kubebuilder:printcolumn:name="Messages",type=integer,JSONPath=`.status.messages`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.conditions[?(@.type=="Completed")].reason`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:subresource:status
+//+operator-sdk:csv:customresourcedefinitions:displayName="Mailhog Restore"
+//+operator-sdk:csv:customresourcedefinitions:resources={{Job,v1}}
+type MailhogRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MailhogRestoreSpec `json:"spec,omitempty"`
+
+	// Status last observed status
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Status MailhogRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MailhogRestoreList contains a list of MailhogRestore
+type MailhogRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MailhogRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MailhogRestore{}, &MailhogRestoreList{})
+}
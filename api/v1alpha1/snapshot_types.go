@@ -0,0 +1,178 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type SnapshotFormat string
+
+const (
+	// MboxSnapshotFormat all messages are stored in a single mboxrd file
+	MboxSnapshotFormat SnapshotFormat = "mbox"
+
+	// EmlSnapshotFormat every message is stored in its own .eml file
+	EmlSnapshotFormat SnapshotFormat = "eml"
+)
+
+const (
+	// CompletedCondition is true once a snapshot, restore or load test finished successfully
+	CompletedCondition = "Completed"
+
+	// InProgressReason the snapshot, restore or load test is waiting or running
+	InProgressReason = "InProgress"
+
+	// SucceededReason the snapshot, restore or load test finished successfully
+	SucceededReason = "Succeeded"
+
+	// FailedReason the snapshot, restore or load test failed and will not be retried
+	FailedReason = "Failed"
+)
+
+// MailhogSnapshotSpec defines the desired state of MailhogSnapshot
+type MailhogSnapshotSpec struct {
+	// Instance is the name of the MailhogInstance (in the same namespace) whose messages are exported
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Mailhog Instance",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Instance string `json:"instance"`
+
+	// Format is how the messages are stored, mbox = one mboxrd file, eml = one file per message
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=mbox;eml
+	//+kubebuilder:default:="eml"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Format",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:mbox","urn:alm:descriptor:com.tectonic.ui:select:eml"}
+	Format SnapshotFormat `json:"format,omitempty"`
+
+	// PersistentVolumeClaim stores the snapshot in an existing PVC (written by a Job),
+	// if not set the snapshot is stored in a ConfigMap, which limits its size to about 1MiB
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Persistent Volume Claim"
+	PersistentVolumeClaim *SnapshotVolumeSpec `json:"persistentVolumeClaim,omitempty"`
+}
+
+// SnapshotVolumeSpec defines where in a PVC a snapshot is stored
+type SnapshotVolumeSpec struct {
+	// ClaimName is the name of an existing PVC in the namespace of the snapshot
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Claim Name",xDescriptors={"urn:alm:descriptor:io.kubernetes:PersistentVolumeClaim"}
+	ClaimName string `json:"claimName"`
+
+	// Path is the directory inside the volume, defaults to the snapshot name
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Pattern=`^[a-zA-Z0-9._-]+(/[a-zA-Z0-9._-]+)*$`
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Path",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Path string `json:"path,omitempty"`
+}
+
+// MailhogSnapshotStatus defines the observed state of MailhogSnapshot
+type MailhogSnapshotStatus struct {
+	// Messages is the count of exported messages
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Messages int `json:"messages,omitempty"`
+
+	// Size is the size of the snapshot in bytes
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Size int64 `json:"size,omitempty"`
+
+	// ConfigMap is the name of the ConfigMap containing the snapshot
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// Job is the name of the Job writing the snapshot to the PVC
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	Job string `json:"job,omitempty"`
+
+	// StartTime is when the operator started the export
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the export finished successfully
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Conditions contain the progress of the export
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// MailhogSnapshot exports all messages of a MailhogInstance into a ConfigMap or PVC
+//
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Instance",type=string,JSONPath=`.spec.instance`
+//+kubebuilder:printcolumn:name="Format",type=string,JSONPath=`.spec.format`
+//+kubebuilder:printcolumn:name="Messages",type=integer,JSONPath=`.status.messages`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.conditions[?(@.type=="Completed")].reason`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:subresource:status
+//+operator-sdk:csv:customresourcedefinitions:displayName="Mailhog Snapshot"
+//+operator-sdk:csv:customresourcedefinitions:resources={{ConfigMap,v1},{Job,v1}}
+type MailhogSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MailhogSnapshotSpec `json:"spec,omitempty"`
+
+	// Status last observed status
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	Status MailhogSnapshotStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MailhogSnapshotList contains a list of MailhogSnapshot
+type MailhogSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MailhogSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MailhogSnapshot{}, &MailhogSnapshotList{})
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UpstreamKind is the kind of a catalog upstream
+//
+//+kubebuilder:validation:Enum=MailhogUpstream;ClusterMailhogUpstream
+type UpstreamKind string
+
+const (
+	// NamespacedUpstreamKind is a MailhogUpstream in the namespace of the instance
+	NamespacedUpstreamKind UpstreamKind = "MailhogUpstream"
+
+	// ClusterUpstreamKind is a ClusterMailhogUpstream
+	ClusterUpstreamKind UpstreamKind = "ClusterMailhogUpstream"
+)
+
+// MailhogCatalogUpstreamSpec is an smtp upstream instances can reference instead of repeating it in files.smtpUpstreams
+// the upstream is shown in the UI under the name of the catalog entry
+type MailhogCatalogUpstreamSpec struct {
+	// Host SMTP target Host hostname
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=2
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server hostname",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Host string `json:"host"`
+
+	// Port SMTP target Port
+	//
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=2
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server port",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Port string `json:"port"`
+
+	// Email the target Email address released messages are sent to
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Destination Email on release",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Email string `json:"email,omitempty"`
+
+	// Mechanism the SMTP login Mechanism used. This is _required_ when providing a credentials Secret
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=PLAIN;CRAMMD5
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upstream SMTP server auth mechanism",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:PLAIN","urn:alm:descriptor:com.tectonic.ui:select:CRAMMD5"}
+	Mechanism string `json:"mechanism,omitempty"`
+
+	// CredentialsSecret references a kubernetes.io/basic-auth Secret with the keys username and password
+	// the namespace of a MailhogUpstream must be its own and may be left empty, it is required for a ClusterMailhogUpstream
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credentials Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	CredentialsSecret *corev1.SecretReference `json:"credentialsSecret,omitempty"`
+}
+
+// MailhogUpstreamRefSpec references catalog upstreams by name or label selector
+type MailhogUpstreamRefSpec struct {
+	// Kind is MailhogUpstream (in the namespace of the instance) or ClusterMailhogUpstream
+	//
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default:="MailhogUpstream"
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Kind",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:MailhogUpstream","urn:alm:descriptor:com.tectonic.ui:select:ClusterMailhogUpstream"}
+	Kind UpstreamKind `json:"kind,omitempty"`
+
+	// Name of a single catalog upstream, either name or selector has to be set
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	Name string `json:"name,omitempty"`
+
+	// Selector selects every catalog upstream with matching labels
+	//
+	//+kubebuilder:validation:Optional
+	//+optional
+	//+nullable
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Selector",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:selector:mailhog.operators.patrick.mx:v1alpha1:MailhogUpstream"}
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// MailhogUpstream is an smtp upstream the MailhogInstances of its namespace can reference
+//
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+//+kubebuilder:printcolumn:name="Port",type=string,JSONPath=`.spec.port`
+//+kubebuilder:printcolumn:name="Mechanism",type=string,JSONPath=`.spec.mechanism`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+operator-sdk:csv:customresourcedefinitions:displayName="Mailhog Upstream"
+type MailhogUpstream struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MailhogCatalogUpstreamSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MailhogUpstreamList contains a list of MailhogUpstream
+type MailhogUpstreamList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MailhogUpstream `json:"items"`
+}
+
+// ClusterMailhogUpstream is an smtp upstream the MailhogInstances of every namespace can reference
+//
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+//+kubebuilder:printcolumn:name="Port",type=string,JSONPath=`.spec.port`
+//+kubebuilder:printcolumn:name="Mechanism",type=string,JSONPath=`.spec.mechanism`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+operator-sdk:csv:customresourcedefinitions:displayName="Cluster Mailhog Upstream"
+type ClusterMailhogUpstream struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MailhogCatalogUpstreamSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterMailhogUpstreamList contains a list of ClusterMailhogUpstream
+type ClusterMailhogUpstreamList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterMailhogUpstream `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MailhogUpstream{}, &MailhogUpstreamList{}, &ClusterMailhogUpstream{}, &ClusterMailhogUpstreamList{})
+}
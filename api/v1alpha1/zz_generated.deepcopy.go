@@ -22,7 +22,8 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -31,17 +32,17 @@ func (in *AffinitySpec) DeepCopyInto(out *AffinitySpec) {
 	*out = *in
 	if in.PodAffinity != nil {
 		in, out := &in.PodAffinity, &out.PodAffinity
-		*out = new(v1.PodAffinity)
+		*out = new(corev1.PodAffinity)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.PodAntiAffinity != nil {
 		in, out := &in.PodAntiAffinity, &out.PodAntiAffinity
-		*out = new(v1.PodAntiAffinity)
+		*out = new(corev1.PodAntiAffinity)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.NodeAffinity != nil {
 		in, out := &in.NodeAffinity, &out.NodeAffinity
-		*out = new(v1.NodeAffinity)
+		*out = new(corev1.NodeAffinity)
 		(*in).DeepCopyInto(*out)
 	}
 }
@@ -56,6 +57,130 @@ func (in *AffinitySpec) DeepCopy() *AffinitySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArchiveCursor) DeepCopyInto(out *ArchiveCursor) {
+	*out = *in
+	if in.CursorIDs != nil {
+		in, out := &in.CursorIDs, &out.CursorIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchiveCursor.
+func (in *ArchiveCursor) DeepCopy() *ArchiveCursor {
+	if in == nil {
+		return nil
+	}
+	out := new(ArchiveCursor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArchiveStatus) DeepCopyInto(out *ArchiveStatus) {
+	*out = *in
+	if in.Cursors != nil {
+		in, out := &in.Cursors, &out.Cursors
+		*out = make([]ArchiveCursor, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastUploadTime != nil {
+		in, out := &in.LastUploadTime, &out.LastUploadTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchiveStatus.
+func (in *ArchiveStatus) DeepCopy() *ArchiveStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ArchiveStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChaosStatus) DeepCopyInto(out *ChaosStatus) {
+	*out = *in
+	in.Jim.DeepCopyInto(&out.Jim)
+	if in.Until != nil {
+		in, out := &in.Until, &out.Until
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChaosStatus.
+func (in *ChaosStatus) DeepCopy() *ChaosStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ChaosStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterMailhogUpstream) DeepCopyInto(out *ClusterMailhogUpstream) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterMailhogUpstream.
+func (in *ClusterMailhogUpstream) DeepCopy() *ClusterMailhogUpstream {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterMailhogUpstream)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterMailhogUpstream) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterMailhogUpstreamList) DeepCopyInto(out *ClusterMailhogUpstreamList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterMailhogUpstream, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterMailhogUpstreamList.
+func (in *ClusterMailhogUpstreamList) DeepCopy() *ClusterMailhogUpstreamList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterMailhogUpstreamList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterMailhogUpstreamList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IngressSpec) DeepCopyInto(out *IngressSpec) {
 	*out = *in
@@ -72,32 +197,1189 @@ func (in *IngressSpec) DeepCopy() *IngressSpec {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogFilesSpec) DeepCopyInto(out *MailhogFilesSpec) {
+func (in *JimStatus) DeepCopyInto(out *JimStatus) {
 	*out = *in
-	if in.SmtpUpstreams != nil {
-		in, out := &in.SmtpUpstreams, &out.SmtpUpstreams
-		*out = make([]MailhogUpstreamSpec, len(*in))
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JimStatus.
+func (in *JimStatus) DeepCopy() *JimStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JimStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadTestLatency) DeepCopyInto(out *LoadTestLatency) {
+	*out = *in
+	out.Average = in.Average
+	out.P50 = in.P50
+	out.P95 = in.P95
+	out.P99 = in.P99
+	out.Max = in.Max
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadTestLatency.
+func (in *LoadTestLatency) DeepCopy() *LoadTestLatency {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadTestLatency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogAccessSpec) DeepCopyInto(out *MailhogAccessSpec) {
+	*out = *in
+	if in.Subjects != nil {
+		in, out := &in.Subjects, &out.Subjects
+		*out = make([]MailhogAccessSubject, len(*in))
 		copy(*out, *in)
 	}
-	if in.WebUsers != nil {
-		in, out := &in.WebUsers, &out.WebUsers
-		*out = make([]MailhogWebUserSpec, len(*in))
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogAccessSpec.
+func (in *MailhogAccessSpec) DeepCopy() *MailhogAccessSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogAccessSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogAccessSubject) DeepCopyInto(out *MailhogAccessSubject) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogAccessSubject.
+func (in *MailhogAccessSubject) DeepCopy() *MailhogAccessSubject {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogAccessSubject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogArchiveSpec) DeepCopyInto(out *MailhogArchiveSpec) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogArchiveSpec.
+func (in *MailhogArchiveSpec) DeepCopy() *MailhogArchiveSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogArchiveSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogAssertion) DeepCopyInto(out *MailhogAssertion) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogAssertion.
+func (in *MailhogAssertion) DeepCopy() *MailhogAssertion {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogAssertion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogAssertion) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogAssertionList) DeepCopyInto(out *MailhogAssertionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MailhogAssertion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogAssertionList.
+func (in *MailhogAssertionList) DeepCopy() *MailhogAssertionList {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogAssertionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogAssertionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogAssertionSpec) DeepCopyInto(out *MailhogAssertionSpec) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Count != nil {
+		in, out := &in.Count, &out.Count
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogAssertionSpec.
+func (in *MailhogAssertionSpec) DeepCopy() *MailhogAssertionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogAssertionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogAssertionStatus) DeepCopyInto(out *MailhogAssertionStatus) {
+	*out = *in
+	if in.Matches != nil {
+		in, out := &in.Matches, &out.Matches
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastEvaluationTime != nil {
+		in, out := &in.LastEvaluationTime, &out.LastEvaluationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogFilesSpec.
-func (in *MailhogFilesSpec) DeepCopy() *MailhogFilesSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogAssertionStatus.
+func (in *MailhogAssertionStatus) DeepCopy() *MailhogAssertionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogAssertionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogAuthSpec) DeepCopyInto(out *MailhogAuthSpec) {
+	*out = *in
+	if in.Oidc != nil {
+		in, out := &in.Oidc, &out.Oidc
+		*out = new(MailhogOidcSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rbac != nil {
+		in, out := &in.Rbac, &out.Rbac
+		*out = new(MailhogRbacAuthSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogAuthSpec.
+func (in *MailhogAuthSpec) DeepCopy() *MailhogAuthSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogAuthSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogCatalogUpstreamSpec) DeepCopyInto(out *MailhogCatalogUpstreamSpec) {
+	*out = *in
+	if in.CredentialsSecret != nil {
+		in, out := &in.CredentialsSecret, &out.CredentialsSecret
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogCatalogUpstreamSpec.
+func (in *MailhogCatalogUpstreamSpec) DeepCopy() *MailhogCatalogUpstreamSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogCatalogUpstreamSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogChaosExperiment) DeepCopyInto(out *MailhogChaosExperiment) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogChaosExperiment.
+func (in *MailhogChaosExperiment) DeepCopy() *MailhogChaosExperiment {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogChaosExperiment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogChaosExperiment) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogChaosExperimentList) DeepCopyInto(out *MailhogChaosExperimentList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MailhogChaosExperiment, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogChaosExperimentList.
+func (in *MailhogChaosExperimentList) DeepCopy() *MailhogChaosExperimentList {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogChaosExperimentList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogChaosExperimentList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogChaosExperimentSpec) DeepCopyInto(out *MailhogChaosExperimentSpec) {
+	*out = *in
+	in.Jim.DeepCopyInto(&out.Jim)
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogChaosExperimentSpec.
+func (in *MailhogChaosExperimentSpec) DeepCopy() *MailhogChaosExperimentSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogChaosExperimentSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogChaosExperimentStatus) DeepCopyInto(out *MailhogChaosExperimentStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.EndTime != nil {
+		in, out := &in.EndTime, &out.EndTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRunTime != nil {
+		in, out := &in.NextRunTime, &out.NextRunTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Applied != nil {
+		in, out := &in.Applied, &out.Applied
+		*out = new(JimStatus)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogChaosExperimentStatus.
+func (in *MailhogChaosExperimentStatus) DeepCopy() *MailhogChaosExperimentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogChaosExperimentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogFileSourceSpec) DeepCopyInto(out *MailhogFileSourceSpec) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogFileSourceSpec.
+func (in *MailhogFileSourceSpec) DeepCopy() *MailhogFileSourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogFileSourceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogFilesSpec) DeepCopyInto(out *MailhogFilesSpec) {
+	*out = *in
+	if in.SmtpUpstreams != nil {
+		in, out := &in.SmtpUpstreams, &out.SmtpUpstreams
+		*out = make([]MailhogUpstreamSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UpstreamRefs != nil {
+		in, out := &in.UpstreamRefs, &out.UpstreamRefs
+		*out = make([]MailhogUpstreamRefSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.WebUsers != nil {
+		in, out := &in.WebUsers, &out.WebUsers
+		*out = make([]MailhogWebUserSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.UpstreamsFrom != nil {
+		in, out := &in.UpstreamsFrom, &out.UpstreamsFrom
+		*out = new(MailhogFileSourceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UsersFrom != nil {
+		in, out := &in.UsersFrom, &out.UsersFrom
+		*out = new(MailhogFileSourceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogFilesSpec.
+func (in *MailhogFilesSpec) DeepCopy() *MailhogFilesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogFilesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogInstance) DeepCopyInto(out *MailhogInstance) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstance.
+func (in *MailhogInstance) DeepCopy() *MailhogInstance {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogInstance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogInstance) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogInstanceList) DeepCopyInto(out *MailhogInstanceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MailhogInstance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstanceList.
+func (in *MailhogInstanceList) DeepCopy() *MailhogInstanceList {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogInstanceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogInstanceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogInstanceSettingsSpec) DeepCopyInto(out *MailhogInstanceSettingsSpec) {
+	*out = *in
+	out.StorageMongoDb = in.StorageMongoDb
+	out.StorageMaildir = in.StorageMaildir
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = new(MailhogFilesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(AffinitySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Jim.DeepCopyInto(&out.Jim)
+	out.Ingress = in.Ingress
+	if in.SmtpTls != nil {
+		in, out := &in.SmtpTls, &out.SmtpTls
+		*out = new(MailhogSmtpTlsSpec)
+		**out = **in
+	}
+	if in.SmtpFaults != nil {
+		in, out := &in.SmtpFaults, &out.SmtpFaults
+		*out = make([]MailhogSmtpFaultSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SmtpPolicy != nil {
+		in, out := &in.SmtpPolicy, &out.SmtpPolicy
+		*out = new(MailhogSmtpPolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SmtpTranscripts != nil {
+		in, out := &in.SmtpTranscripts, &out.SmtpTranscripts
+		*out = new(MailhogSmtpTranscriptsSpec)
+		**out = **in
+	}
+	if in.SmtpRelay != nil {
+		in, out := &in.SmtpRelay, &out.SmtpRelay
+		*out = new(MailhogSmtpRelaySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(MailhogAuthSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SidecarResources != nil {
+		in, out := &in.SidecarResources, &out.SidecarResources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(MailhogRetentionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Archive != nil {
+		in, out := &in.Archive, &out.Archive
+		*out = new(MailhogArchiveSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TrustedCa != nil {
+		in, out := &in.TrustedCa, &out.TrustedCa
+		*out = new(MailhogTrustedCaSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstanceSettingsSpec.
+func (in *MailhogInstanceSettingsSpec) DeepCopy() *MailhogInstanceSettingsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogInstanceSettingsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogInstanceSpec) DeepCopyInto(out *MailhogInstanceSpec) {
+	*out = *in
+	in.Settings.DeepCopyInto(&out.Settings)
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = new(MailhogAccessSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Seed != nil {
+		in, out := &in.Seed, &out.Seed
+		*out = new(MailhogSeedSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstanceSpec.
+func (in *MailhogInstanceSpec) DeepCopy() *MailhogInstanceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogInstanceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogInstanceStatus) DeepCopyInto(out *MailhogInstanceStatus) {
+	*out = *in
+	in.Pods.DeepCopyInto(&out.Pods)
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(RetentionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Archive != nil {
+		in, out := &in.Archive, &out.Archive
+		*out = new(ArchiveStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Messages != nil {
+		in, out := &in.Messages, &out.Messages
+		*out = new(MessagesStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SmtpFaults != nil {
+		in, out := &in.SmtpFaults, &out.SmtpFaults
+		*out = make([]SmtpFaultStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.SmtpPolicyViolations != nil {
+		in, out := &in.SmtpPolicyViolations, &out.SmtpPolicyViolations
+		*out = new(SmtpPolicyViolationsStatus)
+		**out = **in
+	}
+	if in.SmtpRelay != nil {
+		in, out := &in.SmtpRelay, &out.SmtpRelay
+		*out = make([]SmtpRelayStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastPodRefreshTime != nil {
+		in, out := &in.LastPodRefreshTime, &out.LastPodRefreshTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Seed != nil {
+		in, out := &in.Seed, &out.Seed
+		*out = new(SeedStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Preserve != nil {
+		in, out := &in.Preserve, &out.Preserve
+		*out = new(PreserveStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SmtpVerification != nil {
+		in, out := &in.SmtpVerification, &out.SmtpVerification
+		*out = new(SmtpVerificationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Upstreams != nil {
+		in, out := &in.Upstreams, &out.Upstreams
+		*out = make([]UpstreamStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Jim != nil {
+		in, out := &in.Jim, &out.Jim
+		*out = new(JimStatus)
+		**out = **in
+	}
+	if in.Chaos != nil {
+		in, out := &in.Chaos, &out.Chaos
+		*out = new(ChaosStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstanceStatus.
+func (in *MailhogInstanceStatus) DeepCopy() *MailhogInstanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogInstanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogJimSpec) DeepCopyInto(out *MailhogJimSpec) {
+	*out = *in
+	if in.LinkspeedMinBytes != nil {
+		in, out := &in.LinkspeedMinBytes, &out.LinkspeedMinBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.LinkspeedMaxBytes != nil {
+		in, out := &in.LinkspeedMaxBytes, &out.LinkspeedMaxBytes
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogJimSpec.
+func (in *MailhogJimSpec) DeepCopy() *MailhogJimSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogJimSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogLoadTest) DeepCopyInto(out *MailhogLoadTest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogLoadTest.
+func (in *MailhogLoadTest) DeepCopy() *MailhogLoadTest {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogLoadTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogLoadTest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogLoadTestList) DeepCopyInto(out *MailhogLoadTestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MailhogLoadTest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogLoadTestList.
+func (in *MailhogLoadTestList) DeepCopy() *MailhogLoadTestList {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogLoadTestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogLoadTestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogLoadTestSpec) DeepCopyInto(out *MailhogLoadTestSpec) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogLoadTestSpec.
+func (in *MailhogLoadTestSpec) DeepCopy() *MailhogLoadTestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogLoadTestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogLoadTestStatus) DeepCopyInto(out *MailhogLoadTestStatus) {
+	*out = *in
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(LoadTestLatency)
+		**out = **in
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogLoadTestStatus.
+func (in *MailhogLoadTestStatus) DeepCopy() *MailhogLoadTestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogLoadTestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogOidcSpec) DeepCopyInto(out *MailhogOidcSpec) {
+	*out = *in
+	in.ClientSecret.DeepCopyInto(&out.ClientSecret)
+	if in.AllowedGroups != nil {
+		in, out := &in.AllowedGroups, &out.AllowedGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedEmails != nil {
+		in, out := &in.AllowedEmails, &out.AllowedEmails
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogOidcSpec.
+func (in *MailhogOidcSpec) DeepCopy() *MailhogOidcSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogOidcSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogRbacAuthSpec) DeepCopyInto(out *MailhogRbacAuthSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogRbacAuthSpec.
+func (in *MailhogRbacAuthSpec) DeepCopy() *MailhogRbacAuthSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogRbacAuthSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogRestore) DeepCopyInto(out *MailhogRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogRestore.
+func (in *MailhogRestore) DeepCopy() *MailhogRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogRestoreList) DeepCopyInto(out *MailhogRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MailhogRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogRestoreList.
+func (in *MailhogRestoreList) DeepCopy() *MailhogRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogRestoreSpec) DeepCopyInto(out *MailhogRestoreSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogRestoreSpec.
+func (in *MailhogRestoreSpec) DeepCopy() *MailhogRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogRestoreStatus) DeepCopyInto(out *MailhogRestoreStatus) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogRestoreStatus.
+func (in *MailhogRestoreStatus) DeepCopy() *MailhogRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogRetentionSpec) DeepCopyInto(out *MailhogRetentionSpec) {
+	*out = *in
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogRetentionSpec.
+func (in *MailhogRetentionSpec) DeepCopy() *MailhogRetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogRetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSeedMessage) DeepCopyInto(out *MailhogSeedMessage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSeedMessage.
+func (in *MailhogSeedMessage) DeepCopy() *MailhogSeedMessage {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogSeedMessage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSeedSpec) DeepCopyInto(out *MailhogSeedSpec) {
+	*out = *in
+	if in.ConfigMaps != nil {
+		in, out := &in.ConfigMaps, &out.ConfigMaps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Messages != nil {
+		in, out := &in.Messages, &out.Messages
+		*out = make([]MailhogSeedMessage, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSeedSpec.
+func (in *MailhogSeedSpec) DeepCopy() *MailhogSeedSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogSeedSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSmtpFaultSpec) DeepCopyInto(out *MailhogSmtpFaultSpec) {
+	*out = *in
+	if in.Delay != nil {
+		in, out := &in.Delay, &out.Delay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSmtpFaultSpec.
+func (in *MailhogSmtpFaultSpec) DeepCopy() *MailhogSmtpFaultSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogSmtpFaultSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSmtpPolicySpec) DeepCopyInto(out *MailhogSmtpPolicySpec) {
+	*out = *in
+	if in.AllowedSenderDomains != nil {
+		in, out := &in.AllowedSenderDomains, &out.AllowedSenderDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSmtpPolicySpec.
+func (in *MailhogSmtpPolicySpec) DeepCopy() *MailhogSmtpPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogSmtpPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSmtpRelayRuleSpec) DeepCopyInto(out *MailhogSmtpRelayRuleSpec) {
+	*out = *in
+	if in.Recipients != nil {
+		in, out := &in.Recipients, &out.Recipients
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSmtpRelayRuleSpec.
+func (in *MailhogSmtpRelayRuleSpec) DeepCopy() *MailhogSmtpRelayRuleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogSmtpRelayRuleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSmtpRelaySpec) DeepCopyInto(out *MailhogSmtpRelaySpec) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]MailhogSmtpRelayRuleSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RetryInterval != nil {
+		in, out := &in.RetryInterval, &out.RetryInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSmtpRelaySpec.
+func (in *MailhogSmtpRelaySpec) DeepCopy() *MailhogSmtpRelaySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogSmtpRelaySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSmtpTlsSpec) DeepCopyInto(out *MailhogSmtpTlsSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSmtpTlsSpec.
+func (in *MailhogSmtpTlsSpec) DeepCopy() *MailhogSmtpTlsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogSmtpTlsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSmtpTranscriptsSpec) DeepCopyInto(out *MailhogSmtpTranscriptsSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSmtpTranscriptsSpec.
+func (in *MailhogSmtpTranscriptsSpec) DeepCopy() *MailhogSmtpTranscriptsSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogFilesSpec)
+	out := new(MailhogSmtpTranscriptsSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogInstance) DeepCopyInto(out *MailhogInstance) {
+func (in *MailhogSnapshot) DeepCopyInto(out *MailhogSnapshot) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -105,18 +1387,18 @@ func (in *MailhogInstance) DeepCopyInto(out *MailhogInstance) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstance.
-func (in *MailhogInstance) DeepCopy() *MailhogInstance {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSnapshot.
+func (in *MailhogSnapshot) DeepCopy() *MailhogSnapshot {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogInstance)
+	out := new(MailhogSnapshot)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MailhogInstance) DeepCopyObject() runtime.Object {
+func (in *MailhogSnapshot) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -124,31 +1406,31 @@ func (in *MailhogInstance) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogInstanceList) DeepCopyInto(out *MailhogInstanceList) {
+func (in *MailhogSnapshotList) DeepCopyInto(out *MailhogSnapshotList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]MailhogInstance, len(*in))
+		*out = make([]MailhogSnapshot, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstanceList.
-func (in *MailhogInstanceList) DeepCopy() *MailhogInstanceList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSnapshotList.
+func (in *MailhogSnapshotList) DeepCopy() *MailhogSnapshotList {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogInstanceList)
+	out := new(MailhogSnapshotList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MailhogInstanceList) DeepCopyObject() runtime.Object {
+func (in *MailhogSnapshotList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -156,112 +1438,174 @@ func (in *MailhogInstanceList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogInstanceSettingsSpec) DeepCopyInto(out *MailhogInstanceSettingsSpec) {
+func (in *MailhogSnapshotSpec) DeepCopyInto(out *MailhogSnapshotSpec) {
 	*out = *in
-	out.StorageMongoDb = in.StorageMongoDb
-	out.StorageMaildir = in.StorageMaildir
-	if in.Files != nil {
-		in, out := &in.Files, &out.Files
-		*out = new(MailhogFilesSpec)
-		(*in).DeepCopyInto(*out)
+	if in.PersistentVolumeClaim != nil {
+		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
+		*out = new(SnapshotVolumeSpec)
+		**out = **in
 	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(v1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSnapshotSpec.
+func (in *MailhogSnapshotSpec) DeepCopy() *MailhogSnapshotSpec {
+	if in == nil {
+		return nil
 	}
-	if in.Affinity != nil {
-		in, out := &in.Affinity, &out.Affinity
-		*out = new(AffinitySpec)
-		(*in).DeepCopyInto(*out)
+	out := new(MailhogSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogSnapshotStatus) DeepCopyInto(out *MailhogSnapshotStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
-	out.Jim = in.Jim
-	out.Ingress = in.Ingress
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstanceSettingsSpec.
-func (in *MailhogInstanceSettingsSpec) DeepCopy() *MailhogInstanceSettingsSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogSnapshotStatus.
+func (in *MailhogSnapshotStatus) DeepCopy() *MailhogSnapshotStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogInstanceSettingsSpec)
+	out := new(MailhogSnapshotStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogInstanceSpec) DeepCopyInto(out *MailhogInstanceSpec) {
+func (in *MailhogStorageMaildirSpec) DeepCopyInto(out *MailhogStorageMaildirSpec) {
 	*out = *in
-	in.Settings.DeepCopyInto(&out.Settings)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstanceSpec.
-func (in *MailhogInstanceSpec) DeepCopy() *MailhogInstanceSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogStorageMaildirSpec.
+func (in *MailhogStorageMaildirSpec) DeepCopy() *MailhogStorageMaildirSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogInstanceSpec)
+	out := new(MailhogStorageMaildirSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogInstanceStatus) DeepCopyInto(out *MailhogInstanceStatus) {
+func (in *MailhogStorageMongoDbSpec) DeepCopyInto(out *MailhogStorageMongoDbSpec) {
 	*out = *in
-	in.Pods.DeepCopyInto(&out.Pods)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogInstanceStatus.
-func (in *MailhogInstanceStatus) DeepCopy() *MailhogInstanceStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogStorageMongoDbSpec.
+func (in *MailhogStorageMongoDbSpec) DeepCopy() *MailhogStorageMongoDbSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogInstanceStatus)
+	out := new(MailhogStorageMongoDbSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogJimSpec) DeepCopyInto(out *MailhogJimSpec) {
+func (in *MailhogTrustedCaSpec) DeepCopyInto(out *MailhogTrustedCaSpec) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogJimSpec.
-func (in *MailhogJimSpec) DeepCopy() *MailhogJimSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogTrustedCaSpec.
+func (in *MailhogTrustedCaSpec) DeepCopy() *MailhogTrustedCaSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogJimSpec)
+	out := new(MailhogTrustedCaSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogStorageMaildirSpec) DeepCopyInto(out *MailhogStorageMaildirSpec) {
+func (in *MailhogUpstream) DeepCopyInto(out *MailhogUpstream) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogStorageMaildirSpec.
-func (in *MailhogStorageMaildirSpec) DeepCopy() *MailhogStorageMaildirSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogUpstream.
+func (in *MailhogUpstream) DeepCopy() *MailhogUpstream {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogStorageMaildirSpec)
+	out := new(MailhogUpstream)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogUpstream) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailhogStorageMongoDbSpec) DeepCopyInto(out *MailhogStorageMongoDbSpec) {
+func (in *MailhogUpstreamList) DeepCopyInto(out *MailhogUpstreamList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MailhogUpstream, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogStorageMongoDbSpec.
-func (in *MailhogStorageMongoDbSpec) DeepCopy() *MailhogStorageMongoDbSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogUpstreamList.
+func (in *MailhogUpstreamList) DeepCopy() *MailhogUpstreamList {
 	if in == nil {
 		return nil
 	}
-	out := new(MailhogStorageMongoDbSpec)
+	out := new(MailhogUpstreamList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailhogUpstreamList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogUpstreamRefSpec) DeepCopyInto(out *MailhogUpstreamRefSpec) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogUpstreamRefSpec.
+func (in *MailhogUpstreamRefSpec) DeepCopy() *MailhogUpstreamRefSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogUpstreamRefSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -269,6 +1613,11 @@ func (in *MailhogStorageMongoDbSpec) DeepCopy() *MailhogStorageMongoDbSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MailhogUpstreamSpec) DeepCopyInto(out *MailhogUpstreamSpec) {
 	*out = *in
+	if in.Tls != nil {
+		in, out := &in.Tls, &out.Tls
+		*out = new(MailhogUpstreamTlsSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogUpstreamSpec.
@@ -281,6 +1630,31 @@ func (in *MailhogUpstreamSpec) DeepCopy() *MailhogUpstreamSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailhogUpstreamTlsSpec) DeepCopyInto(out *MailhogUpstreamTlsSpec) {
+	*out = *in
+	if in.CaConfigMapKeyRef != nil {
+		in, out := &in.CaConfigMapKeyRef, &out.CaConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CaSecretKeyRef != nil {
+		in, out := &in.CaSecretKeyRef, &out.CaSecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailhogUpstreamTlsSpec.
+func (in *MailhogUpstreamTlsSpec) DeepCopy() *MailhogUpstreamTlsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailhogUpstreamTlsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MailhogWebUserSpec) DeepCopyInto(out *MailhogWebUserSpec) {
 	*out = *in
@@ -296,6 +1670,25 @@ func (in *MailhogWebUserSpec) DeepCopy() *MailhogWebUserSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MessagesStatus) DeepCopyInto(out *MessagesStatus) {
+	*out = *in
+	if in.LastReceived != nil {
+		in, out := &in.LastReceived, &out.LastReceived
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MessagesStatus.
+func (in *MessagesStatus) DeepCopy() *MessagesStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MessagesStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
 	*out = *in
@@ -361,3 +1754,173 @@ func (in *PodStatus) DeepCopy() *PodStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreserveStatus) DeepCopyInto(out *PreserveStatus) {
+	*out = *in
+	if in.ExportTime != nil {
+		in, out := &in.ExportTime, &out.ExportTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SourcePods != nil {
+		in, out := &in.SourcePods, &out.SourcePods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RestoredPods != nil {
+		in, out := &in.RestoredPods, &out.RestoredPods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreserveStatus.
+func (in *PreserveStatus) DeepCopy() *PreserveStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PreserveStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetentionStatus) DeepCopyInto(out *RetentionStatus) {
+	*out = *in
+	if in.LastPurgeTime != nil {
+		in, out := &in.LastPurgeTime, &out.LastPurgeTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetentionStatus.
+func (in *RetentionStatus) DeepCopy() *RetentionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RetentionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SeedStatus) DeepCopyInto(out *SeedStatus) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SeedStatus.
+func (in *SeedStatus) DeepCopy() *SeedStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SeedStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SmtpFaultStatus) DeepCopyInto(out *SmtpFaultStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmtpFaultStatus.
+func (in *SmtpFaultStatus) DeepCopy() *SmtpFaultStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SmtpFaultStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SmtpPolicyViolationsStatus) DeepCopyInto(out *SmtpPolicyViolationsStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmtpPolicyViolationsStatus.
+func (in *SmtpPolicyViolationsStatus) DeepCopy() *SmtpPolicyViolationsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SmtpPolicyViolationsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SmtpRelayStatus) DeepCopyInto(out *SmtpRelayStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmtpRelayStatus.
+func (in *SmtpRelayStatus) DeepCopy() *SmtpRelayStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SmtpRelayStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SmtpVerificationStatus) DeepCopyInto(out *SmtpVerificationStatus) {
+	*out = *in
+	if in.LastVerificationTime != nil {
+		in, out := &in.LastVerificationTime, &out.LastVerificationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmtpVerificationStatus.
+func (in *SmtpVerificationStatus) DeepCopy() *SmtpVerificationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SmtpVerificationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotVolumeSpec) DeepCopyInto(out *SnapshotVolumeSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotVolumeSpec.
+func (in *SnapshotVolumeSpec) DeepCopy() *SnapshotVolumeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotVolumeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpstreamStatus) DeepCopyInto(out *UpstreamStatus) {
+	*out = *in
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpstreamStatus.
+func (in *UpstreamStatus) DeepCopy() *UpstreamStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpstreamStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// Mode defines if messages are exported from mailhog or imported into mailhog
+type Mode string
+
+// Format defines how messages are stored in an archive
+type Format string
+
+const (
+	// ExportMode reads all messages from the mailhog apis and writes them to the archive directory
+	ExportMode Mode = "export"
+
+	// ImportMode reads the messages from the archive directory and delivers them to the smtp addresses
+	ImportMode Mode = "import"
+
+	// MboxFormat stores all messages in a single mboxrd file
+	MboxFormat Format = "mbox"
+
+	// EmlFormat stores every message in its own .eml file
+	EmlFormat Format = "eml"
+
+	// ConfigEnv is the environment variable the archive tool reads its json config from
+	ConfigEnv = "MAILHOG_ARCHIVE_CONFIG"
+
+	// PasswordEnv is the environment variable containing the password for the mailhog apis and the smtp user
+	//#nosec G101
+	PasswordEnv = "MAILHOG_ARCHIVE_PASSWORD"
+)
+
+// Config is the configuration of a single archive tool run, passed as json in ConfigEnv
+type Config struct {
+	// Mode selects export or import
+	Mode Mode `json:"mode"`
+
+	// Format of the archive
+	Format Format `json:"format"`
+
+	// Path is the directory of the archive
+	Path string `json:"path"`
+
+	// Apis are the base urls of the mailhog apis messages are exported from
+	Apis []string `json:"apis,omitempty"`
+
+	// User is the basic auth user for the mailhog apis, the password is read from PasswordEnv
+	User string `json:"user,omitempty"`
+
+	// Password is the basic auth password for the mailhog apis and the smtp user
+	Password string `json:"-"`
+
+	// Smtp are the smtp addresses messages are imported to
+	Smtp []string `json:"smtp,omitempty"`
+
+	// SmtpUser authenticates the smtp sessions with AUTH PLAIN if set, the password is read from PasswordEnv
+	SmtpUser string `json:"smtpUser,omitempty"`
+
+	// FallbackAddress is used as envelope address if a message has no sender or recipients
+	FallbackAddress string `json:"fallbackAddress,omitempty"`
+
+	// ResultFile is where the json encoded Result is written to, eg "/dev/termination-log"
+	ResultFile string `json:"resultFile,omitempty"`
+}
+
+// Result describes what a run exported or imported
+type Result struct {
+	// Messages is the count of exported or imported messages
+	Messages int `json:"messages"`
+
+	// Bytes is the size of the archive
+	Bytes int64 `json:"bytes"`
+}
+
+// LoadConfig reads the archive config from the environment
+func LoadConfig() (cfg Config, err error) {
+	raw, found := os.LookupEnv(ConfigEnv)
+	if !found {
+		return cfg, errMissingConfig
+	}
+	if err = json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return cfg, err
+	}
+	cfg.Password = os.Getenv(PasswordEnv)
+	return cfg, cfg.validate()
+}
+
+// validate returns an error if the config can not be run
+func (c Config) validate() error {
+	if c.Path == "" {
+		return errMissingPath
+	}
+	switch c.Format {
+	case MboxFormat, EmlFormat:
+	default:
+		return errUnknownFormat
+	}
+	switch c.Mode {
+	case ExportMode:
+		if len(c.Apis) == 0 {
+			return errMissingApis
+		}
+	case ImportMode:
+		if len(c.Smtp) == 0 {
+			return errMissingSmtp
+		}
+	default:
+		return errUnknownMode
+	}
+	return nil
+}
+
+var (
+	errMissingConfig = errors.New("no archive config found in " + ConfigEnv)
+	errMissingPath   = errors.New("no archive path configured")
+	errMissingApis   = errors.New("no mailhog apis configured to export from")
+	errMissingSmtp   = errors.New("no smtp addresses configured to import to")
+	errUnknownFormat = errors.New("unknown archive format")
+	errUnknownMode   = errors.New("unknown archive mode")
+)
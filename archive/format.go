@@ -0,0 +1,174 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MboxFileName is the name of the file containing all messages in mbox format
+	MboxFileName = "messages.mbox"
+
+	// EmlFileSuffix is the suffix of the message files in eml format
+	EmlFileSuffix = ".eml"
+
+	mboxUnknownSender = "MAILER-DAEMON"
+	mboxDateLayout    = "Mon Jan _2 15:04:05 2006"
+)
+
+// Message is a single raw message
+type Message struct {
+	// ID is the mailhog id of the message, empty for imported messages
+	ID string
+
+	// From is the envelope sender, if known
+	From string
+
+	// To are the envelope recipients, if known
+	To []string
+
+	// Created is the time mailhog received the message
+	Created time.Time
+
+	// Data is the raw message including all headers
+	Data []byte
+}
+
+var (
+	mboxFromLine   = regexp.MustCompile(`^>*From `)
+	mboxQuotedLine = regexp.MustCompile(`^>+From `)
+)
+
+// Encode returns the archive files of the messages in the given format, keyed by file name
+func Encode(format Format, messages []Message) (files map[string][]byte, err error) {
+	files = make(map[string][]byte)
+	switch format {
+	case MboxFormat:
+		files[MboxFileName] = encodeMbox(messages)
+	case EmlFormat:
+		for i, message := range messages {
+			files[fmt.Sprintf("%06d%s", i, EmlFileSuffix)] = message.Data
+		}
+	default:
+		return nil, errUnknownFormat
+	}
+	return files, nil
+}
+
+// Decode returns the messages of the archive files in the given format
+func Decode(format Format, files map[string][]byte) (messages []Message, err error) {
+	switch format {
+	case MboxFormat:
+		data, found := files[MboxFileName]
+		if !found {
+			return nil, errMissingMbox
+		}
+		return decodeMbox(data), nil
+	case EmlFormat:
+		names := make([]string, 0, len(files))
+		for name := range files {
+			if strings.HasSuffix(name, EmlFileSuffix) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			messages = append(messages, Message{Data: files[name]})
+		}
+		return messages, nil
+	default:
+		return nil, errUnknownFormat
+	}
+}
+
+// Size returns the summed size of the archive files
+func Size(files map[string][]byte) (size int64) {
+	for _, data := range files {
+		size += int64(len(data))
+	}
+	return size
+}
+
+// encodeMbox writes the messages in mboxrd format, lines starting with "From " are quoted with ">"
+func encodeMbox(messages []Message) []byte {
+	buf := &bytes.Buffer{}
+	for _, message := range messages {
+		sender := strings.Join(strings.Fields(message.From), "")
+		if sender == "" {
+			sender = mboxUnknownSender
+		}
+		created := message.Created
+		if created.IsZero() {
+			created = time.Unix(0, 0)
+		}
+		buf.WriteString("From " + sender + " " + created.UTC().Format(mboxDateLayout) + "\n")
+
+		data := message.Data
+		if len(data) > 0 && data[len(data)-1] == '\n' {
+			data = data[:len(data)-1]
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if mboxFromLine.Match(line) {
+				buf.WriteByte('>')
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// decodeMbox reads messages in mboxrd format
+func decodeMbox(data []byte) (messages []Message) {
+	var current *Message
+	var lines [][]byte
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+			lines = lines[:len(lines)-1]
+		}
+		current.Data = append(bytes.Join(lines, []byte("\n")), '\n')
+		messages = append(messages, *current)
+	}
+
+	if len(data) > 0 && data[len(data)-1] == '\n' {
+		data = data[:len(data)-1]
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("From ")) {
+			finish()
+			current = &Message{}
+			lines = nil
+			fields := strings.SplitN(strings.TrimSpace(string(line[len("From "):])), " ", 2)
+			if fields[0] != mboxUnknownSender {
+				current.From = fields[0]
+			}
+			if len(fields) == 2 {
+				if created, err := time.Parse(mboxDateLayout, strings.TrimSpace(fields[1])); err == nil {
+					current.Created = created
+				}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if mboxQuotedLine.Match(line) {
+			line = line[1:]
+		}
+		lines = append(lines, line)
+	}
+	finish()
+	return messages
+}
+
+var errMissingMbox = errors.New("archive does not contain " + MboxFileName)
@@ -0,0 +1,244 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const fetchPageSize = 250
+
+// apiMessages is a page of messages returned by the mailhog v2 api
+type apiMessages struct {
+	Total int          `json:"total"`
+	Items []apiMessage `json:"items"`
+}
+
+// apiMessage contains the fields of a v2 api message needed to archive it
+type apiMessage struct {
+	ID      string    `json:"ID"`
+	Created time.Time `json:"Created"`
+	Raw     struct {
+		From string   `json:"From"`
+		To   []string `json:"To"`
+		Data string   `json:"Data"`
+	} `json:"Raw"`
+}
+
+// Fetch returns all messages of a mailhog api, oldest first
+func Fetch(ctx context.Context, client *http.Client, baseUrl, user, password string) (messages []Message, err error) {
+	err = fetchPages(ctx, client, baseUrl, user, password, func(page []Message) bool {
+		messages = append(messages, page...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Merge(messages), nil
+}
+
+// FetchAfter returns the oldest messages of a mailhog api created at or after the time, at most limit, oldest first
+// the api lists the newest messages first, so only the pages up to the first older message are fetched
+// more is true if newer messages were left out because of the limit
+func FetchAfter(ctx context.Context, client *http.Client, baseUrl, user, password string, after time.Time, limit int) (messages []Message, more bool, err error) {
+	err = fetchPages(ctx, client, baseUrl, user, password, func(page []Message) bool {
+		reached := false
+		for _, message := range page {
+			if message.Created.Before(after) {
+				reached = true
+				break
+			}
+			messages = append(messages, message)
+		}
+		// only the oldest messages are kept, the newer ones are fetched again by the next run
+		if len(messages) > limit {
+			messages = append([]Message(nil), messages[len(messages)-limit:]...)
+			more = true
+		}
+		return !reached
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return Merge(messages), more, nil
+}
+
+// fetchPages passes the pages of a mailhog api newest first to visit until it returns false or all pages are fetched
+func fetchPages(ctx context.Context, client *http.Client, baseUrl, user, password string, visit func(page []Message) bool) error {
+	// advance by the received items, an api may return less than the requested limit
+	for start := 0; ; {
+		query := url.Values{}
+		query.Set("start", strconv.Itoa(start))
+		query.Set("limit", strconv.Itoa(fetchPageSize))
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseUrl+"/api/v2/messages?"+query.Encode(), http.NoBody)
+		if err != nil {
+			return err
+		}
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			return fmt.Errorf("%w: %s returned %d", errFetch, baseUrl, resp.StatusCode)
+		}
+		page := apiMessages{}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		messages := make([]Message, 0, len(page.Items))
+		for _, item := range page.Items {
+			messages = append(messages, Message{
+				ID:      item.ID,
+				From:    item.Raw.From,
+				To:      item.Raw.To,
+				Created: item.Created,
+				Data:    []byte(item.Raw.Data),
+			})
+		}
+		start += len(page.Items)
+		if !visit(messages) || len(page.Items) == 0 || start >= page.Total {
+			return nil
+		}
+	}
+}
+
+// Merge returns the messages of all lists without duplicate ids, oldest first
+// messages of separate pods with shared storage are only archived once
+func Merge(lists ...[]Message) (merged []Message) {
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, message := range list {
+			if message.ID != "" {
+				if seen[message.ID] {
+					continue
+				}
+				seen[message.ID] = true
+			}
+			merged = append(merged, message)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Created.Before(merged[j].Created)
+	})
+	return merged
+}
+
+// Deliver sends the messages to an smtp address in one session
+// envelope sender and recipients are taken from the message, with the fallback address for missing ones
+// the session is authenticated first if auth is set
+func Deliver(address string, auth smtp.Auth, timeout time.Duration, fallback string, messages []Message) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+	host, _, _ := net.SplitHostPort(address)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if auth != nil {
+		if err = c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	for _, message := range messages {
+		from, to := Envelope(message, fallback)
+		if err = c.Mail(from); err != nil {
+			return err
+		}
+		for _, recipient := range to {
+			if err = c.Rcpt(recipient); err != nil {
+				return err
+			}
+		}
+		w, err := c.Data()
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(message.Data); err != nil {
+			return err
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+	}
+	return c.Quit()
+}
+
+// PlainAuth returns an smtp.Auth sending user and password with AUTH PLAIN, nil if no user is set
+// unlike smtp.PlainAuth it does not insist on tls, the smtp proxy's operator listener is reached inside the cluster
+func PlainAuth(user, password string) smtp.Auth {
+	if user == "" {
+		return nil
+	}
+	return &plainAuth{user: user, password: password}
+}
+
+// plainAuth is the AUTH PLAIN mechanism without the tls check of smtp.PlainAuth
+type plainAuth struct {
+	user     string
+	password string
+}
+
+// Start begins the exchange with the initial response "\0user\0password"
+func (a *plainAuth) Start(_ *smtp.ServerInfo) (string, []byte, error) {
+	return "PLAIN", []byte("\x00" + a.user + "\x00" + a.password), nil
+}
+
+// Next fails on any challenge, PLAIN is done after the initial response
+func (a *plainAuth) Next(_ []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errUnexpectedChallenge
+	}
+	return nil, nil
+}
+
+// Envelope returns the envelope sender and recipients of a message
+// a known envelope is preferred, otherwise the addresses are taken from the headers
+func Envelope(message Message, fallback string) (from string, to []string) {
+	from, to = message.From, message.To
+	msg, err := mail.ReadMessage(strings.NewReader(string(message.Data)))
+	if err == nil {
+		if addresses, err := msg.Header.AddressList("From"); from == "" && err == nil && len(addresses) > 0 {
+			from = addresses[0].Address
+		}
+		for _, header := range []string{"To", "Cc", "Bcc"} {
+			if addresses, err := msg.Header.AddressList(header); len(message.To) == 0 && err == nil {
+				for _, address := range addresses {
+					to = append(to, address.Address)
+				}
+			}
+		}
+	}
+	if from == "" {
+		from = fallback
+	}
+	if len(to) == 0 {
+		to = []string{fallback}
+	}
+	return from, to
+}
+
+var errUnexpectedChallenge = errors.New("the smtp server sent a challenge after the PLAIN response")
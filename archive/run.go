@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Run exports or imports the messages as configured and writes the result to the result file
+func Run(ctx context.Context, cfg Config, client *http.Client) (result Result, err error) {
+	switch cfg.Mode {
+	case ExportMode:
+		result, err = export(ctx, cfg, client)
+	case ImportMode:
+		result, err = load(cfg, client.Timeout)
+	default:
+		return result, errUnknownMode
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if cfg.ResultFile != "" {
+		raw, _ := json.Marshal(result)
+		if err = os.WriteFile(cfg.ResultFile, raw, 0o600); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// export writes the messages of all apis to the archive directory
+func export(ctx context.Context, cfg Config, client *http.Client) (result Result, err error) {
+	lists := make([][]Message, 0, len(cfg.Apis))
+	for _, api := range cfg.Apis {
+		messages, err := Fetch(ctx, client, api, cfg.User, cfg.Password)
+		if err != nil {
+			return result, err
+		}
+		lists = append(lists, messages)
+	}
+	messages := Merge(lists...)
+
+	files, err := Encode(cfg.Format, messages)
+	if err != nil {
+		return result, err
+	}
+	if err = WriteDir(cfg.Path, files); err != nil {
+		return result, err
+	}
+	return Result{Messages: len(messages), Bytes: Size(files)}, nil
+}
+
+// load delivers the messages of the archive directory to all smtp addresses
+func load(cfg Config, timeout time.Duration) (result Result, err error) {
+	files, err := ReadDir(cfg.Path)
+	if err != nil {
+		return result, err
+	}
+	messages, err := Decode(cfg.Format, files)
+	if err != nil {
+		return result, err
+	}
+	for _, address := range cfg.Smtp {
+		if err = Deliver(address, PlainAuth(cfg.SmtpUser, cfg.Password), timeout, cfg.FallbackAddress, messages); err != nil {
+			return result, err
+		}
+	}
+	return Result{Messages: len(messages), Bytes: Size(files)}, nil
+}
+
+// WriteDir replaces the archive files in a directory, files of previous archives are removed
+func WriteDir(path string, files map[string][]byte) error {
+	if err := os.MkdirAll(path, 0o750); err != nil {
+		return err
+	}
+	existing, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range existing {
+		if _, keep := files[entry.Name()]; !keep && !entry.IsDir() && archiveFile(entry.Name()) {
+			if err = os.Remove(filepath.Join(path, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	for name, data := range files {
+		if err = os.WriteFile(filepath.Join(path, name), data, 0o640); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDir returns the archive files of a directory
+func ReadDir(path string) (files map[string][]byte, err error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files = make(map[string][]byte)
+	for _, entry := range entries {
+		if entry.IsDir() || !archiveFile(entry.Name()) {
+			continue
+		}
+		//#nosec G304 -- the archive directory is chosen by the operator
+		if files[entry.Name()], err = os.ReadFile(filepath.Join(path, entry.Name())); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// archiveFile returns true if the file name belongs to an archive
+func archiveFile(name string) bool {
+	return name == MboxFileName || filepath.Ext(name) == EmlFileSuffix
+}
+
+var errFetch = errors.New("failed to fetch messages")
@@ -0,0 +1,163 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm  = "AWS4-HMAC-SHA256"
+	s3Service    = "s3"
+	s3DateFormat = "20060102T150405Z"
+
+	// EmlContentType is the content type of uploaded messages
+	EmlContentType = "message/rfc822"
+
+	// IndexContentType is the content type of uploaded indexes
+	IndexContentType = "application/json"
+)
+
+// Bucket is a bucket of an s3 compatible object storage, addressed with path style urls
+type Bucket struct {
+	// Endpoint is the base url of the object storage, e.g. http://minio:9000
+	Endpoint string
+
+	// Region is used for the request signature
+	Region string
+
+	// Name of the bucket
+	Name string
+
+	// AccessKeyID and SecretAccessKey are the credentials the requests are signed with
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// IndexEntry describes an uploaded message in the json index of an upload run
+type IndexEntry struct {
+	Key     string    `json:"key"`
+	ID      string    `json:"id"`
+	From    string    `json:"from"`
+	To      []string  `json:"to"`
+	Subject string    `json:"subject,omitempty"`
+	Created time.Time `json:"created"`
+	Size    int       `json:"size"`
+}
+
+// Put uploads an object, signed with signature version 4
+func (b Bucket) Put(ctx context.Context, client *http.Client, key, contentType string, body []byte) error {
+	path := "/" + uriEncode(b.Name, false) + "/" + uriEncode(key, true)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimSuffix(b.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	sum := sha256.Sum256(body)
+	signRequest(req, hex.EncodeToString(sum[:]), b, time.Now(), nil)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%w: %s returned %d %s", errUpload, key, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// NewIndexEntry returns the index entry of a message uploaded to key
+func NewIndexEntry(key string, message Message) IndexEntry {
+	from, to := Envelope(message, "")
+	if len(to) == 1 && to[0] == "" {
+		to = nil
+	}
+	entry := IndexEntry{Key: key, ID: message.ID, From: from, To: to, Created: message.Created, Size: len(message.Data)}
+	if msg, err := mail.ReadMessage(bytes.NewReader(message.Data)); err == nil {
+		entry.Subject = msg.Header.Get("Subject")
+	}
+	return entry
+}
+
+// EncodeIndex returns the json index of an upload run
+func EncodeIndex(entries []IndexEntry) ([]byte, error) {
+	return json.MarshalIndent(entries, "", "  ")
+}
+
+// signRequest adds the signature version 4 headers to a request
+// the host, x-amz-content-sha256 and x-amz-date headers are always signed, additionally the given headers
+func signRequest(req *http.Request, payloadHash string, b Bucket, now time.Time, headers []string) {
+	amzDate := now.UTC().Format(s3DateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := append([]string{"host", "x-amz-content-sha256", "x-amz-date"}, headers...)
+	sort.Strings(signed)
+	canonicalHeaders := ""
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders += name + ":" + strings.TrimSpace(value) + "\n"
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := amzDate[:8] + "/" + b.Region + "/" + s3Service + "/aws4_request"
+	stringToSign := s3Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSha256([]byte("AWS4"+b.SecretAccessKey), amzDate[:8])
+	for _, part := range []string{b.Region, s3Service, "aws4_request"} {
+		key = hmacSha256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", s3Algorithm+" Credential="+b.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode escapes everything except unreserved characters like the signature requires, keeping slashes if wanted
+func uriEncode(s string, keepSlash bool) string {
+	encoded := strings.Builder{}
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && keepSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+var errUpload = errors.New("failed to upload object")
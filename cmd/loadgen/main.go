@@ -0,0 +1,58 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"goimports.patrick.mx/mailhog-operator/loadgen"
+)
+
+var setupLog = ctrl.Log.WithName("loadgen")
+
+const (
+	errLoadConfig = "unable to load load generator config"
+	errRunLoad    = "load generator run failed"
+)
+
+func main() {
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	cfg, err := loadgen.LoadConfig()
+	if err != nil {
+		errExit(err, errLoadConfig)
+	}
+
+	result, err := loadgen.Run(ctrl.SetupSignalHandler(), cfg)
+	if err != nil {
+		errExit(err, errRunLoad)
+	}
+	setupLog.Info("load generator run finished", "address", cfg.Address, "sent", result.Sent, "errors", result.Errors,
+		"throughput", result.Throughput, "p95", result.Latency.P95.String())
+}
+
+func errExit(err error, msg string) {
+	setupLog.Error(err, msg)
+	os.Exit(1)
+}
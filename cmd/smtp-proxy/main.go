@@ -0,0 +1,61 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"goimports.patrick.mx/mailhog-operator/smtpproxy"
+)
+
+var setupLog = ctrl.Log.WithName("smtp-proxy")
+
+const (
+	errLoadConfig  = "unable to load proxy config"
+	errCreateProxy = "unable to create proxy"
+	errRunProxy    = "proxy stopped with error"
+)
+
+func main() {
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	cfg, err := smtpproxy.LoadConfig()
+	if err != nil {
+		errExit(err, errLoadConfig)
+	}
+
+	proxy, err := smtpproxy.New(cfg, setupLog)
+	if err != nil {
+		errExit(err, errCreateProxy)
+	}
+
+	if err := proxy.Run(ctrl.SetupSignalHandler()); err != nil {
+		errExit(err, errRunProxy)
+	}
+}
+
+func errExit(err error, msg string) {
+	setupLog.Error(err, msg)
+	os.Exit(1)
+}
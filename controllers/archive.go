@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// archiveFormat returns the archive format of a snapshot, falling back to the default
+func archiveFormat(snapshot *mailhogv1alpha1.MailhogSnapshot) archive.Format {
+	if snapshot.Spec.Format == mailhogv1alpha1.MboxSnapshotFormat {
+		return archive.MboxFormat
+	}
+	return archive.EmlFormat
+}
+
+// archivePath returns the directory of a snapshot inside the mounted PVC
+func archivePath(snapshot *mailhogv1alpha1.MailhogSnapshot) string {
+	path := snapshot.Name
+	if pvc := snapshot.Spec.PersistentVolumeClaim; pvc != nil && pvc.Path != "" {
+		path = strings.Trim(pvc.Path, "/")
+	}
+	return archiveMount + "/" + path
+}
+
+// archiveFinished returns true if the Completed condition shows a final state, those CRs are not processed again
+func archiveFinished(conditions []metav1.Condition) bool {
+	condition := meta.FindStatusCondition(conditions, mailhogv1alpha1.CompletedCondition)
+	return condition != nil && (condition.Reason == mailhogv1alpha1.SucceededReason || condition.Reason == mailhogv1alpha1.FailedReason)
+}
+
+// setCompletedCondition sets the Completed condition, it is only true for the succeeded reason
+func setCompletedCondition(conditions *[]metav1.Condition, generation int64, reason, message string) {
+	status := metav1.ConditionFalse
+	if reason == mailhogv1alpha1.SucceededReason {
+		status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               mailhogv1alpha1.CompletedCondition,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// archiveJobState returns if a snapshot or restore Job succeeded or ran out of retries
+func archiveJobState(job *batchv1.Job) (succeeded bool, failed bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		succeeded = succeeded || condition.Type == batchv1.JobComplete
+		failed = failed || condition.Type == batchv1.JobFailed
+	}
+	return succeeded || job.Status.Succeeded > 0, failed
+}
+
+// archiveJobResult reads the result the archive tool wrote as termination message of the succeeded Job pod
+func archiveJobResult(ctx context.Context, c client.Reader, job *batchv1.Job) (result archive.Result, err error) {
+	err = jobResult(ctx, c, job, &result)
+	return result, err
+}
+
+// jobResult decodes the json termination message of the succeeded Job pod into result
+func jobResult(ctx context.Context, c client.Reader, job *batchv1.Job, result interface{}) error {
+	podList := &corev1.PodList{}
+	if err := c.List(ctx, podList, client.InNamespace(job.Namespace), client.MatchingLabels{jobNameLabel: job.Name}); err != nil {
+		return err
+	}
+	for _, pod := range podList.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode == 0 && terminated.Message != "" {
+				return json.Unmarshal([]byte(terminated.Message), result)
+			}
+		}
+	}
+	return errMissingArchiveResult
+}
+
+// archiveJobNew returns a Job running the archive tool with the given config, the PVC is mounted at the archive mount
+func archiveJobNew(objectMeta metav1.ObjectMeta, image string, cfg archive.Config, claimName string, readOnly bool, env []corev1.EnvVar) *batchv1.Job {
+	cfg.ResultFile = corev1.TerminationMessagePathDefault
+	cfgBytes, _ := json.Marshal(cfg)
+
+	backoffLimit := int32(2)
+	automountToken := false
+	nonRoot := true
+	privilegeEscalation := false
+
+	return &batchv1.Job{
+		ObjectMeta: objectMeta,
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: objectMeta.Labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automountToken,
+					Containers: []corev1.Container{
+						{
+							Name:    archiveJobContainer,
+							Image:   image,
+							Command: []string{archiveCommand},
+							Env: append([]corev1.EnvVar{
+								{Name: archive.ConfigEnv, Value: string(cfgBytes)},
+							}, env...),
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: volumeNameArchive, MountPath: archiveMount, ReadOnly: readOnly},
+							},
+							TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+							Resources:                archiveJobResources(),
+							SecurityContext: &corev1.SecurityContext{
+								RunAsNonRoot:             &nonRoot,
+								AllowPrivilegeEscalation: &privilegeEscalation,
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: volumeNameArchive,
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: claimName,
+									ReadOnly:  readOnly,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// archivePasswordEnv returns the env var passing the probe password of an instance with basic auth, an
+// authenticating proxy or an intercepting smtp proxy to the archive tool
+func archivePasswordEnv(cr *mailhogv1alpha1.MailhogInstance) (env []corev1.EnvVar) {
+	if !probeUserNeeded(cr) && !smtpProxyIntercepts(cr) {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{
+			Name: archive.PasswordEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: generatedSecretName(cr)},
+					Key:                  secretKeyProbePassword,
+				},
+			},
+		},
+	}
+}
+
+// archiveJobResources returns the resources of the archive tool, which keeps all messages in memory
+func archiveJobResources() corev1.ResourceRequirements {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+	resources.Requests[corev1.ResourceCPU] = resource.MustParse(defaultSidecarResourceCPU)
+	resources.Requests[corev1.ResourceMemory] = resource.MustParse(defaultArchiveResourceMem)
+	resources.Limits[corev1.ResourceMemory] = resource.MustParse(defaultArchiveResourceMem)
+	return resources
+}
+
+// archiveLabels returns the labels of the objects created for a snapshot or restore
+// they must not contain the instance label, so Job pods are not counted as mailhog pods
+func archiveLabels(kind, name string) map[string]string {
+	return map[string]string{
+		crTypeLabel:    kind,
+		managedByLabel: operatorValue,
+		createdByLabel: operatorValue,
+		archiveLabel:   name,
+	}
+}
+
+// readyPods returns the pods whose mailhog container is ready
+func readyPods(pods []corev1.Pod) (ready []corev1.Pod) {
+	for _, pod := range reachablePods(pods) {
+		if status := mailhogContainerStatus(&pod); status != nil && status.Ready {
+			ready = append(ready, pod)
+		}
+	}
+	return ready
+}
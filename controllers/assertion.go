@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+)
+
+// assertionMatcher checks single messages against the expectations of an assertion
+type assertionMatcher struct {
+	recipient string
+	subject   *regexp.Regexp
+	body      string
+	headers   map[string]string
+}
+
+// newAssertionMatcher returns the matcher of an assertion spec, invalid regular expressions are returned as error
+func newAssertionMatcher(spec *mailhogv1alpha1.MailhogAssertionSpec) (*assertionMatcher, error) {
+	matcher := &assertionMatcher{recipient: spec.Recipient, body: spec.BodyContains, headers: spec.Headers}
+	if spec.SubjectRegex != "" {
+		subject, err := regexp.Compile(spec.SubjectRegex)
+		if err != nil {
+			return nil, err
+		}
+		matcher.subject = subject
+	}
+	return matcher, nil
+}
+
+// matches returns true if a message meets all expectations
+func (m *assertionMatcher) matches(message archive.Message) bool {
+	msg, err := mail.ReadMessage(bytes.NewReader(message.Data))
+	if err != nil {
+		return false
+	}
+
+	if m.recipient != "" && !containsAddress(messageRecipients(message, msg.Header), m.recipient) {
+		return false
+	}
+	if m.subject != nil && !m.subject.MatchString(decodeHeader(msg.Header.Get("Subject"))) {
+		return false
+	}
+	for name, expected := range m.headers {
+		found := false
+		for _, value := range msg.Header[textproto.CanonicalMIMEHeaderKey(name)] {
+			if strings.TrimSpace(decodeHeader(value)) == expected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if m.body != "" && !strings.Contains(messageText(msg.Header, msg.Body), m.body) {
+		return false
+	}
+	return true
+}
+
+// messageRecipients returns the envelope recipients and the To / Cc addresses of a message
+func messageRecipients(message archive.Message, header mail.Header) (recipients []string) {
+	recipients = append(recipients, message.To...)
+	for _, name := range []string{"To", "Cc"} {
+		if addresses, err := header.AddressList(name); err == nil {
+			for _, address := range addresses {
+				recipients = append(recipients, address.Address)
+			}
+		}
+	}
+	return recipients
+}
+
+// containsAddress returns true if an address is in the list, ignoring case
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if strings.EqualFold(a, address) {
+			return true
+		}
+	}
+	return false
+}
+
+var headerDecoder = new(mime.WordDecoder)
+
+// decodeHeader decodes RFC 2047 encoded words, undecodable values are returned unchanged
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// messageText returns the decoded text parts of a message body, including the parts of nested multiparts
+func messageText(header mail.Header, body io.Reader) string {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		text := strings.Builder{}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				break
+			}
+			text.WriteString(messageText(mail.Header(part.Header), part))
+		}
+		return text.String()
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return ""
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	text, _ := io.ReadAll(body)
+	return string(text)
+}
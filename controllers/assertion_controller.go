@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// MailhogAssertionReconciler reconciles a MailhogAssertion object
+type MailhogAssertionReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	logger   logr.Logger
+}
+
+// assertionPollInterval is the time between two evaluations of a waiting assertion
+var assertionPollInterval = time.Duration(5) * time.Second
+
+// defaultAssertionTimeout is the time until an assertion fails if it does not specify one
+var defaultAssertionTimeout = time.Duration(5) * time.Minute
+
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogassertions,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogassertions/status,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogassertions/finalizers,verbs=*
+
+// Reconcile evaluates the assertion until it passed or failed, finished assertions are not evaluated again
+func (r *MailhogAssertionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx, "ns", req.Namespace, "assertion", req.Name)
+	r.logger.Info(reconcileStarted)
+
+	assertion := &mailhogv1alpha1.MailhogAssertion{}
+	if err := r.Get(ctx, req.NamespacedName, assertion); err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.Info(crGetNotFound)
+			return ctrl.Result{}, nil
+		}
+		r.logger.Error(err, crGetFailed)
+		return ctrl.Result{}, err
+	}
+	if assertionFinished(assertion.Status.Conditions) || !assertion.DeletionTimestamp.IsZero() {
+		r.logger.Info(reconcileFinished)
+		return ctrl.Result{}, nil
+	}
+
+	original := assertion.Status.DeepCopy()
+	result := r.evaluate(ctx, assertion)
+
+	if !reflect.DeepEqual(*original, assertion.Status) {
+		if err := r.Status().Update(ctx, assertion); err != nil {
+			r.logger.Error(err, failedCrUpdateStatus)
+			return ctrl.Result{RequeueAfter: assertionPollInterval}, err
+		}
+	}
+	return result, nil
+}
+
+// evaluate checks the messages of the instance and sets the conditions, waiting assertions are requeued
+func (r *MailhogAssertionReconciler) evaluate(ctx context.Context, assertion *mailhogv1alpha1.MailhogAssertion) ctrl.Result {
+	logger := r.logger.WithValues(span, spanAssertion)
+
+	now := metav1.Now()
+	if assertion.Status.StartTime == nil {
+		assertion.Status.StartTime = &now
+	}
+	deadline := assertion.Status.StartTime.Add(assertionTimeout(assertion))
+	expired := !now.Time.Before(deadline)
+	expected := assertionCount(assertion)
+
+	matcher, err := newAssertionMatcher(&assertion.Spec)
+	if err != nil {
+		r.completeAssertion(assertion, false, mailhogv1alpha1.InvalidReason, err.Error())
+		return ctrl.Result{}
+	}
+
+	matches, err := r.matchingMessages(ctx, assertion, matcher)
+	if err != nil {
+		logger.Error(err, failedAssertion)
+		if expired {
+			// without an answer of the instance nothing is known about its messages, not even their absence
+			r.completeAssertion(assertion, false, mailhogv1alpha1.UnavailableReason, err.Error())
+			return ctrl.Result{}
+		}
+		setAssertionConditions(assertion, metav1.ConditionFalse, metav1.ConditionFalse, mailhogv1alpha1.WaitingReason, err.Error())
+		return ctrl.Result{RequeueAfter: assertionRequeueTime(now.Time, deadline)}
+	}
+	assertion.Status.LastEvaluationTime = &now
+	assertion.Status.Matches = matches
+	assertion.Status.MatchCount = len(matches)
+
+	found := strconv.Itoa(len(matches)) + " of " + strconv.Itoa(expected) + " expected messages found"
+	switch {
+	case expected > 0 && len(matches) >= expected:
+		r.completeAssertion(assertion, true, mailhogv1alpha1.MatchedReason, found)
+	case expected == 0 && len(matches) > 0:
+		r.completeAssertion(assertion, false, mailhogv1alpha1.MatchedReason, strconv.Itoa(len(matches))+" unexpected messages found")
+	case expired && expected == 0:
+		r.completeAssertion(assertion, true, mailhogv1alpha1.TimeoutReason, "no matching message found until the timeout")
+	case expired:
+		r.completeAssertion(assertion, false, mailhogv1alpha1.TimeoutReason, found+" until the timeout")
+	default:
+		setAssertionConditions(assertion, metav1.ConditionFalse, metav1.ConditionFalse, mailhogv1alpha1.WaitingReason, found)
+		return ctrl.Result{RequeueAfter: assertionRequeueTime(now.Time, deadline)}
+	}
+	return ctrl.Result{}
+}
+
+// matchingMessages returns the ids of the instance's messages matching the assertion, oldest first
+func (r *MailhogAssertionReconciler) matchingMessages(ctx context.Context, assertion *mailhogv1alpha1.MailhogAssertion, matcher *assertionMatcher) (matches []string, err error) {
+	cr := &mailhogv1alpha1.MailhogInstance{}
+	if err = r.Get(ctx, types.NamespacedName{Name: assertion.Spec.Instance, Namespace: assertion.Namespace}, cr); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errMissingAssertionInstance
+		}
+		return nil, err
+	}
+
+	pods, err := mailhogApiPods(ctx, r, cr)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) == 0 {
+		return nil, errNoAssertionPods
+	}
+	if sharedStorage(cr) {
+		pods = pods[:1]
+	}
+
+	lists := make([][]archive.Message, 0, len(pods))
+	for i := range pods {
+		api, err := mailhogApiFor(ctx, r, cr, &pods[i])
+		if err != nil {
+			return nil, err
+		}
+		messages, err := archive.Fetch(ctx, httpClient, api.baseUrl, api.user, api.password)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, messages)
+	}
+
+	for _, message := range archive.Merge(lists...) {
+		if matcher.matches(message) {
+			matches = append(matches, message.ID)
+		}
+	}
+	return matches, nil
+}
+
+// completeAssertion records the final result of an assertion
+func (r *MailhogAssertionReconciler) completeAssertion(assertion *mailhogv1alpha1.MailhogAssertion, passed bool, reason, message string) {
+	now := metav1.Now()
+	assertion.Status.CompletionTime = &now
+	if passed {
+		setAssertionConditions(assertion, metav1.ConditionTrue, metav1.ConditionFalse, reason, message)
+		assertionPassed.Inc()
+		r.Recorder.Event(assertion, corev1.EventTypeNormal, "AssertionPassed", message)
+		return
+	}
+	setAssertionConditions(assertion, metav1.ConditionFalse, metav1.ConditionTrue, reason, message)
+	assertionFailed.Inc()
+	r.Recorder.Event(assertion, corev1.EventTypeWarning, "AssertionFailed", message)
+}
+
+// setAssertionConditions sets the Passed and Failed conditions, both are false while the assertion is waiting
+func setAssertionConditions(assertion *mailhogv1alpha1.MailhogAssertion, passed, failed metav1.ConditionStatus, reason, message string) {
+	for _, condition := range []struct {
+		conditionType string
+		status        metav1.ConditionStatus
+	}{{mailhogv1alpha1.PassedCondition, passed}, {mailhogv1alpha1.FailedCondition, failed}} {
+		meta.SetStatusCondition(&assertion.Status.Conditions, metav1.Condition{
+			Type:               condition.conditionType,
+			Status:             condition.status,
+			ObservedGeneration: assertion.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+}
+
+// assertionFinished returns true if the assertion passed or failed
+func assertionFinished(conditions []metav1.Condition) bool {
+	return meta.IsStatusConditionTrue(conditions, mailhogv1alpha1.PassedCondition) ||
+		meta.IsStatusConditionTrue(conditions, mailhogv1alpha1.FailedCondition)
+}
+
+// assertionTimeout returns the time after the first evaluation until the assertion fails
+func assertionTimeout(assertion *mailhogv1alpha1.MailhogAssertion) time.Duration {
+	if timeout := assertion.Spec.Timeout; timeout != nil && timeout.Duration > 0 {
+		return timeout.Duration
+	}
+	return defaultAssertionTimeout
+}
+
+// assertionCount returns the expected count of matching messages
+func assertionCount(assertion *mailhogv1alpha1.MailhogAssertion) int {
+	if assertion.Spec.Count == nil {
+		return 1
+	}
+	return int(*assertion.Spec.Count)
+}
+
+// assertionRequeueTime returns when a waiting assertion is evaluated again, at the latest at its deadline
+func assertionRequeueTime(now, deadline time.Time) time.Duration {
+	if remaining := deadline.Sub(now); remaining < assertionPollInterval {
+		return remaining
+	}
+	return assertionPollInterval
+}
+
+// SetupWithManager sets up this controller with the Manager
+func (r *MailhogAssertionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mailhogv1alpha1.MailhogAssertion{}).
+		Complete(r)
+}
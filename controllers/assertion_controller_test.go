@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("MailhogAssertion controller", func() {
+	const (
+		ns    = "default"
+		image = "test/test:latest"
+	)
+	instanceName := types.NamespacedName{Name: "tester", Namespace: ns}
+	assertionName := types.NamespacedName{Name: "tester-reset", Namespace: ns}
+
+	getTestingAssertion := func(spec mailhogv1alpha1.MailhogAssertionSpec) *mailhogv1alpha1.MailhogAssertion {
+		spec.Instance = instanceName.Name
+		return &mailhogv1alpha1.MailhogAssertion{
+			ObjectMeta: metav1.ObjectMeta{Name: assertionName.Name, Namespace: ns},
+			Spec:       spec,
+		}
+	}
+
+	startResetMailApi := func() *fakeMailhogApi {
+		received := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+		api := startFakeMailhogApi([]mailhogMessage{
+			{ID: "other@mailhog.example", Created: received.Add(time.Minute)},
+			{ID: "reset@mailhog.example", Created: received},
+		})
+		api.raw["reset@mailhog.example"] = "From: app@sender.example\r\nTo: User <USER@example.com>\r\n" +
+			"Subject: =?UTF-8?Q?Reset_your_password_=E2=9C=93?=\r\nX-Mailer: sample-app\r\n" +
+			"MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=b1\r\n\r\n" +
+			"--b1\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+			"Open https://app.example/password/reset?token=3Dabc123 to reset your passwo=\r\nrd.\r\n" +
+			"--b1\r\nContent-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+			"PGI+aGVsbG88L2I+\r\n--b1--\r\n"
+		api.raw["other@mailhog.example"] = "From: app@sender.example\r\nTo: other@example.com\r\nSubject: Welcome\r\n\r\nhello\r\n"
+		return api
+	}
+
+	getUpdatedAssertion := func() *mailhogv1alpha1.MailhogAssertion {
+		assertion := &mailhogv1alpha1.MailhogAssertion{}
+		Expect(k8sClient.Get(ctx, assertionName, assertion)).To(Succeed())
+		return assertion
+	}
+
+	Context("assertion with matching messages", func() {
+		It("should pass with the ids of the matching messages", func() {
+			api := startResetMailApi()
+			defer api.close()
+
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			assertion := getTestingAssertion(mailhogv1alpha1.MailhogAssertionSpec{
+				Recipient:    "user@example.com",
+				SubjectRegex: "^Reset your password",
+				BodyContains: "token=abc123 to reset your password.",
+				Headers:      map[string]string{"x-mailer": "sample-app"},
+			})
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod"), assertion,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			ar := &MailhogAssertionReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := ar.Reconcile(ctx, reconcile.Request{NamespacedName: assertionName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			updated := getUpdatedAssertion()
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, mailhogv1alpha1.PassedCondition)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, mailhogv1alpha1.FailedCondition)).To(BeTrue())
+			Expect(updated.Status.Matches).To(ConsistOf("reset@mailhog.example"))
+			Expect(updated.Status.CompletionTime).ToNot(BeNil())
+		})
+
+		It("should fail as soon as a message matches an expected count of 0", func() {
+			api := startResetMailApi()
+			defer api.close()
+
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			count := int32(0)
+			assertion := getTestingAssertion(mailhogv1alpha1.MailhogAssertionSpec{
+				SubjectRegex: "Welcome",
+				Count:        &count,
+			})
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod"), assertion,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			ar := &MailhogAssertionReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := ar.Reconcile(ctx, reconcile.Request{NamespacedName: assertionName})
+			Expect(err).ToNot(HaveOccurred())
+
+			updated := getUpdatedAssertion()
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, mailhogv1alpha1.FailedCondition)).To(BeTrue())
+			Expect(updated.Status.Matches).To(ConsistOf("other@mailhog.example"))
+		})
+	})
+
+	Context("assertion without matching messages", func() {
+		It("should wait until the timeout and fail then", func() {
+			api := startResetMailApi()
+			defer api.close()
+
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			assertion := getTestingAssertion(mailhogv1alpha1.MailhogAssertionSpec{
+				Recipient: "nobody@example.com",
+				Timeout:   &metav1.Duration{Duration: time.Minute},
+			})
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod"), assertion,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			ar := &MailhogAssertionReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := ar.Reconcile(ctx, reconcile.Request{NamespacedName: assertionName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(assertionPollInterval))
+
+			updated := getUpdatedAssertion()
+			condition := meta.FindStatusCondition(updated.Status.Conditions, mailhogv1alpha1.PassedCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.WaitingReason))
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, mailhogv1alpha1.FailedCondition)).To(BeTrue())
+
+			updated.Status.StartTime = &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}
+			Expect(k8sClient.Status().Update(ctx, updated)).To(Succeed())
+			res, err = ar.Reconcile(ctx, reconcile.Request{NamespacedName: assertionName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			updated = getUpdatedAssertion()
+			condition = meta.FindStatusCondition(updated.Status.Conditions, mailhogv1alpha1.FailedCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.TimeoutReason))
+			Expect(condition.Message).To(Equal("0 of 1 expected messages found until the timeout"))
+		})
+
+		It("should fail an expected count of 0 if the instance can not be read at the timeout", func() {
+			count := int32(0)
+			assertion := getTestingAssertion(mailhogv1alpha1.MailhogAssertionSpec{
+				Recipient: "nobody@example.com",
+				Count:     &count,
+				Timeout:   &metav1.Duration{Duration: time.Minute},
+			})
+			assertion.Status.StartTime = &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(assertion).Build()
+
+			ar := &MailhogAssertionReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := ar.Reconcile(ctx, reconcile.Request{NamespacedName: assertionName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			updated := getUpdatedAssertion()
+			Expect(meta.IsStatusConditionFalse(updated.Status.Conditions, mailhogv1alpha1.PassedCondition)).To(BeTrue())
+			condition := meta.FindStatusCondition(updated.Status.Conditions, mailhogv1alpha1.FailedCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.UnavailableReason))
+			Expect(condition.Message).To(Equal(errMissingAssertionInstance.Error()))
+		})
+
+		It("should fail immediately for an invalid subject regex", func() {
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			assertion := getTestingAssertion(mailhogv1alpha1.MailhogAssertionSpec{
+				SubjectRegex: "(unclosed",
+			})
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, assertion).Build()
+
+			ar := &MailhogAssertionReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := ar.Reconcile(ctx, reconcile.Request{NamespacedName: assertionName})
+			Expect(err).ToNot(HaveOccurred())
+
+			condition := meta.FindStatusCondition(getUpdatedAssertion().Status.Conditions, mailhogv1alpha1.FailedCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.InvalidReason))
+		})
+	})
+})
@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// MailhogChaosExperimentReconciler reconciles a MailhogChaosExperiment object
+type MailhogChaosExperimentReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	logger   logr.Logger
+}
+
+// defaultChaosDuration is how long jim stays in the instance if the experiment does not specify it
+var defaultChaosDuration = time.Duration(10) * time.Minute
+
+// chaosNow returns the current time, truncated to seconds since the status does not keep more
+var chaosNow = func() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogchaosexperiments,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogchaosexperiments/status,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogchaosexperiments/finalizers,verbs=*
+
+// Reconcile applies the jim settings of the experiment to the status of its instance while a run is due
+// and removes them again afterwards, the instance reconciler rolls out the changed settings
+func (r *MailhogChaosExperimentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx, "ns", req.Namespace, "chaos", req.Name)
+	r.logger.Info(reconcileStarted)
+
+	experiment := &mailhogv1alpha1.MailhogChaosExperiment{}
+	if err := r.Get(ctx, req.NamespacedName, experiment); err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.Info(crGetNotFound)
+			return ctrl.Result{}, nil
+		}
+		r.logger.Error(err, crGetFailed)
+		return ctrl.Result{}, err
+	}
+
+	if !experiment.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, experiment)
+	}
+	if experiment.Status.Phase == mailhogv1alpha1.CompletedChaosPhase {
+		r.logger.Info(reconcileFinished)
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(experiment, chaosRestoreFinalizer) {
+		controllerutil.AddFinalizer(experiment, chaosRestoreFinalizer)
+		if err := r.Update(ctx, experiment); err != nil {
+			r.logger.Error(err, failedCrUpdate)
+			return ctrl.Result{}, err
+		}
+	}
+
+	original := experiment.Status.DeepCopy()
+	result, err := r.run(ctx, experiment)
+
+	if !reflect.DeepEqual(*original, experiment.Status) {
+		if updateErr := r.Status().Update(ctx, experiment); updateErr != nil {
+			r.logger.Error(updateErr, failedCrUpdateStatus)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, updateErr
+		}
+	}
+	return result, err
+}
+
+// run starts and ends the runs of the experiment and returns when it has to be looked at again
+func (r *MailhogChaosExperimentReconciler) run(ctx context.Context, experiment *mailhogv1alpha1.MailhogChaosExperiment) (ctrl.Result, error) {
+	logger := r.logger.WithValues(span, spanChaos)
+	status := &experiment.Status
+	now := chaosNow()
+
+	applied, schedule, err := chaosSettings(experiment)
+	if err != nil {
+		if status.Phase == mailhogv1alpha1.RunningChaosPhase {
+			if restoreErr := r.restore(ctx, experiment); restoreErr != nil {
+				logger.Error(restoreErr, failedChaosRestore)
+				return ctrl.Result{RequeueAfter: archiveRetryTime}, restoreErr
+			}
+			status.EndTime = &metav1.Time{Time: now}
+		}
+		if status.Phase != mailhogv1alpha1.FailedChaosPhase {
+			r.Recorder.Event(experiment, corev1.EventTypeWarning, "ChaosExperimentInvalid", err.Error())
+		}
+		status.Phase = mailhogv1alpha1.FailedChaosPhase
+		status.Message = err.Error()
+		status.NextRunTime = nil
+		return ctrl.Result{}, nil
+	}
+
+	if status.Phase == mailhogv1alpha1.RunningChaosPhase {
+		if status.EndTime != nil && now.Before(status.EndTime.Time) {
+			// the instance status is applied again, in case it was lost or the settings of the experiment changed
+			if err := r.apply(ctx, experiment, status.EndTime.Time); err != nil && err != errChaosInstanceBusy && err != errMissingArchiveInstance {
+				logger.Error(err, failedChaosApply)
+				return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+			}
+			status.Applied = applied
+			return ctrl.Result{RequeueAfter: status.EndTime.Sub(now)}, nil
+		}
+
+		if err := r.restore(ctx, experiment); err != nil {
+			logger.Error(err, failedChaosRestore)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		status.EndTime = &metav1.Time{Time: now}
+		message := "restored the settings of " + experiment.Spec.Instance
+		r.Recorder.Event(experiment, corev1.EventTypeNormal, "ChaosExperimentEnded", message)
+		if schedule == nil {
+			status.Phase = mailhogv1alpha1.CompletedChaosPhase
+			status.Message = message
+			return ctrl.Result{}, nil
+		}
+		status.Phase = mailhogv1alpha1.WaitingChaosPhase
+		status.Message = message
+	}
+
+	end := now.Add(chaosDuration(experiment))
+	if schedule != nil {
+		next := schedule.next(experiment.CreationTimestamp.Time)
+		if status.NextRunTime != nil {
+			next = status.NextRunTime.Time
+		}
+		// runs that were missed entirely, e.g. while the operator was down, are skipped
+		if !next.IsZero() && !now.Before(next.Add(chaosDuration(experiment))) {
+			next = schedule.next(now)
+		}
+		if next.IsZero() {
+			status.Phase = mailhogv1alpha1.FailedChaosPhase
+			status.Message = errCronNoMatch.Error()
+			status.NextRunTime = nil
+			return ctrl.Result{}, nil
+		}
+		if now.Before(next) {
+			status.Phase = mailhogv1alpha1.WaitingChaosPhase
+			status.NextRunTime = &metav1.Time{Time: next}
+			if status.Runs == 0 {
+				status.Message = "waiting for the first scheduled run"
+			}
+			return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+		}
+		end = next.Add(chaosDuration(experiment))
+	}
+
+	if err := r.apply(ctx, experiment, end); err != nil {
+		if err != errChaosInstanceBusy && err != errMissingArchiveInstance {
+			logger.Error(err, failedChaosApply)
+		}
+		status.Phase = mailhogv1alpha1.WaitingChaosPhase
+		status.Message = err.Error()
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, nil
+	}
+
+	status.Phase = mailhogv1alpha1.RunningChaosPhase
+	status.Runs++
+	status.StartTime = &metav1.Time{Time: now}
+	status.EndTime = &metav1.Time{Time: end}
+	status.Applied = applied
+	status.NextRunTime = nil
+	if schedule != nil {
+		if next := schedule.next(end); !next.IsZero() {
+			status.NextRunTime = &metav1.Time{Time: next}
+		}
+	}
+	status.Message = "jim is invited into " + experiment.Spec.Instance + " until " + end.Format(time.RFC3339)
+	chaosRuns.Inc()
+	r.Recorder.Event(experiment, corev1.EventTypeNormal, "ChaosExperimentStarted", status.Message)
+	return ctrl.Result{RequeueAfter: end.Sub(now)}, nil
+}
+
+// apply records the experiment in the status of its instance, an instance runs one experiment at a time
+func (r *MailhogChaosExperimentReconciler) apply(ctx context.Context, experiment *mailhogv1alpha1.MailhogChaosExperiment, until time.Time) error {
+	cr := &mailhogv1alpha1.MailhogInstance{}
+	if err := r.Get(ctx, types.NamespacedName{Name: experiment.Spec.Instance, Namespace: experiment.Namespace}, cr); err != nil {
+		if errors.IsNotFound(err) {
+			return errMissingArchiveInstance
+		}
+		return err
+	}
+
+	current := cr.Status.Chaos
+	if current != nil && current.Experiment != experiment.Name {
+		return errChaosInstanceBusy
+	}
+	if current != nil && reflect.DeepEqual(current.Jim, experiment.Spec.Jim) && current.Until != nil && current.Until.Time.Equal(until) {
+		return nil
+	}
+
+	cr.Status.Chaos = &mailhogv1alpha1.ChaosStatus{
+		Experiment: experiment.Name,
+		Jim:        experiment.Spec.Jim,
+		Until:      &metav1.Time{Time: until},
+	}
+	return r.Status().Update(ctx, cr)
+}
+
+// restore removes the experiment from the status of its instance, so the instance runs with the settings of its spec
+func (r *MailhogChaosExperimentReconciler) restore(ctx context.Context, experiment *mailhogv1alpha1.MailhogChaosExperiment) error {
+	cr := &mailhogv1alpha1.MailhogInstance{}
+	if err := r.Get(ctx, types.NamespacedName{Name: experiment.Spec.Instance, Namespace: experiment.Namespace}, cr); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if cr.Status.Chaos == nil || cr.Status.Chaos.Experiment != experiment.Name {
+		return nil
+	}
+	cr.Status.Chaos = nil
+	return r.Status().Update(ctx, cr)
+}
+
+// finalize restores the instance of a deleted experiment and drops the finalizer afterwards
+func (r *MailhogChaosExperimentReconciler) finalize(ctx context.Context, experiment *mailhogv1alpha1.MailhogChaosExperiment) error {
+	logger := r.logger.WithValues(span, spanFinalizer)
+	if !controllerutil.ContainsFinalizer(experiment, chaosRestoreFinalizer) {
+		return nil
+	}
+	if err := r.restore(ctx, experiment); err != nil {
+		logger.Error(err, failedChaosRestore)
+		return err
+	}
+	controllerutil.RemoveFinalizer(experiment, chaosRestoreFinalizer)
+	if err := r.Update(ctx, experiment); err != nil {
+		logger.Error(err, failedCrUpdate)
+		return err
+	}
+	return nil
+}
+
+// chaosSettings validates the experiment and returns the effective jim values and the parsed schedule, if any
+func chaosSettings(experiment *mailhogv1alpha1.MailhogChaosExperiment) (*mailhogv1alpha1.JimStatus, *cronSchedule, error) {
+	target := &mailhogv1alpha1.MailhogInstance{}
+	target.Spec.Settings.Jim = experiment.Spec.Jim
+	target.Spec.Settings.Jim.Invite = true
+	if _, err := effectiveJim(target); err != nil {
+		return nil, nil, err
+	}
+
+	if experiment.Spec.Schedule == "" {
+		return jimStatus(target), nil, nil
+	}
+	schedule, err := parseCron(experiment.Spec.Schedule)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jimStatus(target), &schedule, nil
+}
+
+// chaosDuration returns how long a run lasts
+func chaosDuration(experiment *mailhogv1alpha1.MailhogChaosExperiment) time.Duration {
+	if duration := experiment.Spec.Duration; duration != nil && duration.Duration > 0 {
+		return duration.Duration
+	}
+	return defaultChaosDuration
+}
+
+// SetupWithManager sets up this controller with the Manager
+func (r *MailhogChaosExperimentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mailhogv1alpha1.MailhogChaosExperiment{}).
+		Complete(r)
+}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("MailhogChaosExperiment controller", func() {
+	const (
+		ns    = "default"
+		image = "test/test:latest"
+	)
+	instanceName := types.NamespacedName{Name: "tester", Namespace: ns}
+	experimentName := types.NamespacedName{Name: "tester-chaos", Namespace: ns}
+	start := time.Date(2026, time.March, 2, 9, 58, 0, 0, time.UTC)
+
+	var now time.Time
+	originalNow := chaosNow
+	BeforeEach(func() {
+		now = start
+		chaosNow = func() time.Time { return now }
+	})
+	AfterEach(func() {
+		chaosNow = originalNow
+	})
+
+	getTestingExperiment := func() *mailhogv1alpha1.MailhogChaosExperiment {
+		return &mailhogv1alpha1.MailhogChaosExperiment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              experimentName.Name,
+				Namespace:         ns,
+				UID:               "chaos-uid",
+				CreationTimestamp: metav1.Time{Time: start.Add(-time.Hour)},
+			},
+			Spec: mailhogv1alpha1.MailhogChaosExperimentSpec{
+				Instance: instanceName.Name,
+				Jim: mailhogv1alpha1.MailhogJimSpec{
+					Preset:           mailhogv1alpha1.FlakyNetworkJimPreset,
+					DisconnectChance: "0.3",
+				},
+				Duration: &metav1.Duration{Duration: 5 * time.Minute},
+			},
+		}
+	}
+
+	getUpdatedExperiment := func() *mailhogv1alpha1.MailhogChaosExperiment {
+		experiment := &mailhogv1alpha1.MailhogChaosExperiment{}
+		Expect(k8sClient.Get(ctx, experimentName, experiment)).To(Succeed())
+		return experiment
+	}
+
+	getUpdatedInstance := func() *mailhogv1alpha1.MailhogInstance {
+		cr := &mailhogv1alpha1.MailhogInstance{}
+		Expect(k8sClient.Get(ctx, instanceName, cr)).To(Succeed())
+		return cr
+	}
+
+	Context("experiment with a fixed duration", func() {
+		It("should invite jim for the duration and restore the settings of the instance afterwards", func() {
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingExperiment()).Build()
+			er := &MailhogChaosExperimentReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			ir := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+
+			res, err := er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(5 * time.Minute))
+
+			experiment := getUpdatedExperiment()
+			Expect(experiment.Finalizers).To(ContainElement(chaosRestoreFinalizer))
+			Expect(experiment.Status.Phase).To(Equal(mailhogv1alpha1.RunningChaosPhase))
+			Expect(experiment.Status.Runs).To(Equal(int32(1)))
+			Expect(experiment.Status.StartTime.Time).To(BeTemporally("==", start))
+			Expect(experiment.Status.EndTime.Time).To(BeTemporally("==", start.Add(5*time.Minute)))
+			Expect(experiment.Status.Applied.Preset).To(Equal(mailhogv1alpha1.FlakyNetworkJimPreset))
+			Expect(experiment.Status.Applied.Disconnect).To(Equal("0.3"))
+			Expect(experiment.Status.Applied.Accept).To(Equal("0.9"))
+
+			_, err = ir.Reconcile(ctx, reconcile.Request{NamespacedName: instanceName})
+			Expect(err).ToNot(HaveOccurred())
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, instanceName, deployment)).To(Succeed())
+			args := deployment.Spec.Template.Spec.Containers[0].Args
+			Expect(args).To(ContainElements(argsJimInvite, "-"+argsJimDisconnectRate+"=0.3"))
+			updatedCr := getUpdatedInstance()
+			Expect(updatedCr.Spec.Settings.Jim.Invite).To(BeFalse())
+			Expect(updatedCr.Status.Chaos.Experiment).To(Equal(experimentName.Name))
+			Expect(updatedCr.Status.Jim.Disconnect).To(Equal("0.3"))
+
+			now = start.Add(5 * time.Minute)
+			_, err = er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			experiment = getUpdatedExperiment()
+			Expect(experiment.Status.Phase).To(Equal(mailhogv1alpha1.CompletedChaosPhase))
+			Expect(experiment.Status.EndTime.Time).To(BeTemporally("==", now))
+			Expect(experiment.Status.Applied).ToNot(BeNil())
+			Expect(getUpdatedInstance().Status.Chaos).To(BeNil())
+
+			_, err = ir.Reconcile(ctx, reconcile.Request{NamespacedName: instanceName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, instanceName, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Args).ToNot(ContainElement(argsJimInvite))
+			Expect(getUpdatedInstance().Status.Jim).To(BeNil())
+		})
+
+		It("should wait while another experiment runs against the instance", func() {
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Status.Chaos = &mailhogv1alpha1.ChaosStatus{Experiment: "other"}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingExperiment()).Build()
+			er := &MailhogChaosExperimentReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+
+			res, err := er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(archiveRetryTime))
+			experiment := getUpdatedExperiment()
+			Expect(experiment.Status.Phase).To(Equal(mailhogv1alpha1.WaitingChaosPhase))
+			Expect(experiment.Status.Message).To(Equal(errChaosInstanceBusy.Error()))
+			Expect(getUpdatedInstance().Status.Chaos.Experiment).To(Equal("other"))
+		})
+
+		It("should refuse invalid jim settings", func() {
+			experiment := getTestingExperiment()
+			experiment.Spec.Jim.AcceptChance = "1.5"
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(experiment).Build()
+			er := &MailhogChaosExperimentReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+
+			_, err := er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			updated := getUpdatedExperiment()
+			Expect(updated.Status.Phase).To(Equal(mailhogv1alpha1.FailedChaosPhase))
+			Expect(updated.Status.Message).To(Equal(errJimProbabilityRange.Error()))
+		})
+	})
+
+	Context("experiment with a schedule", func() {
+		It("should run at every scheduled time", func() {
+			experiment := getTestingExperiment()
+			experiment.Spec.Schedule = "0 10 * * *"
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, experiment).Build()
+			er := &MailhogChaosExperimentReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+
+			res, err := er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(2 * time.Minute))
+			updated := getUpdatedExperiment()
+			Expect(updated.Status.Phase).To(Equal(mailhogv1alpha1.WaitingChaosPhase))
+			Expect(updated.Status.NextRunTime.Time).To(BeTemporally("==", start.Add(2*time.Minute)))
+			Expect(getUpdatedInstance().Status.Chaos).To(BeNil())
+
+			now = start.Add(3 * time.Minute)
+			res, err = er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(4 * time.Minute))
+			updated = getUpdatedExperiment()
+			Expect(updated.Status.Phase).To(Equal(mailhogv1alpha1.RunningChaosPhase))
+			Expect(updated.Status.EndTime.Time).To(BeTemporally("==", start.Add(7*time.Minute)))
+			Expect(updated.Status.NextRunTime.Time).To(BeTemporally("==", start.Add(24*time.Hour+2*time.Minute)))
+			Expect(getUpdatedInstance().Status.Chaos.Until.Time).To(BeTemporally("==", start.Add(7*time.Minute)))
+
+			now = start.Add(7 * time.Minute)
+			_, err = er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			updated = getUpdatedExperiment()
+			Expect(updated.Status.Phase).To(Equal(mailhogv1alpha1.WaitingChaosPhase))
+			Expect(updated.Status.Runs).To(Equal(int32(1)))
+			Expect(getUpdatedInstance().Status.Chaos).To(BeNil())
+
+			// the next run was missed entirely and is skipped
+			now = start.Add(48 * time.Hour)
+			_, err = er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			updated = getUpdatedExperiment()
+			Expect(updated.Status.Phase).To(Equal(mailhogv1alpha1.WaitingChaosPhase))
+			Expect(updated.Status.Runs).To(Equal(int32(1)))
+			Expect(updated.Status.NextRunTime.Time).To(BeTemporally("==", start.Add(48*time.Hour+2*time.Minute)))
+		})
+
+		It("should restore the instance if the experiment is deleted while it runs", func() {
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingExperiment()).Build()
+			er := &MailhogChaosExperimentReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+
+			_, err := er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getUpdatedInstance().Status.Chaos).ToNot(BeNil())
+
+			Expect(k8sClient.Delete(ctx, getUpdatedExperiment())).To(Succeed())
+			_, err = er.Reconcile(ctx, reconcile.Request{NamespacedName: experimentName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getUpdatedInstance().Status.Chaos).To(BeNil())
+		})
+	})
+
+	Context("cron schedules", func() {
+		It("should find the next matching minute", func() {
+			from := time.Date(2026, time.January, 30, 23, 59, 30, 0, time.UTC) // a friday
+			for expression, expected := range map[string]time.Time{
+				"* * * * *":        time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC),
+				"*/15 9-17 * * *":  time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC),
+				"30 2 * * 1-5":     time.Date(2026, time.February, 2, 2, 30, 0, 0, time.UTC),
+				"0 0 1 * *":        time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC),
+				"0 12 29 2 *":      time.Date(2028, time.February, 29, 12, 0, 0, 0, time.UTC),
+				"0 6 15 * 0":       time.Date(2026, time.February, 1, 6, 0, 0, 0, time.UTC),
+				"5,10 8 * 1,3 7":   time.Date(2026, time.March, 1, 8, 5, 0, 0, time.UTC),
+				"10/20 0 31 1 sat": {},
+			} {
+				schedule, err := parseCron(expression)
+				if expected.IsZero() {
+					Expect(err).To(MatchError(errCronInvalidField))
+					continue
+				}
+				Expect(err).ToNot(HaveOccurred(), expression)
+				Expect(schedule.next(from)).To(BeTemporally("==", expected), expression)
+			}
+		})
+
+		It("should reject malformed expressions", func() {
+			_, err := parseCron("0 10 * *")
+			Expect(err).To(MatchError(errCronFieldCount))
+			for _, expression := range []string{"60 * * * *", "* 5-2 * * *", "* * 0 * *", "*/0 * * * *", "a * * * *"} {
+				_, err = parseCron(expression)
+				Expect(err).To(MatchError(errCronInvalidField), expression)
+			}
+			schedule, err := parseCron("0 0 31 2 *")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(schedule.next(start).IsZero()).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ensureClusterRoleBinding reconciles the ClusterRoleBinding which allows the rbac auth proxy to review tokens and access
+// cluster scoped objects can not be owned by a namespaced CR, so a finalizer takes care of the cleanup
+func ensureClusterRoleBinding(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	name := types.NamespacedName{Name: clusterRoleBindingName(cr)}
+	logger := r.logger.WithValues(span, spanClusterRb)
+
+	if rbacAuthNeeded(cr) {
+		if err = r.setFinalizer(ctx, cr, true); err != nil {
+			return err
+		}
+
+		existingBinding := &rbacv1.ClusterRoleBinding{}
+		if err = r.Get(ctx, name, existingBinding); err != nil {
+			if errors.IsNotFound(err) {
+				binding := clusterRoleBindingNew(cr)
+				return r.createUnowned(ctx, cr, logger, binding, clusterRoleBindingCreate)
+			}
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+
+		updatedBinding, updateNeeded, err := clusterRoleBindingUpdates(cr, existingBinding)
+		if err != nil {
+			logger.Error(err, failedUpdateCheck)
+			return err
+		} else if updateNeeded {
+			return r.updateUnowned(ctx, cr, logger, updatedBinding, clusterRoleBindingUpdate)
+		}
+
+	} else if controllerutil.ContainsFinalizer(cr, clusterResourcesFinalizer) {
+		if err = r.finalize(ctx, cr); err != nil {
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// finalize removes cluster scoped objects of the CR and drops the finalizer afterwards
+func (r *MailhogInstanceReconciler) finalize(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanFinalizer)
+
+	if !controllerutil.ContainsFinalizer(cr, clusterResourcesFinalizer) {
+		return nil
+	}
+
+	name := types.NamespacedName{Name: clusterRoleBindingName(cr)}
+	toBeDeletedBinding := &rbacv1.ClusterRoleBinding{}
+	if err = r.delete(ctx, cr, name, toBeDeletedBinding, logger, clusterRoleBindingDelete); err != nil {
+		return err
+	}
+
+	return r.setFinalizer(ctx, cr, false)
+}
+
+// setFinalizer adds or removes the cluster resources finalizer of the CR
+func (r *MailhogInstanceReconciler) setFinalizer(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, wanted bool) error {
+	if controllerutil.ContainsFinalizer(cr, clusterResourcesFinalizer) == wanted {
+		return nil
+	}
+
+	if wanted {
+		controllerutil.AddFinalizer(cr, clusterResourcesFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(cr, clusterResourcesFinalizer)
+	}
+	if err := r.Update(ctx, cr); err != nil {
+		r.logger.Error(err, failedCrUpdate)
+		return err
+	}
+	return nil
+}
+
+// clusterRoleBindingName returns the name of the ClusterRoleBinding for the CR, unique across namespaces
+func clusterRoleBindingName(cr *mailhogv1alpha1.MailhogInstance) string {
+	return mh + "-" + cr.Namespace + "-" + cr.Name + serviceAccountSuffix
+}
+
+// clusterRoleBindingNew returns a ClusterRoleBinding in the wanted state
+func clusterRoleBindingNew(cr *mailhogv1alpha1.MailhogInstance) (newBinding *rbacv1.ClusterRoleBinding) {
+	meta := CreateMetaMaker(cr)
+	meta.Name = clusterRoleBindingName(cr)
+	meta.Namespace = ""
+	meta.Labels[crNamespaceLabel] = cr.Namespace
+
+	binding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: meta.GetMeta(),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     authDelegatorClusterRole,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      serviceAccountName(cr),
+				Namespace: cr.Namespace,
+			},
+		},
+	}
+
+	return binding
+}
+
+// clusterRoleBindingUpdates checks if a ClusterRoleBinding needs to be updated
+func clusterRoleBindingUpdates(cr *mailhogv1alpha1.MailhogInstance, oldBinding *rbacv1.ClusterRoleBinding) (updatedBinding *rbacv1.ClusterRoleBinding, updateNeeded bool, err error) {
+	newBinding := clusterRoleBindingNew(cr)
+
+	updateNeeded, err = checkPatch(oldBinding, newBinding)
+	if updateNeeded == true {
+		return newBinding, updateNeeded, err
+	}
+	return oldBinding, updateNeeded, err
+}
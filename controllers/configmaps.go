@@ -2,7 +2,7 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
+	"strings"
 
 	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
@@ -15,7 +15,7 @@ func ensureConfigMap(ctx context.Context, r *MailhogInstanceReconciler, cr *mail
 	name := types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}
 	logger := r.logger.WithValues(span, spanConfigMap)
 
-	if cr.Spec.Settings.Files != nil {
+	if configMapNeeded(cr) {
 		existingCM := &corev1.ConfigMap{}
 		if err = r.Get(ctx, name, existingCM); err != nil {
 			if errors.IsNotFound(err) {
@@ -44,25 +44,19 @@ func ensureConfigMap(ctx context.Context, r *MailhogInstanceReconciler, cr *mail
 	return nil
 }
 
+// configMapNeeded returns true if the CR requires settings files without secret content
+// web users and smtp upstreams carry password hashes and credentials, they are rendered into Secrets
+func configMapNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	auth := cr.Spec.Settings.Auth
+	return auth != nil && auth.Oidc != nil && len(auth.Oidc.AllowedEmails) > 0
+}
+
 // configMapNew returns a ConfigMap in the wanted state
 func configMapNew(cr *mailhogv1alpha1.MailhogInstance) (newConfigMap *corev1.ConfigMap) {
 	data := make(map[string]string)
 
-	if len(cr.Spec.Settings.Files.SmtpUpstreams) > 0 {
-		servers := make(map[string]mailhogv1alpha1.MailhogUpstreamSpec)
-		for _, server := range cr.Spec.Settings.Files.SmtpUpstreams {
-			servers[server.Name] = server
-		}
-		serverBytes, _ := json.Marshal(servers)
-		data[settingsFileUpstreamsName] = string(serverBytes)
-	}
-
-	if len(cr.Spec.Settings.Files.WebUsers) > 0 {
-		users := ""
-		for _, credential := range cr.Spec.Settings.Files.WebUsers {
-			users += credential.Name + ":" + credential.PasswordHash + "\n"
-		}
-		data[settingsFilePasswordsName] = users
+	if auth := cr.Spec.Settings.Auth; auth != nil && auth.Oidc != nil && len(auth.Oidc.AllowedEmails) > 0 {
+		data[oidcEmailsFileName] = strings.Join(auth.Oidc.AllowedEmails, "\n") + "\n"
 	}
 
 	meta := CreateMetaMaker(cr)
@@ -7,12 +7,19 @@ const (
 	defaultResourceCPU    = "200m"
 	defaultResourceMemory = "150Mi"
 
+	defaultSidecarResourceCPU = "50m"
+	defaultSidecarResourceMem = "32Mi"
+
 	volumeNameMaildir  = "maildir-storage"
 	volumeNameSettings = "settings-files"
+	volumeNameServers  = "settings-servers"
+	volumeNameSmtpTls  = "smtp-tls"
+	volumeNameSmtpAuth = "smtp-auth"
 
 	settingsFilesMount        = "/mailhog/settings/files"
 	settingsFileUpstreamsName = "upstream.servers.json"
-	settingsFileUpstreamsPath = settingsFilesMount + "/" + "upstream.servers.json"
+	settingsServersMount      = "/mailhog/settings/servers"
+	settingsFileUpstreamsPath = settingsServersMount + "/" + "upstream.servers.json"
 	//#nosec G101
 	settingsFilePasswordsName = "users.list.bcrypt"
 	settingsFilePasswordsPath = settingsFilesMount + "/" + "users.list.bcrypt"
@@ -23,7 +30,152 @@ const (
 	portWebName  = "http"
 	protoTcp     = "TCP"
 
+	portSmtpStartTlsDefault = 1587
+	portSmtpStartTlsName    = "smtp-starttls"
+	portSmtpTlsDefault      = 1465
+	portSmtpTlsName         = "smtps"
+	portSmtpProxy           = 1026
+	portSmtpProxyName       = "smtp-proxy"
+	portSmtpProxyAdmin      = 8026
+	portSmtpProxyAdminName  = "proxy-admin"
+	portSmtpOperator        = 1027
+	portSmtpOperatorName    = "smtp-operator"
+	smtpFaultDefaultCode    = 550
+
+	smtpTranscriptsDefaultKeep = 100
+	smtpRelayDefaultAttempts   = 5
+
+	portWebProxy     = 4180
+	portWebProxyName = "http-proxy"
+
+	webProxyName             = "auth-proxy"
+	webProxyUpstream         = "http://127.0.0.1:8025/"
+	webProxyMount            = "/etc/auth-proxy"
+	webProxyPingPath         = "/ping"
+	defaultOidcProxyImage    = "quay.io/oauth2-proxy/oauth2-proxy:v7.2.1"
+	oidcDiscoveryPath        = "/.well-known/openid-configuration"
+	oidcEmailsFileName       = "oidc.authenticated.emails"
+	webProxyHtpasswdFileName = "operator.htpasswd"
+	envOidcClientSecret      = "OAUTH2_PROXY_CLIENT_SECRET"
+	envOidcCookieSecret      = "OAUTH2_PROXY_COOKIE_SECRET"
+	volumeNameWebProxyConfig = "auth-proxy-files"
+	volumeNameWebProxyToken  = "auth-proxy-token"
+	serviceAccountTokenMount = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubeRootCaConfigMap      = "kube-root-ca.crt"
+	serviceAccountTokenTtl   = 3607
+
+	defaultRbacProxyImage            = "quay.io/openshift/origin-oauth-proxy:4.10"
+	defaultRbacVerb                  = "get"
+	envRbacCookieSecret              = "COOKIE_SECRET"
+	serviceAccountSuffix             = "-auth-proxy"
+	oauthRedirectReferenceAnnotation = "serviceaccounts.openshift.io/oauth-redirectreference.primary"
+	oauthRedirectUriAnnotation       = "serviceaccounts.openshift.io/oauth-redirecturi.primary"
+	authDelegatorClusterRole         = "system:auth-delegator"
+	clusterResourcesFinalizer        = "mailhog.operators.patrick.mx/cluster-resources"
+
+	roleInfix = "-" + mh + "-"
+
+	generatedSecretSuffix = "-generated"
+	upstreamsSecretSuffix = "-upstreams"
+	usersSecretSuffix     = "-users"
+	secretKeyCookie       = "cookie-secret"
+	//#nosec G101
+	secretKeyProbePassword = "probe-password"
+	//#nosec G101
+	secretKeyProbeHash = "probe-password-hash"
+	//#nosec G101
+	secretKeyProbeHtpasswd = "probe-htpasswd"
+
+	probeUserName       = "mailhog-operator-probe"
+	seedFallbackAddress = "seed@mailhog-operator.local"
+	seedSourceLabel     = "mailhog.operators.patrick.mx/seed-source"
+	//#nosec G101
+	envProbePassword = "PROBE_PASSWORD"
+
+	defaultMongoImage           = "mongo:5.0"
+	retentionJobSuffix          = "-retention-ttl"
+	retentionJobContainer       = "ttl-index"
+	retentionPageSize           = 250
+	envRetentionMongoDb         = "MONGO_DB"
+	envRetentionMongoCollection = "MONGO_COLLECTION"
+	envRetentionTtlSeconds      = "TTL_SECONDS"
+	defaultRetentionResourceCPU = "100m"
+	defaultRetentionResourceMem = "256Mi"
+	retentionTtlScript          = `const database = db.getSiblingDB(process.env.MONGO_DB);
+const index = {name: "mailhog_retention_ttl", expireAfterSeconds: parseInt(process.env.TTL_SECONDS)};
+try {
+  database.getCollection(process.env.MONGO_COLLECTION).createIndex({created: 1}, index);
+} catch (e) {
+  const result = database.runCommand({collMod: process.env.MONGO_COLLECTION, index: index});
+  if (!result.ok) { print(result.errmsg); quit(1); }
+}`
+
+	snapshotSuffix            = "-snapshot"
+	snapshotTypeValue         = "mailhogsnapshot"
+	snapshotConfigMapMaxSize  = 1000 * 1024
+	restoreSuffix             = "-restore"
+	preserveSuffix            = "-preserved"
+	restoreTypeValue          = "mailhogrestore"
+	restoreFallbackAddress    = "restore@mailhog-operator.local"
+	archiveCommand            = "/mailhog-archive"
+	archiveJobContainer       = "archive"
+	archiveMount              = "/snapshot"
+	archiveLabel              = "mailhog.operators.patrick.mx/archive"
+	volumeNameArchive         = "snapshot"
+	defaultArchiveResourceMem = "256Mi"
+	jobNameLabel              = "job-name"
+	loadTestSuffix            = "-loadtest"
+	loadTestTypeValue         = "mailhogloadtest"
+	loadgenCommand            = "/loadgen"
+	loadgenJobContainer       = "loadgen"
+	chaosRestoreFinalizer     = "mailhog.operators.patrick.mx/chaos-restore"
+
+	defaultArchiveRegion  = "us-east-1"
+	archiveAccessKeyIdKey = "accessKeyId"
+	//#nosec G101
+	archiveSecretAccessKeyKey = "secretAccessKey"
+	archiveIndexTimeFormat    = "20060102T150405.000000000Z"
+	archiveUploadBatchSize    = 500
+
+	smtpProxyName        = "smtp-proxy"
+	smtpProxyCommand     = "/smtp-proxy"
+	smtpProxyBackend     = "127.0.0.1:1025"
+	smtpProxyTlsMount    = "/etc/smtp-proxy/tls"
+	smtpProxyTlsCertPath = smtpProxyTlsMount + "/" + "tls.crt"
+	smtpProxyTlsKeyPath  = smtpProxyTlsMount + "/" + "tls.key"
+	smtpProxyAuthMount   = "/etc/smtp-proxy/auth"
+
+	volumeNameSmtpOperator = "smtp-operator"
+	smtpProxyOperatorMount = "/etc/smtp-proxy/operator"
+	//#nosec G101
+	smtpProxyOperatorPassword = "password"
+	//#nosec G101
+	smtpProxyOperatorPasswdPath = smtpProxyOperatorMount + "/" + smtpProxyOperatorPassword
+
+	volumeNameSmtpRelay = "smtp-relay"
+	smtpProxyRelayMount = "/etc/smtp-proxy/relay"
+	smtpRelayUsername   = "username"
+	//#nosec G101
+	smtpRelayPassword = "password"
+
+	volumeNameTrustedCa  = "trusted-ca"
+	volumeNameUpstreamCa = "upstream-ca"
+	trustedCaMount       = "/etc/mailhog/trusted-ca"
+	trustedCaDefaultKey  = "ca-bundle.crt"
+	upstreamCaMount      = "/etc/mailhog/upstream-ca"
+	envSslCertFile       = "SSL_CERT_FILE"
+	envSslCertDir        = "SSL_CERT_DIR"
+
+	volumeNameSettingsFrom    = "settings-from"
+	settingsFromMount         = "/mailhog/settings/from"
+	settingsFromUpstreamsPath = settingsFromMount + "/" + settingsFileUpstreamsName
+
+	smtpVerificationAddress = "smtp-verification@mailhog-operator.local"
+	smtpVerificationHeader  = "X-Mailhog-Operator-Verification"
+	deploymentRevisionKey   = "deployment.kubernetes.io/revision"
+
 	crNameLabel          = "mailhoginstance_cr"
+	crNamespaceLabel     = "mailhoginstance_ns"
 	crTypeLabel          = "mailhogtype"
 	crTypeValue          = "mailhoginstance"
 	runtimeLabel         = "app.openshift.io/runtime"
@@ -58,13 +210,35 @@ const (
 	eventUpdated = "child resource updated by mailhog-operator"
 	eventDeleted = "child resource deleted by mailhog-operator"
 
-	failedGetExisting = "failed to get existing object"
-	failedUpdateCheck = "failed to check if object needs an update"
-	stateEnsured      = "object state ensured"
+	failedGetExisting     = "failed to get existing object"
+	failedUpdateCheck     = "failed to check if object needs an update"
+	failedGenerateSecret  = "failed to generate secret values"
+	failedRetentionPurge  = "failed to purge messages"
+	failedMessagesStatus  = "failed to get message count"
+	failedSeedSources     = "failed to read seed messages"
+	failedSeedDelivery    = "failed to deliver seed messages"
+	failedSnapshotExport  = "failed to export snapshot"
+	failedRestoreDelivery = "failed to replay snapshot"
+	failedPreserveExport  = "failed to preserve messages"
+	failedPreserveReplay  = "failed to replay preserved messages"
+	failedArchiveUpload   = "failed to upload messages"
+	failedAssertion       = "failed to evaluate assertion"
+	failedSmtpVerify      = "failed to verify smtp delivery"
+	failedLoadTest        = "failed to run load test"
+	failedChaosApply      = "failed to apply chaos experiment"
+	failedChaosRestore    = "failed to restore settings after chaos experiment"
+	failedSmtpFaultHits   = "failed to get smtp fault hits"
+	failedSmtpViolations  = "failed to get smtp policy violations"
+	failedSmtpRelayStats  = "failed to get smtp relay stats"
+	failedUpstreamCheck   = "failed to check smtp upstream"
+	failedOidcDiscovery   = "failed to discover oidc issuer"
+	stateEnsured          = "object state ensured"
+	stateRolloutBlocked   = "rollout blocked until the messages are exported"
 
 	failedListPods       = "failed to list pods"
 	failedListRoutes     = "failed to list routes"
 	failedCrRefresh      = "failed to get latest cr version before update"
+	failedCrUpdate       = "failed to update cr"
 	failedCrUpdateStatus = "failed to update cr status"
 	updatedCrStatus      = "updated cr status"
 	noCrUpdateNeeded     = "no cr status update required"
@@ -77,6 +251,26 @@ const (
 	spanDeployment = "deployment"
 	spanConfigMap  = "configMap"
 	spanIgress     = "ingress"
+	spanSecret     = "secret"
+	spanServiceAcc = "serviceAccount"
+	spanClusterRb  = "clusterRoleBinding"
+	spanFinalizer  = "finalizer"
+	spanRole       = "role"
+	spanRoleBind   = "roleBinding"
+	spanRetention  = "retention"
+	spanSeed       = "seed"
+	spanSnapshot   = "snapshot"
+	spanRestore    = "restore"
+	spanPreserve   = "preserve"
+	spanUpload     = "upload"
+	spanAssertion  = "assertion"
+	spanSmtpVerify = "smtpVerify"
+	spanLoadTest   = "loadtest"
+	spanChaos      = "chaos"
+	spanUpstreams  = "upstreams"
+	spanOidcIssuer = "oidcIssuer"
+	spanServerFile = "serversFile"
+	spanUsersFile  = "usersFile"
 
 	crGetNotFound = "cr not found, probably it was deleted"
 	crGetFailed   = "failed to get cr"
@@ -84,8 +278,10 @@ const (
 	reconcileStarted  = "staring reconcile"
 	reconcileFinished = "reconciliation finished, nothing to do"
 
-	envBindWebValue  = "0.0.0.0:8025"
-	envBindSmtpValue = "0.0.0.0:1025"
+	envBindWebValue       = "0.0.0.0:8025"
+	envBindWebLocalValue  = "127.0.0.1:8025"
+	envBindSmtpValue      = "0.0.0.0:1025"
+	envBindSmtpLocalValue = "127.0.0.1:1025"
 
 	envSmtpBind         = "MH_SMTP_BIND_ADDR"
 	envApiBind          = "MH_API_BIND_ADDR"
@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	"github.com/banzaicloud/k8s-objectmatcher/patch"
@@ -25,7 +26,9 @@ import (
 	routev1 "github.com/openshift/api/route/v1"
 	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -51,15 +54,40 @@ type MailhogInstanceReconciler struct {
 // requeueTime default ReconcileAfter value is 10 seconds
 var requeueTime = time.Duration(5) * time.Minute
 
+// defaultRetentionInterval is the time between two message purges if the CR does not specify one
+var defaultRetentionInterval = time.Duration(5) * time.Minute
+
+// defaultArchiveUploadInterval is the time between two uploads to the object storage if the CR does not specify one
+var defaultArchiveUploadInterval = time.Duration(15) * time.Minute
+
+// messagesStatusInterval is the time between two refreshes of the message status
+var messagesStatusInterval = time.Duration(1) * time.Minute
+
+// podRequestTimeout bounds every request to the api or the smtp proxy of a single pod while refreshing the status
+var podRequestTimeout = time.Duration(2) * time.Second
+
+// httpClient is used for all http requests the operator sends itself (e.g. to mailhog apis or oidc issuers)
+var httpClient = &http.Client{Timeout: time.Duration(5) * time.Second}
+
 //+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhoginstances,verbs=*
 //+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhoginstances/status,verbs=*
 //+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhoginstances/scale,verbs=*
 //+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhoginstances/finalizers,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogupstreams;clustermailhogupstreams,verbs=get;list;watch
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=*
 //+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=*
 //+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=services,verbs=*
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=*
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=*
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=*
+//+kubebuilder:rbac:groups="",resources=services/proxy,verbs=get;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=*
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=*
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=*
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=*
 //+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=*
 //+kubebuilder:rbac:groups="",resources=events,verbs=create
 
@@ -82,6 +110,14 @@ func (r *MailhogInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
+	// clean up cluster scoped objects before the CR is gone
+	if !cr.DeletionTimestamp.IsZero() {
+		if err := r.finalize(ctx, cr); err != nil {
+			return ctrl.Result{RequeueAfter: requeueTime}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// ensure child objects
 	for _, ensure := range controllerAssurances {
 		if err := ensure(ctx, r, cr); err != nil {
@@ -90,16 +126,59 @@ func (r *MailhogInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	}
 
 	r.logger.Info(reconcileFinished)
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: periodicRequeueTime(cr)}, nil
+}
+
+// periodicRequeueTime returns when the CR needs to be reconciled again for periodic tasks, 0 if there are none
+// the message status is refreshed as long as an api answered, so it does not go stale between pod events
+func periodicRequeueTime(cr *mailhogv1alpha1.MailhogInstance) (requeue time.Duration) {
+	if cr.Spec.Settings.Retention != nil {
+		requeue = retentionInterval(cr)
+	}
+	if cr.Spec.Settings.Archive != nil && (requeue == 0 || archiveUploadInterval(cr) < requeue) {
+		requeue = archiveUploadInterval(cr)
+	}
+	if (cr.Status.Messages != nil || smtpProxyIntercepts(cr)) && (requeue == 0 || messagesStatusInterval < requeue) {
+		requeue = messagesStatusInterval
+	}
+	// preserved messages are replayed again after a failed delivery, a failed export blocking the rollout is retried
+	if preserve := cr.Status.Preserve; preserve != nil && preserve.Phase != mailhogv1alpha1.RestoredPreservePhase && (requeue == 0 || archiveRetryTime < requeue) {
+		requeue = archiveRetryTime
+	}
+	// an upload run that reached its message limit continues shortly
+	if status := cr.Status.Archive; cr.Spec.Settings.Archive != nil && status != nil && status.Pending && (requeue == 0 || archiveRetryTime < requeue) {
+		requeue = archiveRetryTime
+	}
+	if smtpVerificationFailed(cr) && (requeue == 0 || archiveRetryTime < requeue) {
+		requeue = archiveRetryTime
+	}
+	if upstreamChecksEnabled(cr) && (requeue == 0 || upstreamCheckInterval < requeue) {
+		requeue = upstreamCheckInterval
+	}
+	return requeue
 }
 
 var controllerAssurances = []func(context.Context, *MailhogInstanceReconciler, *mailhogv1alpha1.MailhogInstance) error{
 	ensureCrValid,
+	ensureOidcIssuerDiscovered,
+	ensureSecret,
+	ensureServiceAccount,
+	ensureClusterRoleBinding,
+	ensureRoles,
+	ensureRoleBindings,
 	ensureDeployment,
 	ensureService,
 	ensureConfigMap,
+	ensureUpstreamsSecret,
+	ensureUsersSecret,
 	ensureRoute,
 	ensureIngress,
+	ensureRetention,
+	ensureSeed,
+	ensurePreserved,
+	ensureArchiveUpload,
+	ensureSmtpVerified,
+	ensureUpstreamsChecked,
 	ensureStatus,
 }
 
@@ -134,10 +213,40 @@ func (r *MailhogInstanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&corev1.Service{}).
 		Owns(&routev1.Route{}).
 		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&batchv1.Job{}).
 		Watches(
 			&source.Kind{Type: &corev1.Pod{}},
 			handler.EnqueueRequestsFromMapFunc(r.findObjectsForPod),
 			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
 		).
+		Watches(
+			&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.findObjectsForSettingsFile),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findObjectsForSettingsFile),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findObjectsForCatalogCredentials),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
+		).
+		Watches(
+			&source.Kind{Type: &mailhogv1alpha1.MailhogUpstream{}},
+			handler.EnqueueRequestsFromMapFunc(r.findObjectsForUpstream),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})),
+		).
+		Watches(
+			&source.Kind{Type: &mailhogv1alpha1.ClusterMailhogUpstream{}},
+			handler.EnqueueRequestsFromMapFunc(r.findObjectsForClusterUpstream),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})),
+		).
 		Complete(r)
 }
@@ -1,8 +1,28 @@
 package controllers
 
 import (
+	"bufio"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"k8s.io/client-go/tools/record"
 
@@ -12,9 +32,16 @@ import (
 	. "github.com/onsi/gomega"
 	routev1 "github.com/openshift/api/route/v1"
 	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	"goimports.patrick.mx/mailhog-operator/smtpproxy"
+	"golang.org/x/crypto/bcrypt"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -53,7 +80,7 @@ var _ = BeforeSuite(func() {
 	err = routev1.AddToScheme(scheme)
 	Expect(err).NotTo(HaveOccurred())
 
-	recorder = record.NewFakeRecorder(10)
+	recorder = record.NewFakeRecorder(1024)
 })
 
 var _ = AfterSuite(func() {
@@ -182,8 +209,8 @@ var _ = Describe("MailhogInstance controller", func() {
 		})
 	})
 
-	Context("reconcile with a mailhog cr that needs a configmap for ui password", func() {
-		It("should create the configmap", func() {
+	Context("reconcile with a mailhog cr that needs a users file for ui password", func() {
+		It("should render the users file into a secret instead of the configmap", func() {
 			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
 			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
 				WebUsers: []mailhogv1alpha1.MailhogWebUserSpec{
@@ -205,15 +232,89 @@ var _ = Describe("MailhogInstance controller", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(res).Should(Equal(reconcile.Result{}))
 
-			createdConfigMap := &corev1.ConfigMap{}
-			err = k8sClient.Get(ctx, nsname, createdConfigMap)
+			usersSecret := &corev1.Secret{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: usersSecretName(cr), Namespace: ns}, usersSecret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(usersSecret.Data[settingsFilePasswordsName]).To(HavePrefix("gOmega:bcrypt.gibberish\n"))
+			Expect(k8sClient.Get(ctx, nsname, &corev1.ConfigMap{})).ToNot(Succeed())
+
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			Expect(createdDeployment.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name:         volumeNameSettings,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: usersSecretName(cr)}},
+			}))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that protects the ui with basic auth", func() {
+		It("should check readiness as internal probe user without exposing its password", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.WebPath = "mailhog"
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				WebUsers: []mailhogv1alpha1.MailhogWebUserSpec{
+					{
+						Name:         "gOmega",
+						PasswordHash: "bcrypt.gibberish",
+					},
+				},
+			}
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			createdSecret := &corev1.Secret{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: ns}, createdSecret)
+			Expect(err).ToNot(HaveOccurred())
+			password := createdSecret.Data[secretKeyProbePassword]
+			hash := createdSecret.Data[secretKeyProbeHash]
+			Expect(bcrypt.CompareHashAndPassword(hash, password)).To(Succeed())
+
+			usersSecret := &corev1.Secret{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: usersSecretName(cr), Namespace: ns}, usersSecret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(usersSecret.Data[settingsFilePasswordsName])).To(ContainSubstring(probeUserName + ":" + string(hash) + "\n"))
+			Expect(string(usersSecret.Data[settingsFilePasswordsName])).ToNot(ContainSubstring(string(password)))
+
+			createdDeployment := &appsv1.Deployment{}
+			err = k8sClient.Get(ctx, nsname, createdDeployment)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(createdConfigMap.Data[settingsFilePasswordsName]).ToNot(BeEmpty())
+			container := createdDeployment.Spec.Template.Spec.Containers[0]
+			Expect(container.ReadinessProbe.Exec).ToNot(BeNil())
+			Expect(container.ReadinessProbe.Exec.Command[2]).To(ContainSubstring("@127.0.0.1:8025/mailhog" + httpHealthPath))
+			Expect(container.ReadinessProbe.Exec.Command[2]).ToNot(ContainSubstring(string(password)))
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{
+				Name: envProbePassword,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: generatedSecretName(cr)},
+						Key:                  secretKeyProbePassword,
+					},
+				},
+			}))
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: ns}, createdSecret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdSecret.Data[secretKeyProbeHash]).To(Equal(hash))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			status, _ := json.Marshal(updatedCr.Status)
+			Expect(string(status)).ToNot(ContainSubstring(string(password)))
 		})
 	})
 
-	Context("reconcile with a mailhog cr that needs a configmap for smtp upstream", func() {
-		It("should create the configmap correctly formatted", func() {
+	Context("reconcile with a mailhog cr that needs a settings file for smtp upstream", func() {
+		It("should create the upstreams secret correctly formatted", func() {
 			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
 			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
 				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{
@@ -242,12 +343,21 @@ var _ = Describe("MailhogInstance controller", func() {
 			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
 			res, err := r.Reconcile(ctx, req)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(res).Should(Equal(reconcile.Result{}))
+			Expect(res).Should(Equal(reconcile.Result{RequeueAfter: upstreamCheckInterval}))
 
-			createdConfigMap := &corev1.ConfigMap{}
-			err = k8sClient.Get(ctx, nsname, createdConfigMap)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(createdConfigMap.Data[settingsFileUpstreamsName]).To(Equal(expectedJson))
+			Expect(k8sClient.Get(ctx, nsname, &corev1.ConfigMap{})).ToNot(Succeed())
+			upstreamsSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: upstreamsSecretName(cr), Namespace: ns}, upstreamsSecret)).To(Succeed())
+			Expect(string(upstreamsSecret.Data[settingsFileUpstreamsName])).To(Equal(expectedJson))
+
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			Expect(createdDeployment.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name:         volumeNameServers,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: upstreamsSecretName(cr)}},
+			}))
+			Expect(createdDeployment.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: volumeNameServers, MountPath: settingsServersMount, ReadOnly: true}))
+			Expect(createdDeployment.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: envUpstreamSmtpFile, Value: settingsFileUpstreamsPath}))
 		})
 	})
 
@@ -307,6 +417,84 @@ var _ = Describe("MailhogInstance controller", func() {
 			Expect(updatedCr.Status.Error).ToNot(BeEmpty())
 			Expect(updatedCr.Status.Error).To(Equal(errJimNonFloatFound.Error()))
 		})
+
+		It("should reject probabilities outside 0..1 and link speeds with min above max", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Jim.Invite = true
+			cr.Spec.Settings.Jim.Disconnect = "7.5"
+			Expect(checkJimSettings(cr)).To(MatchError(errJimProbabilityRange))
+
+			cr.Spec.Settings.Jim.Disconnect = ""
+			cr.Spec.Settings.Jim.RejectAuthChance = "-0.1"
+			Expect(checkJimSettings(cr)).To(MatchError(errJimProbabilityRange))
+
+			cr.Spec.Settings.Jim.RejectAuthChance = ""
+			cr.Spec.Settings.Jim.LinkspeedMin = "4096"
+			cr.Spec.Settings.Jim.LinkspeedMaxBytes = int64Ptr(2048)
+			Expect(checkJimSettings(cr)).To(MatchError(errJimLinkspeedRange))
+
+			cr.Spec.Settings.Jim.LinkspeedMaxBytes = nil
+			cr.Spec.Settings.Jim.LinkspeedMin = "20000"
+			Expect(checkJimSettings(cr)).To(MatchError(errJimLinkspeedRange))
+
+			cr.Spec.Settings.Jim.LinkspeedMin = "1.5"
+			Expect(checkJimSettings(cr)).To(MatchError(errJimNonIntegerFound))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that uses a jim preset", func() {
+		It("should expand the preset into args and show the effective values in the status", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Jim.Preset = mailhogv1alpha1.SlowLinkJimPreset
+			cr.Spec.Settings.Jim.LinkspeedMaxBytes = int64Ptr(4096)
+			cr.Spec.Settings.Jim.RejectAuth = "0.5"
+			cr.Spec.Settings.Jim.RejectAuthChance = "0.25"
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+				argsJimInvite,
+				"-" + argsJimDisconnectRate + "=0",
+				"-" + argsJimAccept + "=1",
+				"-" + argsJimLinkSpeedAffect + "=1",
+				"-" + argsJimLinkSpeedMin + "=256",
+				"-" + argsJimLinkSpeedMax + "=4096",
+				"-" + argsJimRejectSender + "=0",
+				"-" + argsJimRejectRecipient + "=0",
+				"-" + argsJimRejectAuth + "=0.25",
+			}))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Jim).To(Equal(&mailhogv1alpha1.JimStatus{
+				Preset:          mailhogv1alpha1.SlowLinkJimPreset,
+				Disconnect:      "0",
+				Accept:          "1",
+				LinkspeedAffect: "1",
+				LinkspeedMin:    "256",
+				LinkspeedMax:    "4096",
+				RejectSender:    "0",
+				RejectRecipient: "0",
+				RejectAuth:      "0.25",
+			}))
+		})
+
+		It("should show mailhog's defaults for values that are not set", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Jim.Invite = true
+			cr.Spec.Settings.Jim.AcceptChance = "0.5"
+
+			Expect(jimArgs(cr)).To(Equal([]string{argsJimInvite, "-" + argsJimAccept + "=0.5"}))
+			status := jimStatus(cr)
+			Expect(status.Accept).To(Equal("0.5"))
+			Expect(status.Disconnect).To(Equal("0.005"))
+			Expect(status.LinkspeedMax).To(Equal("10240"))
+		})
 	})
 
 	Context("reconcile with a mailhog cr that specifies an non-relative webroot", func() {
@@ -332,7 +520,2490 @@ var _ = Describe("MailhogInstance controller", func() {
 			}
 		})
 	})
-})
+
+	Context("reconcile with a mailhog cr that enables smtp tls", func() {
+		It("should add the smtp proxy sidecar and expose its ports", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.SmtpTls = &mailhogv1alpha1.MailhogSmtpTlsSpec{
+				SecretName: "smtp-cert",
+			}
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).Should(Equal(reconcile.Result{}))
+
+			createdDeployment := &appsv1.Deployment{}
+			err = k8sClient.Get(ctx, nsname, createdDeployment)
+			Expect(err).ToNot(HaveOccurred())
+			containers := createdDeployment.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[1].Name).To(Equal(smtpProxyName))
+			Expect(containers[1].Ports).To(HaveLen(2))
+			Expect(containers[1].Resources.Limits.Memory().String()).To(Equal(defaultSidecarResourceMem))
+			Expect(createdDeployment.Spec.Template.Spec.Volumes[0].Secret.SecretName).To(Equal("smtp-cert"))
+
+			createdService := &corev1.Service{}
+			err = k8sClient.Get(ctx, nsname, createdService)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdService.Spec.Ports).To(HaveLen(4))
+			Expect(createdService.Spec.Ports[2].Port).To(BeEquivalentTo(portSmtpStartTlsDefault))
+			Expect(createdService.Spec.Ports[3].Port).To(BeEquivalentTo(portSmtpTlsDefault))
+		})
+
+		It("should give the sidecars the resources of the cr", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.SmtpTls = &mailhogv1alpha1.MailhogSmtpTlsSpec{SecretName: "smtp-cert"}
+			cr.Spec.Settings.SidecarResources = &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+			}
+
+			containers := deploymentNew(cr).Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[1].Resources.Limits.Memory().String()).To(Equal("256Mi"))
+			Expect(containers[1].Resources.Requests).To(BeEmpty())
+		})
+	})
+
+	Context("reconcile with a mailhog cr that injects smtp faults", func() {
+		It("should route smtp traffic through the proxy and report the fault hits", func() {
+			mailhogApi := startFakeMailhogApi(nil)
+			defer mailhogApi.close()
+			admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.URL.Path).To(Equal(smtpproxy.RulesPath))
+				user, password, found := req.BasicAuth()
+				Expect(found).To(BeTrue())
+				Expect(user).To(Equal(probeUserName))
+				Expect(password).ToNot(BeEmpty())
+				_ = json.NewEncoder(w).Encode([]smtpproxy.RuleHits{{Name: "bounces", Hits: 3}, {Name: "greylist", Hits: 1}})
+			}))
+			defer admin.Close()
+			originalAdminUrl := smtpProxyAdminUrl
+			smtpProxyAdminUrl = func(*corev1.Pod) string { return admin.URL }
+			defer func() { smtpProxyAdminUrl = originalAdminUrl }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.SmtpFaults = []mailhogv1alpha1.MailhogSmtpFaultSpec{
+				{Name: "bounces", Stage: mailhogv1alpha1.RcptToSmtpFaultStage, Address: "bounce-*@", Action: mailhogv1alpha1.RejectSmtpFaultAction},
+				{Name: "greylist", Stage: mailhogv1alpha1.MailFromSmtpFaultStage, Action: mailhogv1alpha1.GreylistSmtpFaultAction},
+				{Name: "slow", Stage: mailhogv1alpha1.DataSmtpFaultStage, Address: "@slow.example", Action: mailhogv1alpha1.DelaySmtpFaultAction,
+					Delay: &metav1.Duration{Duration: 2 * time.Second}},
+			}
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod-a"), getTestingPod(cr, "tester-pod-b"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(messagesStatusInterval))
+
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			containers := createdDeployment.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[1].Ports[0].ContainerPort).To(BeEquivalentTo(portSmtpProxy))
+			Expect(containers[1].ReadinessProbe.TCPSocket.Port.IntValue()).To(Equal(portSmtpProxy))
+			cfg := smtpproxy.Config{}
+			Expect(json.Unmarshal([]byte(containers[1].Env[0].Value), &cfg)).To(Succeed())
+			Expect(cfg.Listeners).To(Equal([]smtpproxy.Listener{
+				{Name: portSmtpProxyName, Address: ":1026", Mode: smtpproxy.PlainMode},
+				{Name: portSmtpOperatorName, Address: ":1027", Mode: smtpproxy.OperatorMode},
+			}))
+			Expect(cfg.AdminAddress).To(Equal(":8026"))
+			Expect(cfg.Backend).To(Equal(envBindSmtpLocalValue))
+			Expect(containers[0].Env).To(ContainElement(corev1.EnvVar{Name: envSmtpBind, Value: envBindSmtpLocalValue}))
+			for _, port := range containers[0].Ports {
+				Expect(port.ContainerPort).ToNot(BeEquivalentTo(portSmtp))
+			}
+			Expect(cfg.Rules).To(HaveLen(3))
+			Expect(cfg.Rules[0].Code).To(Equal(smtpFaultDefaultCode))
+			Expect(cfg.Rules[2].Delay).To(Equal(2 * time.Second))
+
+			createdService := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, nsname, createdService)).To(Succeed())
+			Expect(createdService.Spec.Ports).To(HaveLen(2))
+			Expect(createdService.Spec.Ports[0].TargetPort.IntValue()).To(Equal(portSmtpProxy))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.SmtpFaults).To(Equal([]mailhogv1alpha1.SmtpFaultStatus{
+				{Name: "bounces", Hits: 6}, {Name: "greylist", Hits: 2}, {Name: "slow", Hits: 0},
+			}))
+		})
+
+		It("should refuse faults the proxy can not apply", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.SmtpFaults = []mailhogv1alpha1.MailhogSmtpFaultSpec{
+				{Name: "slow", Stage: mailhogv1alpha1.DataSmtpFaultStage, Action: mailhogv1alpha1.DelaySmtpFaultAction},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errInvalidSmtpFault))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Error).To(HavePrefix(errInvalidSmtpFault.Error()))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that enforces an smtp policy", func() {
+		It("should mount the credentials into the proxy and report the violations", func() {
+			mailhogApi := startFakeMailhogApi(nil)
+			defer mailhogApi.close()
+			admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.URL.Path).To(Equal(smtpproxy.ViolationsPath))
+				_ = json.NewEncoder(w).Encode(map[smtpproxy.Violation]int64{smtpproxy.AuthViolation: 2, smtpproxy.SizeViolation: 1})
+			}))
+			defer admin.Close()
+			originalAdminUrl := smtpProxyAdminUrl
+			smtpProxyAdminUrl = func(*corev1.Pod) string { return admin.URL }
+			defer func() { smtpProxyAdminUrl = originalAdminUrl }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.SmtpPolicy = &mailhogv1alpha1.MailhogSmtpPolicySpec{
+				AuthSecretName:       "smtp-users",
+				AllowedSenderDomains: []string{"app.example"},
+				MaxMessageBytes:      1048576,
+				MaxRecipients:        10,
+			}
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod-a"), getTestingPod(cr, "tester-pod-b"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			containers := createdDeployment.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[1].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: volumeNameSmtpAuth, MountPath: smtpProxyAuthMount, ReadOnly: true}))
+			Expect(createdDeployment.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name:         volumeNameSmtpAuth,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "smtp-users"}},
+			}))
+			cfg := smtpproxy.Config{}
+			Expect(json.Unmarshal([]byte(containers[1].Env[0].Value), &cfg)).To(Succeed())
+			Expect(cfg.Rules).To(BeEmpty())
+			Expect(cfg.Policy).To(Equal(smtpproxy.Policy{
+				CredentialsDir:       smtpProxyAuthMount,
+				AllowedSenderDomains: []string{"app.example"},
+				MaxMessageSize:       1048576,
+				MaxRecipients:        10,
+			}))
+
+			createdService := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, nsname, createdService)).To(Succeed())
+			Expect(createdService.Spec.Ports[0].TargetPort.IntValue()).To(Equal(portSmtpProxy))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.SmtpFaults).To(BeNil())
+			Expect(updatedCr.Status.SmtpPolicyViolations).To(Equal(&mailhogv1alpha1.SmtpPolicyViolationsStatus{Auth: 4, Size: 2}))
+		})
+
+		It("should refuse sender domains that can never match", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.SmtpPolicy = &mailhogv1alpha1.MailhogSmtpPolicySpec{
+				AllowedSenderDomains: []string{"app@example.com"},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errInvalidSenderDomain))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that records smtp transcripts", func() {
+		It("should configure the proxy and keep its admin port on the pods for the operator", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.SmtpTranscripts = &mailhogv1alpha1.MailhogSmtpTranscriptsSpec{}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			containers := createdDeployment.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			cfg := smtpproxy.Config{}
+			Expect(json.Unmarshal([]byte(containers[1].Env[0].Value), &cfg)).To(Succeed())
+			Expect(cfg.Transcripts).To(Equal(smtpTranscriptsDefaultKeep))
+			Expect(cfg.AdminAddress).To(Equal(":8026"))
+			Expect(cfg.Operator).To(Equal(smtpproxy.Operator{User: probeUserName, PasswordFile: smtpProxyOperatorPasswdPath}))
+			Expect(containers[1].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: volumeNameSmtpOperator, MountPath: smtpProxyOperatorMount, ReadOnly: true}))
+			Expect(createdDeployment.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+				Name: volumeNameSmtpOperator,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+					SecretName: generatedSecretName(cr),
+					Items:      []corev1.KeyToPath{{Key: secretKeyProbePassword, Path: smtpProxyOperatorPassword}},
+				}},
+			}))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: ns}, &corev1.Secret{})).To(Succeed())
+
+			createdService := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, nsname, createdService)).To(Succeed())
+			Expect(createdService.Spec.Ports).To(HaveLen(2))
+			Expect(createdService.Spec.Ports[0].TargetPort.IntValue()).To(Equal(portSmtpProxy))
+			for _, port := range createdService.Spec.Ports {
+				Expect(port.Name).ToNot(Equal(portSmtpProxyAdminName))
+			}
+		})
+	})
+
+	Context("reconcile with a mailhog cr that relays captured mail", func() {
+		It("should configure the relay rules and summarise them in the status", func() {
+			mailhogApi := startFakeMailhogApi(nil)
+			defer mailhogApi.close()
+			admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.URL.Path).To(Equal(smtpproxy.RelayPath))
+				_ = json.NewEncoder(w).Encode([]smtpproxy.RelayStats{{Name: "staff", Relayed: 4, Retries: 1, Pending: 1}})
+			}))
+			defer admin.Close()
+			originalAdminUrl := smtpProxyAdminUrl
+			smtpProxyAdminUrl = func(*corev1.Pod) string { return admin.URL }
+			defer func() { smtpProxyAdminUrl = originalAdminUrl }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{
+					{Name: "corp", Save: true, Email: "qa@ourcompany.com", Host: "relay.ourcompany.com", Port: "587", Username: "mailhog", Password: "secret", Mechanism: "PLAIN"},
+					{Name: "other", Save: true, Email: "qa@other.example", Host: "relay.other.example", Port: "25"},
+				},
+			}
+			cr.Spec.Settings.SmtpRelay = &mailhogv1alpha1.MailhogSmtpRelaySpec{
+				Rules: []mailhogv1alpha1.MailhogSmtpRelayRuleSpec{
+					{Name: "staff", Recipients: []string{"@ourcompany.com"}, Upstream: "corp"},
+				},
+				RatePerMinute: 30,
+			}
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod-a"), getTestingPod(cr, "tester-pod-b"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			containers := createdDeployment.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			cfg := smtpproxy.Config{}
+			Expect(json.Unmarshal([]byte(containers[1].Env[0].Value), &cfg)).To(Succeed())
+			Expect(cfg.Relay).To(Equal(smtpproxy.Relay{
+				Upstreams: []smtpproxy.RelayUpstream{
+					{Name: "corp", Address: "relay.ourcompany.com:587", Mechanism: "PLAIN",
+						UsernameFile: smtpProxyRelayMount + "/relay-0-username", PasswordFile: smtpProxyRelayMount + "/relay-0-password"},
+				},
+				Rules:         []smtpproxy.RelayRule{{Name: "staff", Recipients: []string{"@ourcompany.com"}, Upstream: "corp"}},
+				MaxAttempts:   smtpRelayDefaultAttempts,
+				RetryInterval: smtpRelayDefaultRetry,
+				RatePerMinute: 30,
+				MaxQueueBytes: 8 << 20,
+			}))
+			Expect(containers[1].Env[0].Value).ToNot(ContainSubstring("secret"))
+			Expect(containers[1].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: volumeNameSmtpRelay, MountPath: smtpProxyRelayMount, ReadOnly: true}))
+			Expect(createdDeployment.Spec.Template.Spec.Volumes).To(ContainElement(HaveField("Secret", &corev1.SecretVolumeSource{
+				SecretName: upstreamsSecretName(cr),
+				Items: []corev1.KeyToPath{
+					{Key: "relay-0-password", Path: "relay-0-password"},
+					{Key: "relay-0-username", Path: "relay-0-username"},
+				},
+			})))
+			upstreamsSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: upstreamsSecretName(cr), Namespace: cr.Namespace}, upstreamsSecret)).To(Succeed())
+			Expect(upstreamsSecret.Data).To(HaveKeyWithValue("relay-0-username", []byte("mailhog")))
+			Expect(upstreamsSecret.Data).To(HaveKeyWithValue("relay-0-password", []byte("secret")))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.SmtpRelay).To(Equal([]mailhogv1alpha1.SmtpRelayStatus{
+				{Name: "staff", Relayed: 8, Retries: 2, Pending: 2},
+			}))
+		})
+
+		It("should refuse rules referencing an unknown upstream", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.SmtpRelay = &mailhogv1alpha1.MailhogSmtpRelaySpec{
+				Rules: []mailhogv1alpha1.MailhogSmtpRelayRuleSpec{
+					{Name: "staff", Recipients: []string{"@ourcompany.com"}, Upstream: "corp"},
+				},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errInvalidSmtpRelay))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Error).To(ContainSubstring("unknown upstream"))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that has smtp upstreams", func() {
+		It("should report the upstream checks and warn about failing upstreams", func() {
+			server := startFakeSmtpServer()
+			defer server.close()
+			server.requireAuth("secret")
+			host, port, err := net.SplitHostPort(server.address)
+			Expect(err).ToNot(HaveOccurred())
+			closed, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			_, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+			Expect(closed.Close()).To(Succeed())
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{
+					{Name: "corp", Save: true, Email: "qa@ourcompany.com", Host: host, Port: port, Username: "mailhog", Password: "secret", Mechanism: "PLAIN"},
+					{Name: "typo", Save: true, Email: "qa@ourcompany.com", Host: host, Port: port, Username: "mailhog", Password: "secrte", Mechanism: "PLAIN"},
+					{Name: "down", Save: true, Email: "qa@ourcompany.com", Host: host, Port: closedPort},
+				},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+			events := record.NewFakeRecorder(100)
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: events}
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(upstreamCheckInterval))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			upstreams := updatedCr.Status.Upstreams
+			Expect(upstreams).To(HaveLen(3))
+			Expect(upstreams[0]).To(SatisfyAll(
+				HaveField("Name", "corp"), HaveField("Address", server.address), HaveField("Reachable", true),
+				HaveField("Tls", false), HaveField("Auth", mailhogv1alpha1.AuthSucceededResult), HaveField("Error", ""),
+			))
+			Expect(upstreams[0].LastCheckTime).ToNot(BeNil())
+			Expect(upstreams[1]).To(SatisfyAll(
+				HaveField("Name", "typo"), HaveField("Reachable", true), HaveField("Auth", mailhogv1alpha1.AuthFailedResult),
+				HaveField("Error", ContainSubstring("535")),
+			))
+			Expect(upstreams[2]).To(SatisfyAll(
+				HaveField("Name", "down"), HaveField("Reachable", false), HaveField("Auth", mailhogv1alpha1.AuthSkippedResult),
+				HaveField("Error", ContainSubstring("refused")),
+			))
+
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.UpstreamsReachableCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.UnreachableReason))
+			Expect(condition.Message).To(Equal("failed upstreams: typo, down"))
+			Expect(upstreamCheckEvents(events)).To(ConsistOf(
+				HavePrefix("Warning UpstreamCheckFailed upstream typo ("+server.address+"): auth: 535"),
+				HavePrefix("Warning UpstreamCheckFailed upstream down"),
+			))
+
+			// the upstreams are not checked again before the interval passed
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(upstreamCheckEvents(events)).To(BeEmpty())
+		})
+
+		It("should drop the results once the checks are disabled", func() {
+			server := startFakeSmtpServer()
+			defer server.close()
+			host, port, err := net.SplitHostPort(server.address)
+			Expect(err).ToNot(HaveOccurred())
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{
+					{Name: "corp", Save: true, Email: "qa@ourcompany.com", Host: host, Port: port},
+				},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Upstreams).To(HaveLen(1))
+			Expect(updatedCr.Status.Upstreams[0].Auth).To(Equal(mailhogv1alpha1.AuthSkippedResult))
+			Expect(meta.IsStatusConditionTrue(updatedCr.Status.Conditions, mailhogv1alpha1.UpstreamsReachableCondition)).To(BeTrue())
+
+			updatedCr.Spec.Settings.DisableUpstreamChecks = true
+			Expect(k8sClient.Update(ctx, updatedCr)).To(Succeed())
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(reconcile.Result{}))
+
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Upstreams).To(BeNil())
+			Expect(meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.UpstreamsReachableCondition)).To(BeNil())
+		})
+	})
+
+	Context("reconcile with a mailhog cr that trusts custom ca bundles", func() {
+		It("should mount the bundles into mailhog and the relay", func() {
+			_, caPem := testCertificate("relay.corp")
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.DisableUpstreamChecks = true
+			cr.Spec.Settings.TrustedCa = &mailhogv1alpha1.MailhogTrustedCaSpec{ConfigMapName: "trusted-bundle"}
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{
+					{
+						Name: "corp", Save: true, Email: "qa@ourcompany.com", Host: "10.0.0.25", Port: "587",
+						Tls: &mailhogv1alpha1.MailhogUpstreamTlsSpec{
+							CaSecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "corp-ca"}, Key: "ca.crt"},
+							ServerName:     "relay.corp",
+							MinVersion:     "1.3",
+						},
+					},
+				},
+			}
+			cr.Spec.Settings.SmtpRelay = &mailhogv1alpha1.MailhogSmtpRelaySpec{
+				Rules: []mailhogv1alpha1.MailhogSmtpRelayRuleSpec{
+					{Name: "staff", Recipients: []string{"@ourcompany.com"}, Upstream: "corp"},
+				},
+			}
+			objects := []client.Object{
+				cr,
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "trusted-bundle", Namespace: ns}, Data: map[string]string{"ca-bundle.crt": string(caPem)}},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "corp-ca", Namespace: ns}, Data: map[string][]byte{"ca.crt": caPem}},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			spec := createdDeployment.Spec.Template.Spec
+			Expect(spec.Volumes).To(ContainElements(
+				corev1.Volume{Name: volumeNameTrustedCa, VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "trusted-bundle"},
+				}}},
+				corev1.Volume{Name: volumeNameUpstreamCa, VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{Secret: &corev1.SecretProjection{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "corp-ca"},
+						Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "corp.crt"}},
+					}}},
+				}}},
+			))
+			Expect(spec.Containers).To(HaveLen(2))
+			Expect(spec.Containers[0].Env).To(ContainElements(
+				corev1.EnvVar{Name: envSslCertFile, Value: "/etc/mailhog/trusted-ca/ca-bundle.crt"},
+				corev1.EnvVar{Name: envSslCertDir, Value: "/etc/mailhog/upstream-ca"},
+			))
+			for _, container := range spec.Containers {
+				Expect(container.VolumeMounts).To(ContainElements(
+					corev1.VolumeMount{Name: volumeNameTrustedCa, MountPath: trustedCaMount, ReadOnly: true},
+					corev1.VolumeMount{Name: volumeNameUpstreamCa, MountPath: upstreamCaMount, ReadOnly: true},
+				))
+			}
+
+			cfg := smtpproxy.Config{}
+			Expect(json.Unmarshal([]byte(spec.Containers[1].Env[0].Value), &cfg)).To(Succeed())
+			Expect(cfg.Relay.Upstreams).To(Equal([]smtpproxy.RelayUpstream{{
+				Name: "corp", Address: "10.0.0.25:587", CaFile: "/etc/mailhog/upstream-ca/corp.crt", ServerName: "relay.corp", MinTlsVersion: "1.3",
+			}}))
+			Expect(spec.Containers[1].Env).To(ContainElement(corev1.EnvVar{Name: envSslCertFile, Value: "/etc/mailhog/trusted-ca/ca-bundle.crt"}))
+
+			upstreamsSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: upstreamsSecretName(cr), Namespace: ns}, upstreamsSecret)).To(Succeed())
+			Expect(string(upstreamsSecret.Data[settingsFileUpstreamsName])).ToNot(ContainSubstring("tls"))
+		})
+
+		It("should flag upstreams referencing a missing or invalid ca bundle", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{
+					{
+						Name: "corp", Save: true, Email: "qa@ourcompany.com", Host: "relay.corp", Port: "587",
+						Tls: &mailhogv1alpha1.MailhogUpstreamTlsSpec{
+							CaConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "corp-ca"}, Key: "ca.crt"},
+						},
+					},
+				},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errMissingCaBundle))
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Error).To(HaveSuffix("upstream corp"))
+
+			invalidCa := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "corp-ca", Namespace: ns}, Data: map[string]string{"ca.crt": "not a certificate"}}
+			Expect(k8sClient.Create(ctx, invalidCa)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errInvalidCaBundle))
+
+			cr.Spec.Settings.Files.SmtpUpstreams[0].Tls.CaSecretKeyRef = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "corp-ca"}, Key: "ca.crt"}
+			Expect(checkUpstreamTls(cr)).To(MatchError(errInvalidUpstreamTls))
+			cr.Spec.Settings.Files.SmtpUpstreams[0].Tls.CaSecretKeyRef = nil
+			cr.Spec.Settings.Files.SmtpUpstreams[0].Name = "../corp"
+			Expect(checkUpstreamTls(cr)).To(MatchError(errInvalidUpstreamTls))
+		})
+
+		It("should check STARTTLS upstreams with their ca bundle and ignore the relay only server name", func() {
+			server := startFakeSmtpServer()
+			defer server.close()
+			host, port, err := net.SplitHostPort(server.address)
+			Expect(err).ToNot(HaveOccurred())
+			certificate, caPem := testCertificate(host)
+			server.offerStartTls(certificate)
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{
+					{
+						Name: "corp", Save: true, Email: "qa@ourcompany.com", Host: host, Port: port,
+						Tls: &mailhogv1alpha1.MailhogUpstreamTlsSpec{
+							CaConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "corp-ca"}, Key: "ca.crt"},
+							ServerName:        "relay.corp",
+						},
+					},
+					{Name: "untrusted", Save: true, Email: "qa@ourcompany.com", Host: host, Port: port},
+				},
+			}
+			objects := []client.Object{
+				cr, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "corp-ca", Namespace: ns}, Data: map[string]string{"ca.crt": string(caPem)}},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Upstreams).To(HaveLen(2))
+			Expect(updatedCr.Status.Upstreams[0]).To(SatisfyAll(HaveField("Reachable", true), HaveField("Tls", true), HaveField("Error", "")))
+			Expect(updatedCr.Status.Upstreams[1]).To(SatisfyAll(
+				HaveField("Reachable", true), HaveField("Tls", false), HaveField("Error", SatisfyAll(HavePrefix("starttls: "), ContainSubstring("x509"))),
+			))
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.UpstreamsReachableCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.RejectedReason))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that references catalog upstreams", func() {
+		It("should render the resolved catalog entries next to the inline upstreams", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.DisableUpstreamChecks = true
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{
+					{Name: "local", Host: "localhost", Port: "25"},
+				},
+				UpstreamRefs: []mailhogv1alpha1.MailhogUpstreamRefSpec{
+					{Kind: mailhogv1alpha1.ClusterUpstreamKind, Name: "company"},
+					{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "qa"}}},
+					{Name: "qa-relay"},
+				},
+			}
+			company := &mailhogv1alpha1.ClusterMailhogUpstream{
+				ObjectMeta: metav1.ObjectMeta{Name: "company"},
+				Spec: mailhogv1alpha1.MailhogCatalogUpstreamSpec{
+					Host: "smtp.example.com", Port: "587", Mechanism: "CRAMMD5",
+					CredentialsSecret: &corev1.SecretReference{Name: "company-credentials", Namespace: "mail"},
+				},
+			}
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "company-credentials", Namespace: "mail"},
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("mailhog"),
+					corev1.BasicAuthPasswordKey: []byte("secret"),
+				},
+			}
+			qa := &mailhogv1alpha1.MailhogUpstream{
+				ObjectMeta: metav1.ObjectMeta{Name: "qa-relay", Namespace: ns, Labels: map[string]string{"team": "qa"}},
+				Spec:       mailhogv1alpha1.MailhogCatalogUpstreamSpec{Host: "qa.example.com", Port: "25", Email: "qa@example.com"},
+			}
+			other := &mailhogv1alpha1.MailhogUpstream{
+				ObjectMeta: metav1.ObjectMeta{Name: "qa-relay", Namespace: "other", Labels: map[string]string{"team": "qa"}},
+				Spec:       mailhogv1alpha1.MailhogCatalogUpstreamSpec{Host: "other.example.com", Port: "25"},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, company, credentials, qa, other).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			upstreamsSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: upstreamsSecretName(cr), Namespace: ns}, upstreamsSecret)).To(Succeed())
+			Expect(upstreamsSecret.Data[settingsFileUpstreamsName]).To(MatchJSON(`{
+				"local":{"name":"local","host":"localhost","port":"25"},
+				"company":{"name":"company","host":"smtp.example.com","port":"587","username":"mailhog","password":"secret","mechanism":"CRAMMD5"},
+				"qa-relay":{"name":"qa-relay","email":"qa@example.com","host":"qa.example.com","port":"25"}
+			}`))
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: envUpstreamSmtpFile, Value: settingsFileUpstreamsPath}))
+
+			Expect(r.findObjectsForClusterUpstream(company)).To(Equal([]reconcile.Request{req}))
+			Expect(r.findObjectsForUpstream(qa)).To(Equal([]reconcile.Request{req}))
+			Expect(r.findObjectsForUpstream(other)).To(BeEmpty())
+			unrelated := &mailhogv1alpha1.ClusterMailhogUpstream{ObjectMeta: metav1.ObjectMeta{Name: "unrelated"}}
+			Expect(r.findObjectsForClusterUpstream(unrelated)).To(BeEmpty())
+			Expect(r.findObjectsForCatalogCredentials(credentials)).To(Equal([]reconcile.Request{req}))
+			Expect(r.findObjectsForCatalogCredentials(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "company-credentials", Namespace: ns}})).To(BeEmpty())
+		})
+
+		It("should refuse missing entries, missing credentials and conflicting names", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				UpstreamRefs: []mailhogv1alpha1.MailhogUpstreamRefSpec{
+					{Kind: mailhogv1alpha1.ClusterUpstreamKind, Name: "company"},
+				},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errMissingCatalogUpstream))
+
+			company := &mailhogv1alpha1.ClusterMailhogUpstream{
+				ObjectMeta: metav1.ObjectMeta{Name: "company"},
+				Spec: mailhogv1alpha1.MailhogCatalogUpstreamSpec{
+					Host: "smtp.example.com", Port: "587", Mechanism: "PLAIN",
+					CredentialsSecret: &corev1.SecretReference{Name: "company-credentials"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, company)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errMissingCatalogCredentials))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			company.Spec.CredentialsSecret = nil
+			Expect(k8sClient.Update(ctx, company)).To(Succeed())
+			updatedCr.Spec.Settings.Files.SmtpUpstreams = []mailhogv1alpha1.MailhogUpstreamSpec{
+				{Name: "company", Host: "localhost", Port: "25"},
+			}
+			Expect(k8sClient.Update(ctx, updatedCr)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errUpstreamNameConflict))
+
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Error).To(ContainSubstring("smtpUpstreams and ClusterMailhogUpstream/company"))
+		})
+
+		It("should refuse MailhogUpstreams reading credentials of another namespace", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				UpstreamRefs: []mailhogv1alpha1.MailhogUpstreamRefSpec{{Name: "qa-relay"}},
+			}
+			qa := &mailhogv1alpha1.MailhogUpstream{
+				ObjectMeta: metav1.ObjectMeta{Name: "qa-relay", Namespace: ns},
+				Spec: mailhogv1alpha1.MailhogCatalogUpstreamSpec{
+					Host: "qa.example.com", Port: "587", Mechanism: "PLAIN",
+					CredentialsSecret: &corev1.SecretReference{Name: "company-credentials", Namespace: "mail"},
+				},
+			}
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "company-credentials", Namespace: "mail"},
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("mailhog"),
+					corev1.BasicAuthPasswordKey: []byte("secret"),
+				},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, qa, credentials).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errForeignCatalogCredentials))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: upstreamsSecretName(cr), Namespace: ns}, &corev1.Secret{})).ToNot(Succeed())
+
+			qa.Spec.CredentialsSecret.Namespace = ns
+			credentials = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "company-credentials", Namespace: ns},
+				Data:       credentials.Data,
+			}
+			Expect(k8sClient.Update(ctx, qa)).To(Succeed())
+			Expect(k8sClient.Create(ctx, credentials)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should refuse references with neither or both of name and selector", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				UpstreamRefs: []mailhogv1alpha1.MailhogUpstreamRefSpec{
+					{Name: "qa-relay", Selector: &metav1.LabelSelector{}},
+				},
+			}
+			Expect(checkUpstreamRefs(cr)).To(MatchError(errInvalidUpstreamRef))
+			cr.Spec.Settings.Files.UpstreamRefs[0] = mailhogv1alpha1.MailhogUpstreamRefSpec{}
+			Expect(checkUpstreamRefs(cr)).To(MatchError(errInvalidUpstreamRef))
+			cr.Spec.Settings.Files.UpstreamRefs[0] = mailhogv1alpha1.MailhogUpstreamRefSpec{Selector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Near"}},
+			}}
+			Expect(checkUpstreamRefs(cr)).To(MatchError(errInvalidUpstreamRef))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that reads settings files from existing objects", func() {
+		It("should mount a sole upstreams file directly and merge the users file", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.DisableUpstreamChecks = true
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				WebUsers: []mailhogv1alpha1.MailhogWebUserSpec{{Name: "inline", PasswordHash: "bcrypt.inline"}},
+				UpstreamsFrom: &mailhogv1alpha1.MailhogFileSourceSpec{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "gitops"}, Key: "servers.json"},
+				},
+				UsersFrom: &mailhogv1alpha1.MailhogFileSourceSpec{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "sealed"}, Key: "users"},
+				},
+			}
+			upstreamsFile := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "gitops", Namespace: ns},
+				Data:       map[string]string{"servers.json": `{"corp":{"name":"corp","host":"smtp.example.com","port":"25"}}`},
+			}
+			usersFile := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "sealed", Namespace: ns},
+				Data:       map[string][]byte{"users": []byte("alice:bcrypt.alice\n\nbob:bcrypt.bob\n")},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, upstreamsFile, usersFile).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			createdSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: ns}, createdSecret)).To(Succeed())
+			Expect(k8sClient.Get(ctx, nsname, &corev1.ConfigMap{})).ToNot(Succeed())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: upstreamsSecretName(cr), Namespace: ns}, &corev1.Secret{})).ToNot(Succeed())
+			usersSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: usersSecretName(cr), Namespace: ns}, usersSecret)).To(Succeed())
+			Expect(string(usersSecret.Data[settingsFilePasswordsName])).To(Equal(
+				"inline:bcrypt.inline\nalice:bcrypt.alice\nbob:bcrypt.bob\n" + probeUserName + ":" + string(createdSecret.Data[secretKeyProbeHash]) + "\n",
+			))
+
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			podSpec := createdDeployment.Spec.Template.Spec
+			Expect(podSpec.Volumes).To(ContainElement(corev1.Volume{
+				Name: volumeNameSettingsFrom,
+				VolumeSource: corev1.VolumeSource{
+					Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{{
+							ConfigMap: &corev1.ConfigMapProjection{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "gitops"},
+								Items:                []corev1.KeyToPath{{Key: "servers.json", Path: settingsFileUpstreamsName}},
+							},
+						}},
+					},
+				},
+			}))
+			Expect(podSpec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: volumeNameSettingsFrom, MountPath: settingsFromMount, ReadOnly: true}))
+			Expect(podSpec.Containers[0].Env).To(ContainElements(
+				corev1.EnvVar{Name: envUpstreamSmtpFile, Value: settingsFromUpstreamsPath},
+				corev1.EnvVar{Name: envWebAuthFile, Value: settingsFilePasswordsPath},
+			))
+			Expect(podSpec.Containers[0].ReadinessProbe.Exec).ToNot(BeNil())
+
+			Expect(r.findObjectsForSettingsFile(upstreamsFile)).To(Equal([]reconcile.Request{req}))
+			Expect(r.findObjectsForSettingsFile(usersFile)).To(Equal([]reconcile.Request{req}))
+			Expect(r.findObjectsForSettingsFile(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "gitops", Namespace: ns}})).To(BeEmpty())
+
+			// a changed users file is merged again
+			usersFile.Data["users"] = []byte("carol:bcrypt.carol\n")
+			Expect(k8sClient.Update(ctx, usersFile)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: usersSecretName(cr), Namespace: ns}, usersSecret)).To(Succeed())
+			Expect(string(usersSecret.Data[settingsFilePasswordsName])).To(HavePrefix("inline:bcrypt.inline\ncarol:bcrypt.carol\n"))
+		})
+
+		It("should merge a referenced upstreams file with inline upstreams and refuse conflicts", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.DisableUpstreamChecks = true
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				SmtpUpstreams: []mailhogv1alpha1.MailhogUpstreamSpec{{Name: "local", Host: "localhost", Port: "25"}},
+				UpstreamsFrom: &mailhogv1alpha1.MailhogFileSourceSpec{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "sealed"}, Key: "servers.json"},
+				},
+			}
+			upstreamsFile := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "sealed", Namespace: ns},
+				Data:       map[string][]byte{"servers.json": []byte(`{"corp":{"host":"smtp.example.com","port":"587","username":"mailhog","password":"secret","mechanism":"PLAIN"}}`)},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, upstreamsFile).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, nsname, &corev1.ConfigMap{})).ToNot(Succeed())
+			upstreamsSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: upstreamsSecretName(cr), Namespace: ns}, upstreamsSecret)).To(Succeed())
+			Expect(upstreamsSecret.Data[settingsFileUpstreamsName]).To(MatchJSON(`{
+				"local":{"name":"local","host":"localhost","port":"25"},
+				"corp":{"name":"corp","host":"smtp.example.com","port":"587","username":"mailhog","password":"secret","mechanism":"PLAIN"}
+			}`))
+			createdDeployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, createdDeployment)).To(Succeed())
+			Expect(createdDeployment.Spec.Template.Spec.Volumes).ToNot(ContainElement(HaveField("Name", volumeNameSettingsFrom)))
+			Expect(createdDeployment.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: envUpstreamSmtpFile, Value: settingsFileUpstreamsPath}))
+
+			upstreamsFile.Data["servers.json"] = []byte(`{"local":{"host":"smtp.example.com","port":"25"}}`)
+			Expect(k8sClient.Update(ctx, upstreamsFile)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errUpstreamNameConflict))
+
+			upstreamsFile.Data["servers.json"] = []byte(`["local"]`)
+			Expect(k8sClient.Update(ctx, upstreamsFile)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errInvalidSettingsFile))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Error).To(ContainSubstring("upstreamsFrom"))
+		})
+
+		It("should refuse conflicting, reserved and missing users", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				WebUsers: []mailhogv1alpha1.MailhogWebUserSpec{{Name: "alice", PasswordHash: "bcrypt.inline"}},
+				UsersFrom: &mailhogv1alpha1.MailhogFileSourceSpec{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "gitops"}, Key: "users"},
+				},
+			}
+			usersFile := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "gitops", Namespace: ns},
+				Data:       map[string]string{"users": "alice:bcrypt.alice\n"},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, usersFile).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errWebUserConflict))
+
+			usersFile.Data["users"] = probeUserName + ":bcrypt.probe\n"
+			Expect(k8sClient.Update(ctx, usersFile)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errReservedWebUser))
+
+			Expect(k8sClient.Delete(ctx, usersFile)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).To(MatchError(errMissingSettingsFile))
+		})
+
+		It("should refuse sources with neither or both of ConfigMap and Secret key", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				UsersFrom: &mailhogv1alpha1.MailhogFileSourceSpec{},
+			}
+			Expect(checkFileSources(cr)).To(MatchError(errInvalidFileSource))
+			cr.Spec.Settings.Files.UsersFrom = &mailhogv1alpha1.MailhogFileSourceSpec{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "gitops"}, Key: "users"},
+				SecretKeyRef:    &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "sealed"}, Key: "users"},
+			}
+			Expect(checkFileSources(cr)).To(MatchError(errInvalidFileSource))
+			cr.Spec.Settings.Files.UsersFrom.SecretKeyRef = nil
+			Expect(checkFileSources(cr)).To(Succeed())
+		})
+	})
+
+	Context("reconcile with a mailhog cr that specifies conflicting smtp tls ports", func() {
+		It("should return an error and refuse to proceed", func() {
+			ports := [][]int32{{portSmtp, 0}, {0, portWeb}, {2000, 2000}}
+			for _, pair := range ports {
+				cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+				cr.Spec.Settings.SmtpTls = &mailhogv1alpha1.MailhogSmtpTlsSpec{
+					SecretName:      "smtp-cert",
+					StartTlsPort:    pair[0],
+					ImplicitTlsPort: pair[1],
+				}
+				objects := []client.Object{
+					cr,
+				}
+				k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+				r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+				_, err := r.Reconcile(ctx, req)
+				Expect(err).To(HaveOccurred())
+
+				updatedCr := &mailhogv1alpha1.MailhogInstance{}
+				err = k8sClient.Get(ctx, nsname, updatedCr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(updatedCr.Status.Error).To(Equal(errSmtpTlsPortConflict.Error()))
+			}
+		})
+	})
+
+	Context("reconcile with a mailhog cr that enables oidc auth", func() {
+		It("should route web traffic through the auth proxy", func() {
+			issuer := fakeOidcIssuer("")
+			defer issuer.Close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.RouteTrafficInlet)
+			cr.Spec.Settings.Auth = &mailhogv1alpha1.MailhogAuthSpec{
+				Oidc: &mailhogv1alpha1.MailhogOidcSpec{
+					IssuerURL: issuer.URL,
+					ClientID:  "mailhog",
+					ClientSecret: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "oidc-client"},
+						Key:                  "secret",
+					},
+					AllowedEmails: []string{"gopher@example.com"},
+				},
+			}
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).Should(Equal(reconcile.Result{}))
+
+			createdDeployment := &appsv1.Deployment{}
+			err = k8sClient.Get(ctx, nsname, createdDeployment)
+			Expect(err).ToNot(HaveOccurred())
+			containers := createdDeployment.Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[1].Name).To(Equal(webProxyName))
+			Expect(containers[1].Args).To(ContainElements(
+				"--oidc-issuer-url="+issuer.URL,
+				"--htpasswd-file="+webProxyMount+"/"+webProxyHtpasswdFileName,
+				"--authenticated-emails-file="+webProxyMount+"/"+oidcEmailsFileName,
+			))
+			// mailhog is only reachable through the proxy
+			Expect(containers[0].Env).To(ContainElements(
+				corev1.EnvVar{Name: envApiBind, Value: envBindWebLocalValue},
+				corev1.EnvVar{Name: envUiBind, Value: envBindWebLocalValue},
+			))
+			Expect(containers[0].Ports).ToNot(ContainElement(HaveField("ContainerPort", BeEquivalentTo(portWeb))))
+			Expect(containers[0].ReadinessProbe.HTTPGet).To(BeNil())
+			Expect(containers[0].ReadinessProbe.Exec.Command[2]).To(ContainSubstring("http://127.0.0.1:8025" + httpHealthPath))
+			Expect(containers[0].LivenessProbe.Exec).ToNot(BeNil())
+
+			createdService := &corev1.Service{}
+			err = k8sClient.Get(ctx, nsname, createdService)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdService.Spec.Ports[1].TargetPort.IntVal).To(BeEquivalentTo(portWebProxy))
+
+			createdRoute := &routev1.Route{}
+			err = k8sClient.Get(ctx, nsname, createdRoute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdRoute.Spec.Port.TargetPort.IntVal).To(BeEquivalentTo(portWebProxy))
+
+			createdSecret := &corev1.Secret{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: ns}, createdSecret)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdSecret.Data[secretKeyCookie]).To(HaveLen(32))
+			Expect(string(createdSecret.Data[secretKeyProbeHtpasswd])).To(Equal(probeUserName + ":" + string(createdSecret.Data[secretKeyProbeHash]) + "\n"))
+
+			createdConfigMap := &corev1.ConfigMap{}
+			err = k8sClient.Get(ctx, nsname, createdConfigMap)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdConfigMap.Data[oidcEmailsFileName]).To(Equal("gopher@example.com\n"))
+			Expect(createdConfigMap.Data).ToNot(HaveKey(settingsFilePasswordsName))
+
+			// the operator reaches the api through the proxy as probe user
+			pod := getTestingPod(cr, "tester-pod")
+			api, err := mailhogApiFor(ctx, k8sClient, cr, pod)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(api.baseUrl).To(Equal("http://" + pod.Status.PodIP + ":" + strconv.Itoa(portWebProxy)))
+			Expect(api.user).To(Equal(probeUserName))
+			Expect(api.password).To(Equal(string(createdSecret.Data[secretKeyProbePassword])))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(updatedCr.Status.Conditions, mailhogv1alpha1.OidcIssuerDiscoveredCondition)).To(BeTrue())
+		})
+	})
+
+	Context("reconcile with a mailhog cr whose oidc issuer announces another issuer", func() {
+		It("should report the mismatch once per generation without blocking the reconcile", func() {
+			issuer := fakeOidcIssuer("https://elsewhere.example.com")
+			defer issuer.Close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Auth = &mailhogv1alpha1.MailhogAuthSpec{
+				Oidc: &mailhogv1alpha1.MailhogOidcSpec{
+					IssuerURL: issuer.URL,
+					ClientID:  "mailhog",
+					ClientSecret: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "oidc-client"},
+						Key:                  "secret",
+					},
+				},
+			}
+			cr.UID = "tester-uid"
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			events := record.NewFakeRecorder(100)
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: events}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, &appsv1.Deployment{})).To(Succeed())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Error).To(BeEmpty())
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.OidcIssuerDiscoveredCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.IssuerMismatchReason))
+			Expect(events.Events).To(Receive(ContainSubstring("OidcDiscoveryFailed")))
+
+			// the issuer is not asked again for the same generation, even once it is gone
+			issuer.Close()
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			condition = meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.OidcIssuerDiscoveredCondition)
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.IssuerMismatchReason))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that enables rbac auth", func() {
+		It("should create the proxy service account and clean up its cluster role binding on deletion", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.RouteTrafficInlet)
+			cr.Spec.Settings.Auth = &mailhogv1alpha1.MailhogAuthSpec{
+				Rbac: &mailhogv1alpha1.MailhogRbacAuthSpec{},
+			}
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).Should(Equal(reconcile.Result{}))
+
+			createdDeployment := &appsv1.Deployment{}
+			err = k8sClient.Get(ctx, nsname, createdDeployment)
+			Expect(err).ToNot(HaveOccurred())
+			podSpec := createdDeployment.Spec.Template.Spec
+			Expect(*podSpec.AutomountServiceAccountToken).To(BeFalse())
+			Expect(podSpec.ServiceAccountName).To(Equal(serviceAccountName(cr)))
+			// only the oauth-proxy gets the token, mailhog is only reachable through it
+			Expect(podSpec.Volumes).To(ContainElement(serviceAccountTokenVolume()))
+			Expect(podSpec.Containers[0].VolumeMounts).ToNot(ContainElement(HaveField("Name", volumeNameWebProxyToken)))
+			Expect(podSpec.Containers[1].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: volumeNameWebProxyToken, MountPath: serviceAccountTokenMount, ReadOnly: true}))
+			Expect(podSpec.Containers[1].Args).To(ContainElement("--htpasswd-file=" + webProxyMount + "/" + webProxyHtpasswdFileName))
+			Expect(podSpec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: envApiBind, Value: envBindWebLocalValue}))
+			Expect(podSpec.Containers[1].Args).To(ContainElement(`--openshift-sar={"namespace":"default","verb":"get","group":"mailhog.operators.patrick.mx","resource":"mailhoginstances","name":"tester"}`))
+
+			createdServiceAccount := &corev1.ServiceAccount{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: serviceAccountName(cr), Namespace: ns}, createdServiceAccount)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdServiceAccount.Annotations[oauthRedirectReferenceAnnotation]).To(ContainSubstring(`"name":"tester"`))
+
+			bindingName := types.NamespacedName{Name: clusterRoleBindingName(cr)}
+			createdBinding := &rbacv1.ClusterRoleBinding{}
+			err = k8sClient.Get(ctx, bindingName, createdBinding)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdBinding.RoleRef.Name).To(Equal(authDelegatorClusterRole))
+			Expect(createdBinding.Subjects[0].Name).To(Equal(serviceAccountName(cr)))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedCr.Finalizers).To(ContainElement(clusterResourcesFinalizer))
+
+			err = k8sClient.Delete(ctx, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = k8sClient.Get(ctx, bindingName, createdBinding)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Context("reconcile with a mailhog cr that grants access to subjects", func() {
+		It("should create the instance roles and bind the subjects", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			// owner references of the children need the uid for the second reconcile to compare them
+			cr.UID = "tester-uid"
+			cr.Spec.Access = &mailhogv1alpha1.MailhogAccessSpec{
+				Subjects: []mailhogv1alpha1.MailhogAccessSubject{
+					{Kind: rbacv1.UserKind, Name: "alice"},
+					{Kind: rbacv1.ServiceAccountKind, Name: "ci", Role: mailhogv1alpha1.AdminAccessRole},
+				},
+			}
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			viewerName := types.NamespacedName{Name: "tester-mailhog-viewer", Namespace: ns}
+			adminName := types.NamespacedName{Name: "tester-mailhog-admin", Namespace: ns}
+
+			createdRole := &rbacv1.Role{}
+			err = k8sClient.Get(ctx, adminName, createdRole)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdRole.Rules).To(ContainElement(rbacv1.PolicyRule{
+				APIGroups:     []string{mailhogv1alpha1.GroupVersion.Group},
+				Resources:     []string{"mailhoginstances/scale"},
+				ResourceNames: []string{"tester"},
+				Verbs:         []string{"get", "update", "patch"},
+			}))
+			err = k8sClient.Get(ctx, viewerName, createdRole)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdRole.Rules).To(HaveLen(2))
+			Expect(roleAllows(createdRole, "get", ns, "/api/v1/namespaces/"+ns+"/services/tester:http/proxy/api/v2/messages")).To(BeTrue())
+			Expect(roleAllows(createdRole, "get", ns, "/api/v1/namespaces/"+ns+"/services/tester:8025/proxy/")).To(BeTrue())
+			Expect(roleAllows(createdRole, "delete", ns, "/api/v1/namespaces/"+ns+"/services/tester:http/proxy/api/v1/messages")).To(BeFalse())
+			Expect(roleAllows(createdRole, "get", ns, "/api/v1/namespaces/"+ns+"/secrets/"+generatedSecretName(cr))).To(BeFalse())
+
+			createdBinding := &rbacv1.RoleBinding{}
+			err = k8sClient.Get(ctx, viewerName, createdBinding)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdBinding.Subjects).To(ConsistOf(rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "alice"}))
+			err = k8sClient.Get(ctx, adminName, createdBinding)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(createdBinding.Subjects).To(ConsistOf(rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: ns}))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			updatedCr.Spec.Access = nil
+			err = k8sClient.Update(ctx, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = k8sClient.Get(ctx, adminName, createdBinding)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, adminName, createdRole)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("reconcile with a mailhog cr whose pods captured messages", func() {
+		It("should report the message count and newest message in the status", func() {
+			received := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+			mailhogApi := startFakeMailhogApi([]mailhogMessage{
+				{ID: "newest@mailhog.example", Created: received},
+				{ID: "older@mailhog.example", Created: received.Add(-time.Hour)},
+			})
+			defer mailhogApi.close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod-a"), getTestingPod(cr, "tester-pod-b"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(messagesStatusInterval))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedCr.Status.Messages).ToNot(BeNil())
+			// memory storage is separate per pod, both pods report the fake api's messages
+			Expect(updatedCr.Status.Messages.Total).To(Equal(4))
+			Expect(updatedCr.Status.Messages.LastReceived.Time.Equal(received)).To(BeTrue())
+		})
+
+		It("should authenticate and use the web path when asking the api", func() {
+			mailhogApi := startFakeMailhogApi([]mailhogMessage{
+				{ID: "only@mailhog.example", Created: time.Now()},
+			})
+			defer mailhogApi.close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.WebPath = "mailhog"
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				WebUsers: []mailhogv1alpha1.MailhogWebUserSpec{
+					{
+						Name:         "gOmega",
+						PasswordHash: "bcrypt.gibberish",
+					},
+				},
+			}
+			generated, err := secretNew(cr, nil)
+			Expect(err).ToNot(HaveOccurred())
+			mailhogApi.mu.Lock()
+			mailhogApi.webPath = "mailhog"
+			mailhogApi.user = probeUserName
+			mailhogApi.password = string(generated.Data[secretKeyProbePassword])
+			mailhogApi.mu.Unlock()
+
+			objects := []client.Object{
+				cr, generated, getTestingPod(cr, "tester-pod"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedCr.Status.Messages).ToNot(BeNil())
+			Expect(updatedCr.Status.Messages.Total).To(Equal(1))
+		})
+
+		It("should only ask the pods again once the refresh interval passed", func() {
+			mailhogApi := startFakeMailhogApi([]mailhogMessage{
+				{ID: "first@mailhog.example", Created: time.Now()},
+			})
+			defer mailhogApi.close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingPod(cr, "tester-pod")).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Messages.Total).To(Equal(1))
+			Expect(updatedCr.Status.LastPodRefreshTime).ToNot(BeNil())
+
+			mailhogApi.store("second@mailhog.example", "")
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Messages.Total).To(Equal(1))
+
+			refreshed := metav1.NewTime(updatedCr.Status.LastPodRefreshTime.Add(-messagesStatusInterval))
+			updatedCr.Status.LastPodRefreshTime = &refreshed
+			Expect(k8sClient.Status().Update(ctx, updatedCr)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Messages.Total).To(Equal(2))
+		})
+
+		It("should judge pods by their mailhog container regardless of sidecars", func() {
+			mailhogApi := startFakeMailhogApi(nil)
+			defer mailhogApi.close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			withSidecar := getTestingPod(cr, "tester-pod")
+			withSidecar.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{Name: smtpProxyName, Ready: false, RestartCount: 5},
+				{Name: mh, Ready: true},
+			}
+			starting := getTestingPod(cr, "starting-pod")
+			starting.Status.ContainerStatuses = nil
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, withSidecar, starting).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Pods.Ready).To(Equal([]string{"tester-pod"}))
+			Expect(updatedCr.Status.Pods.Other).To(Equal([]string{"starting-pod"}))
+			Expect(updatedCr.Status.ReadyPodCount).To(Equal(1))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that has a retention policy", func() {
+		It("should purge old and surplus messages via the api and record it in the status", func() {
+			now := time.Now()
+			mailhogApi := startFakeMailhogApi([]mailhogMessage{
+				{ID: "newest@mailhog.example", Created: now.Add(-time.Minute)},
+				{ID: "newer@mailhog.example", Created: now.Add(-2 * time.Minute)},
+				{ID: "older@mailhog.example", Created: now.Add(-3 * time.Minute)},
+				{ID: "ancient@mailhog.example", Created: now.Add(-48 * time.Hour)},
+			})
+			defer mailhogApi.close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.Retention = &mailhogv1alpha1.MailhogRetentionSpec{
+				MaxAge:      &metav1.Duration{Duration: 24 * time.Hour},
+				MaxMessages: 2,
+			}
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(messagesStatusInterval))
+			Expect(mailhogApi.deletedIds()).To(ConsistOf("older@mailhog.example", "ancient@mailhog.example"))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedCr.Status.Retention).ToNot(BeNil())
+			Expect(updatedCr.Status.Retention.LastPurgeTime).ToNot(BeNil())
+			Expect(updatedCr.Status.Retention.LastPurgeDeleted).To(BeEquivalentTo(2))
+			Expect(updatedCr.Status.Retention.TotalDeleted).To(BeEquivalentTo(2))
+			Expect(updatedCr.Status.Retention.Error).To(BeEmpty())
+
+			// the next purge is only due after the interval
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mailhogApi.deletedIds()).To(HaveLen(2))
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedCr.Status.Retention.TotalDeleted).To(BeEquivalentTo(2))
+		})
+
+		It("should create a ttl index job for mongodb storage", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Storage = mailhogv1alpha1.MongoDBStorage
+			cr.Spec.Settings.StorageMongoDb = mailhogv1alpha1.MailhogStorageMongoDbSpec{
+				URI:        "mongodb:27017",
+				Db:         "mailhog",
+				Collection: "messages",
+			}
+			cr.Spec.Settings.Retention = &mailhogv1alpha1.MailhogRetentionSpec{
+				MaxAge: &metav1.Duration{Duration: time.Hour},
+			}
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			createdJob := &batchv1.Job{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: retentionJobName(cr), Namespace: ns}, createdJob)
+			Expect(err).ToNot(HaveOccurred())
+			container := createdJob.Spec.Template.Spec.Containers[0]
+			Expect(container.Command[1]).To(Equal("mongodb://mongodb:27017"))
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: envRetentionTtlSeconds, Value: "3600"}))
+			Expect(container.Resources.Limits.Memory().String()).To(Equal(defaultRetentionResourceMem))
+			Expect(createdJob.Spec.Template.Labels).ToNot(HaveKey(crNameLabel))
+		})
+
+		It("should reject a retention policy without limits", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Retention = &mailhogv1alpha1.MailhogRetentionSpec{}
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(Equal(errMissingRetentionLimit))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that has seed messages", func() {
+		It("should deliver the messages once to every ready pod and record it in a condition", func() {
+			smtpServer := startFakeSmtpServer()
+			defer smtpServer.close()
+			originalAddress := mailhogSmtpAddress
+			mailhogSmtpAddress = func(*mailhogv1alpha1.MailhogInstance, *corev1.Pod) string { return smtpServer.address }
+			defer func() { mailhogSmtpAddress = originalAddress }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Seed = &mailhogv1alpha1.MailhogSeedSpec{
+				ConfigMaps: []string{"fixtures"},
+				Messages: []mailhogv1alpha1.MailhogSeedMessage{
+					{Name: "inline", Eml: "From: inline@sender.example\r\nTo: inline@receiver.example\r\nSubject: inline\r\n\r\nhello inline\r\n"},
+				},
+			}
+			fixtures := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "fixtures", Namespace: ns},
+				Data: map[string]string{
+					"a.eml": "From: a@sender.example\r\nTo: a@receiver.example\r\nSubject: a\r\n\r\nhello a\r\n",
+					"b.eml": "Subject: b without addresses\r\n\r\nhello b\r\n",
+				},
+			}
+			objects := []client.Object{
+				cr, fixtures, getTestingPod(cr, "tester-pod"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(smtpServer.received()).To(HaveLen(3))
+			Expect(smtpServer.received()[0].to).To(ConsistOf("a@receiver.example"))
+			Expect(smtpServer.received()[1].from).To(Equal(seedFallbackAddress))
+			Expect(smtpServer.received()[2].data).To(ContainSubstring("hello inline"))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.SeededCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(smtpServer.received()).To(HaveLen(3))
+		})
+
+		It("should report missing seed sources in the condition", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Seed = &mailhogv1alpha1.MailhogSeedSpec{
+				Secrets: []string{"missing"},
+			}
+			objects := []client.Object{
+				cr,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			err = k8sClient.Get(ctx, nsname, updatedCr)
+			Expect(err).ToNot(HaveOccurred())
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.SeededCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(Equal(errMissingSeedSource.Error()))
+		})
+
+		It("should only deliver secrets labeled as seed source", func() {
+			smtpServer := startFakeSmtpServer()
+			defer smtpServer.close()
+			originalAddress := mailhogSmtpAddress
+			mailhogSmtpAddress = func(*mailhogv1alpha1.MailhogInstance, *corev1.Pod) string { return smtpServer.address }
+			defer func() { mailhogSmtpAddress = originalAddress }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Seed = &mailhogv1alpha1.MailhogSeedSpec{
+				Secrets: []string{"fixtures"},
+			}
+			fixtures := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "fixtures", Namespace: ns},
+				Data: map[string][]byte{
+					"a.eml": []byte("From: a@sender.example\r\nTo: a@receiver.example\r\nSubject: a\r\n\r\nhello a\r\n"),
+				},
+			}
+			objects := []client.Object{
+				cr, fixtures, getTestingPod(cr, "tester-pod"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(smtpServer.received()).To(BeEmpty())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.SeededCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(HavePrefix(errUnlabeledSeedSource.Error()))
+
+			fixtures.Labels = map[string]string{seedSourceLabel: "true"}
+			Expect(k8sClient.Update(ctx, fixtures)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(smtpServer.received()).To(HaveLen(1))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that archives messages", func() {
+		It("should upload every message once with an index per run", func() {
+			received := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+			api := startFakeMailhogApi([]mailhogMessage{
+				{ID: "b@mailhog.example", Created: received},
+				{ID: "a@mailhog.example", Created: received},
+			})
+			defer api.close()
+			api.raw["a@mailhog.example"] = "From: a@sender.example\r\nTo: a@receiver.example\r\nSubject: a\r\n\r\nhello a\r\n"
+			api.raw["b@mailhog.example"] = "Subject: b\r\n\r\nhello b\r\n"
+			storage := startFakeObjectStorage("access", "archive")
+			defer storage.server.Close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.Archive = &mailhogv1alpha1.MailhogArchiveSpec{
+				Endpoint:          storage.server.URL,
+				Bucket:            "archive",
+				Prefix:            "staging/",
+				CredentialsSecret: "archive-credentials",
+			}
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "archive-credentials", Namespace: ns},
+				Data:       map[string][]byte{archiveAccessKeyIdKey: []byte("access"), archiveSecretAccessKeyKey: []byte("secret")},
+			}
+			objects := []client.Object{
+				cr, credentials, getTestingPod(cr, "tester-pod"),
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(messagesStatusInterval))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			status := updatedCr.Status.Archive
+			Expect(status).ToNot(BeNil())
+			Expect(status.Error).To(BeEmpty())
+			Expect(status.LastUploaded).To(Equal(int64(2)))
+			Expect(status.Pending).To(BeFalse())
+			Expect(status.Cursors).To(HaveLen(1))
+			Expect(status.Cursors[0].Source).To(Equal("tester-pod"))
+			Expect(status.Cursors[0].Cursor).To(Equal("2022-05-01T10:00:00Z"))
+			Expect(status.Cursors[0].CursorIDs).To(ConsistOf("a@mailhog.example", "b@mailhog.example"))
+
+			Expect(storage.keys()).To(ConsistOf(
+				"archive/staging/2022/05/01/a@mailhog.example.eml",
+				"archive/staging/2022/05/01/b@mailhog.example.eml",
+				"archive/"+status.LastIndex,
+			))
+			index := []archive.IndexEntry{}
+			Expect(json.Unmarshal(storage.object("archive/"+status.LastIndex), &index)).To(Succeed())
+			Expect(index).To(HaveLen(2))
+			Expect(index[0].Subject).To(Equal("b"))
+			Expect(index[1].To).To(ConsistOf("a@receiver.example"))
+
+			api.mu.Lock()
+			api.messages = append([]mailhogMessage{{ID: "c@mailhog.example", Created: received.Add(time.Minute)}}, api.messages...)
+			api.raw["c@mailhog.example"] = "Subject: c\r\n\r\nhello c\r\n"
+			api.mu.Unlock()
+			updatedCr.Status.Archive.LastUploadTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			Expect(k8sClient.Status().Update(ctx, updatedCr)).To(Succeed())
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Archive.LastUploaded).To(Equal(int64(1)))
+			Expect(updatedCr.Status.Archive.TotalUploaded).To(Equal(int64(3)))
+			Expect(storage.uploads("archive/staging/2022/05/01/a@mailhog.example.eml")).To(Equal(1))
+			Expect(storage.keys()).To(ContainElement("archive/staging/2022/05/01/c@mailhog.example.eml"))
+		})
+
+		It("should keep a cursor per pod and upload the older messages of a pod that was unreachable", func() {
+			received := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+			apiA := startFakeMailhogApi([]mailhogMessage{{ID: "a@mailhog.example", Created: received.Add(time.Minute)}})
+			defer apiA.close()
+			apiA.raw["a@mailhog.example"] = "Subject: a\r\n\r\nhello a\r\n"
+			apiUrlA := mailhogApiUrl
+			mailhogApiUrl = apiA.original
+			apiB := startFakeMailhogApi([]mailhogMessage{{ID: "b@mailhog.example", Created: received}})
+			defer apiB.close()
+			apiB.raw["b@mailhog.example"] = "Subject: b\r\n\r\nhello b\r\n"
+			apiUrlB := mailhogApiUrl
+			// every fake replaces the address of any pod, so each pod is routed to its own one
+			mailhogApiUrl = func(cr *mailhogv1alpha1.MailhogInstance, pod *corev1.Pod) string {
+				if pod.Name == "tester-pod-b" {
+					return apiUrlB(cr, pod)
+				}
+				return apiUrlA(cr, pod)
+			}
+			storage := startFakeObjectStorage("access", "archive")
+			defer storage.server.Close()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.Archive = &mailhogv1alpha1.MailhogArchiveSpec{
+				Endpoint:          storage.server.URL,
+				Bucket:            "archive",
+				CredentialsSecret: "archive-credentials",
+			}
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "archive-credentials", Namespace: ns},
+				Data:       map[string][]byte{archiveAccessKeyIdKey: []byte("access"), archiveSecretAccessKeyKey: []byte("secret")},
+			}
+			podB := getTestingPod(cr, "tester-pod-b")
+			podB.Status.Phase = corev1.PodPending
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, credentials, getTestingPod(cr, "tester-pod-a"), podB).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Archive.LastUploaded).To(Equal(int64(1)))
+			Expect(updatedCr.Status.Archive.Cursors).To(HaveLen(1))
+			Expect(updatedCr.Status.Archive.Cursors[0].Source).To(Equal("tester-pod-a"))
+
+			// the message of the pod that was unreachable is older than the cursor of the other pod
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "tester-pod-b", Namespace: ns}, podB)).To(Succeed())
+			podB.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, podB)).To(Succeed())
+			updatedCr.Status.Archive.LastUploadTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			Expect(k8sClient.Status().Update(ctx, updatedCr)).To(Succeed())
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Archive.Error).To(BeEmpty())
+			Expect(updatedCr.Status.Archive.LastUploaded).To(Equal(int64(1)))
+			Expect(updatedCr.Status.Archive.TotalUploaded).To(Equal(int64(2)))
+			Expect(storage.uploads("archive/2022/05/01/a@mailhog.example.eml")).To(Equal(1))
+			Expect(storage.uploads("archive/2022/05/01/b@mailhog.example.eml")).To(Equal(1))
+
+			Expect(k8sClient.Delete(ctx, podB)).To(Succeed())
+			updatedCr.Status.Archive.LastUploadTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			Expect(k8sClient.Status().Update(ctx, updatedCr)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Archive.LastUploaded).To(BeZero())
+			Expect(updatedCr.Status.Archive.Cursors).To(HaveLen(1))
+			Expect(updatedCr.Status.Archive.Cursors[0].Source).To(Equal("tester-pod-a"))
+		})
+
+		It("should report missing credentials", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Archive = &mailhogv1alpha1.MailhogArchiveSpec{
+				Endpoint:          "http://127.0.0.1:1",
+				Bucket:            "archive",
+				CredentialsSecret: "missing",
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Archive.Error).To(Equal(errMissingArchiveCredentials.Error()))
+		})
+	})
+
+	Context("reconcile with a mailhog cr that preserves messages on rollout", func() {
+		It("should export the messages before the rollout and replay them into the new pods", func() {
+			received := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+			api := startFakeMailhogApi([]mailhogMessage{
+				{ID: "b@mailhog.example", Created: received.Add(time.Minute)},
+				{ID: "a@mailhog.example", Created: received},
+			})
+			defer api.close()
+			api.raw["a@mailhog.example"] = "From: a@sender.example\r\nTo: a@receiver.example\r\nSubject: a\r\n\r\nhello a\r\n"
+			api.raw["b@mailhog.example"] = "From: b@sender.example\r\nTo: b@receiver.example\r\nSubject: b\r\n\r\nhello b\r\n"
+
+			smtpServer := startFakeSmtpServer()
+			defer smtpServer.close()
+			originalAddress := mailhogSmtpAddress
+			mailhogSmtpAddress = func(*mailhogv1alpha1.MailhogInstance, *corev1.Pod) string { return smtpServer.address }
+			defer func() { mailhogSmtpAddress = originalAddress }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.PreserveOnRollout = true
+			oldPod := getTestingPod(cr, "old-pod")
+			objects := []client.Object{
+				cr, oldPod,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Preserve).To(BeNil())
+
+			updatedCr.Spec.Image = "test/test:v2"
+			Expect(k8sClient.Update(ctx, updatedCr)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Preserve).ToNot(BeNil())
+			Expect(updatedCr.Status.Preserve.Phase).To(Equal(mailhogv1alpha1.ExportedPreservePhase))
+			Expect(updatedCr.Status.Preserve.Messages).To(Equal(2))
+			Expect(updatedCr.Status.Preserve.SourcePods).To(ConsistOf(string(oldPod.UID)))
+			preserved := types.NamespacedName{Name: nsname.Name + preserveSuffix, Namespace: ns}
+			Expect(k8sClient.Get(ctx, preserved, &corev1.ConfigMap{})).To(Succeed())
+			// the old pod still has its messages
+			Expect(smtpServer.received()).To(BeEmpty())
+
+			Expect(k8sClient.Delete(ctx, oldPod)).To(Succeed())
+			newPod := getTestingPod(cr, "new-pod")
+			Expect(k8sClient.Create(ctx, newPod)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(smtpServer.received()).To(HaveLen(2))
+			Expect(smtpServer.received()[0].to).To(ConsistOf("a@receiver.example"))
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Preserve.Phase).To(Equal(mailhogv1alpha1.RestoredPreservePhase))
+			Expect(updatedCr.Status.Preserve.RestoredPods).To(ConsistOf(string(newPod.UID)))
+			Expect(updatedCr.Status.Preserve.CompletionTime).ToNot(BeNil())
+			err = k8sClient.Get(ctx, preserved, &corev1.ConfigMap{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(smtpServer.received()).To(HaveLen(2))
+		})
+
+		It("should block the migration until the messages could be exported", func() {
+			received := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+			api := startFakeMailhogApi([]mailhogMessage{
+				{ID: "a@mailhog.example", Created: received},
+			})
+			defer api.close()
+			// the export exceeds the size of a ConfigMap
+			api.raw["a@mailhog.example"] = "From: a@sender.example\r\nTo: a@receiver.example\r\nSubject: a\r\n\r\n" + strings.Repeat("0123456789abcdef\r\n", 64<<10)
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingPod(cr, "old-pod")).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			updatedCr.Spec.Settings.Storage = mailhogv1alpha1.MongoDBStorage
+			updatedCr.Spec.Settings.StorageMongoDb = mailhogv1alpha1.MailhogStorageMongoDbSpec{URI: "mongodb:27017", Db: "mailhog", Collection: "messages"}
+			Expect(k8sClient.Update(ctx, updatedCr)).To(Succeed())
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(archiveRetryTime))
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, deployment)).To(Succeed())
+			Expect(deploymentStorage(deployment)).To(Equal(mailhogv1alpha1.MemoryStorage))
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Preserve.Phase).To(Equal(mailhogv1alpha1.FailedPreservePhase))
+			Expect(updatedCr.Status.Preserve.Error).To(Equal(errPreserveTooLarge.Error()))
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.StorageMigrationCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.MigrationFailedReason))
+
+			// once the messages fit, the export succeeds and the rollout continues
+			api.raw["a@mailhog.example"] = "From: a@sender.example\r\nTo: a@receiver.example\r\nSubject: a\r\n\r\nhello a\r\n"
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, deployment)).To(Succeed())
+			Expect(deploymentStorage(deployment)).To(Equal(mailhogv1alpha1.MongoDBStorage))
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Preserve.Phase).To(Equal(mailhogv1alpha1.ExportedPreservePhase))
+			condition = meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.StorageMigrationCondition)
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.MigratingReason))
+		})
+
+		It("should forget a failed export once the change is reverted", func() {
+			api := startFakeMailhogApi(nil)
+			defer api.close()
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			updatedCr.Status.Preserve = &mailhogv1alpha1.PreserveStatus{MigrateFrom: mailhogv1alpha1.MaildirStorage, Phase: mailhogv1alpha1.FailedPreservePhase, Error: "unreachable"}
+			setStorageMigrationCondition(updatedCr)
+			Expect(k8sClient.Status().Update(ctx, updatedCr)).To(Succeed())
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Preserve).To(BeNil())
+			Expect(meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.StorageMigrationCondition)).To(BeNil())
+		})
+
+		It("should migrate the messages into a new storage backend once", func() {
+			received := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+			api := startFakeMailhogApi([]mailhogMessage{
+				{ID: "a@mailhog.example", Created: received},
+			})
+			defer api.close()
+			api.raw["a@mailhog.example"] = "From: a@sender.example\r\nTo: a@receiver.example\r\nSubject: a\r\n\r\nhello a\r\n"
+
+			smtpServer := startFakeSmtpServer()
+			defer smtpServer.close()
+			originalAddress := mailhogSmtpAddress
+			mailhogSmtpAddress = func(*mailhogv1alpha1.MailhogInstance, *corev1.Pod) string { return smtpServer.address }
+			defer func() { mailhogSmtpAddress = originalAddress }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			oldPod := getTestingPod(cr, "old-pod")
+			objects := []client.Object{
+				cr, oldPod,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			updatedCr.Spec.Settings.Storage = mailhogv1alpha1.MongoDBStorage
+			updatedCr.Spec.Settings.StorageMongoDb = mailhogv1alpha1.MailhogStorageMongoDbSpec{URI: "mongodb:27017", Db: "mailhog", Collection: "messages"}
+			Expect(k8sClient.Update(ctx, updatedCr)).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Preserve.MigrateFrom).To(Equal(mailhogv1alpha1.MemoryStorage))
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.StorageMigrationCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.MigratingReason))
+
+			Expect(k8sClient.Delete(ctx, oldPod)).To(Succeed())
+			Expect(k8sClient.Create(ctx, getTestingPod(cr, "new-pod-1"))).To(Succeed())
+			Expect(k8sClient.Create(ctx, getTestingPod(cr, "new-pod-2"))).To(Succeed())
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			// the mongodb storage is shared, so the messages are imported only once
+			Expect(smtpServer.received()).To(HaveLen(1))
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(updatedCr.Status.Preserve.Phase).To(Equal(mailhogv1alpha1.RestoredPreservePhase))
+			condition = meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.StorageMigrationCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).To(Equal("1 messages migrated from memory to mongodb"))
+		})
+
+		It("should reject other storage types", func() {
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.PreserveOnRollout = true
+			cr.Spec.Settings.Storage = mailhogv1alpha1.MaildirStorage
+			cr.Spec.Settings.StorageMaildir.Path = "/maildir"
+			Expect(checkPreserveOnRollout(cr)).To(MatchError(errPreserveNeedsMemoryStorage))
+		})
+	})
+
+	Context("reconcile with a mailhog cr whose deployment was rolled out", func() {
+		completeRollout := func() {
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, nsname, deployment)).To(Succeed())
+			deployment.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: deployment.Generation,
+				Replicas:           *deployment.Spec.Replicas,
+				UpdatedReplicas:    *deployment.Spec.Replicas,
+				AvailableReplicas:  *deployment.Spec.Replicas,
+			}
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+		}
+
+		It("should verify the smtp delivery through the service once per revision", func() {
+			api := startFakeMailhogApi(nil)
+			defer api.close()
+			smtpServer := startFakeSmtpServer()
+			defer smtpServer.close()
+			smtpServer.forwardTo(api)
+			originalAddress := mailhogServiceSmtpAddress
+			mailhogServiceSmtpAddress = func(*mailhogv1alpha1.MailhogInstance) string { return smtpServer.address }
+			defer func() { mailhogServiceSmtpAddress = originalAddress }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingPod(cr, "tester-pod")).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.RolloutPendingReason))
+			Expect(smtpServer.received()).To(BeEmpty())
+
+			completeRollout()
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			condition = meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.VerifiedReason))
+			Expect(updatedCr.Status.SmtpVerification).ToNot(BeNil())
+			Expect(smtpServer.received()).To(HaveLen(1))
+			Expect(smtpServer.received()[0].from).To(Equal(smtpVerificationAddress))
+			Expect(smtpServer.received()[0].data).To(ContainSubstring(smtpVerificationHeader))
+			Expect(api.deletedIds()).To(HaveLen(1))
+
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(smtpServer.received()).To(HaveLen(1))
+		})
+
+		It("should verify the smtp delivery through the Service as operator if the smtp proxy enforces a policy", func() {
+			api := startFakeMailhogApi(nil)
+			defer api.close()
+			proxyServer := startFakeSmtpServer()
+			defer proxyServer.close()
+			proxyServer.forwardTo(api)
+			admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_ = json.NewEncoder(w).Encode(map[smtpproxy.Violation]int64{})
+			}))
+			defer admin.Close()
+			originalServiceAddress, originalAdminUrl := mailhogServiceSmtpAddress, smtpProxyAdminUrl
+			mailhogServiceSmtpAddress = func(*mailhogv1alpha1.MailhogInstance) string { return proxyServer.address }
+			smtpProxyAdminUrl = func(*corev1.Pod) string { return admin.URL }
+			defer func() {
+				mailhogServiceSmtpAddress, smtpProxyAdminUrl = originalServiceAddress, originalAdminUrl
+			}()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.SmtpPolicy = &mailhogv1alpha1.MailhogSmtpPolicySpec{
+				AuthSecretName:       "smtp-users",
+				AllowedSenderDomains: []string{"app.example"},
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingPod(cr, "tester-pod")).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			generated := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: cr.Namespace}, generated)).To(Succeed())
+			proxyServer.requireAuth(string(generated.Data[secretKeyProbePassword]))
+			completeRollout()
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.VerifiedReason))
+			Expect(condition.Message).To(ContainSubstring(proxyServer.address))
+			Expect(proxyServer.received()).To(HaveLen(1))
+			Expect(api.deletedIds()).To(HaveLen(1))
+		})
+
+		It("should report a test message that was not stored", func() {
+			api := startFakeMailhogApi(nil)
+			defer api.close()
+			smtpServer := startFakeSmtpServer()
+			defer smtpServer.close()
+			originalAddress, originalAttempts := mailhogServiceSmtpAddress, smtpVerificationAttempts
+			mailhogServiceSmtpAddress = func(*mailhogv1alpha1.MailhogInstance) string { return smtpServer.address }
+			smtpVerificationAttempts = 1
+			defer func() { mailhogServiceSmtpAddress, smtpVerificationAttempts = originalAddress, originalAttempts }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingPod(cr, "tester-pod")).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			completeRollout()
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(archiveRetryTime))
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			condition := meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition)
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.NotStoredReason))
+		})
+
+		It("should not send test messages if the verification is disabled", func() {
+			smtpServer := startFakeSmtpServer()
+			defer smtpServer.close()
+			originalAddress := mailhogServiceSmtpAddress
+			mailhogServiceSmtpAddress = func(*mailhogv1alpha1.MailhogInstance) string { return smtpServer.address }
+			defer func() { mailhogServiceSmtpAddress = originalAddress }()
+
+			cr := getTestingCr(nsname, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			cr.Spec.Settings.DisableSmtpVerification = true
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingPod(cr, "tester-pod")).Build()
+
+			r := &MailhogInstanceReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+			completeRollout()
+			_, err = r.Reconcile(ctx, req)
+			Expect(err).ToNot(HaveOccurred())
+
+			updatedCr := &mailhogv1alpha1.MailhogInstance{}
+			Expect(k8sClient.Get(ctx, nsname, updatedCr)).To(Succeed())
+			Expect(meta.FindStatusCondition(updatedCr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition)).To(BeNil())
+			Expect(smtpServer.received()).To(BeEmpty())
+		})
+	})
+})
+
+// fakeObjectStorage is a minimal s3 compatible object storage accepting path style uploads of a single access key
+type fakeObjectStorage struct {
+	server  *httptest.Server
+	mu      sync.Mutex
+	objects map[string][]byte
+	counts  map[string]int
+}
+
+func startFakeObjectStorage(accessKeyId, bucket string) *fakeObjectStorage {
+	storage := &fakeObjectStorage{objects: make(map[string][]byte), counts: make(map[string]int)}
+	storage.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+accessKeyId+"/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		key := strings.TrimPrefix(req.URL.Path, "/")
+		if req.Method != http.MethodPut || !strings.HasPrefix(key, bucket+"/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		storage.mu.Lock()
+		defer storage.mu.Unlock()
+		storage.objects[key] = body
+		storage.counts[key]++
+	}))
+	return storage
+}
+
+func (s *fakeObjectStorage) keys() (keys []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (s *fakeObjectStorage) object(key string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[key]
+}
+
+func (s *fakeObjectStorage) uploads(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[key]
+}
+
+// fakeSmtpMessage is a message received by the fake smtp server
+type fakeSmtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSmtpServer is a minimal smtp server recording every message it accepts
+type fakeSmtpServer struct {
+	listener net.Listener
+	address  string
+	mu       sync.Mutex
+	messages []fakeSmtpMessage
+	deliver  func(fakeSmtpMessage)
+	password string
+	tls      *tls.Config
+}
+
+func startFakeSmtpServer() *fakeSmtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	server := &fakeSmtpServer{listener: listener, address: listener.Addr().String()}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSmtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	writer := textproto.NewWriter(bufio.NewWriter(conn))
+	_ = writer.PrintfLine("220 fake.example ESMTP")
+	current, upgraded, authenticated := fakeSmtpMessage{}, false, false
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			password, tlsConfig := s.extensions()
+			extensions := []string{"fake.example"}
+			if tlsConfig != nil && !upgraded {
+				extensions = append(extensions, "STARTTLS")
+			}
+			if password != "" {
+				extensions = append(extensions, "AUTH PLAIN")
+			}
+			for i, extension := range extensions {
+				separator := "-"
+				if i == len(extensions)-1 {
+					separator = " "
+				}
+				_ = writer.PrintfLine("250%s%s", separator, extension)
+			}
+		case "STARTTLS":
+			_, tlsConfig := s.extensions()
+			_ = writer.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			reader = textproto.NewReader(bufio.NewReader(tlsConn))
+			writer = textproto.NewWriter(bufio.NewWriter(tlsConn))
+			upgraded = true
+		case "AUTH":
+			password, _ := s.extensions()
+			fields := strings.Fields(line)
+			credentials, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			parts := strings.Split(string(credentials), "\x00")
+			if len(parts) == 3 && parts[2] == password {
+				_ = writer.PrintfLine("235 2.7.0 Authentication successful")
+				authenticated = true
+				continue
+			}
+			_ = writer.PrintfLine("535 5.7.8 Authentication credentials invalid")
+		case "MAIL":
+			if password, _ := s.extensions(); password != "" && !authenticated {
+				_ = writer.PrintfLine("530 5.7.0 Authentication required")
+				continue
+			}
+			current = fakeSmtpMessage{from: smtpPathArg(line)}
+			_ = writer.PrintfLine("250 Ok")
+		case "RCPT":
+			current.to = append(current.to, smtpPathArg(line))
+			_ = writer.PrintfLine("250 Ok")
+		case "DATA":
+			_ = writer.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := reader.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			current.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, current)
+			deliver := s.deliver
+			s.mu.Unlock()
+			if deliver != nil {
+				deliver(current)
+			}
+			_ = writer.PrintfLine("250 Ok: queued")
+		case "QUIT":
+			_ = writer.PrintfLine("221 Bye")
+			return
+		default:
+			_ = writer.PrintfLine("250 Ok")
+		}
+	}
+}
+
+func (s *fakeSmtpServer) received() []fakeSmtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeSmtpMessage{}, s.messages...)
+}
+
+// requireAuth announces AUTH PLAIN, only accepts the given password and refuses mail before it
+func (s *fakeSmtpServer) requireAuth(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// offerStartTls announces STARTTLS with the given certificate
+func (s *fakeSmtpServer) offerStartTls(certificate tls.Certificate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tls = &tls.Config{Certificates: []tls.Certificate{certificate}}
+}
+
+func (s *fakeSmtpServer) extensions() (password string, tlsConfig *tls.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.password, s.tls
+}
+
+// testCertificate returns a self signed certificate for the name and its PEM encoding
+func testCertificate(name string) (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{name}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	certificate, err := tls.X509KeyPair(certPem, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	Expect(err).ToNot(HaveOccurred())
+	return certificate, certPem
+}
+
+// forwardTo stores every accepted message in the fake api, like a mailhog pod behind the Service
+func (s *fakeSmtpServer) forwardTo(api *fakeMailhogApi) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliver = func(message fakeSmtpMessage) {
+		api.store("forwarded-"+strconv.Itoa(len(s.messages))+"@mailhog.example", message.data)
+	}
+}
+
+func (s *fakeSmtpServer) close() {
+	_ = s.listener.Close()
+}
+
+// upstreamCheckEvents drains the recorded events and returns the ones of failed upstream checks
+func upstreamCheckEvents(recorder *record.FakeRecorder) (events []string) {
+	for {
+		select {
+		case event := <-recorder.Events:
+			if strings.Contains(event, "UpstreamCheckFailed") {
+				events = append(events, event)
+			}
+		default:
+			return events
+		}
+	}
+}
+
+// smtpPathArg returns the address of a MAIL FROM / RCPT TO command
+func smtpPathArg(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+// fakeMailhogApi serves the parts of the mailhog api the operator uses from an in memory message list
+// while it is running, mailhogApiUrl points all pods to it
+// webPath, user and password can be set to require them like a mailhog with WebPath and basic auth
+type fakeMailhogApi struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	messages []mailhogMessage
+	deleted  []string
+	original func(*mailhogv1alpha1.MailhogInstance, *corev1.Pod) string
+
+	webPath  string
+	user     string
+	password string
+	raw      map[string]string
+}
+
+// fakeMailhogPage is a page of the v2 api including the raw message data
+type fakeMailhogPage struct {
+	Total int               `json:"total"`
+	Count int               `json:"count"`
+	Start int               `json:"start"`
+	Items []fakeMailhogItem `json:"items"`
+}
+
+// fakeMailhogItem is a v2 api message including the raw message data
+type fakeMailhogItem struct {
+	ID      string    `json:"ID"`
+	Created time.Time `json:"Created"`
+	Raw     struct {
+		From string `json:"From"`
+		Data string `json:"Data"`
+	} `json:"Raw"`
+}
+
+func startFakeMailhogApi(messages []mailhogMessage) *fakeMailhogApi {
+	api := &fakeMailhogApi{messages: messages, original: mailhogApiUrl, raw: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/messages", func(w http.ResponseWriter, req *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		start, _ := strconv.Atoi(req.URL.Query().Get("start"))
+		limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+		page := fakeMailhogPage{Total: len(api.messages), Start: start, Items: []fakeMailhogItem{}}
+		for i := start; i < len(api.messages) && i < start+limit; i++ {
+			item := fakeMailhogItem{ID: api.messages[i].ID, Created: api.messages[i].Created}
+			item.Raw.Data = api.raw[item.ID]
+			page.Items = append(page.Items, item)
+		}
+		page.Count = len(page.Items)
+		_ = json.NewEncoder(w).Encode(page)
+	})
+	mux.HandleFunc("/api/v2/search", func(w http.ResponseWriter, req *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		page := fakeMailhogPage{Items: []fakeMailhogItem{}}
+		for _, message := range api.messages {
+			if req.URL.Query().Get("kind") == "containing" && strings.Contains(api.raw[message.ID], req.URL.Query().Get("query")) {
+				page.Items = append(page.Items, fakeMailhogItem{ID: message.ID, Created: message.Created})
+			}
+		}
+		page.Total, page.Count = len(page.Items), len(page.Items)
+		_ = json.NewEncoder(w).Encode(page)
+	})
+	mux.HandleFunc("/api/v1/messages/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		id := strings.TrimPrefix(req.URL.Path, "/api/v1/messages/")
+		for i, message := range api.messages {
+			if message.ID == id {
+				api.messages = append(api.messages[:i], api.messages[i+1:]...)
+				api.deleted = append(api.deleted, id)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	api.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		api.mu.Lock()
+		webPath, wantUser, wantPassword := api.webPath, api.user, api.password
+		api.mu.Unlock()
+		if user, password, _ := req.BasicAuth(); wantUser != "" && (user != wantUser || password != wantPassword) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if webPath != "" {
+			http.StripPrefix("/"+webPath, mux).ServeHTTP(w, req)
+			return
+		}
+		mux.ServeHTTP(w, req)
+	}))
+	// only the pod address is replaced, the path is built by the operator
+	mailhogApiUrl = func(cr *mailhogv1alpha1.MailhogInstance, pod *corev1.Pod) string {
+		return strings.Replace(api.original(cr, pod), "http://"+pod.Status.PodIP+":"+strconv.Itoa(portWeb), api.server.URL, 1)
+	}
+	return api
+}
+
+// store adds a message as newest message
+func (a *fakeMailhogApi) store(id, data string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messages = append([]mailhogMessage{{ID: id, Created: time.Now()}}, a.messages...)
+	a.raw[id] = data
+}
+
+func (a *fakeMailhogApi) deletedIds() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string{}, a.deleted...)
+}
+
+func (a *fakeMailhogApi) close() {
+	mailhogApiUrl = a.original
+	a.server.Close()
+}
+
+// getTestingPod returns a running and ready mailhog pod of the CR
+// roleAllows evaluates the rules of the role like the api server does for a request of a core group path
+func roleAllows(role *rbacv1.Role, verb, namespace, path string) bool {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/v1/namespaces/"+namespace+"/"), "/")
+	resource, name := parts[0], ""
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 2 {
+		resource += "/" + parts[2]
+	}
+	for _, rule := range role.Rules {
+		if containsString(rule.APIGroups, "") && containsString(rule.Resources, resource) && containsString(rule.Verbs, verb) &&
+			(len(rule.ResourceNames) == 0 || containsString(rule.ResourceNames, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func getTestingPod(cr *mailhogv1alpha1.MailhogInstance, name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			UID:       types.UID(name + "-uid"),
+			Labels:    CreateMetaMaker(cr).GetLabels(),
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: "10.0.0.1",
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: mh, Ready: true},
+			},
+		},
+	}
+}
+
+// fakeOidcIssuer serves an oidc discovery document, an empty issuer announces the server itself
+func fakeOidcIssuer(announcedIssuer string) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	if announcedIssuer == "" {
+		announcedIssuer = server.URL
+	}
+	mux.HandleFunc(oidcDiscoveryPath, func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 announcedIssuer,
+			"authorization_endpoint": announcedIssuer + "/auth",
+			"token_endpoint":         announcedIssuer + "/token",
+			"jwks_uri":               announcedIssuer + "/keys",
+		})
+	})
+	return server
+}
 
 func getTestingCr(nsname types.NamespacedName, image string, inlet mailhogv1alpha1.TrafficInletResource) *mailhogv1alpha1.MailhogInstance {
 	return &mailhogv1alpha1.MailhogInstance{
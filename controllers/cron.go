@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five field cron expression, every field is a bitset of the allowed values
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	// dayOfMonthAny and dayOfWeekAny mark a * field, if both day fields are restricted either one matches
+	dayOfMonthAny, dayOfWeekAny bool
+}
+
+// cronSearchLimit ends the search for schedules that never match, like the 31st of february
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// parseCron parses "minute hour day-of-month month day-of-week"
+// every field supports *, single values, ranges, steps and comma separated lists, sunday is 0 or 7
+func parseCron(expression string) (schedule cronSchedule, err error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return schedule, errCronFieldCount
+	}
+
+	for _, field := range []struct {
+		value    string
+		min, max int
+		target   *uint64
+	}{
+		{fields[0], 0, 59, &schedule.minute},
+		{fields[1], 0, 23, &schedule.hour},
+		{fields[2], 1, 31, &schedule.dayOfMonth},
+		{fields[3], 1, 12, &schedule.month},
+		{fields[4], 0, 7, &schedule.dayOfWeek},
+	} {
+		if *field.target, err = parseCronField(field.value, field.min, field.max); err != nil {
+			return schedule, err
+		}
+	}
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+	schedule.dayOfMonthAny = fields[2] == "*"
+	schedule.dayOfWeekAny = fields[4] == "*"
+	return schedule, nil
+}
+
+// parseCronField returns the bitset of the values in a single field
+func parseCronField(field string, min, max int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if base, stepValue, found := strings.Cut(part, "/"); found {
+			if step, err = strconv.Atoi(stepValue); err != nil || step < 1 {
+				return 0, errCronInvalidField
+			}
+			part = base
+		}
+
+		first, last := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			from, to, _ := strings.Cut(part, "-")
+			if first, err = strconv.Atoi(from); err != nil {
+				return 0, errCronInvalidField
+			}
+			if last, err = strconv.Atoi(to); err != nil {
+				return 0, errCronInvalidField
+			}
+		default:
+			if first, err = strconv.Atoi(part); err != nil {
+				return 0, errCronInvalidField
+			}
+			last = first
+			// a single value with a step runs until the end of the range, like 5/15
+			if step > 1 {
+				last = max
+			}
+		}
+		if first < min || last > max || first > last {
+			return 0, errCronInvalidField
+		}
+
+		for value := first; value <= last; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first minute matching the schedule after the given time, the zero time if there is none
+func (s cronSchedule) next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the cron rule that a restricted day of month or day of week is enough if both are restricted
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	dayOfMonth := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.dayOfMonthAny || s.dayOfWeekAny {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
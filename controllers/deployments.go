@@ -30,9 +30,21 @@ func ensureDeployment(ctx context.Context, r *MailhogInstanceReconciler, cr *mai
 		logger.Error(err, failedUpdateCheck)
 		return err
 	} else if updateNeeded {
+		preserved, err := r.preserveBeforeRollout(ctx, cr, existingDeployment, updatedDeployment)
+		if err != nil {
+			return err
+		}
+		if !preserved {
+			// the export is retried on the next reconcile, rolling out now would lose the messages
+			logger.Info(stateRolloutBlocked)
+			return nil
+		}
 		return r.update(ctx, cr, logger, updatedDeployment, deploymentUpdate)
 	}
 
+	if err = r.abandonPreserve(ctx, cr); err != nil {
+		return err
+	}
 	logger.Info(stateEnsured)
 	return nil
 }
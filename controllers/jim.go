@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"strconv"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+)
+
+// jimSettings are chaos monkey values, nil values are left to mailhog's defaults
+type jimSettings struct {
+	disconnect      *float64
+	accept          *float64
+	linkspeedAffect *float64
+	linkspeedMin    *int64
+	linkspeedMax    *int64
+	rejectSender    *float64
+	rejectRecipient *float64
+	rejectAuth      *float64
+}
+
+// jimDefaults are the values mailhog uses for flags that are not given
+var jimDefaults = jimSettings{
+	disconnect:      floatPtr(0.005),
+	accept:          floatPtr(0.99),
+	linkspeedAffect: floatPtr(0.1),
+	linkspeedMin:    int64Ptr(1024),
+	linkspeedMax:    int64Ptr(10240),
+	rejectSender:    floatPtr(0.05),
+	rejectRecipient: floatPtr(0.05),
+	rejectAuth:      floatPtr(0.05),
+}
+
+// jimPresets set every value, so mailhog's defaults do not leak into a preset
+var jimPresets = map[mailhogv1alpha1.JimPreset]jimSettings{
+	mailhogv1alpha1.FlakyNetworkJimPreset: {
+		disconnect:      floatPtr(0.05),
+		accept:          floatPtr(0.9),
+		linkspeedAffect: floatPtr(0.2),
+		linkspeedMin:    int64Ptr(1024),
+		linkspeedMax:    int64Ptr(10240),
+		rejectSender:    floatPtr(0),
+		rejectRecipient: floatPtr(0),
+		rejectAuth:      floatPtr(0),
+	},
+	mailhogv1alpha1.StrictRelayJimPreset: {
+		disconnect:      floatPtr(0),
+		accept:          floatPtr(1),
+		linkspeedAffect: floatPtr(0),
+		linkspeedMin:    int64Ptr(1024),
+		linkspeedMax:    int64Ptr(10240),
+		rejectSender:    floatPtr(0.2),
+		rejectRecipient: floatPtr(0.2),
+		rejectAuth:      floatPtr(0.2),
+	},
+	mailhogv1alpha1.SlowLinkJimPreset: {
+		disconnect:      floatPtr(0),
+		accept:          floatPtr(1),
+		linkspeedAffect: floatPtr(1),
+		linkspeedMin:    int64Ptr(256),
+		linkspeedMax:    int64Ptr(2048),
+		rejectSender:    floatPtr(0),
+		rejectRecipient: floatPtr(0),
+		rejectAuth:      floatPtr(0),
+	},
+}
+
+// activeJim returns the jim settings of a running chaos experiment or the ones of the spec
+func activeJim(cr *mailhogv1alpha1.MailhogInstance) mailhogv1alpha1.MailhogJimSpec {
+	if chaos := cr.Status.Chaos; chaos != nil {
+		jim := chaos.Jim
+		jim.Invite = true
+		return jim
+	}
+	return cr.Spec.Settings.Jim
+}
+
+// jimEnabled returns true if jim was invited, a preset was chosen or a chaos experiment is running
+func jimEnabled(cr *mailhogv1alpha1.MailhogInstance) bool {
+	jim := activeJim(cr)
+	return jim.Invite || jim.Preset != ""
+}
+
+// effectiveJim returns the chaos monkey values of the CR
+// the preset is the base, the deprecated string values override it and the typed values override both
+func effectiveJim(cr *mailhogv1alpha1.MailhogInstance) (settings jimSettings, err error) {
+	jim := activeJim(cr)
+	if jim.Preset != "" {
+		preset, found := jimPresets[jim.Preset]
+		if !found {
+			return settings, errJimUnknownPreset
+		}
+		settings = preset
+	}
+
+	for _, value := range []struct {
+		legacy string
+		typed  mailhogv1alpha1.JimChance
+		target **float64
+	}{
+		{jim.Disconnect, jim.DisconnectChance, &settings.disconnect},
+		{jim.Accept, jim.AcceptChance, &settings.accept},
+		{jim.LinkspeedAffect, jim.LinkspeedAffectChance, &settings.linkspeedAffect},
+		{jim.RejectSender, jim.RejectSenderChance, &settings.rejectSender},
+		{jim.RejectRecipient, jim.RejectRecipientChance, &settings.rejectRecipient},
+		{jim.RejectAuth, jim.RejectAuthChance, &settings.rejectAuth},
+	} {
+		for _, given := range []string{value.legacy, string(value.typed)} {
+			if given == "" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(given, 64)
+			if err != nil {
+				return settings, errJimNonFloatFound
+			}
+			*value.target = &parsed
+		}
+		if probability := *value.target; probability != nil && (*probability < 0 || *probability > 1) {
+			return settings, errJimProbabilityRange
+		}
+	}
+
+	for _, value := range []struct {
+		legacy string
+		typed  *int64
+		target **int64
+	}{
+		{jim.LinkspeedMin, jim.LinkspeedMinBytes, &settings.linkspeedMin},
+		{jim.LinkspeedMax, jim.LinkspeedMaxBytes, &settings.linkspeedMax},
+	} {
+		if value.legacy != "" {
+			parsed, err := strconv.ParseInt(value.legacy, 10, 64)
+			if err != nil {
+				return settings, errJimNonIntegerFound
+			}
+			*value.target = &parsed
+		}
+		if value.typed != nil {
+			*value.target = value.typed
+		}
+		if speed := *value.target; speed != nil && *speed < 1 {
+			return settings, errJimLinkspeedRange
+		}
+	}
+
+	// a single given bound is compared with mailhog's default for the other one
+	min, max := settings.linkspeedMin, settings.linkspeedMax
+	if min == nil {
+		min = jimDefaults.linkspeedMin
+	}
+	if max == nil {
+		max = jimDefaults.linkspeedMax
+	}
+	if *min > *max {
+		return settings, errJimLinkspeedRange
+	}
+	return settings, nil
+}
+
+// jimStatus returns the effective chaos monkey configuration shown in the status, nil if jim is not active
+func jimStatus(cr *mailhogv1alpha1.MailhogInstance) *mailhogv1alpha1.JimStatus {
+	if !jimEnabled(cr) {
+		return nil
+	}
+	settings, err := effectiveJim(cr)
+	if err != nil {
+		return nil
+	}
+
+	float := func(value, fallback *float64) string {
+		if value == nil {
+			value = fallback
+		}
+		return strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+	integer := func(value, fallback *int64) string {
+		if value == nil {
+			value = fallback
+		}
+		return strconv.FormatInt(*value, 10)
+	}
+	return &mailhogv1alpha1.JimStatus{
+		Preset:          activeJim(cr).Preset,
+		Disconnect:      float(settings.disconnect, jimDefaults.disconnect),
+		Accept:          float(settings.accept, jimDefaults.accept),
+		LinkspeedAffect: float(settings.linkspeedAffect, jimDefaults.linkspeedAffect),
+		LinkspeedMin:    integer(settings.linkspeedMin, jimDefaults.linkspeedMin),
+		LinkspeedMax:    integer(settings.linkspeedMax, jimDefaults.linkspeedMax),
+		RejectSender:    float(settings.rejectSender, jimDefaults.rejectSender),
+		RejectRecipient: float(settings.rejectRecipient, jimDefaults.rejectRecipient),
+		RejectAuth:      float(settings.rejectAuth, jimDefaults.rejectAuth),
+	}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/loadgen"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// MailhogLoadTestReconciler reconciles a MailhogLoadTest object
+type MailhogLoadTestReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	logger   logr.Logger
+}
+
+// defaultLoadTestDuration is how long messages are sent if the load test does not specify it
+var defaultLoadTestDuration = time.Duration(1) * time.Minute
+
+// loadTestDeadlineMargin is the time a load test Job may run longer than its duration before it is stopped
+var loadTestDeadlineMargin = time.Duration(5) * time.Minute
+
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogloadtests,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogloadtests/status,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogloadtests/finalizers,verbs=*
+
+// Reconcile starts the load generator Job once and records its result, load tests are not run again
+func (r *MailhogLoadTestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx, "ns", req.Namespace, "loadtest", req.Name)
+	r.logger.Info(reconcileStarted)
+
+	loadTest := &mailhogv1alpha1.MailhogLoadTest{}
+	if err := r.Get(ctx, req.NamespacedName, loadTest); err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.Info(crGetNotFound)
+			return ctrl.Result{}, nil
+		}
+		r.logger.Error(err, crGetFailed)
+		return ctrl.Result{}, err
+	}
+	if archiveFinished(loadTest.Status.Conditions) || !loadTest.DeletionTimestamp.IsZero() {
+		r.logger.Info(reconcileFinished)
+		return ctrl.Result{}, nil
+	}
+
+	original := loadTest.Status.DeepCopy()
+	if loadTest.Status.StartTime == nil {
+		now := metav1.Now()
+		loadTest.Status.StartTime = &now
+	}
+
+	result, err := r.run(ctx, loadTest)
+	if err != nil {
+		setCompletedCondition(&loadTest.Status.Conditions, loadTest.Generation, mailhogv1alpha1.InProgressReason, err.Error())
+	}
+
+	if !reflect.DeepEqual(*original, loadTest.Status) {
+		if updateErr := r.Status().Update(ctx, loadTest); updateErr != nil {
+			r.logger.Error(updateErr, failedCrUpdateStatus)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, updateErr
+		}
+	}
+	return result, nil
+}
+
+// run creates the load generator Job and records its result once it finished
+// a returned error is recorded in the condition and retried, final failures set the Failed reason themselves
+func (r *MailhogLoadTestReconciler) run(ctx context.Context, loadTest *mailhogv1alpha1.MailhogLoadTest) (ctrl.Result, error) {
+	logger := r.logger.WithValues(span, spanLoadTest)
+	name := types.NamespacedName{Name: loadTest.Name + loadTestSuffix, Namespace: loadTest.Namespace}
+
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, name, job); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, failedGetExisting)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+
+		cr := &mailhogv1alpha1.MailhogInstance{}
+		if err = r.Get(ctx, types.NamespacedName{Name: loadTest.Spec.Instance, Namespace: loadTest.Namespace}, cr); err != nil {
+			if errors.IsNotFound(err) {
+				return ctrl.Result{RequeueAfter: archiveRetryTime}, errMissingArchiveInstance
+			}
+			logger.Error(err, crGetFailed)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+
+		job = loadTestJobNew(loadTest, cr)
+		if err = ctrl.SetControllerReference(loadTest, job, r.Scheme); err != nil {
+			logger.Error(err, messageFailedSetOwnerRef)
+			return ctrl.Result{}, err
+		}
+		if err = r.Create(ctx, job); err != nil {
+			logger.Error(err, messageFailedCreate)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		jobCreate.Inc()
+		loadTest.Status.Job = job.Name
+		setCompletedCondition(&loadTest.Status.Conditions, loadTest.Generation, mailhogv1alpha1.InProgressReason,
+			"sending "+strconv.Itoa(int(loadTest.Spec.MessagesPerSecond))+" messages per second to "+loadTest.Spec.Instance)
+		return ctrl.Result{}, nil
+	}
+
+	succeeded, failed := archiveJobState(job)
+	switch {
+	case succeeded:
+		result := loadgen.Result{}
+		if err := jobResult(ctx, r, job, &result); err != nil {
+			logger.Error(err, failedLoadTest)
+		}
+		r.completeLoadTest(loadTest, result)
+	case failed:
+		r.Recorder.Event(loadTest, corev1.EventTypeWarning, "LoadTestFailed", errLoadTestJobFailed.Error())
+		setCompletedCondition(&loadTest.Status.Conditions, loadTest.Generation, mailhogv1alpha1.FailedReason, errLoadTestJobFailed.Error())
+	default:
+		setCompletedCondition(&loadTest.Status.Conditions, loadTest.Generation, mailhogv1alpha1.InProgressReason, "waiting for job "+job.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// completeLoadTest records the result of the load generator, a run without any accepted message failed
+func (r *MailhogLoadTestReconciler) completeLoadTest(loadTest *mailhogv1alpha1.MailhogLoadTest, result loadgen.Result) {
+	now := metav1.Now()
+	loadTest.Status.CompletionTime = &now
+	loadTest.Status.Sent = result.Sent
+	loadTest.Status.Errors = result.Errors
+	loadTest.Status.LastError = result.LastError
+	loadTest.Status.Throughput = strconv.FormatFloat(result.Throughput, 'f', 2, 64)
+	loadTest.Status.Latency = &mailhogv1alpha1.LoadTestLatency{
+		Average: metav1.Duration{Duration: result.Latency.Average},
+		P50:     metav1.Duration{Duration: result.Latency.P50},
+		P95:     metav1.Duration{Duration: result.Latency.P95},
+		P99:     metav1.Duration{Duration: result.Latency.P99},
+		Max:     metav1.Duration{Duration: result.Latency.Max},
+	}
+	loadTestSent.Add(float64(result.Sent))
+
+	message := "sent " + strconv.FormatInt(result.Sent, 10) + " messages to " + loadTest.Spec.Instance + " with " +
+		loadTest.Status.Throughput + " messages per second, " + strconv.FormatInt(result.Errors, 10) + " errors"
+	if result.Sent == 0 {
+		if result.LastError != "" {
+			message += ", last error: " + result.LastError
+		}
+		setCompletedCondition(&loadTest.Status.Conditions, loadTest.Generation, mailhogv1alpha1.FailedReason, message)
+		r.Recorder.Event(loadTest, corev1.EventTypeWarning, "LoadTestFailed", message)
+		return
+	}
+	setCompletedCondition(&loadTest.Status.Conditions, loadTest.Generation, mailhogv1alpha1.SucceededReason, message)
+	r.Recorder.Event(loadTest, corev1.EventTypeNormal, "LoadTestCompleted", message)
+}
+
+// loadTestDuration returns how long messages are sent
+func loadTestDuration(loadTest *mailhogv1alpha1.MailhogLoadTest) time.Duration {
+	if duration := loadTest.Spec.Duration; duration != nil && duration.Duration > 0 {
+		return duration.Duration
+	}
+	return defaultLoadTestDuration
+}
+
+// loadTestJobNew returns the Job running the load generator against the Service of the instance
+// the Job is not retried, since a second run would add to the load of the first one
+func loadTestJobNew(loadTest *mailhogv1alpha1.MailhogLoadTest, cr *mailhogv1alpha1.MailhogInstance) *batchv1.Job {
+	cfg := loadgen.Config{
+		Address:     mailhogServiceSmtpAddress(cr),
+		Rate:        int(loadTest.Spec.MessagesPerSecond),
+		Duration:    loadTestDuration(loadTest),
+		Concurrency: int(loadTest.Spec.Concurrency),
+		MessageSize: int(loadTest.Spec.MessageSize),
+		From:        loadTest.Spec.SenderPattern,
+		To:          loadTest.Spec.RecipientPattern,
+		ResultFile:  corev1.TerminationMessagePathDefault,
+	}
+	cfgBytes, _ := json.Marshal(cfg)
+
+	labels := map[string]string{
+		crTypeLabel:    loadTestTypeValue,
+		managedByLabel: operatorValue,
+		createdByLabel: operatorValue,
+	}
+	backoffLimit := int32(0)
+	deadline := int64((cfg.Duration + loadTestDeadlineMargin).Seconds())
+	automountToken := false
+	nonRoot := true
+	privilegeEscalation := false
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      loadTest.Name + loadTestSuffix,
+			Namespace: loadTest.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automountToken,
+					Containers: []corev1.Container{
+						{
+							Name:    loadgenJobContainer,
+							Image:   sidecarImage(cr),
+							Command: []string{loadgenCommand},
+							Env: []corev1.EnvVar{
+								{Name: loadgen.ConfigEnv, Value: string(cfgBytes)},
+							},
+							TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+							Resources:                archiveJobResources(),
+							SecurityContext: &corev1.SecurityContext{
+								RunAsNonRoot:             &nonRoot,
+								AllowPrivilegeEscalation: &privilegeEscalation,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// SetupWithManager sets up this controller with the Manager
+func (r *MailhogLoadTestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mailhogv1alpha1.MailhogLoadTest{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
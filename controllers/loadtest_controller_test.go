@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/loadgen"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("MailhogLoadTest controller", func() {
+	const (
+		ns    = "default"
+		image = "test/test:latest"
+	)
+	instanceName := types.NamespacedName{Name: "tester", Namespace: ns}
+	loadTestName := types.NamespacedName{Name: "tester-load", Namespace: ns}
+	jobName := types.NamespacedName{Name: loadTestName.Name + loadTestSuffix, Namespace: ns}
+
+	getTestingLoadTest := func() *mailhogv1alpha1.MailhogLoadTest {
+		return &mailhogv1alpha1.MailhogLoadTest{
+			ObjectMeta: metav1.ObjectMeta{Name: loadTestName.Name, Namespace: ns, UID: "loadtest-uid"},
+			Spec: mailhogv1alpha1.MailhogLoadTestSpec{
+				Instance:          instanceName.Name,
+				MessagesPerSecond: 20,
+				Duration:          &metav1.Duration{Duration: 2 * time.Minute},
+				Concurrency:       3,
+				MessageSize:       2048,
+				SenderPattern:     "app-{worker}@sender.example",
+				RecipientPattern:  "user-{n}@receiver.example",
+			},
+		}
+	}
+
+	getUpdatedLoadTest := func() *mailhogv1alpha1.MailhogLoadTest {
+		loadTest := &mailhogv1alpha1.MailhogLoadTest{}
+		Expect(k8sClient.Get(ctx, loadTestName, loadTest)).To(Succeed())
+		return loadTest
+	}
+
+	completeJob := func(result string) {
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, jobName, job)).To(Succeed())
+		job.Status.Succeeded = 1
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+		jobPod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "loadgen-pod", Namespace: ns, Labels: map[string]string{jobNameLabel: job.Name}},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: loadgenJobContainer,
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 0,
+						Message:  result,
+					}},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, jobPod)).To(Succeed())
+	}
+
+	Context("load test against an existing instance", func() {
+		It("should run the load generator job and record its result", func() {
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			objects := []client.Object{cr, getTestingLoadTest()}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			lr := &MailhogLoadTestReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := lr.Reconcile(ctx, reconcile.Request{NamespacedName: loadTestName})
+			Expect(err).ToNot(HaveOccurred())
+
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, jobName, job)).To(Succeed())
+			Expect(*job.Spec.BackoffLimit).To(BeZero())
+			Expect(*job.Spec.ActiveDeadlineSeconds).To(Equal(int64((2*time.Minute + loadTestDeadlineMargin).Seconds())))
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal(defaultSidecarImage))
+			Expect(container.Command).To(Equal([]string{loadgenCommand}))
+			cfg := loadgen.Config{}
+			Expect(json.Unmarshal([]byte(container.Env[0].Value), &cfg)).To(Succeed())
+			Expect(cfg.Address).To(Equal("tester.default.svc:1025"))
+			Expect(cfg.Rate).To(Equal(20))
+			Expect(cfg.Duration).To(Equal(2 * time.Minute))
+			Expect(cfg.Concurrency).To(Equal(3))
+			Expect(cfg.From).To(Equal("app-{worker}@sender.example"))
+			Expect(job.Spec.Template.Labels).ToNot(HaveKey(crNameLabel))
+			Expect(getUpdatedLoadTest().Status.Job).To(Equal(jobName.Name))
+
+			completeJob(`{"sent":2390,"errors":3,"lastError":"421 too busy","elapsed":120000000000,"throughput":19.92,` +
+				`"latency":{"average":4000000,"p50":3000000,"p95":9000000,"p99":15000000,"max":80000000}}`)
+			_, err = lr.Reconcile(ctx, reconcile.Request{NamespacedName: loadTestName})
+			Expect(err).ToNot(HaveOccurred())
+
+			updated := getUpdatedLoadTest()
+			condition := meta.FindStatusCondition(updated.Status.Conditions, mailhogv1alpha1.CompletedCondition)
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.SucceededReason))
+			Expect(updated.Status.Sent).To(Equal(int64(2390)))
+			Expect(updated.Status.Errors).To(Equal(int64(3)))
+			Expect(updated.Status.LastError).To(Equal("421 too busy"))
+			Expect(updated.Status.Throughput).To(Equal("19.92"))
+			Expect(updated.Status.Latency.P95.Duration).To(Equal(9 * time.Millisecond))
+			Expect(updated.Status.CompletionTime).ToNot(BeNil())
+		})
+
+		It("should fail if no message was accepted", func() {
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr, getTestingLoadTest()).Build()
+
+			lr := &MailhogLoadTestReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := lr.Reconcile(ctx, reconcile.Request{NamespacedName: loadTestName})
+			Expect(err).ToNot(HaveOccurred())
+			completeJob(`{"sent":0,"errors":12,"lastError":"connection refused"}`)
+			_, err = lr.Reconcile(ctx, reconcile.Request{NamespacedName: loadTestName})
+			Expect(err).ToNot(HaveOccurred())
+
+			condition := meta.FindStatusCondition(getUpdatedLoadTest().Status.Conditions, mailhogv1alpha1.CompletedCondition)
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.FailedReason))
+			Expect(condition.Message).To(ContainSubstring("connection refused"))
+		})
+	})
+
+	Context("load test against a missing instance", func() {
+		It("should wait for the instance", func() {
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(getTestingLoadTest()).Build()
+
+			lr := &MailhogLoadTestReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := lr.Reconcile(ctx, reconcile.Request{NamespacedName: loadTestName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(archiveRetryTime))
+
+			condition := meta.FindStatusCondition(getUpdatedLoadTest().Status.Conditions, mailhogv1alpha1.CompletedCondition)
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.InProgressReason))
+			Expect(condition.Message).To(Equal(errMissingArchiveInstance.Error()))
+			Expect(k8sClient.Get(ctx, jobName, &batchv1.Job{})).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// mailhogApiUrl returns the base url of the mailhog api of a pod (including the web path)
+// behind an authenticating proxy mailhog only listens on loopback, so the api is reached through the proxy
+// it is a variable so tests can point it to a fake api
+var mailhogApiUrl = func(cr *mailhogv1alpha1.MailhogInstance, pod *corev1.Pod) string {
+	base := "http://" + pod.Status.PodIP + ":" + strconv.Itoa(int(webTargetPort(cr)))
+	if webPath := cr.Spec.Settings.WebPath; webPath != "" {
+		base += "/" + webPath
+	}
+	return base
+}
+
+// mailhogApi is a minimal client for the api of a single mailhog pod
+type mailhogApi struct {
+	baseUrl  string
+	user     string
+	password string
+}
+
+// mailhogMessages is a page of messages returned by the v2 api
+type mailhogMessages struct {
+	Total int              `json:"total"`
+	Count int              `json:"count"`
+	Start int              `json:"start"`
+	Items []mailhogMessage `json:"items"`
+}
+
+// mailhogMessage contains the fields of a v2 api message the operator cares about
+type mailhogMessage struct {
+	ID      string    `json:"ID"`
+	Created time.Time `json:"Created"`
+}
+
+// mailhogApiPods returns the pods of the CR whose api can be reached
+func mailhogApiPods(ctx context.Context, c client.Reader, cr *mailhogv1alpha1.MailhogInstance) (pods []corev1.Pod, err error) {
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(cr.Namespace),
+		client.MatchingLabels(CreateMetaMaker(cr).GetLabels()),
+	}
+	if err = c.List(ctx, podList, listOpts...); err != nil {
+		return nil, err
+	}
+	return reachablePods(podList.Items), nil
+}
+
+// reachablePods returns the pods that are running and have an ip
+func reachablePods(pods []corev1.Pod) (reachable []corev1.Pod) {
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" && pod.DeletionTimestamp.IsZero() {
+			reachable = append(reachable, pod)
+		}
+	}
+	return reachable
+}
+
+// mailhogContainerStatus returns the status of the mailhog container of the pod, nil if it has none yet
+// the pod may run sidecars, so the position of the status is not relied on
+func mailhogContainerStatus(pod *corev1.Pod) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == mh {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// mailhogApiFor returns an api client for the given pod, authenticating as internal probe user if basic auth or
+// an authenticating proxy is enabled
+func mailhogApiFor(ctx context.Context, c client.Reader, cr *mailhogv1alpha1.MailhogInstance, pod *corev1.Pod) (*mailhogApi, error) {
+	api := &mailhogApi{baseUrl: mailhogApiUrl(cr, pod)}
+	if probeUserNeeded(cr) {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: cr.Namespace}, secret); err != nil {
+			return nil, err
+		}
+		api.user = probeUserName
+		api.password = string(secret.Data[secretKeyProbePassword])
+	}
+	return api, nil
+}
+
+// messages returns a page of messages, newest first
+func (a *mailhogApi) messages(ctx context.Context, start, limit int) (page mailhogMessages, err error) {
+	query := url.Values{}
+	query.Set("start", strconv.Itoa(start))
+	query.Set("limit", strconv.Itoa(limit))
+
+	resp, err := a.do(ctx, http.MethodGet, "/api/v2/messages?"+query.Encode())
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	return page, err
+}
+
+// search returns the first page of messages matching the query, kind is one of from, to or containing
+func (a *mailhogApi) search(ctx context.Context, kind, value string) (page mailhogMessages, err error) {
+	query := url.Values{}
+	query.Set("kind", kind)
+	query.Set("query", value)
+
+	resp, err := a.do(ctx, http.MethodGet, "/api/v2/search?"+query.Encode())
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	return page, err
+}
+
+// deleteMessage deletes a single message
+func (a *mailhogApi) deleteMessage(ctx context.Context, id string) error {
+	resp, err := a.do(ctx, http.MethodDelete, "/api/v1/messages/"+url.PathEscape(id))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a request to the api and fails on non 2xx responses
+func (a *mailhogApi) do(ctx context.Context, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.baseUrl+path, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if a.user != "" {
+		req.SetBasicAuth(a.user, a.password)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s %s returned %d", errMailhogApi, method, path, resp.StatusCode)
+	}
+	return resp, nil
+}
@@ -108,6 +108,156 @@ var (
 			Help: "Number of times a reconcile deleted an Ingress",
 		},
 	)
+	secretCreate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_secret_create_total",
+			Help: "Number of times a reconcile created a Secret",
+		},
+	)
+	secretUpdate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_secret_update_total",
+			Help: "Number of times a reconcile updated a Secret",
+		},
+	)
+	secretDelete = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_secret_delete_total",
+			Help: "Number of times a reconcile deleted a Secret",
+		},
+	)
+	serviceAccountCreate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_serviceaccount_create_total",
+			Help: "Number of times a reconcile created a ServiceAccount",
+		},
+	)
+	serviceAccountUpdate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_serviceaccount_update_total",
+			Help: "Number of times a reconcile updated a ServiceAccount",
+		},
+	)
+	serviceAccountDelete = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_serviceaccount_delete_total",
+			Help: "Number of times a reconcile deleted a ServiceAccount",
+		},
+	)
+	clusterRoleBindingCreate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_clusterrolebinding_create_total",
+			Help: "Number of times a reconcile created a ClusterRoleBinding",
+		},
+	)
+	clusterRoleBindingUpdate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_clusterrolebinding_update_total",
+			Help: "Number of times a reconcile updated a ClusterRoleBinding",
+		},
+	)
+	clusterRoleBindingDelete = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_clusterrolebinding_delete_total",
+			Help: "Number of times a reconcile deleted a ClusterRoleBinding",
+		},
+	)
+	roleCreate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_role_create_total",
+			Help: "Number of times a reconcile created a Role",
+		},
+	)
+	roleUpdate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_role_update_total",
+			Help: "Number of times a reconcile updated a Role",
+		},
+	)
+	roleDelete = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_role_delete_total",
+			Help: "Number of times a reconcile deleted a Role",
+		},
+	)
+	roleBindingCreate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_rolebinding_create_total",
+			Help: "Number of times a reconcile created a RoleBinding",
+		},
+	)
+	roleBindingUpdate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_rolebinding_update_total",
+			Help: "Number of times a reconcile updated a RoleBinding",
+		},
+	)
+	roleBindingDelete = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_rolebinding_delete_total",
+			Help: "Number of times a reconcile deleted a RoleBinding",
+		},
+	)
+	jobCreate = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_job_create_total",
+			Help: "Number of times a reconcile created a Job",
+		},
+	)
+	jobDelete = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_job_delete_total",
+			Help: "Number of times a reconcile deleted a Job",
+		},
+	)
+	retentionDeleted = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_retention_deleted_messages_total",
+			Help: "Number of messages deleted because they exceeded the retention settings",
+		},
+	)
+	snapshotMessages = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_snapshot_messages_total",
+			Help: "Number of messages exported by snapshots",
+		},
+	)
+	restoreMessages = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_restore_messages_total",
+			Help: "Number of messages delivered by restores, counted once per pod",
+		},
+	)
+	assertionPassed = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_assertion_passed_total",
+			Help: "Number of mailhog assertions that passed",
+		},
+	)
+	assertionFailed = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_assertion_failed_total",
+			Help: "Number of mailhog assertions that failed",
+		},
+	)
+	loadTestSent = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_loadtest_sent_messages_total",
+			Help: "Number of messages sent by mailhog load tests",
+		},
+	)
+	chaosRuns = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_chaos_experiment_runs_total",
+			Help: "Number of times a chaos experiment applied jim to an instance",
+		},
+	)
+	archiveUploaded = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mailhog_archive_uploaded_messages_total",
+			Help: "Number of messages uploaded to an object storage archive",
+		},
+	)
 )
 
 func init() {
@@ -117,4 +267,13 @@ func init() {
 	metrics.Registry.MustRegister(crUpdate, crValidationSuccess, crValidationFailure)
 	metrics.Registry.MustRegister(confMapCreate, confMapUpdate, confMapDelete)
 	metrics.Registry.MustRegister(ingressCreate, ingressUpdate, ingressDelete)
+	metrics.Registry.MustRegister(secretCreate, secretUpdate, secretDelete)
+	metrics.Registry.MustRegister(serviceAccountCreate, serviceAccountUpdate, serviceAccountDelete)
+	metrics.Registry.MustRegister(clusterRoleBindingCreate, clusterRoleBindingUpdate, clusterRoleBindingDelete)
+	metrics.Registry.MustRegister(roleCreate, roleUpdate, roleDelete)
+	metrics.Registry.MustRegister(roleBindingCreate, roleBindingUpdate, roleBindingDelete)
+	metrics.Registry.MustRegister(jobCreate, jobDelete, retentionDeleted)
+	metrics.Registry.MustRegister(snapshotMessages, restoreMessages, archiveUploaded)
+	metrics.Registry.MustRegister(assertionPassed, assertionFailed, loadTestSent)
+	metrics.Registry.MustRegister(chaosRuns)
 }
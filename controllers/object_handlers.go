@@ -21,15 +21,38 @@ func (r *MailhogInstanceReconciler) create(ctx context.Context,
 	logger logr.Logger,
 	obj client.Object,
 	tickFunc prometheus.Counter,
+) (err error) {
+	return r.createObject(ctx, cr, logger, obj, tickFunc, true)
+}
+
+// createUnowned tries to create the given object without a controller reference (e.g. cluster scoped objects)
+func (r *MailhogInstanceReconciler) createUnowned(ctx context.Context,
+	cr *mailhogv1alpha1.MailhogInstance,
+	logger logr.Logger,
+	obj client.Object,
+	tickFunc prometheus.Counter,
+) (err error) {
+	return r.createObject(ctx, cr, logger, obj, tickFunc, false)
+}
+
+// createObject tries to create the given object, optionally controlled by the CR
+func (r *MailhogInstanceReconciler) createObject(ctx context.Context,
+	cr *mailhogv1alpha1.MailhogInstance,
+	logger logr.Logger,
+	obj client.Object,
+	tickFunc prometheus.Counter,
+	owned bool,
 ) (err error) {
 	if err = patch.DefaultAnnotator.SetLastAppliedAnnotation(obj); err != nil {
 		logger.Error(err, messageFailedGetInitialObject)
 		return err
 	}
 
-	if err = ctrl.SetControllerReference(cr, obj, r.Scheme); err != nil {
-		logger.Error(err, messageFailedSetOwnerRef)
-		return err
+	if owned {
+		if err = ctrl.SetControllerReference(cr, obj, r.Scheme); err != nil {
+			logger.Error(err, messageFailedSetOwnerRef)
+			return err
+		}
 	}
 
 	if err = r.Create(ctx, obj); err != nil {
@@ -86,9 +109,32 @@ func (r *MailhogInstanceReconciler) update(ctx context.Context,
 	obj client.Object,
 	tickFunc prometheus.Counter,
 ) (err error) {
-	if err = ctrl.SetControllerReference(cr, obj, r.Scheme); err != nil {
-		logger.Error(err, messageFailedSetOwnerRefUpdate)
-		return err
+	return r.updateObject(ctx, cr, logger, obj, tickFunc, true)
+}
+
+// updateUnowned tries to update the given object without a controller reference (e.g. cluster scoped objects)
+func (r *MailhogInstanceReconciler) updateUnowned(ctx context.Context,
+	cr *mailhogv1alpha1.MailhogInstance,
+	logger logr.Logger,
+	obj client.Object,
+	tickFunc prometheus.Counter,
+) (err error) {
+	return r.updateObject(ctx, cr, logger, obj, tickFunc, false)
+}
+
+// updateObject tries to update the given object, optionally controlled by the CR
+func (r *MailhogInstanceReconciler) updateObject(ctx context.Context,
+	cr *mailhogv1alpha1.MailhogInstance,
+	logger logr.Logger,
+	obj client.Object,
+	tickFunc prometheus.Counter,
+	owned bool,
+) (err error) {
+	if owned {
+		if err = ctrl.SetControllerReference(cr, obj, r.Scheme); err != nil {
+			logger.Error(err, messageFailedSetOwnerRefUpdate)
+			return err
+		}
 	}
 	if err = r.Update(ctx, obj); err != nil {
 		if errors.IsInvalid(err) && obj.GetObjectKind().GroupVersionKind() == appsv1.SchemeGroupVersion.WithKind("Deployment") {
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"strconv"
+
 	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -12,7 +14,7 @@ import (
 func podTemplate(cr *mailhogv1alpha1.MailhogInstance) corev1.PodTemplateSpec {
 	meta := CreateMetaMaker(cr)
 	env := envForCr(cr)
-	ports := portsForCr()
+	ports := portsForCr(cr)
 	image := cr.Spec.Image
 
 	var resources corev1.ResourceRequirements
@@ -22,7 +24,7 @@ func podTemplate(cr *mailhogv1alpha1.MailhogInstance) corev1.PodTemplateSpec {
 		resources = *cr.Spec.Settings.Resources.DeepCopy()
 	}
 
-	isExplicitlyFalse := false
+	automountToken := false
 
 	pod := corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
@@ -38,18 +40,41 @@ func podTemplate(cr *mailhogv1alpha1.MailhogInstance) corev1.PodTemplateSpec {
 					Resources:      resources,
 					LivenessProbe:  getProbeTcp(portWeb),
 					StartupProbe:   getProbeTcp(portWeb),
-					ReadinessProbe: getProbeHttp(portWeb, cr.Spec.Settings.WebPath+httpHealthPath),
+					ReadinessProbe: getProbeHttp(portWeb, healthPath(cr)),
 				},
 			},
-			AutomountServiceAccountToken: &isExplicitlyFalse,
+			AutomountServiceAccountToken: &automountToken,
 		},
 	}
 
-	if cr.Spec.Settings.Storage == mailhogv1alpha1.MaildirStorage || cr.Spec.Settings.Files != nil {
+	if webProxyNeeded(cr) {
+		// mailhog only listens on the loopback interface, the kubelet can not reach it from outside the pod
+		pod.Spec.Containers[0].LivenessProbe = getProbeHttpLocal(portWeb, healthPath(cr))
+		pod.Spec.Containers[0].StartupProbe = getProbeHttpLocal(portWeb, healthPath(cr))
+		pod.Spec.Containers[0].ReadinessProbe = getProbeHttpLocal(portWeb, healthPath(cr))
+	}
+
+	if cr.Spec.Settings.Storage == mailhogv1alpha1.MaildirStorage || cr.Spec.Settings.Files != nil || cr.Spec.Settings.TrustedCa != nil {
 		pod.Spec.Volumes, pod.Spec.Containers[0].VolumeMounts = podVolumes(cr)
 	}
 
-	if cr.Spec.Settings.Jim.Invite == true {
+	if smtpProxyNeeded(cr) {
+		pod.Spec.Containers = append(pod.Spec.Containers, smtpProxyContainer(cr))
+		pod.Spec.Volumes = append(pod.Spec.Volumes, smtpProxyVolumes(cr)...)
+	}
+
+	if webProxyNeeded(cr) {
+		pod.Spec.Containers = append(pod.Spec.Containers, webProxyContainer(cr))
+		pod.Spec.Volumes = append(pod.Spec.Volumes, webProxyVolumes(cr)...)
+	}
+
+	if rbacAuthNeeded(cr) {
+		// the oauth-proxy authenticates itself as oauth client with the service account token,
+		// which webProxyVolumes projects into the proxy only instead of mounting it into every container
+		pod.Spec.ServiceAccountName = serviceAccountName(cr)
+	}
+
+	if jimEnabled(cr) {
 		pod.Spec.Containers[0].Args = jimArgs(cr)
 	}
 
@@ -66,9 +91,20 @@ func podTemplate(cr *mailhogv1alpha1.MailhogInstance) corev1.PodTemplateSpec {
 		}
 	}
 
-	if cr.Spec.Settings.Files != nil && len(cr.Spec.Settings.Files.WebUsers) > 0 {
-		// since http authentication is active, kube can no longer perform a http health check, switch to socket
-		pod.Spec.Containers[0].ReadinessProbe = getProbeTcp(portWeb)
+	if basicAuthEnabled(cr) {
+		// kube can not send credentials from a Secret with a http probe, so the internal probe user is checked via exec
+		pod.Spec.Containers[0].ReadinessProbe = getProbeHttpAuthenticated(portWeb, healthPath(cr))
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, corev1.EnvVar{
+			Name: envProbePassword,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: generatedSecretName(cr),
+					},
+					Key: secretKeyProbePassword,
+				},
+			},
+		})
 	}
 
 	return pod
@@ -76,7 +112,7 @@ func podTemplate(cr *mailhogv1alpha1.MailhogInstance) corev1.PodTemplateSpec {
 
 // podVolumes will return the required volumes and mounts for a given CR
 func podVolumes(cr *mailhogv1alpha1.MailhogInstance) (volumes []corev1.Volume, volumeMounts []corev1.VolumeMount) {
-	if cr.Spec.Settings.Storage == mailhogv1alpha1.MaildirStorage || cr.Spec.Settings.Files != nil {
+	if cr.Spec.Settings.Storage == mailhogv1alpha1.MaildirStorage {
 		if cr.Spec.Settings.StorageMaildir.Path != "" && cr.Spec.Settings.Storage == mailhogv1alpha1.MaildirStorage {
 
 			if claimName := cr.Spec.Settings.StorageMaildir.PvName; claimName == "" {
@@ -103,25 +139,135 @@ func podVolumes(cr *mailhogv1alpha1.MailhogInstance) (volumes []corev1.Volume, v
 			})
 
 		}
-		if cr.Spec.Settings.Files != nil {
-			volumes = append(volumes, corev1.Volume{
-				Name: volumeNameSettings,
-				VolumeSource: corev1.VolumeSource{
-					ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: cr.Name,
-						},
+	}
+
+	if basicAuthEnabled(cr) {
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameSettings,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: usersSecretName(cr),
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeNameSettings,
+			MountPath: settingsFilesMount,
+			ReadOnly:  true,
+		})
+	}
+
+	if upstreamsSecretNeeded(cr) {
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameServers,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: upstreamsSecretName(cr),
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeNameServers,
+			MountPath: settingsServersMount,
+			ReadOnly:  true,
+		})
+	}
+
+	// an upstreams file without other upstreams to merge with is read by mailhog as it is
+	if upstreamsFileDirect(cr) {
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameSettingsFrom,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{
+						settingsFileProjection(cr.Spec.Settings.Files.UpstreamsFrom, settingsFileUpstreamsName),
+					},
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeNameSettingsFrom,
+			MountPath: settingsFromMount,
+			ReadOnly:  true,
+		})
+	}
+
+	if trustedCa := cr.Spec.Settings.TrustedCa; trustedCa != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameTrustedCa,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: trustedCa.ConfigMapName,
 					},
 				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeNameTrustedCa,
+			MountPath: trustedCaMount,
+			ReadOnly:  true,
+		})
+	}
+
+	if sources := upstreamCaSources(cr); len(sources) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameUpstreamCa,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: sources,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeNameUpstreamCa,
+			MountPath: upstreamCaMount,
+			ReadOnly:  true,
+		})
+	}
+	return volumes, volumeMounts
+}
+
+// upstreamCaSources returns the ca bundles of the smtp upstreams, each projected into a file named after its upstream
+func upstreamCaSources(cr *mailhogv1alpha1.MailhogInstance) (sources []corev1.VolumeProjection) {
+	if cr.Spec.Settings.Files == nil {
+		return sources
+	}
+	for _, upstream := range cr.Spec.Settings.Files.SmtpUpstreams {
+		if upstream.Tls == nil {
+			continue
+		}
+		if ref := upstream.Tls.CaConfigMapKeyRef; ref != nil {
+			sources = append(sources, corev1.VolumeProjection{
+				ConfigMap: &corev1.ConfigMapProjection{
+					LocalObjectReference: ref.LocalObjectReference,
+					Items:                []corev1.KeyToPath{{Key: ref.Key, Path: upstreamCaFile(upstream)}},
+				},
 			})
-			volumeMounts = append(volumeMounts, corev1.VolumeMount{
-				Name:      volumeNameSettings,
-				MountPath: settingsFilesMount,
+		}
+		if ref := upstream.Tls.CaSecretKeyRef; ref != nil {
+			sources = append(sources, corev1.VolumeProjection{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: ref.LocalObjectReference,
+					Items:                []corev1.KeyToPath{{Key: ref.Key, Path: upstreamCaFile(upstream)}},
+				},
 			})
-
 		}
 	}
-	return volumes, volumeMounts
+	return sources
+}
+
+// upstreamCaFile returns the file name of an upstream's ca bundle below upstreamCaMount, empty if it has none
+func upstreamCaFile(upstream mailhogv1alpha1.MailhogUpstreamSpec) string {
+	if upstream.Tls == nil || (upstream.Tls.CaConfigMapKeyRef == nil && upstream.Tls.CaSecretKeyRef == nil) {
+		return ""
+	}
+	return upstream.Name + ".crt"
+}
+
+// trustedCaFile returns the path of the trusted ca bundle in the mailhog container
+func trustedCaFile(trustedCa *mailhogv1alpha1.MailhogTrustedCaSpec) string {
+	return trustedCaMount + "/" + trustedCaRef(trustedCa).Key
 }
 
 // defaultResources will return the resource limits used when none are specified in the CR
@@ -155,6 +301,49 @@ func getProbeHttp(port int, path string) (probe *corev1.Probe) {
 	}
 }
 
+// getProbeHttpAuthenticated will return a new exec probe requesting the given port and path as internal probe user
+// the password is read from the environment by the shell, so it never shows up in the pod spec
+func getProbeHttpAuthenticated(port int, path string) (probe *corev1.Probe) {
+	url := "http://" + probeUserName + ":${" + envProbePassword + "}@127.0.0.1:" + strconv.Itoa(port) + path
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/sh", "-c", "wget -q -O /dev/null \"" + url + "\""},
+			},
+		},
+		InitialDelaySeconds: 10,
+		TimeoutSeconds:      2,
+		PeriodSeconds:       5,
+		SuccessThreshold:    1,
+		FailureThreshold:    3,
+	}
+}
+
+// getProbeHttpLocal will return a new exec probe requesting the given port and path on the loopback interface
+func getProbeHttpLocal(port int, path string) (probe *corev1.Probe) {
+	url := "http://127.0.0.1:" + strconv.Itoa(port) + path
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/sh", "-c", "wget -q -O /dev/null \"" + url + "\""},
+			},
+		},
+		InitialDelaySeconds: 10,
+		TimeoutSeconds:      2,
+		PeriodSeconds:       5,
+		SuccessThreshold:    1,
+		FailureThreshold:    3,
+	}
+}
+
+// healthPath returns the path of the api endpoint used for readiness checks, below the web path if one is set
+func healthPath(cr *mailhogv1alpha1.MailhogInstance) string {
+	if webPath := cr.Spec.Settings.WebPath; webPath != "" {
+		return "/" + webPath + httpHealthPath
+	}
+	return httpHealthPath
+}
+
 // getProbeTcp will return a new tcp probe on the given port
 func getProbeTcp(port int) (probe *corev1.Probe) {
 	return &corev1.Probe{
@@ -172,24 +361,42 @@ func getProbeTcp(port int) (probe *corev1.Probe) {
 }
 
 // jimArgs will return the needed container args for the desired chaos monkey configuration
+// only values that are set are passed, mailhog uses its defaults for the others
 func jimArgs(cr *mailhogv1alpha1.MailhogInstance) []string {
 	args := make([]string, 0)
 
-	if cr.Spec.Settings.Jim.Invite == true {
+	if jimEnabled(cr) {
+		settings, _ := effectiveJim(cr)
 		args = append(args, argsJimInvite)
-		args = appendNonEmptyArg(args, argsJimDisconnectRate, cr.Spec.Settings.Jim.Disconnect)
-		args = appendNonEmptyArg(args, argsJimAccept, cr.Spec.Settings.Jim.Accept)
-		args = appendNonEmptyArg(args, argsJimLinkSpeedAffect, cr.Spec.Settings.Jim.LinkspeedAffect)
-		args = appendNonEmptyArg(args, argsJimLinkSpeedMin, cr.Spec.Settings.Jim.LinkspeedMin)
-		args = appendNonEmptyArg(args, argsJimLinkSpeedMax, cr.Spec.Settings.Jim.LinkspeedMax)
-		args = appendNonEmptyArg(args, argsJimRejectSender, cr.Spec.Settings.Jim.RejectSender)
-		args = appendNonEmptyArg(args, argsJimRejectRecipient, cr.Spec.Settings.Jim.RejectRecipient)
-		args = appendNonEmptyArg(args, argsJimRejectAuth, cr.Spec.Settings.Jim.RejectAuth)
+		args = appendNonEmptyArg(args, argsJimDisconnectRate, formatJimFloat(settings.disconnect))
+		args = appendNonEmptyArg(args, argsJimAccept, formatJimFloat(settings.accept))
+		args = appendNonEmptyArg(args, argsJimLinkSpeedAffect, formatJimFloat(settings.linkspeedAffect))
+		args = appendNonEmptyArg(args, argsJimLinkSpeedMin, formatJimInt(settings.linkspeedMin))
+		args = appendNonEmptyArg(args, argsJimLinkSpeedMax, formatJimInt(settings.linkspeedMax))
+		args = appendNonEmptyArg(args, argsJimRejectSender, formatJimFloat(settings.rejectSender))
+		args = appendNonEmptyArg(args, argsJimRejectRecipient, formatJimFloat(settings.rejectRecipient))
+		args = appendNonEmptyArg(args, argsJimRejectAuth, formatJimFloat(settings.rejectAuth))
 	}
 
 	return args
 }
 
+// formatJimFloat returns the arg value of a jim probability, empty if it is not set
+func formatJimFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+// formatJimInt returns the arg value of a jim link speed, empty if it is not set
+func formatJimInt(value *int64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
 // appendNonemptyArgs will append container args if the value is non-empty
 func appendNonEmptyArg(args []string, arg string, value string) []string {
 	if value == "" {
@@ -200,35 +407,50 @@ func appendNonEmptyArg(args []string, arg string, value string) []string {
 }
 
 // portsForCr will return the desired ContainerPorts of a given CR
-func portsForCr() (p []corev1.ContainerPort) {
-	return []corev1.ContainerPort{
-		{
+// the web port is not declared if only the authenticating proxy may reach it,
+// the smtp port is not declared if only the smtp proxy may reach it
+func portsForCr(cr *mailhogv1alpha1.MailhogInstance) (p []corev1.ContainerPort) {
+	if !webProxyNeeded(cr) {
+		p = append(p, corev1.ContainerPort{
 			Name:          portWebName,
 			ContainerPort: portWeb,
 			Protocol:      protoTcp,
-		},
-		{
+		})
+	}
+	if !smtpProxyIntercepts(cr) {
+		p = append(p, corev1.ContainerPort{
 			Name:          portSmtpName,
 			ContainerPort: portSmtp,
 			Protocol:      protoTcp,
-		},
+		})
 	}
+	return p
 }
 
 // envForCr will return the desired environment variables for a give CR
 func envForCr(crs *mailhogv1alpha1.MailhogInstance) (e []corev1.EnvVar) {
+	// behind an authenticating proxy the ui / api must not be reachable without it
+	webBind := envBindWebValue
+	if webProxyNeeded(crs) {
+		webBind = envBindWebLocalValue
+	}
+	// behind the smtp proxy its faults, policy and relay rules must not be bypassed
+	smtpBind := envBindSmtpValue
+	if smtpProxyIntercepts(crs) {
+		smtpBind = envBindSmtpLocalValue
+	}
 	e = []corev1.EnvVar{
 		{
 			Name:  envSmtpBind,
-			Value: envBindSmtpValue,
+			Value: smtpBind,
 		},
 		{
 			Name:  envApiBind,
-			Value: envBindWebValue,
+			Value: webBind,
 		},
 		{
 			Name:  envUiBind,
-			Value: envBindWebValue,
+			Value: webBind,
 		},
 	}
 
@@ -248,14 +470,23 @@ func envForCr(crs *mailhogv1alpha1.MailhogInstance) (e []corev1.EnvVar) {
 	e = appendNonEmptyEnv(e, envCorsOrigin, crs.Spec.Settings.CorsOrigin)
 	e = appendNonEmptyEnv(e, envWebPath, crs.Spec.Settings.WebPath)
 
-	if crs.Spec.Settings.Files != nil {
-		if len(crs.Spec.Settings.Files.SmtpUpstreams) > 0 {
-			e = appendNonEmptyEnv(e, envUpstreamSmtpFile, settingsFileUpstreamsPath)
-		}
+	if upstreamsFileDirect(crs) {
+		e = appendNonEmptyEnv(e, envUpstreamSmtpFile, settingsFromUpstreamsPath)
+	} else if upstreamsSecretNeeded(crs) {
+		e = appendNonEmptyEnv(e, envUpstreamSmtpFile, settingsFileUpstreamsPath)
+	}
 
-		if len(crs.Spec.Settings.Files.WebUsers) > 0 {
-			e = appendNonEmptyEnv(e, envWebAuthFile, settingsFilePasswordsPath)
-		}
+	if basicAuthEnabled(crs) {
+		e = appendNonEmptyEnv(e, envWebAuthFile, settingsFilePasswordsPath)
+	}
+
+	// go reads the system certificates from SSL_CERT_FILE and every file in SSL_CERT_DIR,
+	// the upstream bundles replace the default directories but not the default bundle file
+	if trustedCa := crs.Spec.Settings.TrustedCa; trustedCa != nil {
+		e = appendNonEmptyEnv(e, envSslCertFile, trustedCaFile(trustedCa))
+	}
+	if len(upstreamCaSources(crs)) > 0 {
+		e = appendNonEmptyEnv(e, envSslCertDir, upstreamCaMount)
 	}
 
 	return
@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"reflect"
+	"strconv"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// preserveNeeded returns true if messages need to be exported before a rollout
+func preserveNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	return cr.Spec.Settings.PreserveOnRollout && cr.Spec.Settings.Storage == mailhogv1alpha1.MemoryStorage
+}
+
+// rolloutNeeded returns true if the Deployment update changes more than the replica count, which creates new pods
+func rolloutNeeded(oldDeployment, newDeployment *appsv1.Deployment) (bool, error) {
+	scaledDeployment := newDeployment.DeepCopy()
+	scaledDeployment.Spec.Replicas = oldDeployment.Spec.Replicas
+	return checkPatch(oldDeployment, scaledDeployment)
+}
+
+// deploymentStorage returns the storage backend the pods of a Deployment use, mailhog defaults to memory
+func deploymentStorage(deployment *appsv1.Deployment) mailhogv1alpha1.StorageSetting {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name != mh {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == envStorage && env.Value != "" {
+				return mailhogv1alpha1.StorageSetting(env.Value)
+			}
+		}
+	}
+	return mailhogv1alpha1.MemoryStorage
+}
+
+// sharedStorage returns true if all pods use the same storage, so messages only need to be delivered once
+func sharedStorage(cr *mailhogv1alpha1.MailhogInstance) bool {
+	return cr.Spec.Settings.Storage == mailhogv1alpha1.MongoDBStorage
+}
+
+// preserveBeforeRollout exports the messages if a Deployment update rolls out pods that would lose them,
+// either because they are kept in memory or because the storage backend changes
+// it returns false if the export failed, the update must then wait for the next attempt
+func (r *MailhogInstanceReconciler) preserveBeforeRollout(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, oldDeployment, newDeployment *appsv1.Deployment) (bool, error) {
+	if from := deploymentStorage(oldDeployment); from != deploymentStorage(newDeployment) {
+		return r.preserveMessages(ctx, cr, from)
+	}
+	if !preserveNeeded(cr) {
+		return true, nil
+	}
+	rollout, err := rolloutNeeded(oldDeployment, newDeployment)
+	if err != nil {
+		r.logger.WithValues(span, spanPreserve).Error(err, failedUpdateCheck)
+		return false, err
+	}
+	if !rollout {
+		return true, nil
+	}
+	return r.preserveMessages(ctx, cr, "")
+}
+
+// preserveMessages exports the messages of all pods into a ConfigMap before a rollout
+// a failed export is recorded in the status and blocks the rollout, which would lose the messages otherwise
+func (r *MailhogInstanceReconciler) preserveMessages(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, migrateFrom mailhogv1alpha1.StorageSetting) (bool, error) {
+	logger := r.logger.WithValues(span, spanPreserve)
+
+	now := metav1.Now()
+	status := &mailhogv1alpha1.PreserveStatus{MigrateFrom: migrateFrom, Phase: mailhogv1alpha1.ExportedPreservePhase, ExportTime: &now}
+	messages, sourcePods, exportErr := r.exportPreservedMessages(ctx, cr)
+	if exportErr == nil {
+		exportErr = r.storePreservedMessages(ctx, cr, messages)
+	}
+	if exportErr != nil {
+		logger.Error(exportErr, failedPreserveExport)
+		r.Recorder.Event(cr, corev1.EventTypeWarning, "PreserveFailed", "rollout blocked: "+exportErr.Error())
+		status.Phase = mailhogv1alpha1.FailedPreservePhase
+		status.Error = exportErr.Error()
+		return false, r.updatePreserveStatus(ctx, cr, status)
+	}
+	r.Recorder.Event(cr, corev1.EventTypeNormal, "Preserved", "exported "+strconv.Itoa(len(messages))+" messages before rollout")
+	status.Messages = len(messages)
+	status.SourcePods = sourcePods
+	return true, r.updatePreserveStatus(ctx, cr, status)
+}
+
+// abandonPreserve clears a failed export once no rollout is pending anymore, eg because the change was reverted
+func (r *MailhogInstanceReconciler) abandonPreserve(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) error {
+	preserve := cr.Status.Preserve
+	if preserve == nil || preserve.Phase != mailhogv1alpha1.FailedPreservePhase {
+		return nil
+	}
+	cr.Status.Preserve = nil
+	meta.RemoveStatusCondition(&cr.Status.Conditions, mailhogv1alpha1.StorageMigrationCondition)
+	if err := r.Status().Update(ctx, cr); err != nil {
+		r.logger.WithValues(span, spanPreserve).Error(err, failedCrUpdateStatus)
+		return err
+	}
+	return nil
+}
+
+// exportPreservedMessages fetches the messages of all reachable pods
+// every memory storage pod has its own messages, the new pods receive all of them
+// replayed messages get new ids, so duplicates from earlier replays are detected by their data
+func (r *MailhogInstanceReconciler) exportPreservedMessages(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) (messages []archive.Message, sourcePods []string, err error) {
+	podList := &corev1.PodList{}
+	if err = r.List(ctx, podList, client.InNamespace(cr.Namespace), client.MatchingLabels(CreateMetaMaker(cr).GetLabels())); err != nil {
+		return nil, nil, err
+	}
+	// pods that are not reachable can not be exported, but will be replaced and must not receive the messages
+	for _, pod := range podList.Items {
+		sourcePods = append(sourcePods, string(pod.UID))
+	}
+
+	pods := reachablePods(podList.Items)
+	lists := make([][]archive.Message, 0, len(pods))
+	for i := range pods {
+		api, err := mailhogApiFor(ctx, r, cr, &pods[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		podMessages, err := archive.Fetch(ctx, httpClient, api.baseUrl, api.user, api.password)
+		if err != nil {
+			return nil, nil, err
+		}
+		lists = append(lists, podMessages)
+	}
+
+	seen := make(map[[sha256.Size]byte]bool)
+	for _, message := range archive.Merge(lists...) {
+		sum := sha256.Sum256(message.Data)
+		if !seen[sum] {
+			seen[sum] = true
+			messages = append(messages, message)
+		}
+	}
+	return messages, sourcePods, nil
+}
+
+// storePreservedMessages writes the messages into the preserve ConfigMap in mbox format
+func (r *MailhogInstanceReconciler) storePreservedMessages(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, messages []archive.Message) error {
+	logger := r.logger.WithValues(span, spanPreserve)
+
+	files, err := archive.Encode(archive.MboxFormat, messages)
+	if err != nil {
+		return err
+	}
+	if archive.Size(files) > snapshotConfigMapMaxSize {
+		return errPreserveTooLarge
+	}
+
+	configMap := preserveConfigMapNew(cr, files)
+	existing := &corev1.ConfigMap{}
+	if err = r.Get(ctx, types.NamespacedName{Name: configMap.Name, Namespace: cr.Namespace}, existing); err != nil {
+		if errors.IsNotFound(err) {
+			return r.create(ctx, cr, logger, configMap, confMapCreate)
+		}
+		return err
+	}
+	existing.BinaryData = configMap.BinaryData
+	return r.update(ctx, cr, logger, existing, confMapUpdate)
+}
+
+// ensurePreserved replays the preserved messages into every ready pod that was created by the rollout,
+// or into a single pod for shared storage. Once no pod from before the rollout is left and all pods received
+// the messages, the ConfigMap is removed
+func ensurePreserved(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanPreserve)
+	name := types.NamespacedName{Name: preserveConfigMapName(cr), Namespace: cr.Namespace}
+
+	status := cr.Status.Preserve
+	if status == nil || status.Phase != mailhogv1alpha1.ExportedPreservePhase {
+		if err = r.delete(ctx, cr, name, &corev1.ConfigMap{}, logger, confMapDelete); err != nil {
+			return err
+		}
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err = r.Get(ctx, name, configMap); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+		return r.preserveFailed(ctx, cr, errMissingPreserved)
+	}
+	messages, err := archive.Decode(archive.MboxFormat, configMap.BinaryData)
+	if err != nil {
+		return r.preserveFailed(ctx, cr, err)
+	}
+
+	podList := &corev1.PodList{}
+	if err = r.List(ctx, podList, client.InNamespace(cr.Namespace), client.MatchingLabels(CreateMetaMaker(cr).GetLabels())); err != nil {
+		logger.Error(err, failedListPods)
+		return err
+	}
+	auth, err := mailhogSmtpAuth(ctx, r, cr)
+	if err != nil {
+		logger.Error(err, failedGetExisting)
+		return err
+	}
+
+	updated := status.DeepCopy()
+	updated.Error = ""
+	sourcesLeft, pending := false, false
+	for _, pod := range podList.Items {
+		uid := string(pod.UID)
+		if containsString(status.SourcePods, uid) {
+			sourcesLeft = true
+			continue
+		}
+		if containsString(updated.RestoredPods, uid) || (sharedStorage(cr) && len(updated.RestoredPods) > 0) {
+			continue
+		}
+		ready := readyPods([]corev1.Pod{pod})
+		if len(ready) == 0 {
+			pending = true
+			continue
+		}
+		if err = archive.Deliver(mailhogSmtpAddress(cr, &pod), auth, httpClient.Timeout, restoreFallbackAddress, messages); err != nil {
+			logger.Error(err, failedPreserveReplay, "pod", pod.Name)
+			updated.Error = err.Error()
+			pending = true
+			continue
+		}
+		r.Recorder.Event(cr, corev1.EventTypeNormal, "Preserved", "replayed "+strconv.Itoa(len(messages))+" preserved messages to pod "+pod.Name)
+		updated.RestoredPods = append(updated.RestoredPods, uid)
+	}
+
+	if !sourcesLeft && !pending && len(updated.RestoredPods) > 0 {
+		now := metav1.Now()
+		updated.Phase = mailhogv1alpha1.RestoredPreservePhase
+		updated.CompletionTime = &now
+	}
+
+	if err = r.updatePreserveStatus(ctx, cr, updated); err != nil {
+		return err
+	}
+	if updated.Phase == mailhogv1alpha1.RestoredPreservePhase {
+		return r.delete(ctx, cr, name, &corev1.ConfigMap{}, logger, confMapDelete)
+	}
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// preserveFailed records an error that prevents replaying the preserved messages
+func (r *MailhogInstanceReconciler) preserveFailed(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, preserveErr error) error {
+	r.logger.WithValues(span, spanPreserve).Error(preserveErr, failedPreserveReplay)
+	r.Recorder.Event(cr, corev1.EventTypeWarning, "PreserveFailed", preserveErr.Error())
+	updated := cr.Status.Preserve.DeepCopy()
+	updated.Phase = mailhogv1alpha1.FailedPreservePhase
+	updated.Error = preserveErr.Error()
+	return r.updatePreserveStatus(ctx, cr, updated)
+}
+
+// updatePreserveStatus stores the preserve status and the StorageMigration condition if they changed
+func (r *MailhogInstanceReconciler) updatePreserveStatus(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, updated *mailhogv1alpha1.PreserveStatus) error {
+	original := cr.Status.DeepCopy()
+	cr.Status.Preserve = updated
+	if updated.MigrateFrom != "" {
+		setStorageMigrationCondition(cr)
+	}
+	if reflect.DeepEqual(*original, cr.Status) {
+		return nil
+	}
+	if err := r.Status().Update(ctx, cr); err != nil {
+		r.logger.WithValues(span, spanPreserve).Error(err, failedCrUpdateStatus)
+		return err
+	}
+	return nil
+}
+
+// setStorageMigrationCondition sets the StorageMigration condition from the phase of the preserved messages
+func setStorageMigrationCondition(cr *mailhogv1alpha1.MailhogInstance) {
+	preserve := cr.Status.Preserve
+	migration := string(preserve.MigrateFrom) + " to " + string(cr.Spec.Settings.Storage)
+	condition := metav1.Condition{
+		Type:               mailhogv1alpha1.StorageMigrationCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: cr.Generation,
+		Reason:             mailhogv1alpha1.MigratingReason,
+		Message:            strconv.Itoa(preserve.Messages) + " messages exported for the migration from " + migration + ", waiting for the new pods",
+	}
+	switch preserve.Phase {
+	case mailhogv1alpha1.RestoredPreservePhase:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = mailhogv1alpha1.MigratedReason
+		condition.Message = strconv.Itoa(preserve.Messages) + " messages migrated from " + migration
+	case mailhogv1alpha1.FailedPreservePhase:
+		condition.Reason = mailhogv1alpha1.MigrationFailedReason
+		condition.Message = "export for the migration from " + migration + " failed, the rollout waits for it: " + preserve.Error
+	case mailhogv1alpha1.ExportedPreservePhase:
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, condition)
+}
+
+// preserveConfigMapName returns the name of the ConfigMap holding the preserved messages
+func preserveConfigMapName(cr *mailhogv1alpha1.MailhogInstance) string {
+	return cr.Name + preserveSuffix
+}
+
+// preserveConfigMapNew returns the ConfigMap holding the preserved messages
+func preserveConfigMapNew(cr *mailhogv1alpha1.MailhogInstance, files map[string][]byte) *corev1.ConfigMap {
+	meta := CreateMetaMaker(cr)
+	meta.Name = preserveConfigMapName(cr)
+	return &corev1.ConfigMap{
+		ObjectMeta: meta.GetMeta(),
+		BinaryData: files,
+	}
+}
@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// MailhogRestoreReconciler reconciles a MailhogRestore object
+type MailhogRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	logger   logr.Logger
+}
+
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogrestores,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogrestores/status,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogrestores/finalizers,verbs=*
+
+// Reconcile replays the referenced snapshot into the target instance once
+// the restore is owned by the target instance, so it is removed together with the instance
+func (r *MailhogRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx, "ns", req.Namespace, "restore", req.Name)
+	r.logger.Info(reconcileStarted)
+
+	restore := &mailhogv1alpha1.MailhogRestore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.Info(crGetNotFound)
+			return ctrl.Result{}, nil
+		}
+		r.logger.Error(err, crGetFailed)
+		return ctrl.Result{}, err
+	}
+	if archiveFinished(restore.Status.Conditions) || !restore.DeletionTimestamp.IsZero() {
+		r.logger.Info(reconcileFinished)
+		return ctrl.Result{}, nil
+	}
+
+	original := restore.Status.DeepCopy()
+	if restore.Status.StartTime == nil {
+		now := metav1.Now()
+		restore.Status.StartTime = &now
+	}
+
+	result, err := r.replay(ctx, restore)
+	if err != nil {
+		setCompletedCondition(&restore.Status.Conditions, restore.Generation, mailhogv1alpha1.InProgressReason, err.Error())
+	}
+
+	if !reflect.DeepEqual(*original, restore.Status) {
+		if updateErr := r.Status().Update(ctx, restore); updateErr != nil {
+			r.logger.Error(updateErr, failedCrUpdateStatus)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, updateErr
+		}
+	}
+	return result, nil
+}
+
+// replay delivers the snapshot to the ready pods of the target instance or starts / checks the Job doing so
+// a returned error is recorded in the condition and retried, final failures set the Failed reason themselves
+func (r *MailhogRestoreReconciler) replay(ctx context.Context, restore *mailhogv1alpha1.MailhogRestore) (ctrl.Result, error) {
+	logger := r.logger.WithValues(span, spanRestore)
+
+	cr := &mailhogv1alpha1.MailhogInstance{}
+	if err := r.Get(ctx, types.NamespacedName{Name: restore.Spec.Instance, Namespace: restore.Namespace}, cr); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, errMissingArchiveInstance
+		}
+		logger.Error(err, crGetFailed)
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+	}
+	if err := r.ensureInstanceOwner(ctx, restore, cr); err != nil {
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+	}
+
+	snapshot := &mailhogv1alpha1.MailhogSnapshot{}
+	if err := r.Get(ctx, types.NamespacedName{Name: restore.Spec.Snapshot, Namespace: restore.Namespace}, snapshot); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, errMissingSnapshot
+		}
+		logger.Error(err, crGetFailed)
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+	}
+	snapshotState := meta.FindStatusCondition(snapshot.Status.Conditions, mailhogv1alpha1.CompletedCondition)
+	if snapshotState != nil && snapshotState.Reason == mailhogv1alpha1.FailedReason {
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", errSnapshotFailed.Error())
+		setCompletedCondition(&restore.Status.Conditions, restore.Generation, mailhogv1alpha1.FailedReason, errSnapshotFailed.Error())
+		return ctrl.Result{}, nil
+	}
+	if snapshotState == nil || snapshotState.Status != metav1.ConditionTrue {
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, errSnapshotPending
+	}
+
+	if snapshot.Spec.PersistentVolumeClaim != nil {
+		return r.replayJob(ctx, restore, snapshot, cr)
+	}
+	return r.replayConfigMap(ctx, restore, snapshot, cr)
+}
+
+// ensureInstanceOwner adds the target instance as owner of the restore
+func (r *MailhogRestoreReconciler) ensureInstanceOwner(ctx context.Context, restore *mailhogv1alpha1.MailhogRestore, cr *mailhogv1alpha1.MailhogInstance) error {
+	for _, owner := range restore.OwnerReferences {
+		if owner.UID == cr.UID {
+			return nil
+		}
+	}
+
+	status := restore.Status.DeepCopy()
+	if err := controllerutil.SetOwnerReference(cr, restore, r.Scheme); err != nil {
+		r.logger.Error(err, messageFailedSetOwnerRef)
+		return err
+	}
+	if err := r.Update(ctx, restore); err != nil {
+		r.logger.Error(err, failedCrUpdate)
+		return err
+	}
+	// the update returns the stored status, keep the progress of this reconcile
+	restore.Status = *status
+	return nil
+}
+
+// replayConfigMap delivers the messages of a snapshot stored in a ConfigMap to the ready pods
+// memory and maildir storage are separate per pod, mongodb storage is shared so one pod is enough
+func (r *MailhogRestoreReconciler) replayConfigMap(ctx context.Context, restore *mailhogv1alpha1.MailhogRestore, snapshot *mailhogv1alpha1.MailhogSnapshot, cr *mailhogv1alpha1.MailhogInstance) (ctrl.Result, error) {
+	logger := r.logger.WithValues(span, spanRestore)
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: snapshot.Status.ConfigMap, Namespace: snapshot.Namespace}, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", errMissingSnapshotData.Error())
+			setCompletedCondition(&restore.Status.Conditions, restore.Generation, mailhogv1alpha1.FailedReason, errMissingSnapshotData.Error())
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, failedGetExisting)
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+	}
+	messages, err := archive.Decode(archiveFormat(snapshot), configMap.BinaryData)
+	if err != nil {
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", err.Error())
+		setCompletedCondition(&restore.Status.Conditions, restore.Generation, mailhogv1alpha1.FailedReason, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	pods, err := r.replayPods(ctx, cr)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+	}
+	auth, err := mailhogSmtpAuth(ctx, r, cr)
+	if err != nil {
+		logger.Error(err, failedGetExisting)
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+	}
+	for i := range pods {
+		if containsString(restore.Status.Pods, pods[i].Name) {
+			continue
+		}
+		if err = archive.Deliver(mailhogSmtpAddress(cr, &pods[i]), auth, httpClient.Timeout, restoreFallbackAddress, messages); err != nil {
+			logger.Error(err, failedRestoreDelivery, "pod", pods[i].Name)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		restore.Status.Pods = append(restore.Status.Pods, pods[i].Name)
+	}
+
+	r.completeRestore(restore, len(messages))
+	return ctrl.Result{}, nil
+}
+
+// replayJob creates the Job replaying a snapshot stored in a PVC and records its result once it finished
+func (r *MailhogRestoreReconciler) replayJob(ctx context.Context, restore *mailhogv1alpha1.MailhogRestore, snapshot *mailhogv1alpha1.MailhogSnapshot, cr *mailhogv1alpha1.MailhogInstance) (ctrl.Result, error) {
+	logger := r.logger.WithValues(span, spanRestore)
+	name := types.NamespacedName{Name: restore.Name + restoreSuffix, Namespace: restore.Namespace}
+
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, name, job); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, failedGetExisting)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+
+		pods, err := r.replayPods(ctx, cr)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+
+		job = restoreJobNew(restore, snapshot, cr, pods)
+		if err = ctrl.SetControllerReference(restore, job, r.Scheme); err != nil {
+			logger.Error(err, messageFailedSetOwnerRef)
+			return ctrl.Result{}, err
+		}
+		if err = r.Create(ctx, job); err != nil {
+			logger.Error(err, messageFailedCreate)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		jobCreate.Inc()
+		restore.Status.Job = job.Name
+		restore.Status.Pods = nil
+		for _, pod := range pods {
+			restore.Status.Pods = append(restore.Status.Pods, pod.Name)
+		}
+		setCompletedCondition(&restore.Status.Conditions, restore.Generation, mailhogv1alpha1.InProgressReason,
+			"replaying messages to "+strconv.Itoa(len(pods))+" pods")
+		return ctrl.Result{}, nil
+	}
+
+	succeeded, failed := archiveJobState(job)
+	switch {
+	case succeeded:
+		result, err := archiveJobResult(ctx, r, job)
+		if err != nil {
+			logger.Error(err, failedRestoreDelivery)
+		}
+		r.completeRestore(restore, result.Messages)
+	case failed:
+		r.Recorder.Event(restore, corev1.EventTypeWarning, "RestoreFailed", errArchiveJobFailed.Error())
+		setCompletedCondition(&restore.Status.Conditions, restore.Generation, mailhogv1alpha1.FailedReason, errArchiveJobFailed.Error())
+	default:
+		setCompletedCondition(&restore.Status.Conditions, restore.Generation, mailhogv1alpha1.InProgressReason, "waiting for job "+job.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// replayPods returns the ready pods of the target instance the messages are delivered to
+func (r *MailhogRestoreReconciler) replayPods(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) ([]corev1.Pod, error) {
+	pods, err := mailhogApiPods(ctx, r, cr)
+	if err != nil {
+		r.logger.Error(err, failedListPods)
+		return nil, err
+	}
+	pods = readyPods(pods)
+	if len(pods) == 0 {
+		return nil, errNoArchivePods
+	}
+	if cr.Spec.Settings.Storage == mailhogv1alpha1.MongoDBStorage {
+		pods = pods[:1]
+	}
+	return pods, nil
+}
+
+// completeRestore records a successful replay
+func (r *MailhogRestoreReconciler) completeRestore(restore *mailhogv1alpha1.MailhogRestore, messages int) {
+	now := metav1.Now()
+	restore.Status.CompletionTime = &now
+	restore.Status.Messages = messages
+	message := "replayed " + strconv.Itoa(messages) + " messages of " + restore.Spec.Snapshot + " to " + strconv.Itoa(len(restore.Status.Pods)) + " pods"
+	setCompletedCondition(&restore.Status.Conditions, restore.Generation, mailhogv1alpha1.SucceededReason, message)
+	restoreMessages.Add(float64(messages * len(restore.Status.Pods)))
+	r.Recorder.Event(restore, corev1.EventTypeNormal, "RestoreCompleted", message)
+}
+
+// restoreJobNew returns the Job replaying the snapshot stored in a PVC to the given pods
+func restoreJobNew(restore *mailhogv1alpha1.MailhogRestore, snapshot *mailhogv1alpha1.MailhogSnapshot, cr *mailhogv1alpha1.MailhogInstance, pods []corev1.Pod) *batchv1.Job {
+	cfg := archive.Config{
+		Mode:            archive.ImportMode,
+		Format:          archiveFormat(snapshot),
+		Path:            archivePath(snapshot),
+		FallbackAddress: restoreFallbackAddress,
+		SmtpUser:        mailhogSmtpUser(cr),
+	}
+	for i := range pods {
+		cfg.Smtp = append(cfg.Smtp, mailhogSmtpAddress(cr, &pods[i]))
+	}
+
+	objectMeta := metav1.ObjectMeta{
+		Name:      restore.Name + restoreSuffix,
+		Namespace: restore.Namespace,
+		Labels:    archiveLabels(restoreTypeValue, restore.Name),
+	}
+	return archiveJobNew(objectMeta, sidecarImage(cr), cfg, snapshot.Spec.PersistentVolumeClaim.ClaimName, true, archivePasswordEnv(cr))
+}
+
+// SetupWithManager sets up this controller with the Manager
+func (r *MailhogRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mailhogv1alpha1.MailhogRestore{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ensureRetention purges messages exceeding the CRs retention settings once per interval
+// and reconciles the Job creating the ttl index for mongodb storage
+func ensureRetention(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanRetention)
+
+	if err = ensureRetentionJob(ctx, r, cr); err != nil {
+		return err
+	}
+
+	retention := cr.Spec.Settings.Retention
+	if retention == nil {
+		if cr.Status.Retention != nil {
+			cr.Status.Retention = nil
+			if err = r.Status().Update(ctx, cr); err != nil {
+				logger.Error(err, failedCrUpdateStatus)
+				return err
+			}
+		}
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	now := time.Now()
+	if !retentionPurgeDue(cr, now) {
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	deleted, purgeErr := r.purgeMessages(ctx, cr, now)
+	if purgeErr != nil {
+		logger.Error(purgeErr, failedRetentionPurge)
+	}
+	retentionDeleted.Add(float64(deleted))
+	if deleted > 0 {
+		r.Recorder.Event(cr, corev1.EventTypeNormal, "Purged", "deleted "+strconv.FormatInt(deleted, 10)+" messages exceeding the retention settings")
+	}
+
+	status := &mailhogv1alpha1.RetentionStatus{}
+	if cr.Status.Retention != nil {
+		status.TotalDeleted = cr.Status.Retention.TotalDeleted
+	}
+	purgeTime := metav1.NewTime(now)
+	status.LastPurgeTime = &purgeTime
+	status.LastPurgeDeleted = deleted
+	status.TotalDeleted += deleted
+	if purgeErr != nil {
+		status.Error = purgeErr.Error()
+	}
+	cr.Status.Retention = status
+	if err = r.Status().Update(ctx, cr); err != nil {
+		logger.Error(err, failedCrUpdateStatus)
+		return err
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// retentionInterval returns the time between two purges
+func retentionInterval(cr *mailhogv1alpha1.MailhogInstance) time.Duration {
+	if retention := cr.Spec.Settings.Retention; retention != nil && retention.Interval != nil && retention.Interval.Duration > 0 {
+		return retention.Interval.Duration
+	}
+	return defaultRetentionInterval
+}
+
+// retentionPurgeDue returns true if the last purge is at least one interval ago
+func retentionPurgeDue(cr *mailhogv1alpha1.MailhogInstance, now time.Time) bool {
+	status := cr.Status.Retention
+	if status == nil || status.LastPurgeTime == nil {
+		return true
+	}
+	return !now.Before(status.LastPurgeTime.Add(retentionInterval(cr)))
+}
+
+// purgeMessages deletes the messages exceeding the retention settings via the api of the CRs pods
+// memory and maildir storage are separate per pod, mongodb storage is shared so one pod is enough
+func (r *MailhogInstanceReconciler) purgeMessages(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, now time.Time) (deleted int64, err error) {
+	pods, err := mailhogApiPods(ctx, r, cr)
+	if err != nil {
+		return 0, err
+	}
+	if cr.Spec.Settings.Storage == mailhogv1alpha1.MongoDBStorage && len(pods) > 1 {
+		pods = pods[:1]
+	}
+
+	for i := range pods {
+		api, err := mailhogApiFor(ctx, r, cr, &pods[i])
+		if err != nil {
+			return deleted, err
+		}
+		podDeleted, err := purgeMessagesOf(ctx, api, cr.Spec.Settings.Retention, now)
+		deleted += podDeleted
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// purgeMessagesOf deletes the messages of a single api exceeding the retention settings
+func purgeMessagesOf(ctx context.Context, api *mailhogApi, retention *mailhogv1alpha1.MailhogRetentionSpec, now time.Time) (deleted int64, err error) {
+	var messages []mailhogMessage
+	for start := 0; ; start += retentionPageSize {
+		page, err := api.messages(ctx, start, retentionPageSize)
+		if err != nil {
+			return 0, err
+		}
+		messages = append(messages, page.Items...)
+		if len(page.Items) == 0 || start+len(page.Items) >= page.Total {
+			break
+		}
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Created.After(messages[j].Created)
+	})
+
+	for i, message := range messages {
+		tooMany := retention.MaxMessages > 0 && i >= int(retention.MaxMessages)
+		tooOld := retention.MaxAge != nil && message.Created.Before(now.Add(-retention.MaxAge.Duration))
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err = api.deleteMessage(ctx, message.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// ensureRetentionJob reconciles the Job creating the ttl index for mongodb storage
+// the pod template of a Job is immutable, so an outdated Job is deleted and created again by the next reconcile
+func ensureRetentionJob(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	name := types.NamespacedName{Name: retentionJobName(cr), Namespace: cr.Namespace}
+	logger := r.logger.WithValues(span, spanRetention)
+
+	if !retentionJobNeeded(cr) {
+		toBeDeletedJob := &batchv1.Job{}
+		return r.delete(ctx, cr, name, toBeDeletedJob, logger, jobDelete)
+	}
+
+	existingJob := &batchv1.Job{}
+	if err = r.Get(ctx, name, existingJob); err != nil {
+		if errors.IsNotFound(err) {
+			return r.create(ctx, cr, logger, retentionJobNew(cr), jobCreate)
+		}
+		logger.Error(err, failedGetExisting)
+		return err
+	}
+
+	updateNeeded, err := checkPatch(existingJob, retentionJobNew(cr))
+	if err != nil {
+		logger.Error(err, failedUpdateCheck)
+		return err
+	} else if updateNeeded {
+		if err = r.Delete(ctx, existingJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, messageFailedDelete)
+			return err
+		}
+		jobDelete.Inc()
+		r.Recorder.Event(cr, corev1.EventTypeNormal, "SuccessEvent", eventDeleted+": "+batchv1.SchemeGroupVersion.WithKind("Job").String())
+	}
+	return nil
+}
+
+// retentionJobNeeded returns true if a ttl index needs to be created in mongodb
+func retentionJobNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	retention := cr.Spec.Settings.Retention
+	return retention != nil && retention.MaxAge != nil && cr.Spec.Settings.Storage == mailhogv1alpha1.MongoDBStorage
+}
+
+// retentionJobName returns the name of the Job creating the ttl index
+func retentionJobName(cr *mailhogv1alpha1.MailhogInstance) string {
+	return cr.Name + retentionJobSuffix
+}
+
+// retentionJobNew returns a Job in the wanted state
+// the index is created on the creation time mailhog stores with every message, an existing index is modified instead
+func retentionJobNew(cr *mailhogv1alpha1.MailhogInstance) (newJob *batchv1.Job) {
+	retention := cr.Spec.Settings.Retention
+	mongo := cr.Spec.Settings.StorageMongoDb
+
+	image := retention.MongoImage
+	if image == "" {
+		image = defaultMongoImage
+	}
+	uri := mongo.URI
+	if !strings.Contains(uri, "://") {
+		uri = "mongodb://" + uri
+	}
+
+	meta := CreateMetaMaker(cr)
+	meta.Name = retentionJobName(cr)
+	// the job pods must not be counted as mailhog pods
+	labels := make(map[string]string)
+	for k, v := range meta.GetLabels() {
+		if k != crNameLabel {
+			labels[k] = v
+		}
+	}
+
+	backoffLimit := int32(3)
+	automountToken := false
+
+	job := &batchv1.Job{
+		ObjectMeta: meta.GetMeta(),
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyOnFailure,
+					AutomountServiceAccountToken: &automountToken,
+					Containers: []corev1.Container{
+						{
+							Name:    retentionJobContainer,
+							Image:   image,
+							Command: []string{"mongosh", uri, "--quiet", "--eval", retentionTtlScript},
+							Env: []corev1.EnvVar{
+								{Name: envRetentionMongoDb, Value: mongo.Db},
+								{Name: envRetentionMongoCollection, Value: mongo.Collection},
+								{Name: envRetentionTtlSeconds, Value: strconv.FormatInt(int64(retention.MaxAge.Seconds()), 10)},
+							},
+							Resources: retentionJobResources(),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return job
+}
+
+// retentionJobResources returns the resources of the mongosh TTL Job, mongosh runs on node and needs more than a sidecar
+func retentionJobResources() corev1.ResourceRequirements {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+	resources.Requests[corev1.ResourceCPU] = resource.MustParse(defaultRetentionResourceCPU)
+	resources.Requests[corev1.ResourceMemory] = resource.MustParse(defaultRetentionResourceMem)
+	resources.Limits[corev1.ResourceMemory] = resource.MustParse(defaultRetentionResourceMem)
+	return resources
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ensureRoleBindings reconciles the RoleBindings for the subjects listed in the CRs access spec
+func ensureRoleBindings(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanRoleBind)
+
+	for _, accessRole := range accessRoles {
+		name := types.NamespacedName{Name: roleName(cr, accessRole), Namespace: cr.Namespace}
+
+		if len(roleBindingSubjects(cr, accessRole)) == 0 {
+			toBeDeletedBinding := &rbacv1.RoleBinding{}
+			if err = r.delete(ctx, cr, name, toBeDeletedBinding, logger, roleBindingDelete); err != nil {
+				return err
+			}
+			continue
+		}
+
+		existingBinding := &rbacv1.RoleBinding{}
+		if err = r.Get(ctx, name, existingBinding); err != nil {
+			if errors.IsNotFound(err) {
+				if err = r.create(ctx, cr, logger, roleBindingNew(cr, accessRole), roleBindingCreate); err != nil {
+					return err
+				}
+				continue
+			}
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+
+		updatedBinding, updateNeeded, err := roleBindingUpdates(cr, accessRole, existingBinding)
+		if err != nil {
+			logger.Error(err, failedUpdateCheck)
+			return err
+		} else if updateNeeded {
+			if err = r.update(ctx, cr, logger, updatedBinding, roleBindingUpdate); err != nil {
+				return err
+			}
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// roleBindingSubjects returns the subjects of the CR bound to the given access role
+func roleBindingSubjects(cr *mailhogv1alpha1.MailhogInstance, accessRole mailhogv1alpha1.AccessRole) (subjects []rbacv1.Subject) {
+	if cr.Spec.Access == nil {
+		return nil
+	}
+
+	for _, accessSubject := range cr.Spec.Access.Subjects {
+		subjectRole := accessSubject.Role
+		if subjectRole == "" {
+			subjectRole = mailhogv1alpha1.ViewerAccessRole
+		}
+		if subjectRole != accessRole {
+			continue
+		}
+
+		subject := rbacv1.Subject{
+			Kind: accessSubject.Kind,
+			Name: accessSubject.Name,
+		}
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			subject.Namespace = accessSubject.Namespace
+			if subject.Namespace == "" {
+				subject.Namespace = cr.Namespace
+			}
+		} else {
+			subject.APIGroup = rbacv1.GroupName
+		}
+		subjects = append(subjects, subject)
+	}
+
+	return subjects
+}
+
+// roleBindingNew returns a RoleBinding in the wanted state
+func roleBindingNew(cr *mailhogv1alpha1.MailhogInstance, accessRole mailhogv1alpha1.AccessRole) (newBinding *rbacv1.RoleBinding) {
+	meta := CreateMetaMaker(cr)
+	meta.Name = roleName(cr, accessRole)
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: meta.GetMeta(),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     roleName(cr, accessRole),
+		},
+		Subjects: roleBindingSubjects(cr, accessRole),
+	}
+
+	return binding
+}
+
+// roleBindingUpdates checks if a RoleBinding needs to be updated
+func roleBindingUpdates(cr *mailhogv1alpha1.MailhogInstance, accessRole mailhogv1alpha1.AccessRole, oldBinding *rbacv1.RoleBinding) (updatedBinding *rbacv1.RoleBinding, updateNeeded bool, err error) {
+	newBinding := roleBindingNew(cr, accessRole)
+
+	updateNeeded, err = checkPatch(oldBinding, newBinding)
+	if updateNeeded == true {
+		return newBinding, updateNeeded, err
+	}
+	return oldBinding, updateNeeded, err
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// accessRoles are the roles created for every CR
+var accessRoles = []mailhogv1alpha1.AccessRole{
+	mailhogv1alpha1.ViewerAccessRole,
+	mailhogv1alpha1.AdminAccessRole,
+}
+
+// ensureRoles reconciles the viewer and admin Roles of the CR
+func ensureRoles(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanRole)
+
+	for _, accessRole := range accessRoles {
+		name := types.NamespacedName{Name: roleName(cr, accessRole), Namespace: cr.Namespace}
+
+		existingRole := &rbacv1.Role{}
+		if err = r.Get(ctx, name, existingRole); err != nil {
+			if errors.IsNotFound(err) {
+				if err = r.create(ctx, cr, logger, roleNew(cr, accessRole), roleCreate); err != nil {
+					return err
+				}
+				continue
+			}
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+
+		updatedRole, updateNeeded, err := roleUpdates(cr, accessRole, existingRole)
+		if err != nil {
+			logger.Error(err, failedUpdateCheck)
+			return err
+		} else if updateNeeded {
+			if err = r.update(ctx, cr, logger, updatedRole, roleUpdate); err != nil {
+				return err
+			}
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// roleName returns the name of the Role / RoleBinding of the CR for the given access role
+func roleName(cr *mailhogv1alpha1.MailhogInstance, accessRole mailhogv1alpha1.AccessRole) string {
+	return cr.Name + roleInfix + string(accessRole)
+}
+
+// roleNew returns a Role in the wanted state
+func roleNew(cr *mailhogv1alpha1.MailhogInstance, accessRole mailhogv1alpha1.AccessRole) (newRole *rbacv1.Role) {
+	meta := CreateMetaMaker(cr)
+	meta.Name = roleName(cr, accessRole)
+
+	role := &rbacv1.Role{
+		ObjectMeta: meta.GetMeta(),
+		Rules:      roleRules(cr, accessRole),
+	}
+
+	return role
+}
+
+// roleRules returns the policy rules of an access role, always limited to the objects of the CR
+func roleRules(cr *mailhogv1alpha1.MailhogInstance, accessRole mailhogv1alpha1.AccessRole) []rbacv1.PolicyRule {
+	crVerbs := []string{"get"}
+	proxyVerbs := []string{"get"}
+	if accessRole == mailhogv1alpha1.AdminAccessRole {
+		crVerbs = []string{"get", "update", "patch"}
+		proxyVerbs = []string{"get", "create", "update", "patch", "delete"}
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			APIGroups:     []string{mailhogv1alpha1.GroupVersion.Group},
+			Resources:     []string{"mailhoginstances"},
+			ResourceNames: []string{cr.Name},
+			Verbs:         crVerbs,
+		},
+		{
+			// the api server names a proxied service including its port, without one the first (smtp) port is used
+			APIGroups:     []string{""},
+			Resources:     []string{"services/proxy"},
+			ResourceNames: []string{cr.Name, cr.Name + ":" + portWebName, cr.Name + ":" + strconv.Itoa(portWeb)},
+			Verbs:         proxyVerbs,
+		},
+	}
+
+	if accessRole == mailhogv1alpha1.AdminAccessRole {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups:     []string{mailhogv1alpha1.GroupVersion.Group},
+			Resources:     []string{"mailhoginstances/scale"},
+			ResourceNames: []string{cr.Name},
+			Verbs:         []string{"get", "update", "patch"},
+		})
+	}
+
+	return rules
+}
+
+// roleUpdates checks if a Role needs to be updated
+func roleUpdates(cr *mailhogv1alpha1.MailhogInstance, accessRole mailhogv1alpha1.AccessRole, oldRole *rbacv1.Role) (updatedRole *rbacv1.Role, updateNeeded bool, err error) {
+	newRole := roleNew(cr, accessRole)
+
+	updateNeeded, err = checkPatch(oldRole, newRole)
+	if updateNeeded == true {
+		return newRole, updateNeeded, err
+	}
+	return oldRole, updateNeeded, err
+}
@@ -7,7 +7,6 @@ import (
 	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // ensureRoute reconciles openshift Route child objects
@@ -59,10 +58,7 @@ func routeNew(cr *mailhogv1alpha1.MailhogInstance) (newRoute *routev1.Route) {
 				Name: cr.Name,
 			},
 			Port: &routev1.RoutePort{
-				TargetPort: intstr.IntOrString{
-					Type:   intstr.Int,
-					IntVal: 8025,
-				},
+				TargetPort: webTargetPortRef(cr),
 			},
 			TLS: &routev1.TLSConfig{
 				Termination:                   routev1.TLSTerminationEdge,
@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ensureSecret reconciles the Secret holding values generated by the operator
+func ensureSecret(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	name := types.NamespacedName{Name: generatedSecretName(cr), Namespace: cr.Namespace}
+	logger := r.logger.WithValues(span, spanSecret)
+
+	if len(secretKeysForCr(cr)) > 0 {
+		existingSecret := &corev1.Secret{}
+		if err = r.Get(ctx, name, existingSecret); err != nil {
+			if errors.IsNotFound(err) {
+				secret, err := secretNew(cr, nil)
+				if err != nil {
+					logger.Error(err, failedGenerateSecret)
+					return err
+				}
+				return r.create(ctx, cr, logger, secret, secretCreate)
+			}
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+		updatedSecret, updateNeeded, err := secretUpdates(cr, existingSecret)
+		if err != nil {
+			logger.Error(err, failedUpdateCheck)
+			return err
+		} else if updateNeeded {
+			return r.update(ctx, cr, logger, updatedSecret, secretUpdate)
+		}
+
+	} else {
+		toBeDeletedSecret := &corev1.Secret{}
+		if err = r.delete(ctx, cr, name, toBeDeletedSecret, logger, secretDelete); err != nil {
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// ensureUpstreamsSecret reconciles the Secret holding the merged smtp upstreams file
+// the file carries the upstreams' credentials, so unlike the other settings files it is not part of the ConfigMap
+func ensureUpstreamsSecret(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	name := types.NamespacedName{Name: upstreamsSecretName(cr), Namespace: cr.Namespace}
+	logger := r.logger.WithValues(span, spanServerFile)
+
+	if upstreamsSecretNeeded(cr) {
+		upstreams, err := r.smtpUpstreams(ctx, cr)
+		if err != nil {
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+
+		existingSecret := &corev1.Secret{}
+		if err = r.Get(ctx, name, existingSecret); err != nil {
+			if errors.IsNotFound(err) {
+				return r.create(ctx, cr, logger, upstreamsSecretNew(cr, upstreams), secretCreate)
+			}
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+		updatedSecret, updateNeeded, err := upstreamsSecretUpdates(cr, upstreams, existingSecret)
+		if err != nil {
+			logger.Error(err, failedUpdateCheck)
+			return err
+		} else if updateNeeded {
+			return r.update(ctx, cr, logger, updatedSecret, secretUpdate)
+		}
+
+	} else {
+		toBeDeletedSecret := &corev1.Secret{}
+		if err = r.delete(ctx, cr, name, toBeDeletedSecret, logger, secretDelete); err != nil {
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// upstreamsSecretName returns the name of the Secret holding the merged smtp upstreams file
+func upstreamsSecretName(cr *mailhogv1alpha1.MailhogInstance) string {
+	return cr.Name + upstreamsSecretSuffix
+}
+
+// upstreamsSecretNeeded returns true if the operator renders the upstreams file, an upstreams file mailhog reads
+// directly is mounted from its own ConfigMap / Secret
+func upstreamsSecretNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	return smtpUpstreamsNeeded(cr) && !upstreamsFileDirect(cr)
+}
+
+// upstreamsSecretNew returns the upstreams Secret in the wanted state, upstreams are the merged inline and referenced entries
+func upstreamsSecretNew(cr *mailhogv1alpha1.MailhogInstance, upstreams []mailhogv1alpha1.MailhogUpstreamSpec) *corev1.Secret {
+	servers := make(map[string]mailhogv1alpha1.MailhogUpstreamSpec)
+	for _, server := range upstreams {
+		// mailhog does not know the tls settings, its certificates are mounted by podVolumes
+		server.Tls = nil
+		servers[server.Name] = server
+	}
+	serverBytes, _ := json.Marshal(servers)
+
+	// the smtp proxy reads the credentials of the upstreams it relays to from their own keys
+	data := smtpRelayCredentials(cr)
+	data[settingsFileUpstreamsName] = serverBytes
+
+	meta := CreateMetaMaker(cr)
+	meta.Name = upstreamsSecretName(cr)
+	return &corev1.Secret{
+		ObjectMeta: meta.GetMeta(),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       data,
+	}
+}
+
+// upstreamsSecretUpdates checks if the upstreams Secret needs to be updated
+func upstreamsSecretUpdates(cr *mailhogv1alpha1.MailhogInstance, upstreams []mailhogv1alpha1.MailhogUpstreamSpec, oldSecret *corev1.Secret) (updatedSecret *corev1.Secret, updateNeeded bool, err error) {
+	newSecret := upstreamsSecretNew(cr, upstreams)
+
+	updateNeeded, err = checkPatch(oldSecret, newSecret)
+	if updateNeeded == true {
+		return newSecret, updateNeeded, err
+	}
+	return oldSecret, updateNeeded, err
+}
+
+// ensureUsersSecret reconciles the Secret holding the merged web users file
+// the file carries the users' password hashes, so it is not part of the ConfigMap either
+func ensureUsersSecret(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	name := types.NamespacedName{Name: usersSecretName(cr), Namespace: cr.Namespace}
+	logger := r.logger.WithValues(span, spanUsersFile)
+
+	if basicAuthEnabled(cr) {
+		probeHash, err := r.probeUserHash(ctx, cr)
+		if err != nil {
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+		users, err := r.webUsers(ctx, cr)
+		if err != nil {
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+
+		existingSecret := &corev1.Secret{}
+		if err = r.Get(ctx, name, existingSecret); err != nil {
+			if errors.IsNotFound(err) {
+				return r.create(ctx, cr, logger, usersSecretNew(cr, probeHash, users), secretCreate)
+			}
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+		updatedSecret, updateNeeded, err := usersSecretUpdates(cr, probeHash, users, existingSecret)
+		if err != nil {
+			logger.Error(err, failedUpdateCheck)
+			return err
+		} else if updateNeeded {
+			return r.update(ctx, cr, logger, updatedSecret, secretUpdate)
+		}
+
+	} else {
+		toBeDeletedSecret := &corev1.Secret{}
+		if err = r.delete(ctx, cr, name, toBeDeletedSecret, logger, secretDelete); err != nil {
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// usersSecretName returns the name of the Secret holding the merged web users file
+func usersSecretName(cr *mailhogv1alpha1.MailhogInstance) string {
+	return cr.Name + usersSecretSuffix
+}
+
+// usersSecretNew returns the users Secret in the wanted state, users are the merged inline and referenced entries
+// the probe user's hash is added to the file, its password only lives in the generated Secret
+func usersSecretNew(cr *mailhogv1alpha1.MailhogInstance, probeHash string, users []mailhogv1alpha1.MailhogWebUserSpec) *corev1.Secret {
+	usersFile := ""
+	for _, credential := range users {
+		usersFile += credential.Name + ":" + credential.PasswordHash + "\n"
+	}
+	if probeHash != "" {
+		usersFile += probeUserName + ":" + probeHash + "\n"
+	}
+
+	meta := CreateMetaMaker(cr)
+	meta.Name = usersSecretName(cr)
+	return &corev1.Secret{
+		ObjectMeta: meta.GetMeta(),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{settingsFilePasswordsName: []byte(usersFile)},
+	}
+}
+
+// usersSecretUpdates checks if the users Secret needs to be updated
+func usersSecretUpdates(cr *mailhogv1alpha1.MailhogInstance, probeHash string, users []mailhogv1alpha1.MailhogWebUserSpec, oldSecret *corev1.Secret) (updatedSecret *corev1.Secret, updateNeeded bool, err error) {
+	newSecret := usersSecretNew(cr, probeHash, users)
+
+	updateNeeded, err = checkPatch(oldSecret, newSecret)
+	if updateNeeded == true {
+		return newSecret, updateNeeded, err
+	}
+	return oldSecret, updateNeeded, err
+}
+
+// probeUserHash returns the bcrypt hash of the internal probe user from the generated Secret, if basic auth is enabled
+func (r *MailhogInstanceReconciler) probeUserHash(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) (string, error) {
+	if !basicAuthEnabled(cr) {
+		return "", nil
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: cr.Namespace}, secret); err != nil {
+		return "", err
+	}
+	return string(secret.Data[secretKeyProbeHash]), nil
+}
+
+// generatedSecretName returns the name of the Secret holding generated values
+func generatedSecretName(cr *mailhogv1alpha1.MailhogInstance) string {
+	return cr.Name + generatedSecretSuffix
+}
+
+// secretKeysForCr returns the keys the generated Secret needs to contain for the given CR
+func secretKeysForCr(cr *mailhogv1alpha1.MailhogInstance) (keys []string) {
+	if webProxyNeeded(cr) {
+		keys = append(keys, secretKeyCookie)
+	}
+	// the smtp proxy's admin endpoints only answer the probe user too
+	if probeUserNeeded(cr) || smtpProxyIntercepts(cr) {
+		keys = append(keys, secretKeyProbePassword)
+	}
+	return keys
+}
+
+// probeUserNeeded returns true if the operator authenticates as internal probe user towards the pods,
+// either at mailhog's basic auth or at the authenticating proxy in front of it
+func probeUserNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	return basicAuthEnabled(cr) || webProxyNeeded(cr)
+}
+
+// basicAuthEnabled returns true if mailhog itself protects the ui / api with basic auth
+func basicAuthEnabled(cr *mailhogv1alpha1.MailhogInstance) bool {
+	files := cr.Spec.Settings.Files
+	return files != nil && (len(files.WebUsers) > 0 || files.UsersFrom != nil)
+}
+
+// secretNew returns a Secret in the wanted state, values already generated in oldSecret are kept
+func secretNew(cr *mailhogv1alpha1.MailhogInstance, oldSecret *corev1.Secret) (newSecret *corev1.Secret, err error) {
+	data := make(map[string][]byte)
+
+	for _, key := range secretKeysForCr(cr) {
+		if oldSecret != nil && len(oldSecret.Data[key]) > 0 {
+			data[key] = oldSecret.Data[key]
+			continue
+		}
+		if data[key], err = randomSecretValue(); err != nil {
+			return nil, err
+		}
+	}
+
+	if password := data[secretKeyProbePassword]; len(password) > 0 {
+		if data[secretKeyProbeHash], err = probePasswordHash(password, oldSecret); err != nil {
+			return nil, err
+		}
+		if webProxyNeeded(cr) {
+			data[secretKeyProbeHtpasswd] = []byte(probeUserName + ":" + string(data[secretKeyProbeHash]) + "\n")
+		}
+	}
+
+	meta := CreateMetaMaker(cr)
+	meta.Name = generatedSecretName(cr)
+	secret := &corev1.Secret{
+		ObjectMeta: meta.GetMeta(),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       data,
+	}
+
+	return secret, nil
+}
+
+// secretUpdates checks if a Secret needs to be updated
+func secretUpdates(cr *mailhogv1alpha1.MailhogInstance, oldSecret *corev1.Secret) (updatedSecret *corev1.Secret, updateNeeded bool, err error) {
+	newSecret, err := secretNew(cr, oldSecret)
+	if err != nil {
+		return oldSecret, false, err
+	}
+
+	updateNeeded, err = checkPatch(oldSecret, newSecret)
+	if updateNeeded == true {
+		return newSecret, updateNeeded, err
+	}
+	return oldSecret, updateNeeded, err
+}
+
+// probePasswordHash returns the bcrypt hash of the probe password for the auth file
+// bcrypt hashes are salted, so a hash still matching the password is kept to avoid needless rollouts
+func probePasswordHash(password []byte, oldSecret *corev1.Secret) ([]byte, error) {
+	if oldSecret != nil {
+		if oldHash := oldSecret.Data[secretKeyProbeHash]; bcrypt.CompareHashAndPassword(oldHash, password) == nil {
+			return oldHash, nil
+		}
+	}
+	return bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+}
+
+// randomSecretValue returns 32 random hex characters
+func randomSecretValue() ([]byte, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	value := make([]byte, hex.EncodedLen(len(raw)))
+	hex.Encode(value, raw)
+	return value, nil
+}
@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/smtp"
+	"reflect"
+	"sort"
+	"strconv"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// mailhogSmtpAddress returns the address the operator delivers messages to a pod at, the smtp port of mailhog
+// or the operator listener of the smtp proxy if it intercepts, since mailhog then only listens on the loopback interface
+// it is a variable so tests can point it to a fake smtp server
+var mailhogSmtpAddress = func(cr *mailhogv1alpha1.MailhogInstance, pod *corev1.Pod) string {
+	port := portSmtp
+	if smtpProxyIntercepts(cr) {
+		port = portSmtpOperator
+	}
+	return net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port))
+}
+
+// mailhogSmtpAuth returns the authentication of the operator's deliveries, the internal probe user if the smtp proxy
+// intercepts, which exempts them from its policy, faults and relay, none otherwise
+func mailhogSmtpAuth(ctx context.Context, c client.Reader, cr *mailhogv1alpha1.MailhogInstance) (smtp.Auth, error) {
+	if !smtpProxyIntercepts(cr) {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: cr.Namespace}, secret); err != nil {
+		return nil, err
+	}
+	return archive.PlainAuth(probeUserName, string(secret.Data[secretKeyProbePassword])), nil
+}
+
+// mailhogSmtpUser returns the user the archive tool authenticates its deliveries at mailhogSmtpAddress with
+func mailhogSmtpUser(cr *mailhogv1alpha1.MailhogInstance) string {
+	if !smtpProxyIntercepts(cr) {
+		return ""
+	}
+	return probeUserName
+}
+
+// seedMessage is a message delivered to new pods
+type seedMessage struct {
+	name string
+	eml  []byte
+}
+
+// ensureSeed delivers the seed messages to every ready pod that did not receive them yet
+// pods are identified by uid and restart count, since a restarted mailhog with memory storage lost its messages
+// mongodb storage is shared, so the messages are delivered once for all pods
+func ensureSeed(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanSeed)
+
+	if cr.Spec.Seed == nil {
+		if cr.Status.Seed != nil || meta.FindStatusCondition(cr.Status.Conditions, mailhogv1alpha1.SeededCondition) != nil {
+			cr.Status.Seed = nil
+			meta.RemoveStatusCondition(&cr.Status.Conditions, mailhogv1alpha1.SeededCondition)
+			if err = r.Status().Update(ctx, cr); err != nil {
+				logger.Error(err, failedCrUpdateStatus)
+				return err
+			}
+		}
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	original := cr.Status.DeepCopy()
+	messages, err := r.seedMessages(ctx, cr)
+	if err != nil {
+		logger.Error(err, failedSeedSources)
+		return r.seedCondition(ctx, cr, original, metav1.ConditionFalse, "SourceMissing", err.Error())
+	}
+	hash := seedHash(messages)
+
+	status := &mailhogv1alpha1.SeedStatus{Hash: hash}
+	if old := cr.Status.Seed; old != nil && old.Hash == hash {
+		status.Pods = old.Pods
+	}
+
+	podList := &corev1.PodList{}
+	if err = r.List(ctx, podList, client.InNamespace(cr.Namespace), client.MatchingLabels(CreateMetaMaker(cr).GetLabels())); err != nil {
+		logger.Error(err, failedListPods)
+		return err
+	}
+
+	auth, err := mailhogSmtpAuth(ctx, r, cr)
+	if err != nil {
+		logger.Error(err, failedGetExisting)
+		return err
+	}
+
+	var current, delivered []string
+	pending := 0
+	for _, pod := range reachablePods(podList.Items) {
+		key := seedPodKey(&pod)
+		current = append(current, key)
+		if containsString(status.Pods, key) || (sharedStorage(cr) && len(status.Pods) > 0) {
+			delivered = append(delivered, key)
+			continue
+		}
+		if status := mailhogContainerStatus(&pod); status == nil || !status.Ready {
+			pending++
+			continue
+		}
+		if err = deliverSeedMessages(mailhogSmtpAddress(cr, &pod), auth, messages); err != nil {
+			logger.Error(err, failedSeedDelivery, "pod", pod.Name)
+			pending++
+			continue
+		}
+		r.Recorder.Event(cr, corev1.EventTypeNormal, "Seeded", "delivered "+strconv.Itoa(len(messages))+" seed messages to pod "+pod.Name)
+		status.Pods = append(status.Pods, key)
+		delivered = append(delivered, key)
+	}
+
+	// forget pods that are gone, except for shared storage where the delivery counts for all future pods
+	if !sharedStorage(cr) {
+		status.Pods = intersectStrings(status.Pods, current)
+	}
+	sort.Strings(status.Pods)
+	cr.Status.Seed = status
+
+	if pending > 0 || len(delivered) == 0 {
+		return r.seedCondition(ctx, cr, original, metav1.ConditionFalse, "Pending",
+			strconv.Itoa(len(messages))+" messages delivered to "+strconv.Itoa(len(delivered))+" pods, "+strconv.Itoa(pending)+" pods pending")
+	}
+	return r.seedCondition(ctx, cr, original, metav1.ConditionTrue, "Delivered",
+		strconv.Itoa(len(messages))+" messages delivered to "+strconv.Itoa(len(delivered))+" pods")
+}
+
+// seedCondition sets the Seeded condition and stores the status if it differs from the original status
+func (r *MailhogInstanceReconciler) seedCondition(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, original *mailhogv1alpha1.MailhogInstanceStatus, status metav1.ConditionStatus, reason, message string) error {
+	logger := r.logger.WithValues(span, spanSeed)
+
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:               mailhogv1alpha1.SeededCondition,
+		Status:             status,
+		ObservedGeneration: cr.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if !reflect.DeepEqual(*original, cr.Status) {
+		if err := r.Status().Update(ctx, cr); err != nil {
+			logger.Error(err, failedCrUpdateStatus)
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// seedMessages collects the seed messages from all sources, ordered by source and key
+func (r *MailhogInstanceReconciler) seedMessages(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) (messages []seedMessage, err error) {
+	seed := cr.Spec.Seed
+
+	for _, name := range seed.ConfigMaps {
+		configMap := &corev1.ConfigMap{}
+		if err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: cr.Namespace}, configMap); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, errMissingSeedSource
+			}
+			return nil, err
+		}
+		data := make(map[string][]byte)
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			data[key] = value
+		}
+		messages = append(messages, sortedSeedMessages("configmap/"+name, data)...)
+	}
+
+	for _, name := range seed.Secrets {
+		secret := &corev1.Secret{}
+		if err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: cr.Namespace}, secret); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, errMissingSeedSource
+			}
+			return nil, err
+		}
+		// the operator may read every Secret of the namespace, so only Secrets meant to be seed sources are delivered
+		if secret.Labels[seedSourceLabel] != "true" {
+			return nil, fmt.Errorf("%w: %s", errUnlabeledSeedSource, name)
+		}
+		messages = append(messages, sortedSeedMessages("secret/"+name, secret.Data)...)
+	}
+
+	for _, message := range seed.Messages {
+		messages = append(messages, seedMessage{name: "inline/" + message.Name, eml: []byte(message.Eml)})
+	}
+
+	return messages, nil
+}
+
+// sortedSeedMessages returns the messages of a ConfigMap or Secret ordered by key
+func sortedSeedMessages(source string, data map[string][]byte) (messages []seedMessage) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		messages = append(messages, seedMessage{name: source + "/" + key, eml: data[key]})
+	}
+	return messages
+}
+
+// seedHash identifies a set of seed messages, a changed hash delivers the messages again
+func seedHash(messages []seedMessage) string {
+	hash := sha256.New()
+	for _, message := range messages {
+		hash.Write([]byte(message.name))
+		hash.Write([]byte{0})
+		hash.Write(message.eml)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// seedPodKey identifies a pod incarnation
+func seedPodKey(pod *corev1.Pod) string {
+	restarts := int32(0)
+	if status := mailhogContainerStatus(pod); status != nil {
+		restarts = status.RestartCount
+	}
+	return string(pod.UID) + "/" + strconv.Itoa(int(restarts))
+}
+
+// deliverSeedMessages sends the messages in one smtp session
+// envelope sender and recipients are taken from the message headers
+func deliverSeedMessages(address string, auth smtp.Auth, messages []seedMessage) error {
+	archived := make([]archive.Message, 0, len(messages))
+	for _, message := range messages {
+		archived = append(archived, archive.Message{Data: message.eml})
+	}
+	return archive.Deliver(address, auth, httpClient.Timeout, seedFallbackAddress, archived)
+}
+
+// containsString returns true if the slice contains the value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// intersectStrings returns the values that are also contained in keep
+func intersectStrings(values, keep []string) (result []string) {
+	for _, v := range values {
+		if containsString(keep, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ensureServiceAccount reconciles the ServiceAccount used by the rbac auth proxy
+func ensureServiceAccount(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	name := types.NamespacedName{Name: serviceAccountName(cr), Namespace: cr.Namespace}
+	logger := r.logger.WithValues(span, spanServiceAcc)
+
+	if rbacAuthNeeded(cr) {
+		existingServiceAccount := &corev1.ServiceAccount{}
+		if err = r.Get(ctx, name, existingServiceAccount); err != nil {
+			if errors.IsNotFound(err) {
+				serviceAccount := serviceAccountNew(cr)
+				return r.create(ctx, cr, logger, serviceAccount, serviceAccountCreate)
+			}
+			logger.Error(err, failedGetExisting)
+			return err
+		}
+
+		updatedServiceAccount, updateNeeded, err := serviceAccountUpdates(cr, existingServiceAccount)
+		if err != nil {
+			logger.Error(err, failedUpdateCheck)
+			return err
+		} else if updateNeeded {
+			return r.update(ctx, cr, logger, updatedServiceAccount, serviceAccountUpdate)
+		}
+
+	} else {
+		toBeDeletedServiceAccount := &corev1.ServiceAccount{}
+		if err = r.delete(ctx, cr, name, toBeDeletedServiceAccount, logger, serviceAccountDelete); err != nil {
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// serviceAccountName returns the name of the ServiceAccount used by the rbac auth proxy
+func serviceAccountName(cr *mailhogv1alpha1.MailhogInstance) string {
+	return cr.Name + serviceAccountSuffix
+}
+
+// serviceAccountNew returns a ServiceAccount in the wanted state
+// the oauth redirect annotations allow the service account to act as openshift oauth client
+// https://docs.openshift.com/container-platform/4.8/authentication/using-service-accounts-as-oauth-client.html
+func serviceAccountNew(cr *mailhogv1alpha1.MailhogInstance) (newServiceAccount *corev1.ServiceAccount) {
+	meta := CreateMetaMaker(cr)
+	meta.Name = serviceAccountName(cr)
+
+	switch cr.Spec.WebTrafficInlet {
+	case mailhogv1alpha1.RouteTrafficInlet:
+		reference, _ := json.Marshal(map[string]interface{}{
+			"kind":       "OAuthRedirectReference",
+			"apiVersion": "v1",
+			"reference": map[string]string{
+				"kind": "Route",
+				"name": cr.Name,
+			},
+		})
+		meta.Annotations[oauthRedirectReferenceAnnotation] = string(reference)
+	case mailhogv1alpha1.IngressTrafficInlet:
+		scheme := "http://"
+		if webTrafficEncrypted(cr) {
+			scheme = "https://"
+		}
+		meta.Annotations[oauthRedirectUriAnnotation] = scheme + cr.Spec.Settings.Ingress.Host
+	case mailhogv1alpha1.NoTrafficInlet:
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: meta.GetMeta(),
+	}
+
+	return serviceAccount
+}
+
+// serviceAccountUpdates checks if a ServiceAccount needs to be updated
+func serviceAccountUpdates(cr *mailhogv1alpha1.MailhogInstance, oldServiceAccount *corev1.ServiceAccount) (updatedServiceAccount *corev1.ServiceAccount, updateNeeded bool, err error) {
+	newServiceAccount := serviceAccountNew(cr)
+
+	updateNeeded, err = checkPatch(oldServiceAccount, newServiceAccount)
+	if updateNeeded == true {
+		// keep the token secrets managed by the cluster
+		newServiceAccount.Secrets = oldServiceAccount.Secrets
+		newServiceAccount.ImagePullSecrets = oldServiceAccount.ImagePullSecrets
+		return newServiceAccount, updateNeeded, err
+	}
+	return oldServiceAccount, updateNeeded, err
+}
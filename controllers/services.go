@@ -7,7 +7,6 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // ensureService reconciles Service child objects
@@ -47,25 +46,20 @@ func serviceNew(cr *mailhogv1alpha1.MailhogInstance) (newService *corev1.Service
 			Selector: meta.GetLabels(),
 			Ports: []corev1.ServicePort{
 				{
-					Port: portSmtp,
-					Name: portSmtpName,
-					TargetPort: intstr.IntOrString{
-						Type:   intstr.Int,
-						IntVal: portSmtp,
-					},
+					Port:       portSmtp,
+					Name:       portSmtpName,
+					TargetPort: smtpTargetPortRef(cr),
 				},
 				{
-					Port: portWeb,
-					Name: portWebName,
-					TargetPort: intstr.IntOrString{
-						Type:   intstr.Int,
-						IntVal: portWeb,
-					},
+					Port:       portWeb,
+					Name:       portWebName,
+					TargetPort: webTargetPortRef(cr),
 				},
 			},
 			Type: "ClusterIP",
 		},
 	}
+	service.Spec.Ports = append(service.Spec.Ports, smtpProxyServicePorts(cr)...)
 
 	return service
 }
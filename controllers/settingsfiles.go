@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// smtpUpstreams returns the inline smtp upstreams followed by the resolved catalog entries and the upstreams
+// of the referenced upstreams file, every name may only be used once
+func (r *MailhogInstanceReconciler) smtpUpstreams(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) ([]mailhogv1alpha1.MailhogUpstreamSpec, error) {
+	catalog, err := r.catalogUpstreams(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	referenced, err := r.fileUpstreams(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	var upstreams []mailhogv1alpha1.MailhogUpstreamSpec
+	if cr.Spec.Settings.Files != nil {
+		upstreams = append(upstreams, cr.Spec.Settings.Files.SmtpUpstreams...)
+	}
+	upstreams = append(upstreams, catalog...)
+
+	names := map[string]bool{}
+	for _, upstream := range upstreams {
+		names[upstream.Name] = true
+	}
+	for _, upstream := range referenced {
+		if names[upstream.Name] {
+			return nil, fmt.Errorf("%w: %s of upstreamsFrom", errUpstreamNameConflict, upstream.Name)
+		}
+	}
+	return append(upstreams, referenced...), nil
+}
+
+// fileUpstreams returns the upstreams of the referenced upstreams file sorted by name
+// like mailhog, the key of an upstream is used as its name
+func (r *MailhogInstanceReconciler) fileUpstreams(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) ([]mailhogv1alpha1.MailhogUpstreamSpec, error) {
+	files := cr.Spec.Settings.Files
+	if files == nil || files.UpstreamsFrom == nil {
+		return nil, nil
+	}
+	data, err := r.settingsFile(ctx, cr.Namespace, files.UpstreamsFrom)
+	if err != nil {
+		return nil, fmt.Errorf("%w: upstreamsFrom", err)
+	}
+
+	servers := map[string]mailhogv1alpha1.MailhogUpstreamSpec{}
+	if err = json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("%w: upstreamsFrom: %s", errInvalidSettingsFile, err)
+	}
+	upstreams := make([]mailhogv1alpha1.MailhogUpstreamSpec, 0, len(servers))
+	for name, server := range servers {
+		server.Name = name
+		server.Tls = nil
+		upstreams = append(upstreams, server)
+	}
+	sort.Slice(upstreams, func(i, j int) bool {
+		return upstreams[i].Name < upstreams[j].Name
+	})
+	return upstreams, nil
+}
+
+// webUsers returns the inline web users followed by the users of the referenced users file,
+// every name may only be used once and the probe user's name is reserved
+func (r *MailhogInstanceReconciler) webUsers(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) ([]mailhogv1alpha1.MailhogWebUserSpec, error) {
+	files := cr.Spec.Settings.Files
+	if files == nil {
+		return nil, nil
+	}
+	users := append([]mailhogv1alpha1.MailhogWebUserSpec{}, files.WebUsers...)
+	if files.UsersFrom == nil {
+		return users, nil
+	}
+
+	data, err := r.settingsFile(ctx, cr.Namespace, files.UsersFrom)
+	if err != nil {
+		return nil, fmt.Errorf("%w: usersFrom", err)
+	}
+	names := map[string]bool{}
+	for _, user := range users {
+		names[user.Name] = true
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		name, hash, found := strings.Cut(line, ":")
+		if !found || name == "" || hash == "" {
+			return nil, fmt.Errorf("%w: usersFrom: a line is not formatted as name:hash", errInvalidSettingsFile)
+		}
+		if name == probeUserName {
+			return nil, fmt.Errorf("%w: usersFrom", errReservedWebUser)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("%w: %s of usersFrom", errWebUserConflict, name)
+		}
+		names[name] = true
+		users = append(users, mailhogv1alpha1.MailhogWebUserSpec{Name: name, PasswordHash: hash})
+	}
+	return users, nil
+}
+
+// settingsFile returns the content of a referenced ConfigMap or Secret key
+func (r *MailhogInstanceReconciler) settingsFile(ctx context.Context, namespace string, source *mailhogv1alpha1.MailhogFileSourceSpec) ([]byte, error) {
+	var data []byte
+	if ref := source.ConfigMapKeyRef; ref != nil {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, configMap); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, errMissingSettingsFile
+			}
+			return nil, err
+		}
+		data = []byte(configMap.Data[ref.Key])
+	}
+	if ref := source.SecretKeyRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, errMissingSettingsFile
+			}
+			return nil, err
+		}
+		data = secret.Data[ref.Key]
+	}
+	if len(data) == 0 {
+		return nil, errMissingSettingsFile
+	}
+	return data, nil
+}
+
+// upstreamsFileDirect returns true if mailhog reads the referenced upstreams file from its mount,
+// which is the case if there are no other upstreams it has to be merged with
+func upstreamsFileDirect(cr *mailhogv1alpha1.MailhogInstance) bool {
+	files := cr.Spec.Settings.Files
+	return files != nil && files.UpstreamsFrom != nil && len(files.SmtpUpstreams) == 0 && len(files.UpstreamRefs) == 0
+}
+
+// settingsFileProjection returns the projection of a referenced ConfigMap or Secret key into a file
+func settingsFileProjection(source *mailhogv1alpha1.MailhogFileSourceSpec, path string) corev1.VolumeProjection {
+	if ref := source.SecretKeyRef; ref != nil {
+		return corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: ref.LocalObjectReference,
+				Items:                []corev1.KeyToPath{{Key: ref.Key, Path: path}},
+			},
+		}
+	}
+	return corev1.VolumeProjection{
+		ConfigMap: &corev1.ConfigMapProjection{
+			LocalObjectReference: source.ConfigMapKeyRef.LocalObjectReference,
+			Items:                []corev1.KeyToPath{{Key: source.ConfigMapKeyRef.Key, Path: path}},
+		},
+	}
+}
+
+// referencesSettingsFile returns true if the cr reads a settings file from the ConfigMap or Secret
+func referencesSettingsFile(cr *mailhogv1alpha1.MailhogInstance, object client.Object) bool {
+	files := cr.Spec.Settings.Files
+	if files == nil {
+		return false
+	}
+	for _, source := range []*mailhogv1alpha1.MailhogFileSourceSpec{files.UpstreamsFrom, files.UsersFrom} {
+		if source == nil {
+			continue
+		}
+		switch object.(type) {
+		case *corev1.ConfigMap:
+			if source.ConfigMapKeyRef != nil && source.ConfigMapKeyRef.Name == object.GetName() {
+				return true
+			}
+		case *corev1.Secret:
+			if source.SecretKeyRef != nil && source.SecretKeyRef.Name == object.GetName() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findObjectsForSettingsFile is mapper to find which CRs need to be reconciled when a ConfigMap or Secret
+// holding a referenced settings file is changed
+func (r *MailhogInstanceReconciler) findObjectsForSettingsFile(watchedObject client.Object) []reconcile.Request {
+	requests := make([]reconcile.Request, 0)
+
+	list := &mailhogv1alpha1.MailhogInstanceList{}
+	if err := r.List(context.TODO(), list, client.InNamespace(watchedObject.GetNamespace())); err != nil {
+		return requests
+	}
+	for _, cr := range list.Items {
+		if referencesSettingsFile(&cr, watchedObject) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: cr.Namespace,
+					Name:      cr.Name,
+				},
+			})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,602 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/smtpproxy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// smtpProxyNeeded returns true if the CR requires the smtp proxy sidecar
+func smtpProxyNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	return cr.Spec.Settings.SmtpTls != nil || smtpProxyIntercepts(cr)
+}
+
+// smtpProxyIntercepts returns true if the smtp proxy sidecar takes over the plaintext smtp port of the Service
+func smtpProxyIntercepts(cr *mailhogv1alpha1.MailhogInstance) bool {
+	settings := cr.Spec.Settings
+	return len(settings.SmtpFaults) > 0 || settings.SmtpPolicy != nil || settings.SmtpTranscripts != nil || settings.SmtpRelay != nil
+}
+
+// smtpRelayDefaultRetry is the wait before a failed relay is retried if the cr does not specify it
+var smtpRelayDefaultRetry = time.Duration(1) * time.Minute
+
+// smtpProxyRelay returns the relay rules of the smtp proxy sidecar with the upstreams they reference
+func smtpProxyRelay(cr *mailhogv1alpha1.MailhogInstance) (relay smtpproxy.Relay) {
+	spec := cr.Spec.Settings.SmtpRelay
+	if spec == nil {
+		return relay
+	}
+
+	relay.MaxAttempts = int(spec.MaxAttempts)
+	if relay.MaxAttempts == 0 {
+		relay.MaxAttempts = smtpRelayDefaultAttempts
+	}
+	relay.RetryInterval = smtpRelayDefaultRetry
+	if spec.RetryInterval != nil {
+		relay.RetryInterval = spec.RetryInterval.Duration
+	}
+	relay.RatePerMinute = int(spec.RatePerMinute)
+	// a quarter of the sidecar's memory limit is left to the messages waiting for an upstream
+	if memory, found := sidecarResources(cr).Limits[corev1.ResourceMemory]; found {
+		relay.MaxQueueBytes = memory.Value() / 4
+	}
+
+	for _, rule := range spec.Rules {
+		relay.Rules = append(relay.Rules, smtpproxy.RelayRule{
+			Name:       rule.Name,
+			Recipients: rule.Recipients,
+			Upstream:   rule.Upstream,
+		})
+	}
+	for i, upstream := range smtpRelayUpstreams(cr) {
+		relayUpstream := smtpproxy.RelayUpstream{
+			Name:      upstream.Name,
+			Address:   net.JoinHostPort(upstream.Host, upstream.Port),
+			Mechanism: upstream.Mechanism,
+		}
+		// the credentials are mounted from the upstreams Secret, the config is plain text in the Deployment
+		if upstream.Username != "" {
+			relayUpstream.UsernameFile = smtpProxyRelayMount + "/" + smtpRelayCredentialKey(i, smtpRelayUsername)
+			relayUpstream.PasswordFile = smtpProxyRelayMount + "/" + smtpRelayCredentialKey(i, smtpRelayPassword)
+		}
+		if tlsSpec := upstream.Tls; tlsSpec != nil {
+			if caFile := upstreamCaFile(upstream); caFile != "" {
+				relayUpstream.CaFile = upstreamCaMount + "/" + caFile
+			}
+			relayUpstream.ServerName = tlsSpec.ServerName
+			relayUpstream.MinTlsVersion = string(tlsSpec.MinVersion)
+		}
+		relay.Upstreams = append(relay.Upstreams, relayUpstream)
+	}
+	return relay
+}
+
+// smtpRelayUpstreams returns the smtp upstreams referenced by relay rules, in the order of the spec
+func smtpRelayUpstreams(cr *mailhogv1alpha1.MailhogInstance) (upstreams []mailhogv1alpha1.MailhogUpstreamSpec) {
+	spec, files := cr.Spec.Settings.SmtpRelay, cr.Spec.Settings.Files
+	if spec == nil || files == nil {
+		return nil
+	}
+	referenced := map[string]bool{}
+	for _, rule := range spec.Rules {
+		referenced[rule.Upstream] = true
+	}
+	for _, upstream := range files.SmtpUpstreams {
+		if referenced[upstream.Name] {
+			upstreams = append(upstreams, upstream)
+		}
+	}
+	return upstreams
+}
+
+// smtpRelayCredentialKey returns the key of a credential of the i-th relayed upstream in the upstreams Secret
+func smtpRelayCredentialKey(i int, credential string) string {
+	return "relay-" + strconv.Itoa(i) + "-" + credential
+}
+
+// smtpRelayCredentials returns the credentials of the relayed upstreams by their key in the upstreams Secret
+func smtpRelayCredentials(cr *mailhogv1alpha1.MailhogInstance) map[string][]byte {
+	credentials := map[string][]byte{}
+	for i, upstream := range smtpRelayUpstreams(cr) {
+		if upstream.Username != "" {
+			credentials[smtpRelayCredentialKey(i, smtpRelayUsername)] = []byte(upstream.Username)
+			credentials[smtpRelayCredentialKey(i, smtpRelayPassword)] = []byte(upstream.Password)
+		}
+	}
+	return credentials
+}
+
+// smtpProxyTranscripts returns the count of session transcripts the smtp proxy sidecar keeps, 0 if none are recorded
+func smtpProxyTranscripts(cr *mailhogv1alpha1.MailhogInstance) int {
+	transcripts := cr.Spec.Settings.SmtpTranscripts
+	if transcripts == nil {
+		return 0
+	}
+	if transcripts.Keep <= 0 {
+		return smtpTranscriptsDefaultKeep
+	}
+	return int(transcripts.Keep)
+}
+
+// smtpTargetPortRef returns the container port the smtp port of the Service points to
+func smtpTargetPortRef(cr *mailhogv1alpha1.MailhogInstance) intstr.IntOrString {
+	port := int32(portSmtp)
+	if smtpProxyIntercepts(cr) {
+		port = portSmtpProxy
+	}
+	return intstr.IntOrString{
+		Type:   intstr.Int,
+		IntVal: port,
+	}
+}
+
+// smtpProxyRules returns the fault injection rules of the smtp proxy sidecar
+func smtpProxyRules(cr *mailhogv1alpha1.MailhogInstance) (rules []smtpproxy.Rule) {
+	for _, fault := range cr.Spec.Settings.SmtpFaults {
+		rule := smtpproxy.Rule{
+			Name:          fault.Name,
+			Stage:         smtpproxy.Stage(fault.Stage),
+			Address:       fault.Address,
+			AfterMessages: int(fault.AfterMessages),
+			Action:        smtpproxy.Action(fault.Action),
+			Code:          int(fault.Code),
+			Message:       fault.Message,
+		}
+		if rule.Action == smtpproxy.RejectAction && rule.Code == 0 {
+			rule.Code = smtpFaultDefaultCode
+		}
+		if fault.Delay != nil {
+			rule.Delay = fault.Delay.Duration
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// smtpProxyPolicy returns the policy enforced by the smtp proxy sidecar
+func smtpProxyPolicy(cr *mailhogv1alpha1.MailhogInstance) (policy smtpproxy.Policy) {
+	spec := cr.Spec.Settings.SmtpPolicy
+	if spec == nil {
+		return policy
+	}
+	if spec.AuthSecretName != "" {
+		policy.CredentialsDir = smtpProxyAuthMount
+	}
+	policy.AllowedSenderDomains = spec.AllowedSenderDomains
+	policy.MaxMessageSize = spec.MaxMessageBytes
+	policy.MaxRecipients = int(spec.MaxRecipients)
+	return policy
+}
+
+// smtpTlsPorts returns the STARTTLS and implicit TLS ports of a CR, falling back to the defaults
+func smtpTlsPorts(cr *mailhogv1alpha1.MailhogInstance) (startTlsPort int32, implicitTlsPort int32) {
+	startTlsPort, implicitTlsPort = portSmtpStartTlsDefault, portSmtpTlsDefault
+	if tlsSpec := cr.Spec.Settings.SmtpTls; tlsSpec != nil {
+		if tlsSpec.StartTlsPort != 0 {
+			startTlsPort = tlsSpec.StartTlsPort
+		}
+		if tlsSpec.ImplicitTlsPort != 0 {
+			implicitTlsPort = tlsSpec.ImplicitTlsPort
+		}
+	}
+	return
+}
+
+// defaultSidecarImage is the operator image of the same version, so sidecars and jobs match the running operator
+// image builds pin it to the image being built (-ldflags "-X <module>/controllers.defaultSidecarImage=<image>"),
+// the fallback follows VERSION in params.mak
+var defaultSidecarImage = "ghcr.io/patrickmx/mailhog-operator:v0.2.4"
+
+// sidecarImage returns the image used for operator provided sidecars
+func sidecarImage(cr *mailhogv1alpha1.MailhogInstance) string {
+	if image := cr.Spec.Settings.SidecarImage; image != "" {
+		return image
+	}
+	return defaultSidecarImage
+}
+
+// smtpProxyConfig returns the json config passed to the smtp proxy sidecar
+func smtpProxyConfig(cr *mailhogv1alpha1.MailhogInstance) string {
+	cfg := smtpproxy.Config{
+		Backend: smtpProxyBackend,
+		Rules:   smtpProxyRules(cr),
+		Policy:  smtpProxyPolicy(cr),
+
+		Relay:       smtpProxyRelay(cr),
+		Transcripts: smtpProxyTranscripts(cr),
+	}
+
+	if smtpProxyIntercepts(cr) {
+		cfg.AdminAddress = ":" + strconv.Itoa(portSmtpProxyAdmin)
+		cfg.Operator = smtpproxy.Operator{User: probeUserName, PasswordFile: smtpProxyOperatorPasswdPath}
+		cfg.Listeners = append(cfg.Listeners, smtpproxy.Listener{
+			Name:    portSmtpProxyName,
+			Address: ":" + strconv.Itoa(portSmtpProxy),
+			Mode:    smtpproxy.PlainMode,
+		}, smtpproxy.Listener{
+			Name:    portSmtpOperatorName,
+			Address: ":" + strconv.Itoa(portSmtpOperator),
+			Mode:    smtpproxy.OperatorMode,
+		})
+	}
+
+	if cr.Spec.Settings.SmtpTls != nil {
+		startTlsPort, implicitTlsPort := smtpTlsPorts(cr)
+		cfg.CertFile = smtpProxyTlsCertPath
+		cfg.KeyFile = smtpProxyTlsKeyPath
+		cfg.Listeners = append(cfg.Listeners,
+			smtpproxy.Listener{
+				Name:    portSmtpStartTlsName,
+				Address: ":" + strconv.Itoa(int(startTlsPort)),
+				Mode:    smtpproxy.StartTlsMode,
+			},
+			smtpproxy.Listener{
+				Name:    portSmtpTlsName,
+				Address: ":" + strconv.Itoa(int(implicitTlsPort)),
+				Mode:    smtpproxy.ImplicitTlsMode,
+			},
+		)
+	}
+
+	cfgBytes, _ := json.Marshal(cfg)
+	return string(cfgBytes)
+}
+
+// smtpProxyPorts returns the ContainerPorts of the smtp proxy sidecar
+func smtpProxyPorts(cr *mailhogv1alpha1.MailhogInstance) (p []corev1.ContainerPort) {
+	if smtpProxyIntercepts(cr) {
+		p = append(p,
+			corev1.ContainerPort{
+				Name:          portSmtpProxyName,
+				ContainerPort: portSmtpProxy,
+				Protocol:      protoTcp,
+			},
+			corev1.ContainerPort{
+				Name:          portSmtpProxyAdminName,
+				ContainerPort: portSmtpProxyAdmin,
+				Protocol:      protoTcp,
+			},
+			corev1.ContainerPort{
+				Name:          portSmtpOperatorName,
+				ContainerPort: portSmtpOperator,
+				Protocol:      protoTcp,
+			},
+		)
+	}
+	if cr.Spec.Settings.SmtpTls != nil {
+		startTlsPort, implicitTlsPort := smtpTlsPorts(cr)
+		p = append(p,
+			corev1.ContainerPort{
+				Name:          portSmtpStartTlsName,
+				ContainerPort: startTlsPort,
+				Protocol:      protoTcp,
+			},
+			corev1.ContainerPort{
+				Name:          portSmtpTlsName,
+				ContainerPort: implicitTlsPort,
+				Protocol:      protoTcp,
+			},
+		)
+	}
+	return p
+}
+
+// smtpProxyServicePorts returns the ServicePorts exposing the tls listeners of the smtp proxy sidecar
+// the plaintext listener is reached through the smtp port of the Service, the admin port stays reachable on the pods only
+func smtpProxyServicePorts(cr *mailhogv1alpha1.MailhogInstance) (p []corev1.ServicePort) {
+	for _, port := range smtpProxyPorts(cr) {
+		if port.Name == portSmtpProxyName || port.Name == portSmtpProxyAdminName || port.Name == portSmtpOperatorName {
+			continue
+		}
+		p = append(p, corev1.ServicePort{
+			Port: port.ContainerPort,
+			Name: port.Name,
+			TargetPort: intstr.IntOrString{
+				Type:   intstr.Int,
+				IntVal: port.ContainerPort,
+			},
+		})
+	}
+	return p
+}
+
+// smtpProxyContainer returns the smtp proxy sidecar container
+func smtpProxyContainer(cr *mailhogv1alpha1.MailhogInstance) corev1.Container {
+	ports := smtpProxyPorts(cr)
+
+	container := corev1.Container{
+		Name:    smtpProxyName,
+		Image:   sidecarImage(cr),
+		Command: []string{smtpProxyCommand},
+		Ports:   ports,
+		Env: []corev1.EnvVar{
+			{
+				Name:  smtpproxy.ConfigEnv,
+				Value: smtpProxyConfig(cr),
+			},
+		},
+		Resources:      sidecarResources(cr),
+		ReadinessProbe: getProbeTcp(int(ports[0].ContainerPort)),
+		LivenessProbe:  getProbeTcp(int(ports[0].ContainerPort)),
+	}
+
+	if cr.Spec.Settings.SmtpTls != nil {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volumeNameSmtpTls,
+			MountPath: smtpProxyTlsMount,
+			ReadOnly:  true,
+		})
+	}
+
+	if policy := cr.Spec.Settings.SmtpPolicy; policy != nil && policy.AuthSecretName != "" {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volumeNameSmtpAuth,
+			MountPath: smtpProxyAuthMount,
+			ReadOnly:  true,
+		})
+	}
+
+	if smtpProxyIntercepts(cr) {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volumeNameSmtpOperator,
+			MountPath: smtpProxyOperatorMount,
+			ReadOnly:  true,
+		})
+	}
+
+	// the relay verifies the upstreams like mailhog, with the same ca bundles
+	if cr.Spec.Settings.SmtpRelay != nil {
+		if trustedCa := cr.Spec.Settings.TrustedCa; trustedCa != nil {
+			container.Env = append(container.Env, corev1.EnvVar{Name: envSslCertFile, Value: trustedCaFile(trustedCa)})
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      volumeNameTrustedCa,
+				MountPath: trustedCaMount,
+				ReadOnly:  true,
+			})
+		}
+		if len(upstreamCaSources(cr)) > 0 {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      volumeNameUpstreamCa,
+				MountPath: upstreamCaMount,
+				ReadOnly:  true,
+			})
+		}
+		if len(smtpRelayCredentials(cr)) > 0 {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      volumeNameSmtpRelay,
+				MountPath: smtpProxyRelayMount,
+				ReadOnly:  true,
+			})
+		}
+	}
+
+	return container
+}
+
+// smtpProxyVolumes returns the volumes needed by the smtp proxy sidecar
+func smtpProxyVolumes(cr *mailhogv1alpha1.MailhogInstance) (volumes []corev1.Volume) {
+	if tlsSpec := cr.Spec.Settings.SmtpTls; tlsSpec != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameSmtpTls,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: tlsSpec.SecretName,
+				},
+			},
+		})
+	}
+	if policy := cr.Spec.Settings.SmtpPolicy; policy != nil && policy.AuthSecretName != "" {
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameSmtpAuth,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: policy.AuthSecretName,
+				},
+			},
+		})
+	}
+	// the admin endpoints only answer the operator, which authenticates as its probe user
+	if smtpProxyIntercepts(cr) {
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameSmtpOperator,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: generatedSecretName(cr),
+					Items:      []corev1.KeyToPath{{Key: secretKeyProbePassword, Path: smtpProxyOperatorPassword}},
+				},
+			},
+		})
+	}
+	// the relay credentials are rendered into the upstreams Secret next to mailhog's upstreams file
+	if credentials := smtpRelayCredentials(cr); len(credentials) > 0 {
+		keys := make([]string, 0, len(credentials))
+		for key := range credentials {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]corev1.KeyToPath, 0, len(keys))
+		for _, key := range keys {
+			items = append(items, corev1.KeyToPath{Key: key, Path: key})
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeNameSmtpRelay,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: upstreamsSecretName(cr),
+					Items:      items,
+				},
+			},
+		})
+	}
+	return volumes
+}
+
+// sidecarResources returns the resources of operator provided sidecars, the defaults if the CR does not override them
+func sidecarResources(cr *mailhogv1alpha1.MailhogInstance) corev1.ResourceRequirements {
+	if override := cr.Spec.Settings.SidecarResources; override != nil {
+		return *override.DeepCopy()
+	}
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+	resources.Requests[corev1.ResourceCPU] = resource.MustParse(defaultSidecarResourceCPU)
+	resources.Requests[corev1.ResourceMemory] = resource.MustParse(defaultSidecarResourceMem)
+	resources.Limits[corev1.ResourceCPU] = resource.MustParse(defaultSidecarResourceCPU)
+	resources.Limits[corev1.ResourceMemory] = resource.MustParse(defaultSidecarResourceMem)
+	return resources
+}
+
+// smtpProxyAdminUrl returns the base url of the admin endpoints of the smtp proxy sidecar of a pod
+// it is a variable so tests can point it to a fake server
+var smtpProxyAdminUrl = func(pod *corev1.Pod) string {
+	return "http://" + pod.Status.PodIP + ":" + strconv.Itoa(portSmtpProxyAdmin)
+}
+
+// smtpProxyAdminGet decodes the json answer of an admin endpoint of the smtp proxy sidecar of a pod, bounded by podRequestTimeout
+// the request authenticates as internal probe user
+func (r *MailhogInstanceReconciler) smtpProxyAdminGet(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, pod *corev1.Pod, path string, target interface{}) error {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: generatedSecretName(cr), Namespace: cr.Namespace}, secret); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, podRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, smtpProxyAdminUrl(pod)+path, http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(probeUserName, string(secret.Data[secretKeyProbePassword]))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %d", errSmtpProxyAdmin, path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// smtpFaultsStatus sums the hits of every smtp fault over the reachable pods, in the order of the spec
+// if no pod answers, the last known values are kept
+func (r *MailhogInstanceReconciler) smtpFaultsStatus(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, pods []corev1.Pod, logger logr.Logger) []mailhogv1alpha1.SmtpFaultStatus {
+	if len(cr.Spec.Settings.SmtpFaults) == 0 {
+		return nil
+	}
+	pods = reachablePods(pods)
+
+	hits := map[string]int64{}
+	answered := false
+	for i := range pods {
+		podHits := []smtpproxy.RuleHits{}
+		if err := r.smtpProxyAdminGet(ctx, cr, &pods[i], smtpproxy.RulesPath, &podHits); err != nil {
+			logger.Error(err, failedSmtpFaultHits, "pod", pods[i].Name)
+			continue
+		}
+		answered = true
+		for _, rule := range podHits {
+			hits[rule.Name] += rule.Hits
+		}
+	}
+	if !answered {
+		return cr.Status.SmtpFaults
+	}
+
+	status := make([]mailhogv1alpha1.SmtpFaultStatus, 0, len(cr.Spec.Settings.SmtpFaults))
+	for _, fault := range cr.Spec.Settings.SmtpFaults {
+		status = append(status, mailhogv1alpha1.SmtpFaultStatus{Name: fault.Name, Hits: hits[fault.Name]})
+	}
+	return status
+}
+
+// smtpPolicyStatus sums the smtp policy violations over the reachable pods, if no pod answers the last known values are kept
+func (r *MailhogInstanceReconciler) smtpPolicyStatus(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, pods []corev1.Pod, logger logr.Logger) *mailhogv1alpha1.SmtpPolicyViolationsStatus {
+	if cr.Spec.Settings.SmtpPolicy == nil {
+		return nil
+	}
+	pods = reachablePods(pods)
+
+	counts := map[smtpproxy.Violation]int64{}
+	answered := false
+	for i := range pods {
+		podCounts := map[smtpproxy.Violation]int64{}
+		if err := r.smtpProxyAdminGet(ctx, cr, &pods[i], smtpproxy.ViolationsPath, &podCounts); err != nil {
+			logger.Error(err, failedSmtpViolations, "pod", pods[i].Name)
+			continue
+		}
+		answered = true
+		for violation, count := range podCounts {
+			counts[violation] += count
+		}
+	}
+	if !answered {
+		return cr.Status.SmtpPolicyViolations
+	}
+
+	return &mailhogv1alpha1.SmtpPolicyViolationsStatus{
+		Auth:       counts[smtpproxy.AuthViolation],
+		Sender:     counts[smtpproxy.SenderViolation],
+		Size:       counts[smtpproxy.SizeViolation],
+		Recipients: counts[smtpproxy.RecipientsViolation],
+	}
+}
+
+// smtpRelayStatus sums the counters of every relay rule over the reachable pods, in the order of the spec
+// if no pod answers, the last known values are kept
+func (r *MailhogInstanceReconciler) smtpRelayStatus(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, pods []corev1.Pod, logger logr.Logger) []mailhogv1alpha1.SmtpRelayStatus {
+	relay := cr.Spec.Settings.SmtpRelay
+	if relay == nil {
+		return nil
+	}
+	pods = reachablePods(pods)
+
+	sums := map[string]smtpproxy.RelayStats{}
+	answered := false
+	for i := range pods {
+		podStats := []smtpproxy.RelayStats{}
+		if err := r.smtpProxyAdminGet(ctx, cr, &pods[i], smtpproxy.RelayPath, &podStats); err != nil {
+			logger.Error(err, failedSmtpRelayStats, "pod", pods[i].Name)
+			continue
+		}
+		answered = true
+		for _, stats := range podStats {
+			sum := sums[stats.Name]
+			sum.Relayed += stats.Relayed
+			sum.Failed += stats.Failed
+			sum.Retries += stats.Retries
+			sum.Pending += stats.Pending
+			sums[stats.Name] = sum
+		}
+	}
+	if !answered {
+		return cr.Status.SmtpRelay
+	}
+
+	status := make([]mailhogv1alpha1.SmtpRelayStatus, 0, len(relay.Rules))
+	for _, rule := range relay.Rules {
+		sum := sums[rule.Name]
+		status = append(status, mailhogv1alpha1.SmtpRelayStatus{
+			Name:    rule.Name,
+			Relayed: sum.Relayed,
+			Failed:  sum.Failed,
+			Retries: sum.Retries,
+			Pending: sum.Pending,
+		})
+	}
+	return status
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"time"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// mailhogServiceSmtpAddress returns the address of the smtp port of the Service
+// it is a variable so tests can point it to a fake smtp server
+var mailhogServiceSmtpAddress = func(cr *mailhogv1alpha1.MailhogInstance) string {
+	return net.JoinHostPort(cr.Name+"."+cr.Namespace+".svc", strconv.Itoa(portSmtp))
+}
+
+// smtpVerificationAttempts is how often the pods are searched for the test message
+var smtpVerificationAttempts = 5
+
+// smtpVerificationDelay is the time between two searches for the test message
+var smtpVerificationDelay = time.Duration(1) * time.Second
+
+// ensureSmtpVerified sends a tagged test message through the Service once a rollout completed,
+// searches it through the api of the pods and deletes it again
+// every deployment revision is verified once, failed verifications are retried
+func ensureSmtpVerified(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanSmtpVerify)
+
+	if cr.Spec.Settings.DisableSmtpVerification {
+		if cr.Status.SmtpVerification != nil || meta.FindStatusCondition(cr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition) != nil {
+			cr.Status.SmtpVerification = nil
+			meta.RemoveStatusCondition(&cr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition)
+			if err = r.Status().Update(ctx, cr); err != nil {
+				logger.Error(err, failedCrUpdateStatus)
+				return err
+			}
+		}
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err = r.Get(ctx, types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, deployment); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info(stateEnsured)
+			return nil
+		}
+		logger.Error(err, failedGetExisting)
+		return err
+	}
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	original := cr.Status.DeepCopy()
+	revision := deploymentRevision(deployment)
+	if !smtpVerificationDue(cr, revision, time.Now()) {
+		logger.Info(stateEnsured)
+		return nil
+	}
+	if !rolloutComplete(deployment) {
+		return r.smtpVerifiedCondition(ctx, cr, original, metav1.ConditionUnknown, mailhogv1alpha1.RolloutPendingReason,
+			"waiting for revision "+revision+" to be rolled out")
+	}
+
+	now := metav1.Now()
+	cr.Status.SmtpVerification = &mailhogv1alpha1.SmtpVerificationStatus{Revision: revision, LastVerificationTime: &now}
+	tag := "smtp-verification-" + revision + "-" + strconv.FormatInt(now.UnixNano(), 36)
+	// behind the smtp proxy the operator authenticates so the test message is exempt from its policy, faults and relay
+	address := mailhogServiceSmtpAddress(cr)
+	auth, err := mailhogSmtpAuth(ctx, r, cr)
+	if err != nil {
+		logger.Error(err, failedSmtpVerify)
+		return err
+	}
+	if err = archive.Deliver(address, auth, httpClient.Timeout, "", []archive.Message{smtpVerificationMessage(tag, now.Time)}); err != nil {
+		logger.Error(err, failedSmtpVerify)
+		r.Recorder.Event(cr, corev1.EventTypeWarning, "SmtpVerificationFailed", "test message not accepted by "+address+": "+err.Error())
+		return r.smtpVerifiedCondition(ctx, cr, original, metav1.ConditionFalse, mailhogv1alpha1.SendFailedReason,
+			"test message not accepted by "+address+": "+err.Error())
+	}
+
+	pod, err := r.findSmtpVerification(ctx, cr, tag)
+	if err != nil {
+		logger.Error(err, failedSmtpVerify)
+		r.Recorder.Event(cr, corev1.EventTypeWarning, "SmtpVerificationFailed", err.Error())
+		return r.smtpVerifiedCondition(ctx, cr, original, metav1.ConditionFalse, mailhogv1alpha1.NotStoredReason, err.Error())
+	}
+	return r.smtpVerifiedCondition(ctx, cr, original, metav1.ConditionTrue, mailhogv1alpha1.VerifiedReason,
+		"test message sent through "+address+" was stored by pod "+pod+" (revision "+revision+")")
+}
+
+// smtpVerifiedCondition sets the SmtpVerified condition and stores the status if it differs from the original status
+func (r *MailhogInstanceReconciler) smtpVerifiedCondition(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, original *mailhogv1alpha1.MailhogInstanceStatus, status metav1.ConditionStatus, reason, message string) error {
+	logger := r.logger.WithValues(span, spanSmtpVerify)
+
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:               mailhogv1alpha1.SmtpVerifiedCondition,
+		Status:             status,
+		ObservedGeneration: cr.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if !reflect.DeepEqual(*original, cr.Status) {
+		if err := r.Status().Update(ctx, cr); err != nil {
+			logger.Error(err, failedCrUpdateStatus)
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// findSmtpVerification searches the pods for the tagged test message and deletes it, returning the pod that stored it
+// the message is delivered to one pod behind the Service, so every pod is searched
+func (r *MailhogInstanceReconciler) findSmtpVerification(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, tag string) (string, error) {
+	logger := r.logger.WithValues(span, spanSmtpVerify)
+
+	lastErr := errSmtpVerificationNotStored
+	for attempt := 0; attempt < smtpVerificationAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(smtpVerificationDelay):
+			}
+		}
+
+		pods, err := mailhogApiPods(ctx, r, cr)
+		if err != nil {
+			return "", err
+		}
+		for i := range pods {
+			api, err := mailhogApiFor(ctx, r, cr, &pods[i])
+			if err != nil {
+				return "", err
+			}
+			page, err := api.search(ctx, "containing", tag)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if len(page.Items) == 0 {
+				continue
+			}
+			for _, message := range page.Items {
+				if err = api.deleteMessage(ctx, message.ID); err != nil {
+					logger.Error(err, failedSmtpVerify, "pod", pods[i].Name, "message", message.ID)
+				}
+			}
+			return pods[i].Name, nil
+		}
+	}
+	return "", lastErr
+}
+
+// smtpVerificationMessage returns the test message, the tag is part of the subject, a header and the body
+func smtpVerificationMessage(tag string, now time.Time) archive.Message {
+	data := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: mailhog-operator %s\r\nDate: %s\r\n%s: %s\r\n\r\n%s\r\n",
+		smtpVerificationAddress, smtpVerificationAddress, tag, now.Format(time.RFC1123Z), smtpVerificationHeader, tag,
+		"This message verifies the smtp delivery after a rollout and is deleted again: "+tag)
+	return archive.Message{From: smtpVerificationAddress, To: []string{smtpVerificationAddress}, Data: []byte(data)}
+}
+
+// smtpVerificationDue returns true if the revision was not verified yet or the last verification failed a while ago
+func smtpVerificationDue(cr *mailhogv1alpha1.MailhogInstance, revision string, now time.Time) bool {
+	status := cr.Status.SmtpVerification
+	if status == nil || status.Revision != revision || status.LastVerificationTime == nil {
+		return true
+	}
+	if meta.IsStatusConditionTrue(cr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition) {
+		return false
+	}
+	return !now.Before(status.LastVerificationTime.Add(archiveRetryTime))
+}
+
+// smtpVerificationFailed returns true if the last verification failed and is retried
+func smtpVerificationFailed(cr *mailhogv1alpha1.MailhogInstance) bool {
+	return !cr.Spec.Settings.DisableSmtpVerification && meta.IsStatusConditionFalse(cr.Status.Conditions, mailhogv1alpha1.SmtpVerifiedCondition)
+}
+
+// deploymentRevision returns the revision of the deployment's current rollout
+// without the annotation of the deployment controller the generation is used
+func deploymentRevision(deployment *appsv1.Deployment) string {
+	if revision := deployment.Annotations[deploymentRevisionKey]; revision != "" {
+		return revision
+	}
+	return strconv.FormatInt(deployment.Generation, 10)
+}
+
+// rolloutComplete returns true if all replicas of the deployment run the current template and are available
+func rolloutComplete(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.AvailableReplicas == replicas &&
+		status.Replicas == replicas
+}
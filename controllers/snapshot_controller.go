@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// MailhogSnapshotReconciler reconciles a MailhogSnapshot object
+type MailhogSnapshotReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	logger   logr.Logger
+}
+
+// archiveRetryTime is the time until a snapshot or restore waiting for its instance, pods or snapshot is retried
+var archiveRetryTime = time.Duration(30) * time.Second
+
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogsnapshots,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogsnapshots/status,verbs=*
+//+kubebuilder:rbac:groups=mailhog.operators.patrick.mx,resources=mailhogsnapshots/finalizers,verbs=*
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+
+// Reconcile exports the messages of the referenced instance once, snapshots are not updated afterwards
+func (r *MailhogSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = log.FromContext(ctx, "ns", req.Namespace, "snapshot", req.Name)
+	r.logger.Info(reconcileStarted)
+
+	snapshot := &mailhogv1alpha1.MailhogSnapshot{}
+	if err := r.Get(ctx, req.NamespacedName, snapshot); err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.Info(crGetNotFound)
+			return ctrl.Result{}, nil
+		}
+		r.logger.Error(err, crGetFailed)
+		return ctrl.Result{}, err
+	}
+	if archiveFinished(snapshot.Status.Conditions) || !snapshot.DeletionTimestamp.IsZero() {
+		r.logger.Info(reconcileFinished)
+		return ctrl.Result{}, nil
+	}
+
+	original := snapshot.Status.DeepCopy()
+	if snapshot.Status.StartTime == nil {
+		now := metav1.Now()
+		snapshot.Status.StartTime = &now
+	}
+
+	result, err := r.export(ctx, snapshot)
+	if err != nil {
+		setCompletedCondition(&snapshot.Status.Conditions, snapshot.Generation, mailhogv1alpha1.InProgressReason, err.Error())
+	}
+
+	if !reflect.DeepEqual(*original, snapshot.Status) {
+		if updateErr := r.Status().Update(ctx, snapshot); updateErr != nil {
+			r.logger.Error(updateErr, failedCrUpdateStatus)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, updateErr
+		}
+	}
+	return result, nil
+}
+
+// export writes the snapshot into a ConfigMap or starts / checks the Job writing it into a PVC
+// a returned error is recorded in the condition and retried, final failures set the Failed reason themselves
+func (r *MailhogSnapshotReconciler) export(ctx context.Context, snapshot *mailhogv1alpha1.MailhogSnapshot) (ctrl.Result, error) {
+	logger := r.logger.WithValues(span, spanSnapshot)
+
+	cr := &mailhogv1alpha1.MailhogInstance{}
+	if err := r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.Instance, Namespace: snapshot.Namespace}, cr); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, errMissingArchiveInstance
+		}
+		logger.Error(err, crGetFailed)
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+	}
+
+	if snapshot.Spec.PersistentVolumeClaim != nil {
+		return r.exportJob(ctx, snapshot, cr)
+	}
+	return r.exportConfigMap(ctx, snapshot, cr)
+}
+
+// exportConfigMap fetches all messages via the api and stores them in a ConfigMap owned by the snapshot
+func (r *MailhogSnapshotReconciler) exportConfigMap(ctx context.Context, snapshot *mailhogv1alpha1.MailhogSnapshot, cr *mailhogv1alpha1.MailhogInstance) (ctrl.Result, error) {
+	logger := r.logger.WithValues(span, spanSnapshot)
+
+	pods, err := mailhogApiPods(ctx, r, cr)
+	if err != nil {
+		logger.Error(err, failedListPods)
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+	}
+	if len(pods) == 0 {
+		return ctrl.Result{RequeueAfter: archiveRetryTime}, errNoArchivePods
+	}
+	if cr.Spec.Settings.Storage == mailhogv1alpha1.MongoDBStorage {
+		pods = pods[:1]
+	}
+
+	lists := make([][]archive.Message, 0, len(pods))
+	for i := range pods {
+		api, err := mailhogApiFor(ctx, r, cr, &pods[i])
+		if err != nil {
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		messages, err := archive.Fetch(ctx, httpClient, api.baseUrl, api.user, api.password)
+		if err != nil {
+			logger.Error(err, failedSnapshotExport, "pod", pods[i].Name)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		lists = append(lists, messages)
+	}
+	messages := archive.Merge(lists...)
+
+	files, err := archive.Encode(archiveFormat(snapshot), messages)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	size := archive.Size(files)
+	if size > snapshotConfigMapMaxSize {
+		r.Recorder.Event(snapshot, corev1.EventTypeWarning, "SnapshotFailed", errSnapshotTooLarge.Error())
+		setCompletedCondition(&snapshot.Status.Conditions, snapshot.Generation, mailhogv1alpha1.FailedReason,
+			errSnapshotTooLarge.Error()+": "+strconv.FormatInt(size, 10)+" bytes")
+		return ctrl.Result{}, nil
+	}
+
+	configMap := snapshotConfigMapNew(snapshot, files)
+	if err = ctrl.SetControllerReference(snapshot, configMap, r.Scheme); err != nil {
+		logger.Error(err, messageFailedSetOwnerRef)
+		return ctrl.Result{}, err
+	}
+	if err = r.Create(ctx, configMap); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			logger.Error(err, messageFailedCreate)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		// a previous export created the ConfigMap but failed to record it
+		if err = r.Update(ctx, configMap); err != nil {
+			logger.Error(err, messageFailedUpdate)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+	}
+	confMapCreate.Inc()
+
+	r.completeSnapshot(snapshot, archive.Result{Messages: len(messages), Bytes: size})
+	snapshot.Status.ConfigMap = configMap.Name
+	return ctrl.Result{}, nil
+}
+
+// exportJob creates the Job writing the snapshot into the PVC and records its result once it finished
+func (r *MailhogSnapshotReconciler) exportJob(ctx context.Context, snapshot *mailhogv1alpha1.MailhogSnapshot, cr *mailhogv1alpha1.MailhogInstance) (ctrl.Result, error) {
+	logger := r.logger.WithValues(span, spanSnapshot)
+	name := types.NamespacedName{Name: snapshot.Name + snapshotSuffix, Namespace: snapshot.Namespace}
+
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, name, job); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, failedGetExisting)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+
+		claim := &corev1.PersistentVolumeClaim{}
+		claimName := types.NamespacedName{Name: snapshot.Spec.PersistentVolumeClaim.ClaimName, Namespace: snapshot.Namespace}
+		if err = r.Get(ctx, claimName, claim); err != nil {
+			if errors.IsNotFound(err) {
+				return ctrl.Result{RequeueAfter: archiveRetryTime}, errMissingArchiveClaim
+			}
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+
+		pods, err := mailhogApiPods(ctx, r, cr)
+		if err != nil {
+			logger.Error(err, failedListPods)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		if len(pods) == 0 {
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, errNoArchivePods
+		}
+		if cr.Spec.Settings.Storage == mailhogv1alpha1.MongoDBStorage {
+			pods = pods[:1]
+		}
+
+		job = snapshotJobNew(snapshot, cr, pods)
+		if err = ctrl.SetControllerReference(snapshot, job, r.Scheme); err != nil {
+			logger.Error(err, messageFailedSetOwnerRef)
+			return ctrl.Result{}, err
+		}
+		if err = r.Create(ctx, job); err != nil {
+			logger.Error(err, messageFailedCreate)
+			return ctrl.Result{RequeueAfter: archiveRetryTime}, err
+		}
+		jobCreate.Inc()
+		snapshot.Status.Job = job.Name
+		setCompletedCondition(&snapshot.Status.Conditions, snapshot.Generation, mailhogv1alpha1.InProgressReason,
+			"exporting messages of "+strconv.Itoa(len(pods))+" pods")
+		return ctrl.Result{}, nil
+	}
+
+	succeeded, failed := archiveJobState(job)
+	switch {
+	case succeeded:
+		result, err := archiveJobResult(ctx, r, job)
+		if err != nil {
+			logger.Error(err, failedSnapshotExport)
+		}
+		r.completeSnapshot(snapshot, result)
+	case failed:
+		r.Recorder.Event(snapshot, corev1.EventTypeWarning, "SnapshotFailed", errArchiveJobFailed.Error())
+		setCompletedCondition(&snapshot.Status.Conditions, snapshot.Generation, mailhogv1alpha1.FailedReason, errArchiveJobFailed.Error())
+	default:
+		setCompletedCondition(&snapshot.Status.Conditions, snapshot.Generation, mailhogv1alpha1.InProgressReason, "waiting for job "+job.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// completeSnapshot records a successful export
+func (r *MailhogSnapshotReconciler) completeSnapshot(snapshot *mailhogv1alpha1.MailhogSnapshot, result archive.Result) {
+	now := metav1.Now()
+	snapshot.Status.CompletionTime = &now
+	snapshot.Status.Messages = result.Messages
+	snapshot.Status.Size = result.Bytes
+	message := "exported " + strconv.Itoa(result.Messages) + " messages of " + snapshot.Spec.Instance
+	setCompletedCondition(&snapshot.Status.Conditions, snapshot.Generation, mailhogv1alpha1.SucceededReason, message)
+	snapshotMessages.Add(float64(result.Messages))
+	r.Recorder.Event(snapshot, corev1.EventTypeNormal, "SnapshotCompleted", message)
+}
+
+// snapshotConfigMapNew returns the ConfigMap containing the archive files of a snapshot
+func snapshotConfigMapNew(snapshot *mailhogv1alpha1.MailhogSnapshot, files map[string][]byte) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshot.Name + snapshotSuffix,
+			Namespace: snapshot.Namespace,
+			Labels:    archiveLabels(snapshotTypeValue, snapshot.Name),
+		},
+		BinaryData: files,
+	}
+}
+
+// snapshotJobNew returns the Job exporting the messages of the given pods into the snapshot's PVC
+func snapshotJobNew(snapshot *mailhogv1alpha1.MailhogSnapshot, cr *mailhogv1alpha1.MailhogInstance, pods []corev1.Pod) *batchv1.Job {
+	cfg := archive.Config{
+		Mode:   archive.ExportMode,
+		Format: archiveFormat(snapshot),
+		Path:   archivePath(snapshot),
+	}
+	for i := range pods {
+		cfg.Apis = append(cfg.Apis, mailhogApiUrl(cr, &pods[i]))
+	}
+	if probeUserNeeded(cr) {
+		cfg.User = probeUserName
+	}
+
+	objectMeta := metav1.ObjectMeta{
+		Name:      snapshot.Name + snapshotSuffix,
+		Namespace: snapshot.Namespace,
+		Labels:    archiveLabels(snapshotTypeValue, snapshot.Name),
+	}
+	return archiveJobNew(objectMeta, sidecarImage(cr), cfg, snapshot.Spec.PersistentVolumeClaim.ClaimName, false, archivePasswordEnv(cr))
+}
+
+// SetupWithManager sets up this controller with the Manager
+func (r *MailhogSnapshotReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mailhogv1alpha1.MailhogSnapshot{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("MailhogSnapshot and MailhogRestore controllers", func() {
+	const (
+		ns    = "default"
+		image = "test/test:latest"
+	)
+	instanceName := types.NamespacedName{Name: "tester", Namespace: ns}
+	snapshotName := types.NamespacedName{Name: "tester-snap", Namespace: ns}
+	restoreName := types.NamespacedName{Name: "tester-restore", Namespace: ns}
+
+	getTestingSnapshot := func() *mailhogv1alpha1.MailhogSnapshot {
+		return &mailhogv1alpha1.MailhogSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: snapshotName.Name, Namespace: ns, UID: "snapshot-uid"},
+			Spec: mailhogv1alpha1.MailhogSnapshotSpec{
+				Instance: instanceName.Name,
+				Format:   mailhogv1alpha1.MboxSnapshotFormat,
+			},
+		}
+	}
+
+	Context("snapshot into a ConfigMap and restore it", func() {
+		It("should export all messages and replay them to the target instance", func() {
+			received := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+			api := startFakeMailhogApi([]mailhogMessage{
+				{ID: "b@mailhog.example", Created: received.Add(time.Minute)},
+				{ID: "a@mailhog.example", Created: received},
+			})
+			defer api.close()
+			api.raw["a@mailhog.example"] = "From: a@sender.example\r\nTo: a@receiver.example\r\nSubject: a\r\n\r\nFrom the start\r\n"
+			api.raw["b@mailhog.example"] = "From: b@sender.example\r\nTo: b@receiver.example\r\nSubject: b\r\n\r\nhello b\r\n"
+
+			smtpServer := startFakeSmtpServer()
+			defer smtpServer.close()
+			originalAddress := mailhogSmtpAddress
+			mailhogSmtpAddress = func(*mailhogv1alpha1.MailhogInstance, *corev1.Pod) string { return smtpServer.address }
+			defer func() { mailhogSmtpAddress = originalAddress }()
+
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			restore := &mailhogv1alpha1.MailhogRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: restoreName.Name, Namespace: ns, UID: "restore-uid"},
+				Spec: mailhogv1alpha1.MailhogRestoreSpec{
+					Snapshot: snapshotName.Name,
+					Instance: instanceName.Name,
+				},
+			}
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod"), getTestingSnapshot(), restore,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			sr := &MailhogSnapshotReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := sr.Reconcile(ctx, reconcile.Request{NamespacedName: snapshotName})
+			Expect(err).ToNot(HaveOccurred())
+
+			snapshot := &mailhogv1alpha1.MailhogSnapshot{}
+			Expect(k8sClient.Get(ctx, snapshotName, snapshot)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(snapshot.Status.Conditions, mailhogv1alpha1.CompletedCondition)).To(BeTrue())
+			Expect(snapshot.Status.Messages).To(Equal(2))
+			Expect(snapshot.Status.ConfigMap).To(Equal(snapshotName.Name + snapshotSuffix))
+
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: snapshot.Status.ConfigMap, Namespace: ns}, configMap)).To(Succeed())
+			Expect(configMap.OwnerReferences).To(HaveLen(1))
+			Expect(configMap.OwnerReferences[0].UID).To(Equal(snapshot.UID))
+			Expect(string(configMap.BinaryData[archive.MboxFileName])).To(ContainSubstring("\n>From the start"))
+
+			rr := &MailhogRestoreReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err = rr.Reconcile(ctx, reconcile.Request{NamespacedName: restoreName})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(smtpServer.received()).To(HaveLen(2))
+			Expect(smtpServer.received()[0].to).To(ConsistOf("a@receiver.example"))
+			Expect(smtpServer.received()[0].data).To(ContainSubstring("\nFrom the start"))
+			Expect(smtpServer.received()[0].data).ToNot(ContainSubstring(">From"))
+
+			updatedRestore := &mailhogv1alpha1.MailhogRestore{}
+			Expect(k8sClient.Get(ctx, restoreName, updatedRestore)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(updatedRestore.Status.Conditions, mailhogv1alpha1.CompletedCondition)).To(BeTrue())
+			Expect(updatedRestore.Status.Messages).To(Equal(2))
+			Expect(updatedRestore.Status.Pods).To(ConsistOf("tester-pod"))
+			Expect(updatedRestore.OwnerReferences).To(HaveLen(1))
+			Expect(updatedRestore.OwnerReferences[0].UID).To(Equal(cr.UID))
+
+			// finished restores are not replayed again
+			_, err = rr.Reconcile(ctx, reconcile.Request{NamespacedName: restoreName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(smtpServer.received()).To(HaveLen(2))
+		})
+
+		It("should wait for the snapshot before restoring", func() {
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.UID = "tester-uid"
+			restore := &mailhogv1alpha1.MailhogRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: restoreName.Name, Namespace: ns},
+				Spec: mailhogv1alpha1.MailhogRestoreSpec{
+					Snapshot: snapshotName.Name,
+					Instance: instanceName.Name,
+				},
+			}
+			objects := []client.Object{
+				cr, getTestingSnapshot(), restore,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			rr := &MailhogRestoreReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			res, err := rr.Reconcile(ctx, reconcile.Request{NamespacedName: restoreName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RequeueAfter).To(Equal(archiveRetryTime))
+
+			updatedRestore := &mailhogv1alpha1.MailhogRestore{}
+			Expect(k8sClient.Get(ctx, restoreName, updatedRestore)).To(Succeed())
+			condition := meta.FindStatusCondition(updatedRestore.Status.Conditions, mailhogv1alpha1.CompletedCondition)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Reason).To(Equal(mailhogv1alpha1.InProgressReason))
+			Expect(condition.Message).To(Equal(errSnapshotPending.Error()))
+		})
+	})
+
+	Context("snapshot into a PersistentVolumeClaim", func() {
+		It("should export with a job and record its result", func() {
+			cr := getTestingCr(instanceName, image, mailhogv1alpha1.NoTrafficInlet)
+			cr.Spec.Settings.Files = &mailhogv1alpha1.MailhogFilesSpec{
+				WebUsers: []mailhogv1alpha1.MailhogWebUserSpec{{Name: "user", PasswordHash: "hash"}},
+			}
+			snapshot := getTestingSnapshot()
+			snapshot.Spec.PersistentVolumeClaim = &mailhogv1alpha1.SnapshotVolumeSpec{ClaimName: "archive", Path: "snapshots/one"}
+			claim := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "archive", Namespace: ns}}
+			objects := []client.Object{
+				cr, getTestingPod(cr, "tester-pod"), snapshot, claim,
+			}
+			k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			sr := &MailhogSnapshotReconciler{Client: k8sClient, Scheme: scheme, Recorder: recorder}
+			_, err := sr.Reconcile(ctx, reconcile.Request{NamespacedName: snapshotName})
+			Expect(err).ToNot(HaveOccurred())
+
+			job := &batchv1.Job{}
+			jobName := types.NamespacedName{Name: snapshotName.Name + snapshotSuffix, Namespace: ns}
+			Expect(k8sClient.Get(ctx, jobName, job)).To(Succeed())
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal(defaultSidecarImage))
+			Expect(container.Env[1].ValueFrom.SecretKeyRef.Name).To(Equal(generatedSecretName(cr)))
+			cfg := archive.Config{}
+			Expect(json.Unmarshal([]byte(container.Env[0].Value), &cfg)).To(Succeed())
+			Expect(cfg.Mode).To(Equal(archive.ExportMode))
+			Expect(cfg.Path).To(Equal(archiveMount + "/snapshots/one"))
+			Expect(cfg.Apis).To(ConsistOf("http://10.0.0.1:8025"))
+			Expect(cfg.User).To(Equal(probeUserName))
+			Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("archive"))
+			Expect(job.Spec.Template.Labels).ToNot(HaveKey(crNameLabel))
+
+			updatedSnapshot := &mailhogv1alpha1.MailhogSnapshot{}
+			Expect(k8sClient.Get(ctx, snapshotName, updatedSnapshot)).To(Succeed())
+			Expect(meta.FindStatusCondition(updatedSnapshot.Status.Conditions, mailhogv1alpha1.CompletedCondition).Reason).To(Equal(mailhogv1alpha1.InProgressReason))
+
+			job.Status.Succeeded = 1
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+			jobPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "archive-pod", Namespace: ns, Labels: map[string]string{jobNameLabel: job.Name}},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name: archiveJobContainer,
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: 0,
+							Message:  `{"messages":42,"bytes":4200}`,
+						}},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, jobPod)).To(Succeed())
+
+			_, err = sr.Reconcile(ctx, reconcile.Request{NamespacedName: snapshotName})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, snapshotName, updatedSnapshot)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(updatedSnapshot.Status.Conditions, mailhogv1alpha1.CompletedCondition)).To(BeTrue())
+			Expect(updatedSnapshot.Status.Messages).To(Equal(42))
+			Expect(updatedSnapshot.Status.Size).To(Equal(int64(4200)))
+		})
+	})
+})
@@ -3,11 +3,13 @@ package controllers
 import (
 	"context"
 	"reflect"
+	"time"
 
 	"github.com/go-logr/logr"
 	routev1 "github.com/openshift/api/route/v1"
 	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -28,11 +30,14 @@ func ensureStatus(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhog
 			logger.Error(err, failedCrRefresh)
 			return err
 		}
+		// the chaos window is owned by the experiment controller, it may have changed since the cr was read
+		desiredStatus.Chaos = update.Status.Chaos
 		update.Status = desiredStatus
 		if err := r.Status().Update(ctx, update); err != nil {
 			logger.Error(err, failedCrUpdateStatus)
 			return err
 		}
+		cr.Status = desiredStatus
 		logger.Info(updatedCrStatus)
 		crUpdate.Inc()
 	} else {
@@ -56,13 +61,16 @@ func getPodNames(pods []corev1.Pod) []string {
 //nolint:gocritic
 func getPodStates(pods []corev1.Pod) (states mailhogv1alpha1.PodStatus) {
 	for _, pod := range pods {
+		status := mailhogContainerStatus(&pod)
 		if pod.Status.Phase == corev1.PodPending {
 			states.Pending = append(states.Pending, pod.Name)
 		} else if pod.Status.Phase == corev1.PodFailed {
 			states.Failed = append(states.Failed, pod.Name)
-		} else if pod.Status.ContainerStatuses[0].RestartCount > 3 {
+		} else if status == nil {
+			states.Other = append(states.Other, pod.Name)
+		} else if status.RestartCount > 3 {
 			states.Restarting = append(states.Restarting, pod.Name)
-		} else if pod.Status.ContainerStatuses[0].Ready {
+		} else if status.Ready {
 			states.Ready = append(states.Ready, pod.Name)
 		} else {
 			states.Other = append(states.Other, pod.Name)
@@ -75,7 +83,7 @@ func getPodStates(pods []corev1.Pod) (states mailhogv1alpha1.PodStatus) {
 func getReadyPods(pods []corev1.Pod) int {
 	ready := 0
 	for _, pod := range pods {
-		if pod.Status.ContainerStatuses[0].Ready {
+		if status := mailhogContainerStatus(&pod); status != nil && status.Ready {
 			ready++
 		}
 	}
@@ -129,5 +137,78 @@ func (r *MailhogInstanceReconciler) desiredStatus(ctx context.Context, cr *mailh
 	status.ReadyPodCount = getReadyPods(podList.Items)
 	status.LabelSelector = meta.GetSelector()
 	status.Error = ""
+	// purge, seed, preserve, upload, smtp verification and upstream check results are recorded by their ensure steps
+	status.Retention = cr.Status.Retention
+	status.Seed = cr.Status.Seed
+	status.Preserve = cr.Status.Preserve
+	status.Archive = cr.Status.Archive
+	status.SmtpVerification = cr.Status.SmtpVerification
+	status.Upstreams = cr.Status.Upstreams
+	status.Chaos = cr.Status.Chaos
+	status.Conditions = cr.Status.Conditions
+	status.Jim = jimStatus(cr)
+
+	// the pods are only asked once per refresh interval, without pods the last known values are kept
+	status.LastPodRefreshTime = cr.Status.LastPodRefreshTime
+	var refreshPods []corev1.Pod
+	if now := time.Now(); podRefreshDue(cr, now) && len(reachablePods(podList.Items)) > 0 {
+		refreshPods = podList.Items
+		// status values are stored with second precision, compare the same way to avoid needless updates
+		refreshTime := metav1.NewTime(now.Truncate(time.Second))
+		status.LastPodRefreshTime = &refreshTime
+	}
+	status.Messages = r.messagesStatus(ctx, cr, refreshPods, logger)
+	status.SmtpFaults = r.smtpFaultsStatus(ctx, cr, refreshPods, logger)
+	status.SmtpPolicyViolations = r.smtpPolicyStatus(ctx, cr, refreshPods, logger)
+	status.SmtpRelay = r.smtpRelayStatus(ctx, cr, refreshPods, logger)
 	return nil, status
 }
+
+// podRefreshDue returns true if the pods were not asked for their counters within the refresh interval
+func podRefreshDue(cr *mailhogv1alpha1.MailhogInstance, now time.Time) bool {
+	last := cr.Status.LastPodRefreshTime
+	return last == nil || !now.Before(last.Add(messagesStatusInterval))
+}
+
+// messagesStatus asks the api of every reachable pod for its message count and newest message
+// memory and maildir storage are separate per pod, mongodb storage is shared so one pod is enough
+// if no pod answers, the last known values are kept
+func (r *MailhogInstanceReconciler) messagesStatus(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, pods []corev1.Pod, logger logr.Logger) *mailhogv1alpha1.MessagesStatus {
+	pods = reachablePods(pods)
+	if cr.Spec.Settings.Storage == mailhogv1alpha1.MongoDBStorage && len(pods) > 1 {
+		pods = pods[:1]
+	}
+
+	var status *mailhogv1alpha1.MessagesStatus
+	for i := range pods {
+		api, err := mailhogApiFor(ctx, r, cr, &pods[i])
+		if err != nil {
+			logger.Error(err, failedMessagesStatus)
+			continue
+		}
+		podCtx, cancel := context.WithTimeout(ctx, podRequestTimeout)
+		page, err := api.messages(podCtx, 0, 1)
+		cancel()
+		if err != nil {
+			logger.Error(err, failedMessagesStatus, "pod", pods[i].Name)
+			continue
+		}
+
+		if status == nil {
+			status = &mailhogv1alpha1.MessagesStatus{}
+		}
+		status.Total += page.Total
+		if len(page.Items) > 0 {
+			// status values are stored with second precision, compare the same way to avoid needless updates
+			received := metav1.NewTime(page.Items[0].Created.Local().Truncate(time.Second))
+			if status.LastReceived == nil || received.After(status.LastReceived.Time) {
+				status.LastReceived = &received
+			}
+		}
+	}
+
+	if status == nil {
+		return cr.Status.Messages
+	}
+	return status
+}
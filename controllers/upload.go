@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/archive"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ensureArchiveUpload uploads the messages captured since the last run to the object storage once per interval
+func ensureArchiveUpload(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanUpload)
+
+	if cr.Spec.Settings.Archive == nil {
+		if cr.Status.Archive != nil {
+			cr.Status.Archive = nil
+			if err = r.Status().Update(ctx, cr); err != nil {
+				logger.Error(err, failedCrUpdateStatus)
+				return err
+			}
+		}
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	now := time.Now()
+	if !archiveUploadDue(cr, now) {
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	status := &mailhogv1alpha1.ArchiveStatus{}
+	if cr.Status.Archive != nil {
+		status = cr.Status.Archive.DeepCopy()
+	}
+	uploaded, uploadErr := r.uploadMessages(ctx, cr, status, now)
+	archiveUploaded.Add(float64(uploaded))
+	if uploadErr != nil {
+		logger.Error(uploadErr, failedArchiveUpload)
+		r.Recorder.Event(cr, corev1.EventTypeWarning, "ArchiveFailed", uploadErr.Error())
+	}
+	if uploaded > 0 {
+		r.Recorder.Event(cr, corev1.EventTypeNormal, "Archived", "uploaded "+strconv.FormatInt(uploaded, 10)+" messages to bucket "+cr.Spec.Settings.Archive.Bucket)
+	}
+
+	uploadTime := metav1.NewTime(now)
+	status.LastUploadTime = &uploadTime
+	status.LastUploaded = uploaded
+	status.TotalUploaded += uploaded
+	status.Error = ""
+	if uploadErr != nil {
+		status.Error = uploadErr.Error()
+	}
+	cr.Status.Archive = status
+	if err = r.Status().Update(ctx, cr); err != nil {
+		logger.Error(err, failedCrUpdateStatus)
+		return err
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// archiveUploadInterval returns the time between two uploads
+func archiveUploadInterval(cr *mailhogv1alpha1.MailhogInstance) time.Duration {
+	if spec := cr.Spec.Settings.Archive; spec != nil && spec.Interval != nil && spec.Interval.Duration > 0 {
+		return spec.Interval.Duration
+	}
+	return defaultArchiveUploadInterval
+}
+
+// archiveUploadDue returns true if the last upload is at least one interval ago or left messages for the next run
+func archiveUploadDue(cr *mailhogv1alpha1.MailhogInstance, now time.Time) bool {
+	status := cr.Status.Archive
+	if status == nil || status.LastUploadTime == nil || status.Pending {
+		return true
+	}
+	return !now.Before(status.LastUploadTime.Add(archiveUploadInterval(cr)))
+}
+
+// uploadMessages uploads the messages newer than the cursor of their pod oldest first and advances the cursor after
+// each object, so a failed run continues with the first message that was not uploaded. Pods sharing their storage
+// have one cursor. A run uploads at most archiveUploadBatchSize messages, the uploaded ones are listed in an index
+func (r *MailhogInstanceReconciler) uploadMessages(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, status *mailhogv1alpha1.ArchiveStatus, now time.Time) (uploaded int64, err error) {
+	spec := cr.Spec.Settings.Archive
+	bucket, err := r.archiveBucket(ctx, cr)
+	if err != nil {
+		return 0, err
+	}
+
+	podList := &corev1.PodList{}
+	if err = r.List(ctx, podList, client.InNamespace(cr.Namespace), client.MatchingLabels(CreateMetaMaker(cr).GetLabels())); err != nil {
+		return 0, err
+	}
+	// the cursors of pods that are gone are dropped, a pod that is only unreachable keeps its cursor for the next run
+	sources := make([]string, 0, len(podList.Items))
+	for _, pod := range podList.Items {
+		sources = append(sources, archiveSource(cr, &pod))
+	}
+	pruneArchiveCursors(status, sources)
+
+	pods := reachablePods(podList.Items)
+	if sharedStorage(cr) && len(pods) > 1 {
+		pods = pods[:1]
+	}
+	status.Pending = false
+	entries := []archive.IndexEntry{}
+	for i := range pods {
+		remaining := archiveUploadBatchSize - len(entries)
+		if remaining == 0 {
+			status.Pending = true
+			break
+		}
+		var more bool
+		if more, err = r.uploadPodMessages(ctx, cr, &pods[i], bucket, status, remaining, &entries); err != nil {
+			break
+		}
+		status.Pending = status.Pending || more
+	}
+	if len(entries) == 0 {
+		return 0, err
+	}
+
+	indexKey := spec.Prefix + "index/" + now.UTC().Format(archiveIndexTimeFormat) + ".json"
+	index, indexErr := archive.EncodeIndex(entries)
+	if indexErr == nil {
+		indexErr = bucket.Put(ctx, httpClient, indexKey, archive.IndexContentType, index)
+	}
+	if indexErr == nil {
+		status.LastIndex = indexKey
+	} else if err == nil {
+		err = indexErr
+	}
+	return int64(len(entries)), err
+}
+
+// uploadPodMessages uploads at most limit messages of a pod that are newer than its cursor and adds them to the entries
+// more is true if the pod has more messages than the limit
+func (r *MailhogInstanceReconciler) uploadPodMessages(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, pod *corev1.Pod, bucket archive.Bucket, status *mailhogv1alpha1.ArchiveStatus, limit int, entries *[]archive.IndexEntry) (more bool, err error) {
+	api, err := mailhogApiFor(ctx, r, cr, pod)
+	if err != nil {
+		return false, err
+	}
+	cursor := archiveCursorFor(status, archiveSource(cr, pod))
+	// the messages at the cursor time are fetched again, the ids of the uploaded ones are skipped
+	after, _ := time.Parse(time.RFC3339Nano, cursor.Cursor)
+	messages, more, err := archive.FetchAfter(ctx, httpClient, api.baseUrl, api.user, api.password, after, limit+len(cursor.CursorIDs))
+	if err != nil {
+		return false, err
+	}
+
+	pending := messagesAfterCursor(messages, cursor)
+	if len(pending) > limit {
+		pending, more = pending[:limit], true
+	}
+	for _, message := range pending {
+		key := archiveObjectKey(cr.Spec.Settings.Archive.Prefix, message)
+		if err = bucket.Put(ctx, httpClient, key, archive.EmlContentType, message.Data); err != nil {
+			return more, err
+		}
+		*entries = append(*entries, archive.NewIndexEntry(key, message))
+		advanceArchiveCursor(cursor, message)
+	}
+	return more, nil
+}
+
+// archiveBucket returns the bucket of the CRs archive settings with the credentials of its Secret
+func (r *MailhogInstanceReconciler) archiveBucket(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) (archive.Bucket, error) {
+	spec := cr.Spec.Settings.Archive
+	bucket := archive.Bucket{Endpoint: spec.Endpoint, Region: spec.Region, Name: spec.Bucket}
+	if bucket.Region == "" {
+		bucket.Region = defaultArchiveRegion
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: spec.CredentialsSecret, Namespace: cr.Namespace}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return bucket, errMissingArchiveCredentials
+		}
+		return bucket, err
+	}
+	bucket.AccessKeyID = string(secret.Data[archiveAccessKeyIdKey])
+	bucket.SecretAccessKey = string(secret.Data[archiveSecretAccessKeyKey])
+	if bucket.AccessKeyID == "" || bucket.SecretAccessKey == "" {
+		return bucket, errMissingArchiveCredentials
+	}
+	return bucket, nil
+}
+
+// archiveSource returns the name of the cursor of a pod, pods sharing their storage have the same one
+func archiveSource(cr *mailhogv1alpha1.MailhogInstance, pod *corev1.Pod) string {
+	if sharedStorage(cr) {
+		return string(cr.Spec.Settings.Storage)
+	}
+	return pod.Name
+}
+
+// archiveCursorFor returns the cursor of a source, a new one is added to the status if the source has none yet
+func archiveCursorFor(status *mailhogv1alpha1.ArchiveStatus, source string) *mailhogv1alpha1.ArchiveCursor {
+	for i := range status.Cursors {
+		if status.Cursors[i].Source == source {
+			return &status.Cursors[i]
+		}
+	}
+	status.Cursors = append(status.Cursors, mailhogv1alpha1.ArchiveCursor{Source: source})
+	return &status.Cursors[len(status.Cursors)-1]
+}
+
+// pruneArchiveCursors removes the cursors of sources that do not exist anymore
+func pruneArchiveCursors(status *mailhogv1alpha1.ArchiveStatus, sources []string) {
+	cursors := status.Cursors[:0]
+	for _, cursor := range status.Cursors {
+		if containsString(sources, cursor.Source) {
+			cursors = append(cursors, cursor)
+		}
+	}
+	status.Cursors = cursors
+}
+
+// messagesAfterCursor returns the messages that were not uploaded yet
+func messagesAfterCursor(messages []archive.Message, cursor *mailhogv1alpha1.ArchiveCursor) (pending []archive.Message) {
+	after, err := time.Parse(time.RFC3339Nano, cursor.Cursor)
+	if err != nil {
+		return messages
+	}
+	for _, message := range messages {
+		if message.Created.After(after) || (message.Created.Equal(after) && !containsString(cursor.CursorIDs, message.ID)) {
+			pending = append(pending, message)
+		}
+	}
+	return pending
+}
+
+// advanceArchiveCursor moves the cursor to an uploaded message, messages are uploaded oldest first
+func advanceArchiveCursor(cursor *mailhogv1alpha1.ArchiveCursor, message archive.Message) {
+	created := message.Created.UTC().Format(time.RFC3339Nano)
+	if cursor.Cursor != created {
+		cursor.Cursor = created
+		cursor.CursorIDs = nil
+	}
+	cursor.CursorIDs = append(cursor.CursorIDs, message.ID)
+}
+
+// archiveObjectKey returns the key of an uploaded message, grouped by the day it was received
+func archiveObjectKey(prefix string, message archive.Message) string {
+	id := strings.Map(func(c rune) rune {
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.ContainsRune("@._-", c) {
+			return c
+		}
+		return '_'
+	}, message.ID)
+	return prefix + message.Created.UTC().Format("2006/01/02/") + id + ".eml"
+}
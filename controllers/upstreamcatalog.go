@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// catalogUpstream is a resolved MailhogUpstream or ClusterMailhogUpstream
+type catalogUpstream struct {
+	kind mailhogv1alpha1.UpstreamKind
+	meta metav1.ObjectMeta
+	spec mailhogv1alpha1.MailhogCatalogUpstreamSpec
+}
+
+// catalogUpstreams returns the catalog entries referenced by the cr as smtp upstreams, named after their objects
+// an object selected by several references is only returned once, names must not collide with the inline upstreams
+func (r *MailhogInstanceReconciler) catalogUpstreams(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance) ([]mailhogv1alpha1.MailhogUpstreamSpec, error) {
+	files := cr.Spec.Settings.Files
+	if files == nil || len(files.UpstreamRefs) == 0 {
+		return nil, nil
+	}
+
+	names := map[string]string{}
+	for _, upstream := range files.SmtpUpstreams {
+		names[upstream.Name] = "smtpUpstreams"
+	}
+
+	upstreams := []mailhogv1alpha1.MailhogUpstreamSpec{}
+	for _, ref := range files.UpstreamRefs {
+		entries, err := r.referencedUpstreams(ctx, cr, ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			source := string(entry.kind) + "/" + entry.meta.Name
+			if owner, found := names[entry.meta.Name]; found {
+				if owner == source {
+					continue
+				}
+				return nil, fmt.Errorf("%w: %s and %s", errUpstreamNameConflict, owner, source)
+			}
+			names[entry.meta.Name] = source
+
+			upstream, err := r.resolveCatalogUpstream(ctx, entry)
+			if err != nil {
+				return nil, err
+			}
+			upstreams = append(upstreams, upstream)
+		}
+	}
+	return upstreams, nil
+}
+
+// referencedUpstreams returns the catalog entries a single reference points to
+func (r *MailhogInstanceReconciler) referencedUpstreams(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, ref mailhogv1alpha1.MailhogUpstreamRefSpec) (entries []catalogUpstream, err error) {
+	kind := upstreamRefKind(ref)
+
+	if ref.Name != "" {
+		entry := catalogUpstream{kind: kind}
+		if kind == mailhogv1alpha1.ClusterUpstreamKind {
+			upstream := &mailhogv1alpha1.ClusterMailhogUpstream{}
+			err = r.Get(ctx, types.NamespacedName{Name: ref.Name}, upstream)
+			entry.meta, entry.spec = upstream.ObjectMeta, upstream.Spec
+		} else {
+			upstream := &mailhogv1alpha1.MailhogUpstream{}
+			err = r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: cr.Namespace}, upstream)
+			entry.meta, entry.spec = upstream.ObjectMeta, upstream.Spec
+		}
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil, fmt.Errorf("%w: %s %s", errMissingCatalogUpstream, kind, ref.Name)
+			}
+			return nil, err
+		}
+		return []catalogUpstream{entry}, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(ref.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidUpstreamRef, err)
+	}
+	if kind == mailhogv1alpha1.ClusterUpstreamKind {
+		list := &mailhogv1alpha1.ClusterMailhogUpstreamList{}
+		if err = r.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, err
+		}
+		for _, upstream := range list.Items {
+			entries = append(entries, catalogUpstream{kind: kind, meta: upstream.ObjectMeta, spec: upstream.Spec})
+		}
+	} else {
+		list := &mailhogv1alpha1.MailhogUpstreamList{}
+		if err = r.List(ctx, list, client.InNamespace(cr.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, err
+		}
+		for _, upstream := range list.Items {
+			entries = append(entries, catalogUpstream{kind: kind, meta: upstream.ObjectMeta, spec: upstream.Spec})
+		}
+	}
+	return entries, nil
+}
+
+// resolveCatalogUpstream returns the upstream spec of a catalog entry including the credentials of its Secret
+func (r *MailhogInstanceReconciler) resolveCatalogUpstream(ctx context.Context, entry catalogUpstream) (mailhogv1alpha1.MailhogUpstreamSpec, error) {
+	upstream := mailhogv1alpha1.MailhogUpstreamSpec{
+		Name:      entry.meta.Name,
+		Host:      entry.spec.Host,
+		Port:      entry.spec.Port,
+		Email:     entry.spec.Email,
+		Mechanism: entry.spec.Mechanism,
+	}
+
+	ref := entry.spec.CredentialsSecret
+	if ref == nil {
+		return upstream, nil
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = entry.meta.Namespace
+	}
+	// only a cluster scoped entry may read credentials of another namespace, otherwise anyone allowed to create a
+	// MailhogUpstream could have the operator copy Secrets of foreign namespaces into their instances
+	if entry.kind == mailhogv1alpha1.NamespacedUpstreamKind && namespace != entry.meta.Namespace {
+		return upstream, fmt.Errorf("%w: %s %s", errForeignCatalogCredentials, entry.kind, entry.meta.Name)
+	}
+	if ref.Name == "" || namespace == "" {
+		return upstream, fmt.Errorf("%w: %s %s", errMissingCatalogCredentials, entry.kind, entry.meta.Name)
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return upstream, fmt.Errorf("%w: %s %s", errMissingCatalogCredentials, entry.kind, entry.meta.Name)
+		}
+		return upstream, err
+	}
+	upstream.Username = string(secret.Data[corev1.BasicAuthUsernameKey])
+	upstream.Password = string(secret.Data[corev1.BasicAuthPasswordKey])
+	if upstream.Username == "" || upstream.Password == "" {
+		return upstream, fmt.Errorf("%w: %s %s", errMissingCatalogCredentials, entry.kind, entry.meta.Name)
+	}
+	if upstream.Mechanism == "" {
+		return upstream, fmt.Errorf("%w: %s %s", errMissingUpstreamSmtpMechanism, entry.kind, entry.meta.Name)
+	}
+	return upstream, nil
+}
+
+// upstreamRefKind returns the kind of a reference, MailhogUpstream if none is set
+func upstreamRefKind(ref mailhogv1alpha1.MailhogUpstreamRefSpec) mailhogv1alpha1.UpstreamKind {
+	if ref.Kind == "" {
+		return mailhogv1alpha1.NamespacedUpstreamKind
+	}
+	return ref.Kind
+}
+
+// smtpUpstreamsNeeded returns true if the cr has inline smtp upstreams, references catalog entries or an upstreams file
+func smtpUpstreamsNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	files := cr.Spec.Settings.Files
+	return files != nil && (len(files.SmtpUpstreams) > 0 || len(files.UpstreamRefs) > 0 || files.UpstreamsFrom != nil)
+}
+
+// referencesUpstream returns true if one of the cr's references points to the catalog entry
+func referencesUpstream(cr *mailhogv1alpha1.MailhogInstance, kind mailhogv1alpha1.UpstreamKind, upstream client.Object) bool {
+	if cr.Spec.Settings.Files == nil {
+		return false
+	}
+	for _, ref := range cr.Spec.Settings.Files.UpstreamRefs {
+		if upstreamRefKind(ref) != kind {
+			continue
+		}
+		if ref.Name != "" {
+			if ref.Name == upstream.GetName() {
+				return true
+			}
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(ref.Selector)
+		if err == nil && selector.Matches(labels.Set(upstream.GetLabels())) {
+			return true
+		}
+	}
+	return false
+}
+
+// findObjectsForUpstream is mapper to find which CRs need to be reconciled when a MailhogUpstream is changed
+func (r *MailhogInstanceReconciler) findObjectsForUpstream(watchedUpstream client.Object) []reconcile.Request {
+	return r.findObjectsReferencingUpstream(mailhogv1alpha1.NamespacedUpstreamKind, watchedUpstream, client.InNamespace(watchedUpstream.GetNamespace()))
+}
+
+// findObjectsForClusterUpstream is mapper to find which CRs need to be reconciled when a ClusterMailhogUpstream is changed
+func (r *MailhogInstanceReconciler) findObjectsForClusterUpstream(watchedUpstream client.Object) []reconcile.Request {
+	return r.findObjectsReferencingUpstream(mailhogv1alpha1.ClusterUpstreamKind, watchedUpstream)
+}
+
+// findObjectsReferencingUpstream returns a request for every CR referencing the catalog entry
+// deleted entries are matched by their last known labels, so their instances report the missing entry
+func (r *MailhogInstanceReconciler) findObjectsReferencingUpstream(kind mailhogv1alpha1.UpstreamKind, upstream client.Object, opts ...client.ListOption) []reconcile.Request {
+	requests := make([]reconcile.Request, 0)
+
+	list := &mailhogv1alpha1.MailhogInstanceList{}
+	if err := r.List(context.TODO(), list, opts...); err != nil {
+		return requests
+	}
+	for _, cr := range list.Items {
+		if referencesUpstream(&cr, kind, upstream) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: cr.Namespace,
+					Name:      cr.Name,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// findObjectsForCatalogCredentials is mapper to find which CRs need to be reconciled when the credentials Secret of a
+// catalog entry is changed, so rotated credentials reach the upstreams file of every instance referencing the entry
+func (r *MailhogInstanceReconciler) findObjectsForCatalogCredentials(watchedSecret client.Object) []reconcile.Request {
+	requests := make([]reconcile.Request, 0)
+
+	namespaced := &mailhogv1alpha1.MailhogUpstreamList{}
+	if err := r.List(context.TODO(), namespaced, client.InNamespace(watchedSecret.GetNamespace())); err == nil {
+		for _, upstream := range namespaced.Items {
+			if usesCredentials(upstream.ObjectMeta, upstream.Spec, watchedSecret) {
+				requests = append(requests, r.findObjectsForUpstream(&upstream)...)
+			}
+		}
+	}
+
+	cluster := &mailhogv1alpha1.ClusterMailhogUpstreamList{}
+	if err := r.List(context.TODO(), cluster); err == nil {
+		for _, upstream := range cluster.Items {
+			if usesCredentials(upstream.ObjectMeta, upstream.Spec, watchedSecret) {
+				requests = append(requests, r.findObjectsForClusterUpstream(&upstream)...)
+			}
+		}
+	}
+	return requests
+}
+
+// usesCredentials returns true if the catalog entry references the Secret as its credentials
+func usesCredentials(meta metav1.ObjectMeta, spec mailhogv1alpha1.MailhogCatalogUpstreamSpec, secret client.Object) bool {
+	ref := spec.CredentialsSecret
+	if ref == nil || ref.Name != secret.GetName() {
+		return false
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = meta.Namespace
+	}
+	return namespace == secret.GetNamespace()
+}
@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/smtp"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/smtpproxy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// upstreamCheckInterval is the time between two connection checks of the smtp upstreams
+var upstreamCheckInterval = time.Duration(5) * time.Minute
+
+// upstreamCheckHelo is the name the operator greets the upstreams with, the same one mailhog uses when releasing messages
+const upstreamCheckHelo = "localhost"
+
+// ensureUpstreamsChecked periodically connects to every smtp upstream the way mailhog does when releasing a message,
+// so typos in host, port or credentials show up before someone releases a message through the UI
+// the results are recorded in status.upstreams and summarised by the UpstreamsReachable condition
+func ensureUpstreamsChecked(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) (err error) {
+	logger := r.logger.WithValues(span, spanUpstreams)
+
+	if !upstreamChecksEnabled(cr) {
+		if cr.Status.Upstreams != nil || meta.FindStatusCondition(cr.Status.Conditions, mailhogv1alpha1.UpstreamsReachableCondition) != nil {
+			cr.Status.Upstreams = nil
+			meta.RemoveStatusCondition(&cr.Status.Conditions, mailhogv1alpha1.UpstreamsReachableCondition)
+			if err = r.Status().Update(ctx, cr); err != nil {
+				logger.Error(err, failedCrUpdateStatus)
+				return err
+			}
+		}
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	upstreams, err := r.smtpUpstreams(ctx, cr)
+	if err != nil {
+		logger.Error(err, failedGetExisting)
+		return err
+	}
+
+	if !upstreamChecksDue(cr, upstreams, time.Now()) {
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	original := cr.Status.DeepCopy()
+	// status values are stored with second precision, compare the same way to avoid needless updates
+	now := metav1.NewTime(time.Now().Truncate(time.Second))
+	reason, failed := mailhogv1alpha1.ReachableReason, []string{}
+	// the upstreams are checked at the same time, so one that does not answer only delays the reconcile once
+	cr.Status.Upstreams = make([]mailhogv1alpha1.UpstreamStatus, len(upstreams))
+	var checks sync.WaitGroup
+	for i := range upstreams {
+		checks.Add(1)
+		go func(i int) {
+			defer checks.Done()
+			upstream := upstreams[i]
+			if tlsConfig, err := r.upstreamTlsConfig(ctx, cr, upstream); err != nil {
+				cr.Status.Upstreams[i] = mailhogv1alpha1.UpstreamStatus{
+					Name:    upstream.Name,
+					Address: net.JoinHostPort(upstream.Host, upstream.Port),
+					Auth:    mailhogv1alpha1.AuthSkippedResult,
+					Error:   err.Error(),
+				}
+			} else {
+				cr.Status.Upstreams[i] = checkUpstream(upstream, tlsConfig, httpClient.Timeout)
+			}
+			cr.Status.Upstreams[i].LastCheckTime = &now
+		}(i)
+	}
+	checks.Wait()
+
+	for _, status := range cr.Status.Upstreams {
+		if status.Error == "" {
+			continue
+		}
+
+		logger.Error(nil, failedUpstreamCheck, "upstream", status.Name, "address", status.Address, "error", status.Error)
+		r.Recorder.Event(cr, corev1.EventTypeWarning, "UpstreamCheckFailed", "upstream "+status.Name+" ("+status.Address+"): "+status.Error)
+		failed = append(failed, status.Name)
+		if !status.Reachable {
+			reason = mailhogv1alpha1.UnreachableReason
+		} else if reason == mailhogv1alpha1.ReachableReason {
+			reason = mailhogv1alpha1.RejectedReason
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               mailhogv1alpha1.UpstreamsReachableCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: cr.Generation,
+		Reason:             reason,
+		Message:            "all " + strconv.Itoa(len(cr.Status.Upstreams)) + " upstreams accepted the connection",
+	}
+	if len(failed) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Message = "failed upstreams: " + strings.Join(failed, ", ")
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, condition)
+
+	if !reflect.DeepEqual(*original, cr.Status) {
+		if err = r.Status().Update(ctx, cr); err != nil {
+			logger.Error(err, failedCrUpdateStatus)
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// checkUpstream connects to an upstream, greets it with EHLO, upgrades to tls if it offers STARTTLS
+// and authenticates with the upstream's mechanism if it has credentials
+// like a release through mailhog, the certificate has to be valid and PLAIN is only sent over tls or to localhost
+func checkUpstream(upstream mailhogv1alpha1.MailhogUpstreamSpec, tlsConfig *tls.Config, timeout time.Duration) mailhogv1alpha1.UpstreamStatus {
+	status := mailhogv1alpha1.UpstreamStatus{
+		Name:    upstream.Name,
+		Address: net.JoinHostPort(upstream.Host, upstream.Port),
+		Auth:    mailhogv1alpha1.AuthSkippedResult,
+	}
+
+	conn, err := net.DialTimeout("tcp", status.Address, timeout)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, upstream.Host)
+	if err != nil {
+		_ = conn.Close()
+		status.Error = err.Error()
+		return status
+	}
+	defer c.Close()
+
+	if err = c.Hello(upstreamCheckHelo); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(tlsConfig); err != nil {
+			status.Error = "starttls: " + err.Error()
+			return status
+		}
+		status.Tls = true
+	}
+
+	if upstream.Username != "" {
+		auth := smtp.PlainAuth("", upstream.Username, upstream.Password, upstream.Host)
+		if upstream.Mechanism == smtpproxy.CramMd5Mechanism {
+			auth = smtp.CRAMMD5Auth(upstream.Username, upstream.Password)
+		}
+		if err = c.Auth(auth); err != nil {
+			status.Auth = mailhogv1alpha1.AuthFailedResult
+			status.Error = "auth: " + err.Error()
+			return status
+		}
+		status.Auth = mailhogv1alpha1.AuthSucceededResult
+	}
+
+	_ = c.Quit()
+	return status
+}
+
+// upstreamTlsConfig returns the tls settings mailhog verifies an upstream with: the trusted ca bundle replaces the
+// system certificates and the upstream's own ca bundle is trusted additionally
+// the server name and minimum version of the upstream's tls spec are left out, mailhog does not know them and the
+// check reports what mailhog's releases would see
+func (r *MailhogInstanceReconciler) upstreamTlsConfig(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, upstream mailhogv1alpha1.MailhogUpstreamSpec) (*tls.Config, error) {
+	config := &tls.Config{ServerName: upstream.Host}
+	trustedCa := cr.Spec.Settings.TrustedCa
+	if trustedCa != nil || upstreamCaFile(upstream) != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || trustedCa != nil {
+			pool = x509.NewCertPool()
+		}
+		if trustedCa != nil {
+			bundle, err := r.caBundle(ctx, cr.Namespace, trustedCaRef(trustedCa), nil)
+			if err != nil {
+				return nil, err
+			}
+			pool.AppendCertsFromPEM(bundle)
+		}
+		if upstreamCaFile(upstream) != "" {
+			bundle, err := r.caBundle(ctx, cr.Namespace, upstream.Tls.CaConfigMapKeyRef, upstream.Tls.CaSecretKeyRef)
+			if err != nil {
+				return nil, err
+			}
+			pool.AppendCertsFromPEM(bundle)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// caBundle returns the PEM encoded certificates of a ConfigMap or Secret key
+func (r *MailhogInstanceReconciler) caBundle(ctx context.Context, namespace string, configMapRef *corev1.ConfigMapKeySelector, secretRef *corev1.SecretKeySelector) ([]byte, error) {
+	var bundle []byte
+	if configMapRef != nil {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: configMapRef.Name, Namespace: namespace}, configMap); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, errMissingCaBundle
+			}
+			return nil, err
+		}
+		bundle = []byte(configMap.Data[configMapRef.Key])
+	}
+	if secretRef != nil {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: secretRef.Name, Namespace: namespace}, secret); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, errMissingCaBundle
+			}
+			return nil, err
+		}
+		bundle = secret.Data[secretRef.Key]
+	}
+	if len(bundle) == 0 {
+		return nil, errMissingCaBundle
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(bundle) {
+		return nil, errInvalidCaBundle
+	}
+	return bundle, nil
+}
+
+// trustedCaRef returns the ConfigMap key of the trusted ca bundle
+func trustedCaRef(trustedCa *mailhogv1alpha1.MailhogTrustedCaSpec) *corev1.ConfigMapKeySelector {
+	key := trustedCa.Key
+	if key == "" {
+		key = trustedCaDefaultKey
+	}
+	return &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: trustedCa.ConfigMapName}, Key: key}
+}
+
+// upstreamChecksEnabled returns true if the cr has smtp upstreams and their checks are not turned off
+func upstreamChecksEnabled(cr *mailhogv1alpha1.MailhogInstance) bool {
+	return !cr.Spec.Settings.DisableUpstreamChecks && smtpUpstreamsNeeded(cr)
+}
+
+// upstreamChecksDue returns true if the upstreams changed since the last check or it was a while ago
+// catalog entries change without a new generation of the cr, so the checked addresses are compared as well
+func upstreamChecksDue(cr *mailhogv1alpha1.MailhogInstance, upstreams []mailhogv1alpha1.MailhogUpstreamSpec, now time.Time) bool {
+	condition := meta.FindStatusCondition(cr.Status.Conditions, mailhogv1alpha1.UpstreamsReachableCondition)
+	if condition == nil || condition.ObservedGeneration != cr.Generation || len(cr.Status.Upstreams) != len(upstreams) {
+		return true
+	}
+	for i, status := range cr.Status.Upstreams {
+		if status.Name != upstreams[i].Name || status.Address != net.JoinHostPort(upstreams[i].Host, upstreams[i].Port) {
+			return true
+		}
+		if status.LastCheckTime == nil || !now.Before(status.LastCheckTime.Add(upstreamCheckInterval)) {
+			return true
+		}
+	}
+	return false
+}
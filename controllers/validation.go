@@ -3,18 +3,38 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"regexp"
-	"strconv"
+	"strings"
 
+	"github.com/go-logr/logr"
 	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	"goimports.patrick.mx/mailhog-operator/smtpproxy"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var crStatusChecks = []func(*mailhogv1alpha1.MailhogInstance) error{
 	checkOverlappingMounts,
 	checkMissingSettings,
 	checkSmtpUpstreams,
-	checkJimFloats,
+	checkUpstreamTls,
+	checkUpstreamRefs,
+	checkFileSources,
+	checkJimSettings,
 	checkWebPath,
+	checkSmtpTls,
+	checkSmtpFaults,
+	checkSmtpPolicy,
+	checkSmtpRelay,
+	checkWebAuth,
+	checkReservedWebUser,
+	checkRetention,
+	checkPreserveOnRollout,
+}
+
+var crClusterChecks = []func(context.Context, *MailhogInstanceReconciler, *mailhogv1alpha1.MailhogInstance) error{
+	checkCaBundles,
+	checkReferencedSettings,
 }
 
 // ensureCrValid ensures no invalid CRs are processed
@@ -23,13 +43,13 @@ func ensureCrValid(ctx context.Context, r *MailhogInstanceReconciler, cr *mailho
 
 	for _, check := range crStatusChecks {
 		if err = check(cr); err != nil {
-			cr.Status.Error = err.Error()
-			crValidationFailure.Inc()
-			if err := r.Status().Update(ctx, cr); err != nil {
-				logger.Error(err, failedCrUpdateStatus)
-				return err
-			}
-			return err
+			return r.invalidCr(ctx, cr, logger, err)
+		}
+	}
+
+	for _, check := range crClusterChecks {
+		if err = check(ctx, r, cr); err != nil {
+			return r.invalidCr(ctx, cr, logger, err)
 		}
 	}
 
@@ -38,10 +58,21 @@ func ensureCrValid(ctx context.Context, r *MailhogInstanceReconciler, cr *mailho
 	return nil
 }
 
+// invalidCr records the validation error in the CR status
+func (r *MailhogInstanceReconciler) invalidCr(ctx context.Context, cr *mailhogv1alpha1.MailhogInstance, logger logr.Logger, validationErr error) error {
+	cr.Status.Error = validationErr.Error()
+	crValidationFailure.Inc()
+	if err := r.Status().Update(ctx, cr); err != nil {
+		logger.Error(err, failedCrUpdateStatus)
+		return err
+	}
+	return validationErr
+}
+
 // checkOverlappingMounts returns an error if a forbidden mount path is used as maildir path
 func checkOverlappingMounts(cr *mailhogv1alpha1.MailhogInstance) error {
 	if userPath := cr.Spec.Settings.StorageMaildir.Path; userPath != "" {
-		conflictPathRegex := regexp.MustCompile(`^/(usr|mailhog)?(/)?((settings)/?(files)?|(local)/?(bin)?/?(MailHog)?)?$`)
+		conflictPathRegex := regexp.MustCompile(`^/(usr|mailhog)?(/)?((settings)/?(files|from)?|(local)/?(bin)?/?(MailHog)?)?$`)
 		if matches := conflictPathRegex.MatchString(userPath); matches {
 			return errConflictingMount
 		}
@@ -82,28 +113,84 @@ func checkSmtpUpstreams(cr *mailhogv1alpha1.MailhogInstance) error {
 	return nil
 }
 
-// checkJimFloats returns an error if a jim value can not be converted to a float
-func checkJimFloats(cr *mailhogv1alpha1.MailhogInstance) error {
-	if cr.Spec.Settings.Jim.Invite == true {
-		fields := []string{
-			cr.Spec.Settings.Jim.RejectRecipient,
-			cr.Spec.Settings.Jim.RejectAuth,
-			cr.Spec.Settings.Jim.RejectSender,
-			cr.Spec.Settings.Jim.Disconnect,
-			cr.Spec.Settings.Jim.Accept,
-			cr.Spec.Settings.Jim.LinkspeedAffect,
+// checkUpstreamRefs returns an error if a catalog reference sets neither or both of name and selector
+// or its selector can not be parsed
+func checkUpstreamRefs(cr *mailhogv1alpha1.MailhogInstance) error {
+	if cr.Spec.Settings.Files == nil {
+		return nil
+	}
+	for _, ref := range cr.Spec.Settings.Files.UpstreamRefs {
+		if (ref.Name == "") == (ref.Selector == nil) {
+			return errInvalidUpstreamRef
 		}
-		for _, field := range fields {
-			if field != "" {
-				if _, err := strconv.ParseFloat(field, 64); err != nil {
-					return errJimNonFloatFound
-				}
+		if ref.Selector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(ref.Selector); err != nil {
+				return fmt.Errorf("%w: %s", errInvalidUpstreamRef, err)
 			}
 		}
 	}
 	return nil
 }
 
+// checkFileSources returns an error if a settings file source references no or more than one ConfigMap / Secret key
+func checkFileSources(cr *mailhogv1alpha1.MailhogInstance) error {
+	files := cr.Spec.Settings.Files
+	if files == nil {
+		return nil
+	}
+	for _, source := range []*mailhogv1alpha1.MailhogFileSourceSpec{files.UpstreamsFrom, files.UsersFrom} {
+		if source == nil {
+			continue
+		}
+		configMap, secret := source.ConfigMapKeyRef, source.SecretKeyRef
+		if (configMap == nil) == (secret == nil) {
+			return errInvalidFileSource
+		}
+		if (configMap != nil && (configMap.Name == "" || configMap.Key == "")) || (secret != nil && (secret.Name == "" || secret.Key == "")) {
+			return errInvalidFileSource
+		}
+	}
+	return nil
+}
+
+// checkUpstreamTls returns an error if an upstream references no or more than one ca source, an unknown tls version
+// or has a name that can not be used as file name of its ca bundle
+func checkUpstreamTls(cr *mailhogv1alpha1.MailhogInstance) error {
+	if cr.Spec.Settings.Files == nil {
+		return nil
+	}
+	for _, upstream := range cr.Spec.Settings.Files.SmtpUpstreams {
+		tlsSpec := upstream.Tls
+		if tlsSpec == nil {
+			continue
+		}
+		configMap, secret := tlsSpec.CaConfigMapKeyRef, tlsSpec.CaSecretKeyRef
+		if configMap != nil && secret != nil {
+			return errInvalidUpstreamTls
+		}
+		if (configMap != nil && (configMap.Name == "" || configMap.Key == "")) || (secret != nil && (secret.Name == "" || secret.Key == "")) {
+			return errInvalidUpstreamTls
+		}
+		if upstreamCaFile(upstream) != "" && (strings.ContainsAny(upstream.Name, `/\`) || strings.HasPrefix(upstream.Name, ".")) {
+			return errInvalidUpstreamTls
+		}
+		if _, err := smtpproxy.ParseTlsVersion(string(tlsSpec.MinVersion)); err != nil {
+			return errInvalidUpstreamTls
+		}
+	}
+	return nil
+}
+
+// checkJimSettings returns an error if a jim value can not be parsed, a probability is outside 0..1,
+// a link speed is not positive or the minimum link speed exceeds the maximum
+func checkJimSettings(cr *mailhogv1alpha1.MailhogInstance) error {
+	if jimEnabled(cr) {
+		_, err := effectiveJim(cr)
+		return err
+	}
+	return nil
+}
+
 // checkWebPath returns an error if the web path begins or ends in a slash
 func checkWebPath(cr *mailhogv1alpha1.MailhogInstance) error {
 	if path := cr.Spec.Settings.WebPath; path != "" {
@@ -114,11 +201,195 @@ func checkWebPath(cr *mailhogv1alpha1.MailhogInstance) error {
 	return nil
 }
 
+// checkSmtpTls returns an error if the tls sidecar ports conflict with each other or mailhog's own ports
+func checkSmtpTls(cr *mailhogv1alpha1.MailhogInstance) error {
+	if tlsSpec := cr.Spec.Settings.SmtpTls; tlsSpec != nil {
+		if tlsSpec.SecretName == "" {
+			return errMissingSmtpTlsSecret
+		}
+		startTlsPort, implicitTlsPort := smtpTlsPorts(cr)
+		if startTlsPort == implicitTlsPort {
+			return errSmtpTlsPortConflict
+		}
+		for _, port := range []int32{startTlsPort, implicitTlsPort} {
+			if port == portSmtp || port == portWeb {
+				return errSmtpTlsPortConflict
+			}
+			if smtpProxyIntercepts(cr) && (port == portSmtpProxy || port == portSmtpProxyAdmin || port == portSmtpOperator) {
+				return errSmtpTlsPortConflict
+			}
+		}
+	}
+	return nil
+}
+
+// checkSmtpFaults returns an error if a fault can not be applied by the smtp proxy sidecar
+func checkSmtpFaults(cr *mailhogv1alpha1.MailhogInstance) error {
+	if err := smtpproxy.ValidateRules(smtpProxyRules(cr)); err != nil {
+		return fmt.Errorf("%w: %s", errInvalidSmtpFault, err.Error())
+	}
+	return nil
+}
+
+// checkSmtpPolicy returns an error if an allowed sender domain can never match a sender
+func checkSmtpPolicy(cr *mailhogv1alpha1.MailhogInstance) error {
+	if policy := cr.Spec.Settings.SmtpPolicy; policy != nil {
+		for _, domain := range policy.AllowedSenderDomains {
+			if domain == "" || strings.ContainsAny(domain, "@<> \t") {
+				return fmt.Errorf("%w: %q", errInvalidSenderDomain, domain)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSmtpRelay returns an error if a relay rule references an upstream that is not in files.smtpUpstreams
+func checkSmtpRelay(cr *mailhogv1alpha1.MailhogInstance) error {
+	if cr.Spec.Settings.SmtpRelay == nil {
+		return nil
+	}
+	if err := smtpproxy.ValidateRelay(smtpProxyRelay(cr)); err != nil {
+		return fmt.Errorf("%w: %s", errInvalidSmtpRelay, err.Error())
+	}
+	return nil
+}
+
+// checkWebAuth returns an error if the auth settings are incomplete or conflict with basic auth
+func checkWebAuth(cr *mailhogv1alpha1.MailhogInstance) error {
+	if auth := cr.Spec.Settings.Auth; auth != nil && auth.Oidc != nil && auth.Rbac != nil {
+		return errMultipleAuthModes
+	}
+	if webProxyNeeded(cr) && basicAuthEnabled(cr) {
+		return errWebAuthConflict
+	}
+	if auth := cr.Spec.Settings.Auth; auth != nil && auth.Oidc != nil {
+		oidc := auth.Oidc
+		if oidc.IssuerURL == "" || oidc.ClientID == "" || oidc.ClientSecret.Name == "" || oidc.ClientSecret.Key == "" {
+			return errMissingOidcSettings
+		}
+	}
+	return nil
+}
+
+// checkReservedWebUser returns an error if a web user collides with the internal probe user
+func checkReservedWebUser(cr *mailhogv1alpha1.MailhogInstance) error {
+	if basicAuthEnabled(cr) {
+		for _, user := range cr.Spec.Settings.Files.WebUsers {
+			if user.Name == probeUserName {
+				return errReservedWebUser
+			}
+		}
+	}
+	return nil
+}
+
+// checkRetention returns an error if a retention policy would never delete anything
+func checkRetention(cr *mailhogv1alpha1.MailhogInstance) error {
+	if retention := cr.Spec.Settings.Retention; retention != nil && retention.MaxAge == nil && retention.MaxMessages == 0 {
+		return errMissingRetentionLimit
+	}
+	return nil
+}
+
+// checkPreserveOnRollout returns an error if messages should be preserved without memory storage
+func checkPreserveOnRollout(cr *mailhogv1alpha1.MailhogInstance) error {
+	if cr.Spec.Settings.PreserveOnRollout && cr.Spec.Settings.Storage != mailhogv1alpha1.MemoryStorage {
+		return errPreserveNeedsMemoryStorage
+	}
+	return nil
+}
+
+// checkCaBundles returns an error if the trusted ca bundle or the ca bundle of an upstream does not exist
+// or contains no PEM encoded certificate
+func checkCaBundles(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) error {
+	if trustedCa := cr.Spec.Settings.TrustedCa; trustedCa != nil {
+		if _, err := r.caBundle(ctx, cr.Namespace, trustedCaRef(trustedCa), nil); err != nil {
+			return fmt.Errorf("%w: trusted ca %s", err, trustedCa.ConfigMapName)
+		}
+	}
+	if cr.Spec.Settings.Files == nil {
+		return nil
+	}
+	for _, upstream := range cr.Spec.Settings.Files.SmtpUpstreams {
+		if upstreamCaFile(upstream) == "" {
+			continue
+		}
+		if _, err := r.caBundle(ctx, cr.Namespace, upstream.Tls.CaConfigMapKeyRef, upstream.Tls.CaSecretKeyRef); err != nil {
+			return fmt.Errorf("%w: upstream %s", err, upstream.Name)
+		}
+	}
+	return nil
+}
+
+// checkReferencedSettings returns an error if a referenced catalog upstream, its credentials or a referenced settings file
+// do not exist, a settings file can not be parsed or a name of an upstream or web user is used twice
+func checkReferencedSettings(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) error {
+	if _, err := r.smtpUpstreams(ctx, cr); err != nil {
+		return err
+	}
+	_, err := r.webUsers(ctx, cr)
+	return err
+}
+
 var (
 	errConflictingMount             = errors.New("the chosen maildir path conflicts with other paths needed (/usr/local/bin or /mailhog/settings/files)")
 	errMissingMongoDBSettings       = errors.New("mongodb was specified as data storage but not all mongodb params have been specified")
 	errMissingMaildirSettings       = errors.New("maildir was specified as data storage but no path has been specified")
 	errMissingUpstreamSmtpMechanism = errors.New("an upstream smtp server has username / password specified but no auth mechanism")
 	errJimNonFloatFound             = errors.New("a chaos monkey probability rate cannot be unpacked as a float")
+	errJimNonIntegerFound           = errors.New("a chaos monkey link speed cannot be unpacked as an integer")
+	errJimProbabilityRange          = errors.New("a chaos monkey probability rate must be between 0 and 1")
+	errJimLinkspeedRange            = errors.New("chaos monkey link speeds must be positive and the minimum must not exceed the maximum")
+	errJimUnknownPreset             = errors.New("unknown chaos monkey preset")
 	errWebPathNonRelative           = errors.New("web path must be relative (not starting or ending with slash)")
+	errMissingSmtpTlsSecret         = errors.New("smtp tls was specified but no certificate secret has been specified")
+	errSmtpTlsPortConflict          = errors.New("smtp tls ports must differ from each other and from the smtp / http / smtp proxy ports")
+	errInvalidSmtpFault             = errors.New("an smtp fault is invalid")
+	errInvalidSenderDomain          = errors.New("an allowed sender domain must be a plain domain name without @")
+	errInvalidSmtpRelay             = errors.New("an smtp relay rule is invalid")
+	errInvalidUpstreamTls           = errors.New("an upstream tls spec needs at most one ca ConfigMap or Secret key, a known tls version and a name usable as file name")
+	errMissingCaBundle              = errors.New("a referenced ca bundle ConfigMap / Secret or its key does not exist")
+	errInvalidCaBundle              = errors.New("a referenced ca bundle contains no PEM encoded certificate")
+	errInvalidUpstreamRef           = errors.New("an upstream reference needs either a name or a valid label selector")
+	errMissingCatalogUpstream       = errors.New("a referenced MailhogUpstream / ClusterMailhogUpstream does not exist")
+	errMissingCatalogCredentials    = errors.New("the credentials Secret of a catalog upstream does not exist or lacks username / password")
+	errForeignCatalogCredentials    = errors.New("a MailhogUpstream can only reference a credentials Secret in its own namespace")
+	errUpstreamNameConflict         = errors.New("two smtp upstreams share the same name")
+	errInvalidFileSource            = errors.New("a settings file source needs either a ConfigMap or a Secret key")
+	errMissingSettingsFile          = errors.New("a referenced settings file ConfigMap / Secret or its key does not exist")
+	errInvalidSettingsFile          = errors.New("a referenced settings file can not be parsed")
+	errWebUserConflict              = errors.New("two web users share the same name")
+	errMissingOidcSettings          = errors.New("oidc auth was specified but issuer url, client id or client secret reference are missing")
+	errWebAuthConflict              = errors.New("auth proxy modes can not be combined with basic auth web users")
+	errMultipleAuthModes            = errors.New("only one auth mode (oidc or rbac) can be specified")
+	errMissingRetentionLimit        = errors.New("retention was specified but neither max age nor max messages have been specified")
+	errMissingSeedSource            = errors.New("a ConfigMap or Secret referenced as seed source does not exist")
+	errUnlabeledSeedSource          = errors.New("a Secret referenced as seed source lacks the label " + seedSourceLabel + "=true")
+	errReservedWebUser              = errors.New("the web user name " + probeUserName + " is reserved for readiness probes")
+	errPreserveNeedsMemoryStorage   = errors.New("messages can only be preserved on rollout with memory storage")
+	errPreserveTooLarge             = errors.New("the preserved messages exceed the size limit of a ConfigMap")
+	errMissingPreserved             = errors.New("the ConfigMap holding the preserved messages does not exist")
+	errMissingArchiveCredentials    = errors.New("the archive credentials Secret does not exist or lacks accessKeyId / secretAccessKey")
+	errSmtpVerificationNotStored    = errors.New("the smtp verification message was not found through the api of any pod")
+	errCronFieldCount               = errors.New("a schedule must have five fields: minute hour day-of-month month day-of-week")
+	errCronInvalidField             = errors.New("a schedule field is not a valid value, range, step or list")
+	errCronNoMatch                  = errors.New("the schedule does not match any time within the next years")
+	errMissingArchiveInstance       = errors.New("the referenced mailhog instance does not exist")
+	errMissingArchiveClaim          = errors.New("the referenced persistent volume claim does not exist")
+	errMissingArchiveResult         = errors.New("the job did not report a result")
+	errNoArchivePods                = errors.New("the mailhog instance has no ready pods")
+	errArchiveJobFailed             = errors.New("the archive job failed, check the logs of its pods")
+	errSnapshotTooLarge             = errors.New("the snapshot exceeds the size limit of a ConfigMap, use a persistent volume claim")
+	errMissingSnapshot              = errors.New("the referenced mailhog snapshot does not exist")
+	errSnapshotPending              = errors.New("the referenced mailhog snapshot did not complete yet")
+	errSnapshotFailed               = errors.New("the referenced mailhog snapshot failed")
+	errMissingSnapshotData          = errors.New("the ConfigMap of the referenced mailhog snapshot does not exist")
+	errLoadTestJobFailed            = errors.New("the load generator job failed, check the logs of its pod")
+	errChaosInstanceBusy            = errors.New("another chaos experiment is running against the mailhog instance")
+	errMissingAssertionInstance     = errors.New("the referenced mailhog instance does not exist")
+	errNoAssertionPods              = errors.New("the mailhog instance has no ready pods")
+	errMailhogApi                   = errors.New("mailhog api request failed")
+	errSmtpProxyAdmin               = errors.New("smtp proxy admin request failed")
+	errOidcDiscoveryFailed          = errors.New("the oidc discovery document of the issuer could not be fetched")
+	errOidcIssuerMismatch           = errors.New("the oidc discovery document names a different issuer than the configured issuer url")
 )
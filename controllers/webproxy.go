@@ -0,0 +1,385 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	mailhogv1alpha1 "goimports.patrick.mx/mailhog-operator/api/v1alpha1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// webProxyNeeded returns true if the CR requires an authenticating proxy in front of the web port
+func webProxyNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	auth := cr.Spec.Settings.Auth
+	return auth != nil && (auth.Oidc != nil || auth.Rbac != nil)
+}
+
+// rbacAuthNeeded returns true if access to the web port is checked against the cluster's RBAC rules
+func rbacAuthNeeded(cr *mailhogv1alpha1.MailhogInstance) bool {
+	auth := cr.Spec.Settings.Auth
+	return auth != nil && auth.Rbac != nil
+}
+
+// webTargetPort returns the container port web traffic from the Service, Route and Ingress is sent to
+func webTargetPort(cr *mailhogv1alpha1.MailhogInstance) int32 {
+	if webProxyNeeded(cr) {
+		return portWebProxy
+	}
+	return portWeb
+}
+
+// webProxyContainer returns the authenticating proxy sidecar for the configured auth mode
+func webProxyContainer(cr *mailhogv1alpha1.MailhogInstance) corev1.Container {
+	var container corev1.Container
+	if rbacAuthNeeded(cr) {
+		container = rbacProxyContainer(cr)
+	} else {
+		container = oidcProxyContainer(cr)
+	}
+	container.Name = webProxyName
+	container.Ports = []corev1.ContainerPort{
+		{
+			Name:          portWebProxyName,
+			ContainerPort: portWebProxy,
+			Protocol:      protoTcp,
+		},
+	}
+	container.Args = append(container.Args, "--htpasswd-file="+webProxyMount+"/"+webProxyHtpasswdFileName)
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      volumeNameWebProxyConfig,
+		MountPath: webProxyMount,
+		ReadOnly:  true,
+	})
+	if rbacAuthNeeded(cr) {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volumeNameWebProxyToken,
+			MountPath: serviceAccountTokenMount,
+			ReadOnly:  true,
+		})
+	}
+	container.Resources = sidecarResources(cr)
+	container.LivenessProbe = getProbeTcp(portWebProxy)
+	container.ReadinessProbe = getProbeHttp(portWebProxy, webProxyPingPath)
+	return container
+}
+
+// webProxyVolumes returns the volumes needed by the authenticating proxy sidecar
+// the htpasswd file lets the operator reach the api of a pod as internal probe user, mailhog only listens on loopback
+func webProxyVolumes(cr *mailhogv1alpha1.MailhogInstance) (volumes []corev1.Volume) {
+	sources := []corev1.VolumeProjection{
+		{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: generatedSecretName(cr),
+				},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  secretKeyProbeHtpasswd,
+						Path: webProxyHtpasswdFileName,
+					},
+				},
+			},
+		},
+	}
+	if oidc := cr.Spec.Settings.Auth.Oidc; oidc != nil && !rbacAuthNeeded(cr) && len(oidc.AllowedEmails) > 0 {
+		sources = append(sources, corev1.VolumeProjection{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: cr.Name,
+				},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  oidcEmailsFileName,
+						Path: oidcEmailsFileName,
+					},
+				},
+			},
+		})
+	}
+	volumes = append(volumes, corev1.Volume{
+		Name: volumeNameWebProxyConfig,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: sources,
+			},
+		},
+	})
+	if rbacAuthNeeded(cr) {
+		volumes = append(volumes, serviceAccountTokenVolume())
+	}
+	return volumes
+}
+
+// serviceAccountTokenVolume returns the files the pod's service account token is usually automounted with,
+// the oauth-proxy is the only container allowed to use the token bound to system:auth-delegator
+func serviceAccountTokenVolume() corev1.Volume {
+	expiration := int64(serviceAccountTokenTtl)
+	return corev1.Volume{
+		Name: volumeNameWebProxyToken,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Path:              "token",
+							ExpirationSeconds: &expiration,
+						},
+					},
+					{
+						ConfigMap: &corev1.ConfigMapProjection{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: kubeRootCaConfigMap,
+							},
+							Items: []corev1.KeyToPath{
+								{
+									Key:  "ca.crt",
+									Path: "ca.crt",
+								},
+							},
+						},
+					},
+					{
+						DownwardAPI: &corev1.DownwardAPIProjection{
+							Items: []corev1.DownwardAPIVolumeFile{
+								{
+									Path: "namespace",
+									FieldRef: &corev1.ObjectFieldSelector{
+										APIVersion: "v1",
+										FieldPath:  "metadata.namespace",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// oidcProxyContainer returns an oauth2-proxy container configured for the CRs OIDC settings
+func oidcProxyContainer(cr *mailhogv1alpha1.MailhogInstance) corev1.Container {
+	oidc := cr.Spec.Settings.Auth.Oidc
+
+	image := oidc.Image
+	if image == "" {
+		image = defaultOidcProxyImage
+	}
+
+	args := []string{
+		"--http-address=0.0.0.0:" + strconv.Itoa(portWebProxy),
+		"--upstream=" + webProxyUpstream,
+		"--provider=oidc",
+		"--oidc-issuer-url=" + oidc.IssuerURL,
+		"--client-id=" + oidc.ClientID,
+		"--reverse-proxy=true",
+		"--skip-provider-button=true",
+		"--ping-path=" + webProxyPingPath,
+		"--cookie-secure=" + strconv.FormatBool(webTrafficEncrypted(cr)),
+		"--display-htpasswd-form=false",
+	}
+	for _, group := range oidc.AllowedGroups {
+		args = append(args, "--allowed-group="+group)
+	}
+
+	container := corev1.Container{
+		Image: image,
+		Args:  args,
+		Env: []corev1.EnvVar{
+			{
+				Name: envOidcClientSecret,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: oidc.ClientSecret.DeepCopy(),
+				},
+			},
+			{
+				Name: envOidcCookieSecret,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: generatedSecretName(cr),
+						},
+						Key: secretKeyCookie,
+					},
+				},
+			},
+		},
+	}
+
+	if len(oidc.AllowedEmails) > 0 {
+		container.Args = append(container.Args, "--authenticated-emails-file="+webProxyMount+"/"+oidcEmailsFileName)
+	} else {
+		container.Args = append(container.Args, "--email-domain=*")
+	}
+
+	return container
+}
+
+// rbacProxyContainer returns an openshift oauth-proxy container checking access with SubjectAccessReviews
+func rbacProxyContainer(cr *mailhogv1alpha1.MailhogInstance) corev1.Container {
+	rbac := cr.Spec.Settings.Auth.Rbac
+
+	image := rbac.Image
+	if image == "" {
+		image = defaultRbacProxyImage
+	}
+
+	sar, _ := json.Marshal(rbacResourceAttributes(cr))
+	delegate, _ := json.Marshal(map[string]authorizationv1.ResourceAttributes{"/": rbacResourceAttributes(cr)})
+
+	return corev1.Container{
+		Image: image,
+		Args: []string{
+			"--provider=openshift",
+			"--http-address=0.0.0.0:" + strconv.Itoa(portWebProxy),
+			"--https-address=",
+			"--upstream=" + webProxyUpstream,
+			"--openshift-service-account=" + serviceAccountName(cr),
+			"--openshift-sar=" + string(sar),
+			"--openshift-delegate-urls=" + string(delegate),
+			"--skip-provider-button=true",
+			"--pass-basic-auth=false",
+			"--ping-path=" + webProxyPingPath,
+			"--cookie-secure=" + strconv.FormatBool(webTrafficEncrypted(cr)),
+			"--cookie-secret=$(" + envRbacCookieSecret + ")",
+		},
+		Env: []corev1.EnvVar{
+			{
+				Name: envRbacCookieSecret,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: generatedSecretName(cr),
+						},
+						Key: secretKeyCookie,
+					},
+				},
+			},
+		},
+	}
+}
+
+// rbacResourceAttributes returns the access check performed for every user of the web ui / api
+func rbacResourceAttributes(cr *mailhogv1alpha1.MailhogInstance) authorizationv1.ResourceAttributes {
+	verb := cr.Spec.Settings.Auth.Rbac.Verb
+	if verb == "" {
+		verb = defaultRbacVerb
+	}
+	return authorizationv1.ResourceAttributes{
+		Namespace: cr.Namespace,
+		Verb:      verb,
+		Group:     mailhogv1alpha1.GroupVersion.Group,
+		Resource:  "mailhoginstances",
+		Name:      cr.Name,
+	}
+}
+
+// webTrafficEncrypted returns true if the web ui is only reachable via https from outside the cluster
+func webTrafficEncrypted(cr *mailhogv1alpha1.MailhogInstance) bool {
+	switch cr.Spec.WebTrafficInlet {
+	case mailhogv1alpha1.RouteTrafficInlet:
+		return true
+	case mailhogv1alpha1.IngressTrafficInlet:
+		return cr.Spec.Settings.Ingress.TlsSecret != ""
+	case mailhogv1alpha1.NoTrafficInlet:
+	}
+	return false
+}
+
+// webTargetPortRef returns the web target port as IntOrString
+func webTargetPortRef(cr *mailhogv1alpha1.MailhogInstance) intstr.IntOrString {
+	return intstr.IntOrString{
+		Type:   intstr.Int,
+		IntVal: webTargetPort(cr),
+	}
+}
+
+// ensureOidcIssuerDiscovered fetches the discovery document of the oidc issuer once per generation and reports the
+// result in the OidcIssuerDiscovered condition, an unreachable issuer does not hold back the rest of the reconcile
+func ensureOidcIssuerDiscovered(ctx context.Context, r *MailhogInstanceReconciler, cr *mailhogv1alpha1.MailhogInstance) error {
+	logger := r.logger.WithValues(span, spanOidcIssuer)
+
+	auth := cr.Spec.Settings.Auth
+	if auth == nil || auth.Oidc == nil {
+		if meta.FindStatusCondition(cr.Status.Conditions, mailhogv1alpha1.OidcIssuerDiscoveredCondition) != nil {
+			meta.RemoveStatusCondition(&cr.Status.Conditions, mailhogv1alpha1.OidcIssuerDiscoveredCondition)
+			if err := r.Status().Update(ctx, cr); err != nil {
+				logger.Error(err, failedCrUpdateStatus)
+				return err
+			}
+		}
+		logger.Info(stateEnsured)
+		return nil
+	}
+	if condition := meta.FindStatusCondition(cr.Status.Conditions, mailhogv1alpha1.OidcIssuerDiscoveredCondition); condition != nil && condition.ObservedGeneration == cr.Generation {
+		logger.Info(stateEnsured)
+		return nil
+	}
+
+	original := cr.Status.DeepCopy()
+	condition := metav1.Condition{
+		Type:               mailhogv1alpha1.OidcIssuerDiscoveredCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: cr.Generation,
+		Reason:             mailhogv1alpha1.DiscoveredReason,
+		Message:            "the discovery document names " + auth.Oidc.IssuerURL,
+	}
+	if err := discoverOidcIssuer(ctx, auth.Oidc.IssuerURL); err != nil {
+		logger.Error(err, failedOidcDiscovery)
+		r.Recorder.Event(cr, corev1.EventTypeWarning, "OidcDiscoveryFailed", err.Error())
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = mailhogv1alpha1.DiscoveryFailedReason
+		if errors.Is(err, errOidcIssuerMismatch) {
+			condition.Reason = mailhogv1alpha1.IssuerMismatchReason
+		}
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, condition)
+	if !reflect.DeepEqual(*original, cr.Status) {
+		if err := r.Status().Update(ctx, cr); err != nil {
+			logger.Error(err, failedCrUpdateStatus)
+			return err
+		}
+	}
+
+	logger.Info(stateEnsured)
+	return nil
+}
+
+// discoverOidcIssuer returns an error if the oidc discovery document can not be fetched or names another issuer
+func discoverOidcIssuer(ctx context.Context, issuerUrl string) error {
+	issuer := strings.TrimSuffix(issuerUrl, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+oidcDiscoveryPath, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errOidcDiscoveryFailed, err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errOidcDiscoveryFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", errOidcDiscoveryFailed, resp.Status)
+	}
+
+	discovery := struct {
+		Issuer string `json:"issuer"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return fmt.Errorf("%w: %s", errOidcDiscoveryFailed, err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return fmt.Errorf("%w: %s", errOidcIssuerMismatch, discovery.Issuer)
+	}
+	return nil
+}
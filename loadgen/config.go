@@ -0,0 +1,111 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	// ConfigEnv is the environment variable the load generator reads its json config from
+	ConfigEnv = "MAILHOG_LOADGEN_CONFIG"
+
+	// SequencePlaceholder is replaced with the sequence number of a message in sender and recipient patterns
+	SequencePlaceholder = "{n}"
+
+	// WorkerPlaceholder is replaced with the number of the sending connection in sender and recipient patterns
+	WorkerPlaceholder = "{worker}"
+)
+
+// Config is the configuration of a single load generator run, passed as json in ConfigEnv
+type Config struct {
+	// Address is the smtp address messages are sent to
+	Address string `json:"address"`
+
+	// Rate is the count of messages sent per second over all connections
+	Rate int `json:"rate"`
+
+	// Duration is how long messages are sent
+	Duration time.Duration `json:"duration"`
+
+	// Concurrency is the count of parallel smtp connections
+	Concurrency int `json:"concurrency"`
+
+	// MessageSize is the approximate size of every message in bytes
+	MessageSize int `json:"messageSize"`
+
+	// From is the sender pattern, see SequencePlaceholder and WorkerPlaceholder
+	From string `json:"from"`
+
+	// To is the recipient pattern, see SequencePlaceholder and WorkerPlaceholder
+	To string `json:"to"`
+
+	// Timeout limits a single smtp connection attempt
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// ResultFile is where the json encoded Result is written to, eg "/dev/termination-log"
+	ResultFile string `json:"resultFile,omitempty"`
+}
+
+// Result describes the throughput, latency and errors of a run
+type Result struct {
+	// Sent is the count of messages accepted by the smtp server
+	Sent int64 `json:"sent"`
+
+	// Errors is the count of messages that could not be sent
+	Errors int64 `json:"errors"`
+
+	// LastError is the last error message, empty without errors
+	LastError string `json:"lastError,omitempty"`
+
+	// Elapsed is the time from the first to the last message
+	Elapsed time.Duration `json:"elapsed"`
+
+	// Throughput is the count of sent messages per second
+	Throughput float64 `json:"throughput"`
+
+	// Latency is the time from MAIL FROM until the message was accepted
+	Latency Latency `json:"latency"`
+}
+
+// Latency summarizes the latencies of the sent messages
+type Latency struct {
+	Average time.Duration `json:"average"`
+	P50     time.Duration `json:"p50"`
+	P95     time.Duration `json:"p95"`
+	P99     time.Duration `json:"p99"`
+	Max     time.Duration `json:"max"`
+}
+
+// LoadConfig reads the load generator config from the environment
+func LoadConfig() (cfg Config, err error) {
+	raw, found := os.LookupEnv(ConfigEnv)
+	if !found {
+		return cfg, errMissingConfig
+	}
+	if err = json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.validate()
+}
+
+// validate returns an error if the config can not be run
+func (c Config) validate() error {
+	switch {
+	case c.Address == "":
+		return errMissingAddress
+	case c.Rate < 1 || c.Concurrency < 1 || c.Duration <= 0:
+		return errInvalidLoad
+	case c.From == "" || c.To == "":
+		return errMissingPattern
+	}
+	return nil
+}
+
+var (
+	errMissingConfig  = errors.New("no load generator config found in " + ConfigEnv)
+	errMissingAddress = errors.New("no smtp address configured")
+	errInvalidLoad    = errors.New("rate, concurrency and duration must be positive")
+	errMissingPattern = errors.New("no sender or recipient pattern configured")
+)
@@ -0,0 +1,165 @@
+package loadgen
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLoadgen(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Loadgen Suite")
+}
+
+var _ = Describe("load generator", func() {
+	It("should send messages at the rate and report the result", func() {
+		server := startFakeServer()
+		defer server.close()
+		resultDir, err := os.MkdirTemp("", "loadgen")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(resultDir)
+		resultFile := filepath.Join(resultDir, "result")
+
+		cfg := Config{
+			Address:     server.address,
+			Rate:        50,
+			Duration:    time.Duration(500) * time.Millisecond,
+			Concurrency: 2,
+			MessageSize: 2000,
+			From:        "sender-{worker}@sender.example",
+			To:          "user-{n}@receiver.example",
+			ResultFile:  resultFile,
+		}
+		result, err := Run(context.Background(), cfg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Errors).To(BeZero())
+		Expect(result.Sent).To(BeNumerically(">", 5))
+		Expect(result.Sent).To(BeNumerically("<=", 26))
+		Expect(result.Throughput).To(BeNumerically(">", 0))
+		Expect(result.Latency.Max).To(BeNumerically(">=", result.Latency.P50))
+
+		recipients := server.recipients()
+		Expect(recipients).To(HaveLen(int(result.Sent)))
+		Expect(recipients).To(ContainElement("user-1@receiver.example"))
+
+		raw, err := os.ReadFile(resultFile)
+		Expect(err).ToNot(HaveOccurred())
+		written := Result{}
+		Expect(json.Unmarshal(raw, &written)).To(Succeed())
+		Expect(written.Sent).To(Equal(result.Sent))
+	})
+
+	It("should count messages that could not be sent", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		address := listener.Addr().String()
+		Expect(listener.Close()).To(Succeed())
+
+		result, err := Run(context.Background(), Config{
+			Address: address, Rate: 20, Duration: time.Duration(200) * time.Millisecond, Concurrency: 1,
+			From: "a@sender.example", To: "b@receiver.example",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Sent).To(BeZero())
+		Expect(result.Errors).To(BeNumerically(">", 0))
+		Expect(result.LastError).ToNot(BeEmpty())
+	})
+
+	It("should pad messages to the requested size", func() {
+		for _, size := range []int{0, 200, 1000, 4321} {
+			data := message(size, 7, 1, "a@sender.example", "b@receiver.example")
+			if size > 200 {
+				Expect(len(data)).To(BeNumerically("~", size, 2))
+			}
+			Expect(string(data)).To(ContainSubstring("Subject: load test message 7\r\n"))
+		}
+	})
+
+	It("should reject configs without load", func() {
+		Expect(Config{Address: "a:25", Rate: 0, Concurrency: 1, Duration: time.Second, From: "a", To: "b"}.validate()).To(MatchError(errInvalidLoad))
+		Expect(Config{Address: "a:25", Rate: 1, Concurrency: 1, Duration: time.Second}.validate()).To(MatchError(errMissingPattern))
+	})
+})
+
+// fakeServer is a minimal smtp server recording the recipients of accepted messages
+type fakeServer struct {
+	listener net.Listener
+	address  string
+	mu       sync.Mutex
+	rcpts    []string
+}
+
+func startFakeServer() *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	s := &fakeServer{listener: listener, address: listener.Addr().String()}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	_, _ = conn.Write([]byte("220 mailhog.example ESMTP MailHog\r\n"))
+	recipient := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.Fields(line + " x")[0])
+		switch verb {
+		case "RCPT":
+			recipient = strings.Trim(line[strings.Index(line, "<"):], "<>\r\n")
+			_, _ = conn.Write([]byte("250 Ok\r\n"))
+		case "DATA":
+			_, _ = conn.Write([]byte("354 End data with <CR><LF>.<CR><LF>\r\n"))
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if strings.TrimRight(dataLine, "\r\n") == "." {
+					break
+				}
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, recipient)
+			s.mu.Unlock()
+			_, _ = conn.Write([]byte("250 Ok: queued\r\n"))
+		case "QUIT":
+			_, _ = conn.Write([]byte("221 Bye\r\n"))
+			return
+		default:
+			_, _ = conn.Write([]byte("250 Ok\r\n"))
+		}
+	}
+}
+
+func (s *fakeServer) recipients() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.rcpts...)
+}
+
+func (s *fakeServer) close() {
+	_ = s.listener.Close()
+}
@@ -0,0 +1,208 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/smtp"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTimeout limits connection attempts if the config does not
+const defaultTimeout = time.Duration(10) * time.Second
+
+// sample is the outcome of sending a single message
+type sample struct {
+	latency time.Duration
+	err     error
+}
+
+// Run sends messages at the configured rate until the duration elapsed or the context is cancelled
+// and writes the result to the result file. The rate is an upper bound, if all connections are busy
+// messages are skipped, so a slow server shows up as lower throughput and higher latency
+func Run(ctx context.Context, cfg Config) (result Result, err error) {
+	if err = cfg.validate(); err != nil {
+		return result, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	sequence := make(chan int64)
+	samples := make(chan sample, cfg.Concurrency)
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < cfg.Concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			send(cfg, worker, sequence, samples)
+		}(worker)
+	}
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	start := time.Now()
+	go schedule(ctx, cfg.Rate, sequence)
+
+	latencies := make([]time.Duration, 0, cfg.Rate)
+	for s := range samples {
+		if s.err != nil {
+			result.Errors++
+			result.LastError = s.err.Error()
+			continue
+		}
+		result.Sent++
+		latencies = append(latencies, s.latency)
+	}
+	result.Elapsed = time.Since(start)
+	if seconds := result.Elapsed.Seconds(); seconds > 0 {
+		result.Throughput = math.Round(float64(result.Sent)/seconds*100) / 100
+	}
+	result.Latency = summarize(latencies)
+
+	if cfg.ResultFile != "" {
+		raw, _ := json.Marshal(result)
+		if err = os.WriteFile(cfg.ResultFile, raw, 0o600); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// schedule hands out sequence numbers at the given rate per second until the context is done
+func schedule(ctx context.Context, rate int, sequence chan<- int64) {
+	defer close(sequence)
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	for n := int64(1); ; n++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case sequence <- n:
+		}
+	}
+}
+
+// send delivers a message for every sequence number, reusing its smtp connection as long as it works
+func send(cfg Config, worker int, sequence <-chan int64, samples chan<- sample) {
+	var c *smtp.Client
+	defer func() {
+		if c != nil {
+			_ = c.Quit()
+		}
+	}()
+
+	for n := range sequence {
+		if c == nil {
+			var err error
+			if c, err = dial(cfg); err != nil {
+				samples <- sample{err: err}
+				continue
+			}
+		}
+		from, to := expand(cfg.From, n, worker), expand(cfg.To, n, worker)
+		start := time.Now()
+		if err := deliver(c, from, to, message(cfg.MessageSize, n, worker, from, to)); err != nil {
+			_ = c.Close()
+			c = nil
+			samples <- sample{err: err}
+			continue
+		}
+		samples <- sample{latency: time.Since(start)}
+	}
+}
+
+// dial opens an smtp connection
+func dial(cfg Config) (*smtp.Client, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	conn, err := net.DialTimeout("tcp", cfg.Address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(cfg.Address)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// deliver sends a single message over an open connection
+func deliver(c *smtp.Client, from, to string, data []byte) error {
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// expand replaces the placeholders of a sender or recipient pattern
+func expand(pattern string, n int64, worker int) string {
+	return strings.NewReplacer(SequencePlaceholder, strconv.FormatInt(n, 10), WorkerPlaceholder, strconv.Itoa(worker)).Replace(pattern)
+}
+
+// message returns a plain text message padded to roughly the given size
+func message(size int, n int64, worker int, from, to string) []byte {
+	data := strings.Builder{}
+	fmt.Fprintf(&data, "From: %s\r\nTo: %s\r\nSubject: load test message %d\r\nMessage-ID: <loadgen-%d-%d-%d@mailhog-operator.local>\r\nDate: %s\r\n\r\n",
+		from, to, n, worker, n, time.Now().UnixNano(), time.Now().Format(time.RFC1123Z))
+
+	line := strings.Repeat("x", 76) + "\r\n"
+	for data.Len()+len(line) <= size {
+		data.WriteString(line)
+	}
+	if remaining := size - data.Len() - 2; remaining > 0 {
+		data.WriteString(line[:remaining] + "\r\n")
+	}
+	return []byte(data.String())
+}
+
+// summarize returns the average, percentiles and maximum of the latencies
+func summarize(latencies []time.Duration) (latency Latency) {
+	if len(latencies) == 0 {
+		return latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	total := time.Duration(0)
+	for _, l := range latencies {
+		total += l
+	}
+	percentile := func(p float64) time.Duration {
+		return latencies[int(math.Ceil(p*float64(len(latencies))))-1]
+	}
+	return Latency{
+		Average: total / time.Duration(len(latencies)),
+		P50:     percentile(0.5),
+		P95:     percentile(0.95),
+		P99:     percentile(0.99),
+		Max:     latencies[len(latencies)-1],
+	}
+}
@@ -91,6 +91,41 @@ func main() {
 	}).SetupWithManager(mgr); err != nil {
 		errExit(err, errCreateController)
 	}
+	if err = (&controllers.MailhogSnapshotReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(eventRecorderSource),
+	}).SetupWithManager(mgr); err != nil {
+		errExit(err, errCreateController)
+	}
+	if err = (&controllers.MailhogRestoreReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(eventRecorderSource),
+	}).SetupWithManager(mgr); err != nil {
+		errExit(err, errCreateController)
+	}
+	if err = (&controllers.MailhogAssertionReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(eventRecorderSource),
+	}).SetupWithManager(mgr); err != nil {
+		errExit(err, errCreateController)
+	}
+	if err = (&controllers.MailhogLoadTestReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(eventRecorderSource),
+	}).SetupWithManager(mgr); err != nil {
+		errExit(err, errCreateController)
+	}
+	if err = (&controllers.MailhogChaosExperimentReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(eventRecorderSource),
+	}).SetupWithManager(mgr); err != nil {
+		errExit(err, errCreateController)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,63 @@
+package smtpproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// MetricsPath serves the prometheus metrics of the proxy
+	MetricsPath = "/metrics"
+
+	// RulesPath serves the hits of every rule as json list of RuleHits
+	RulesPath = "/rules"
+
+	// ViolationsPath serves the count of every kind of policy violation as json object
+	ViolationsPath = "/violations"
+
+	// TranscriptsPath serves the kept session transcripts as json list of Transcript, oldest first
+	TranscriptsPath = "/transcripts"
+
+	// RelayPath serves the counters of every relay rule as json list of RelayStats
+	RelayPath = "/relay"
+)
+
+// adminServer returns the http server for metrics, rule hits, policy violations, transcripts and relay stats
+// everything but the metrics is restricted to the operator, transcripts and relay stats reveal the sessions' content
+func (p *Proxy) adminServer() *http.Server {
+	restrict := p.cfg.Operator.restrict
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc(RulesPath, restrict(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p.rules.counts())
+	}))
+	mux.HandleFunc(ViolationsPath, restrict(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p.policy.violationCounts())
+	}))
+	mux.HandleFunc(TranscriptsPath, restrict(func(w http.ResponseWriter, _ *http.Request) {
+		if p.transcripts == nil {
+			http.Error(w, "session transcripts are not recorded", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(p.transcripts.list())
+	}))
+	mux.HandleFunc(RelayPath, restrict(func(w http.ResponseWriter, _ *http.Request) {
+		stats := []RelayStats{}
+		if p.relayer != nil {
+			stats = p.relayer.stats()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(stats)
+	}))
+	return &http.Server{
+		Addr:              p.cfg.AdminAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
@@ -0,0 +1,133 @@
+package smtpproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// ListenerMode defines how a listener treats incoming smtp sessions
+type ListenerMode string
+
+const (
+	// StartTlsMode offers STARTTLS and refuses mail transactions until tls is active
+	StartTlsMode ListenerMode = "starttls"
+
+	// ImplicitTlsMode expects a tls handshake right after connecting (smtps)
+	ImplicitTlsMode ListenerMode = "tls"
+
+	// PlainMode relays plaintext sessions, used to apply rules in front of mailhog's smtp port
+	PlainMode ListenerMode = "plain"
+
+	// OperatorMode relays the plaintext sessions of the operator after it authenticated as Operator,
+	// they bypass rules, policy, relay and transcripts
+	OperatorMode ListenerMode = "operator"
+
+	// ConfigEnv is the environment variable the proxy reads its json config from
+	ConfigEnv = "SMTP_PROXY_CONFIG"
+)
+
+// Config is the configuration of the smtp proxy sidecar, passed as json in ConfigEnv
+type Config struct {
+	// Backend is the address of mailhog's smtp listener, eg "127.0.0.1:1025"
+	Backend string `json:"backend"`
+
+	// Listeners are the addresses the proxy accepts sessions on
+	Listeners []Listener `json:"listeners"`
+
+	// CertFile is the path of the pem encoded tls certificate
+	CertFile string `json:"certFile,omitempty"`
+
+	// KeyFile is the path of the pem encoded tls key
+	KeyFile string `json:"keyFile,omitempty"`
+
+	// Rules are the faults injected into the sessions of all listeners
+	Rules []Rule `json:"rules,omitempty"`
+
+	// Policy restricts the sessions of all listeners
+	Policy Policy `json:"policy,omitempty"`
+
+	// Relay forwards captured messages to upstreams
+	Relay Relay `json:"relay,omitempty"`
+
+	// Transcripts is the count of session transcripts kept for the admin endpoint, none are recorded if 0
+	Transcripts int `json:"transcripts,omitempty"`
+
+	// AdminAddress serves metrics, rule hits, policy violations, transcripts and relay stats over http if set, eg ":8026"
+	AdminAddress string `json:"adminAddress,omitempty"`
+
+	// Operator is the only identity allowed to read the admin endpoints except the metrics and to use operator listeners,
+	// on the other listeners its sessions bypass rules, policy and relay once it authenticated with AUTH PLAIN
+	Operator Operator `json:"operator,omitempty"`
+}
+
+// Listener is a single address the proxy accepts sessions on
+type Listener struct {
+	// Name is used in logs
+	Name string `json:"name"`
+
+	// Address to listen on, eg ":1587"
+	Address string `json:"address"`
+
+	// Mode defines how sessions on this listener are handled
+	Mode ListenerMode `json:"mode"`
+}
+
+// LoadConfig reads the proxy config from the environment
+func LoadConfig() (cfg Config, err error) {
+	raw, found := os.LookupEnv(ConfigEnv)
+	if !found {
+		return cfg, errMissingConfig
+	}
+	if err = json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.validate()
+}
+
+// validate returns an error if the config can not be served
+func (c Config) validate() error {
+	if c.Backend == "" {
+		return errMissingBackend
+	}
+	if len(c.Listeners) == 0 {
+		return errMissingListeners
+	}
+	for _, listener := range c.Listeners {
+		switch listener.Mode {
+		case StartTlsMode, ImplicitTlsMode:
+			if c.CertFile == "" || c.KeyFile == "" {
+				return errMissingCertificate
+			}
+		case PlainMode:
+		case OperatorMode:
+			if !c.Operator.enabled() {
+				return errMissingOperator
+			}
+		default:
+			return errUnknownMode
+		}
+	}
+	if c.Policy.MaxMessageSize < 0 || c.Policy.MaxRecipients < 0 {
+		return errNegativePolicyLimit
+	}
+	if c.Transcripts < 0 {
+		return errNegativeTranscripts
+	}
+	if err := c.Relay.validate(); err != nil {
+		return err
+	}
+	if err := c.Operator.validate(); err != nil {
+		return err
+	}
+	return ValidateRules(c.Rules)
+}
+
+var (
+	errMissingConfig      = errors.New("no proxy config found in " + ConfigEnv)
+	errMissingBackend     = errors.New("no backend smtp address configured")
+	errMissingListeners   = errors.New("no listeners configured")
+	errMissingCertificate = errors.New("a tls listener is configured but no certificate / key files")
+	errUnknownMode        = errors.New("a listener has an unknown mode")
+	errMissingOperator    = errors.New("an operator listener is configured but no operator")
+)
@@ -0,0 +1,61 @@
+package smtpproxy
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// Operator is the identity the mailhog operator authenticates as towards the proxy
+type Operator struct {
+	// User is the name the operator authenticates as
+	User string `json:"user,omitempty"`
+
+	// PasswordFile is the path of the file holding the operator's password
+	PasswordFile string `json:"passwordFile,omitempty"`
+}
+
+// enabled returns true if an operator identity is configured
+func (o Operator) enabled() bool {
+	return o.User != ""
+}
+
+// validate returns an error if the operator identity can not be checked
+func (o Operator) validate() error {
+	if o.enabled() && o.PasswordFile == "" {
+		return errMissingOperatorPassword
+	}
+	return nil
+}
+
+// authenticate compares the credentials with the operator's, the password file is read on every attempt
+// so a rotated password is picked up without a restart
+func (o Operator) authenticate(user, password string) bool {
+	if !o.enabled() || user != o.User {
+		return false
+	}
+	expected, err := os.ReadFile(o.PasswordFile)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(expected, []byte(password)) == 1
+}
+
+// restrict wraps an admin handler so only the operator may call it, if an operator identity is configured
+func (o Operator) restrict(handler http.HandlerFunc) http.HandlerFunc {
+	if !o.enabled() {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, password, found := r.BasicAuth()
+		if !found || !o.authenticate(user, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="smtp-proxy"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+var errMissingOperatorPassword = errors.New("an operator user is configured but no password file")
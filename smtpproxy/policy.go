@@ -0,0 +1,178 @@
+package smtpproxy
+
+import (
+	"crypto/subtle"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Policy restricts the sessions of all listeners, violations are rejected like a strict mail server would
+type Policy struct {
+	// CredentialsDir contains a file per user, named like the user and containing the password
+	// AUTH is required before MAIL FROM if set
+	CredentialsDir string `json:"credentialsDir,omitempty"`
+
+	// AllowedSenderDomains are the domains MAIL FROM may use, every domain is allowed if empty
+	AllowedSenderDomains []string `json:"allowedSenderDomains,omitempty"`
+
+	// MaxMessageSize is the maximum message size in bytes, unlimited if 0
+	MaxMessageSize int64 `json:"maxMessageSize,omitempty"`
+
+	// MaxRecipients is the maximum count of recipients of a message, unlimited if 0
+	MaxRecipients int `json:"maxRecipients,omitempty"`
+}
+
+// Violation is the kind of a policy violation
+type Violation string
+
+const (
+	// AuthViolation is a failed or missing authentication
+	AuthViolation Violation = "auth"
+
+	// SenderViolation is a sender outside of the allowed domains
+	SenderViolation Violation = "sender"
+
+	// SizeViolation is a message exceeding the maximum size
+	SizeViolation Violation = "size"
+
+	// RecipientsViolation is a recipient exceeding the maximum count
+	RecipientsViolation Violation = "recipients"
+)
+
+var violations = []Violation{AuthViolation, SenderViolation, SizeViolation, RecipientsViolation}
+
+const (
+	replyAuthSuccess       = "235 2.7.0 Authentication successful\r\n"
+	replyAuthRequired      = "530 5.7.0 Authentication required\r\n"
+	replyAuthInvalid       = "535 5.7.8 Authentication credentials invalid\r\n"
+	replyAuthActive        = "503 5.5.1 Already authenticated\r\n"
+	replyAuthUnsupported   = "504 5.5.4 Unrecognized authentication type\r\n"
+	replyAuthCancelled     = "501 5.0.0 Authentication cancelled\r\n"
+	replyAuthSyntax        = "501 5.5.4 Malformed authentication data\r\n"
+	replySenderDenied      = "553 5.7.1 Sender address rejected: domain not allowed\r\n"
+	replySizeExceeded      = "552 5.3.4 Message size exceeds fixed maximum message size\r\n"
+	replyTooManyRecipients = "452 4.5.3 Too many recipients\r\n"
+	replyNeedRecipients    = "503 5.5.1 Need RCPT command\r\n"
+	replyStartData         = "354 End data with <CR><LF>.<CR><LF>\r\n"
+	extensionAuth          = "AUTH PLAIN LOGIN"
+)
+
+// policyEnforcer checks sessions against the policy and counts the violations
+type policyEnforcer struct {
+	Policy
+	counter *prometheus.CounterVec
+	counts  map[Violation]*int64
+}
+
+// newPolicyEnforcer returns a policyEnforcer counting violations in the given counter, labeled with the violation
+func newPolicyEnforcer(policy Policy, counter *prometheus.CounterVec) *policyEnforcer {
+	p := &policyEnforcer{Policy: policy, counter: counter, counts: map[Violation]*int64{}}
+	for _, violation := range violations {
+		p.counts[violation] = new(int64)
+		counter.WithLabelValues(string(violation))
+	}
+	return p
+}
+
+// authRequired returns true if clients have to authenticate before sending
+func (p *policyEnforcer) authRequired() bool {
+	return p.CredentialsDir != ""
+}
+
+// violated counts a violation
+func (p *policyEnforcer) violated(violation Violation) {
+	atomic.AddInt64(p.counts[violation], 1)
+	p.counter.WithLabelValues(string(violation)).Inc()
+}
+
+// violationCounts returns the count of every kind of violation
+func (p *policyEnforcer) violationCounts() map[Violation]int64 {
+	counts := map[Violation]int64{}
+	for violation, count := range p.counts {
+		counts[violation] = atomic.LoadInt64(count)
+	}
+	return counts
+}
+
+// authenticate compares the password with the credentials file of the user, the files are read on every attempt
+// so rotated credentials are picked up without a restart
+func (p *policyEnforcer) authenticate(user, password string) bool {
+	if user == "" || strings.ContainsAny(user, `/\`) || strings.HasPrefix(user, ".") {
+		return false
+	}
+	expected, err := os.ReadFile(filepath.Join(p.CredentialsDir, user))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(expected, []byte(password)) == 1
+}
+
+// senderAllowed returns true if the domain of the sender is allowed
+func (p *policyEnforcer) senderAllowed(sender string) bool {
+	if len(p.AllowedSenderDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(sender, "@")
+	if at < 0 {
+		return false
+	}
+	domain := sender[at+1:]
+	for _, allowed := range p.AllowedSenderDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeExceeded returns true if the SIZE parameter of a MAIL FROM command exceeds the maximum size
+func (p *policyEnforcer) sizeExceeded(line string) bool {
+	if p.MaxMessageSize == 0 {
+		return false
+	}
+	for _, param := range strings.Fields(line) {
+		if value := strings.TrimPrefix(strings.ToUpper(param), "SIZE="); value != strings.ToUpper(param) {
+			size, err := strconv.ParseInt(value, 10, 64)
+			return err == nil && size > p.MaxMessageSize
+		}
+	}
+	return false
+}
+
+// extensions replaces the extensions of a positive EHLO response the policy takes over
+func (p *policyEnforcer) extensions(lines []string) []string {
+	if p.authRequired() {
+		lines = appendExtension(removeExtension(lines, "AUTH"), extensionAuth)
+	}
+	if p.MaxMessageSize > 0 {
+		lines = appendExtension(removeExtension(lines, "SIZE"), "SIZE "+strconv.FormatInt(p.MaxMessageSize, 10))
+	}
+	return lines
+}
+
+// removeExtension drops an extension keyword from a positive EHLO response, the greeting line is kept
+func removeExtension(lines []string, keyword string) []string {
+	if responseCode(lines) != "250" {
+		return lines
+	}
+	kept := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if i > 0 && strings.EqualFold(commandVerb(line[4:]), keyword) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) > 0 {
+		last := kept[len(kept)-1]
+		kept[len(kept)-1] = "250 " + last[4:]
+	}
+	return kept
+}
+
+var errNegativePolicyLimit = errors.New("policy limits can not be negative")
@@ -0,0 +1,197 @@
+package smtpproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	dialTimeout    = 5 * time.Second
+	sessionTimeout = 5 * time.Minute
+)
+
+// Proxy accepts smtp sessions on its listeners and relays them to the mailhog backend
+type Proxy struct {
+	cfg       Config
+	logger    logr.Logger
+	tlsConfig *tls.Config
+	registry  *prometheus.Registry
+	rules     *ruleSet
+	policy    *policyEnforcer
+
+	responses   *prometheus.CounterVec
+	transcripts *transcriptRing
+	relayer     *relayer
+}
+
+// New returns a Proxy for the given config
+func New(cfg Config, logger logr.Logger) (*Proxy, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{cfg: cfg, logger: logger, registry: prometheus.NewRegistry()}
+	ruleHits := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mailhog_smtp_proxy_rule_hits_total",
+			Help: "Number of smtp commands a fault injection rule was applied to",
+		},
+		[]string{"rule"},
+	)
+	p.registry.MustRegister(ruleHits)
+	p.rules = newRuleSet(cfg.Rules, ruleHits)
+	policyViolations := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mailhog_smtp_proxy_policy_violations_total",
+			Help: "Number of smtp commands rejected because they violate the policy",
+		},
+		[]string{"violation"},
+	)
+	p.registry.MustRegister(policyViolations)
+	p.policy = newPolicyEnforcer(cfg.Policy, policyViolations)
+	p.responses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mailhog_smtp_proxy_responses_total",
+			Help: "Number of smtp responses sent to clients by reply code",
+		},
+		[]string{"code"},
+	)
+	p.registry.MustRegister(p.responses)
+	if cfg.Transcripts > 0 {
+		p.transcripts = newTranscriptRing(cfg.Transcripts)
+	}
+	if cfg.Relay.enabled() {
+		relayed := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mailhog_smtp_proxy_relayed_total",
+				Help: "Number of captured messages relayed to an upstream by rule and result",
+			},
+			[]string{"rule", "result"},
+		)
+		p.registry.MustRegister(relayed)
+		p.relayer = newRelayer(cfg.Relay, relayed, logger)
+	}
+	if cfg.CertFile != "" {
+		loader, err := newCertLoader(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		p.tlsConfig = loader.tlsConfig()
+	}
+	return p, nil
+}
+
+// Run serves all listeners until the context is cancelled or a listener fails
+func (p *Proxy) Run(ctx context.Context) error {
+	listeners := make([]net.Listener, 0, len(p.cfg.Listeners))
+	for _, l := range p.cfg.Listeners {
+		listener, err := net.Listen("tcp", l.Address)
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+		p.logger.Info("listening", "listener", l.Name, "address", l.Address, "mode", l.Mode)
+	}
+
+	errs := make(chan error, len(listeners)+1)
+	wg := sync.WaitGroup{}
+	var admin *http.Server
+	if p.cfg.AdminAddress != "" {
+		admin = p.adminServer()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+		p.logger.Info("serving admin endpoints", "address", p.cfg.AdminAddress)
+	}
+	relayCtx, stopRelay := context.WithCancel(ctx)
+	defer stopRelay()
+	if p.relayer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.relayer.run(relayCtx)
+		}()
+	}
+	for i := range listeners {
+		wg.Add(1)
+		go func(listener net.Listener, l Listener) {
+			defer wg.Done()
+			errs <- p.serve(listener, l)
+		}(listeners[i], p.cfg.Listeners[i])
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+	for _, listener := range listeners {
+		_ = listener.Close()
+	}
+	if admin != nil {
+		_ = admin.Close()
+	}
+	stopRelay()
+	wg.Wait()
+	return err
+}
+
+// serve accepts connections on a single listener
+func (p *Proxy) serve(listener net.Listener, l Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go p.handle(conn, l)
+	}
+}
+
+// handle relays a single client connection to the backend
+func (p *Proxy) handle(conn net.Conn, l Listener) {
+	logger := p.logger.WithValues("listener", l.Name, "client", conn.RemoteAddr().String())
+
+	if l.Mode == ImplicitTlsMode {
+		conn = tls.Server(conn, p.tlsConfig)
+	}
+
+	backend, err := net.DialTimeout("tcp", p.cfg.Backend, dialTimeout)
+	if err != nil {
+		logger.Error(err, "failed to connect to backend")
+		_, _ = conn.Write([]byte("421 4.3.0 backend unavailable\r\n"))
+		_ = conn.Close()
+		return
+	}
+
+	s := newSession(conn, backend, l.Mode, p)
+	if p.transcripts != nil && l.Mode != OperatorMode {
+		s.transcript = p.transcripts.start(l.Name, conn.RemoteAddr().String())
+	}
+	err = s.relay()
+	if err != nil {
+		logger.V(1).Info("session ended with error", "error", err.Error())
+	}
+	if s.transcript != nil {
+		s.transcript.finish(s.client, err)
+		p.transcripts.add(s.transcript)
+	}
+	s.close()
+}
@@ -0,0 +1,1232 @@
+package smtpproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestProxy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SMTP Proxy Suite")
+}
+
+var _ = Describe("smtp proxy", func() {
+	var (
+		cancel   context.CancelFunc
+		backend  *fakeBackend
+		startTls string
+		smtps    string
+		certDir  string
+	)
+
+	BeforeEach(func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+
+		backend = startFakeBackend()
+		var err error
+		certDir, err = os.MkdirTemp("", "smtp-proxy")
+		Expect(err).ToNot(HaveOccurred())
+		certFile, keyFile := writeTestCertificate(certDir)
+		startTls, smtps = freeAddress(), freeAddress()
+
+		proxy, err := New(Config{
+			Backend:  backend.address,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			Listeners: []Listener{
+				{Name: "starttls", Address: startTls, Mode: StartTlsMode},
+				{Name: "smtps", Address: smtps, Mode: ImplicitTlsMode},
+			},
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			Expect(proxy.Run(ctx)).To(Succeed())
+		}()
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", smtps)
+			if err == nil {
+				_ = conn.Close()
+			}
+			return err
+		}).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+		backend.close()
+		_ = os.RemoveAll(certDir)
+	})
+
+	It("should advertise STARTTLS and relay mail after the upgrade", func() {
+		c, err := smtp.Dial(startTls)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Hello("tester")).To(Succeed())
+		hasStartTls, _ := c.Extension("STARTTLS")
+		Expect(hasStartTls).To(BeTrue())
+
+		Expect(c.StartTLS(&tls.Config{InsecureSkipVerify: true})).To(Succeed()) //#nosec G402
+		sendTestMail(c)
+		Eventually(backend.messages).Should(Receive(ContainSubstring("hello tls")))
+	})
+
+	It("should refuse mail transactions before STARTTLS", func() {
+		c, err := smtp.Dial(startTls)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Hello("tester")).To(Succeed())
+		err = c.Mail("sender@localhost.local")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("530"))
+	})
+
+	It("should relay mail on the implicit tls listener", func() {
+		conn, err := tls.Dial("tcp", smtps, &tls.Config{InsecureSkipVerify: true}) //#nosec G402
+		Expect(err).ToNot(HaveOccurred())
+		c, err := smtp.NewClient(conn, "localhost")
+		Expect(err).ToNot(HaveOccurred())
+		sendTestMail(c)
+		Eventually(backend.messages).Should(Receive(ContainSubstring("hello tls")))
+	})
+
+	It("should end sessions sending lines over the smtp line limits", func() {
+		conn, err := tls.Dial("tcp", smtps, &tls.Config{InsecureSkipVerify: true}) //#nosec G402
+		Expect(err).ToNot(HaveOccurred())
+		text := textproto.NewConn(conn)
+		defer text.Close()
+		_, _, err = text.ReadResponse(220)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text.PrintfLine("EHLO %s", strings.Repeat("a", maxCommandLine))).To(Succeed())
+		_, _, err = text.ReadResponse(250)
+		Expect(err).To(MatchError(HavePrefix("500")))
+
+		conn, err = tls.Dial("tcp", smtps, &tls.Config{InsecureSkipVerify: true}) //#nosec G402
+		Expect(err).ToNot(HaveOccurred())
+		c, err := smtp.NewClient(conn, "localhost")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("sender@localhost.local")).To(Succeed())
+		Expect(c.Rcpt("receiver@localhost.local")).To(Succeed())
+		w, err := c.Data()
+		Expect(err).ToNot(HaveOccurred())
+		_, _ = w.Write([]byte("Subject: test\r\n\r\n" + strings.Repeat("b", maxTextLine) + "\r\n"))
+		Expect(w.Close()).To(MatchError(HavePrefix("500")))
+		Consistently(backend.messages, "200ms").ShouldNot(Receive())
+	})
+})
+
+// sendTestMail sends a short message through the given client
+func sendTestMail(c *smtp.Client) {
+	Expect(c.Mail("sender@localhost.local")).To(Succeed())
+	Expect(c.Rcpt("receiver@localhost.local")).To(Succeed())
+	w, err := c.Data()
+	Expect(err).ToNot(HaveOccurred())
+	_, err = w.Write([]byte("Subject: test\r\n\r\nhello tls\r\n"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(w.Close()).To(Succeed())
+	Expect(c.Quit()).To(Succeed())
+}
+
+// fakeBackend is a minimal smtp server answering like mailhog
+type fakeBackend struct {
+	listener net.Listener
+	address  string
+	messages chan string
+
+	// failures is the count of MAIL commands answered with a temporary failure
+	failures int32
+
+	// envelopes receives the recipients of every delivered message
+	envelopes chan []string
+}
+
+func startFakeBackend() *fakeBackend {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	b := &fakeBackend{listener: listener, address: listener.Addr().String(), messages: make(chan string, 10), envelopes: make(chan []string, 10)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBackend) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	_, _ = conn.Write([]byte("220 mailhog.example ESMTP MailHog\r\n"))
+	recipients := []string{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch commandVerb(line) {
+		case "EHLO":
+			_, _ = conn.Write([]byte("250-Hello tester\r\n250-PIPELINING\r\n250 AUTH EXTERNAL CRAM-MD5 LOGIN PLAIN\r\n"))
+		case "DATA":
+			_, _ = conn.Write([]byte("354 End data with <CR><LF>.<CR><LF>\r\n"))
+			body := ""
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if strings.TrimRight(dataLine, "\r\n") == "." {
+					break
+				}
+				body += dataLine
+			}
+			b.messages <- body
+			b.envelopes <- recipients
+			recipients = []string{}
+			_, _ = conn.Write([]byte("250 Ok: queued\r\n"))
+		case "MAIL":
+			if atomic.AddInt32(&b.failures, -1) >= 0 {
+				_, _ = conn.Write([]byte("451 4.3.0 Try again later\r\n"))
+				continue
+			}
+			_, _ = conn.Write([]byte("250 Ok\r\n"))
+		case "RCPT":
+			recipients = append(recipients, envelopeAddress(line))
+			_, _ = conn.Write([]byte("250 Ok\r\n"))
+		case "QUIT":
+			_, _ = conn.Write([]byte("221 Bye\r\n"))
+			return
+		default:
+			_, _ = conn.Write([]byte("250 Ok\r\n"))
+		}
+	}
+}
+
+func (b *fakeBackend) close() {
+	_ = b.listener.Close()
+}
+
+// freeAddress returns a currently unused local address
+func freeAddress() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// writeTestCertificate writes a self signed certificate for localhost into dir
+func writeTestCertificate(dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	Expect(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)).To(Succeed())
+	Expect(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)).To(Succeed())
+	return certFile, keyFile
+}
+
+var _ = Describe("smtp proxy fault injection", func() {
+	var (
+		cancel  context.CancelFunc
+		backend *fakeBackend
+		plain   string
+		admin   string
+	)
+
+	startProxy := func(rules ...Rule) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		backend = startFakeBackend()
+		plain, admin = freeAddress(), freeAddress()
+
+		proxy, err := New(Config{
+			Backend:      backend.address,
+			Listeners:    []Listener{{Name: "smtp", Address: plain, Mode: PlainMode}},
+			Rules:        rules,
+			AdminAddress: admin,
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			Expect(proxy.Run(ctx)).To(Succeed())
+		}()
+		Eventually(func() error {
+			_, err := http.Get("http://" + admin + RulesPath)
+			return err
+		}).Should(Succeed())
+	}
+
+	ruleHits := func() (hits map[string]int64) {
+		response, err := http.Get("http://" + admin + RulesPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		counts := []RuleHits{}
+		Expect(json.NewDecoder(response.Body).Decode(&counts)).To(Succeed())
+		hits = map[string]int64{}
+		for _, count := range counts {
+			hits[count.Name] = count.Hits
+		}
+		return hits
+	}
+
+	AfterEach(func() {
+		cancel()
+		backend.close()
+	})
+
+	It("should reject matching recipients and count the hits", func() {
+		startProxy(Rule{Name: "bounces", Stage: RcptToStage, Address: "bounce-*@", Action: RejectAction, Code: 550, Message: "5.1.1 Mailbox does not exist"})
+
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("sender@localhost.local")).To(Succeed())
+		err = c.Rcpt("Bounce-42@receiver.example")
+		Expect(err).To(Equal(&textproto.Error{Code: 550, Msg: "5.1.1 Mailbox does not exist"}))
+		Expect(c.Rcpt("user@receiver.example")).To(Succeed())
+		Expect(c.Quit()).To(Succeed())
+
+		Expect(ruleHits()).To(Equal(map[string]int64{"bounces": 1}))
+		response, err := http.Get("http://" + admin + MetricsPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		metrics, err := io.ReadAll(response.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(metrics)).To(ContainSubstring(`mailhog_smtp_proxy_rule_hits_total{rule="bounces"} 1`))
+	})
+
+	It("should greylist the first attempt of a sender", func() {
+		startProxy(Rule{Name: "greylist", Stage: MailFromStage, Action: GreylistAction})
+
+		for attempt, expected := range []string{"451", "", ""} {
+			c, err := smtp.Dial(plain)
+			Expect(err).ToNot(HaveOccurred())
+			err = c.Mail("app@sender.example")
+			if expected == "" {
+				Expect(err).ToNot(HaveOccurred(), "attempt %d", attempt)
+			} else {
+				Expect(err.Error()).To(HavePrefix(expected))
+			}
+			_ = c.Quit()
+		}
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("other@sender.example").Error()).To(HavePrefix("451"))
+		Expect(ruleHits()["greylist"]).To(Equal(int64(2)))
+	})
+
+	It("should forget greylisted senders after the expiry and keep the greylist bounded", func() {
+		originalExpiry, originalMax := greylistExpiry, greylistMaxEntries
+		defer func() { greylistExpiry, greylistMaxEntries = originalExpiry, originalMax }()
+		greylistExpiry, greylistMaxEntries = 50*time.Millisecond, 2
+		rules := newRuleSet([]Rule{{Name: "greylist", Stage: MailFromStage, Action: GreylistAction}},
+			prometheus.NewCounterVec(prometheus.CounterOpts{Name: "greylist_test_hits"}, []string{"rule"}))
+
+		Expect(rules.firstAttempt("greylist", "a@sender.example")).To(BeTrue())
+		Expect(rules.firstAttempt("greylist", "A@sender.example")).To(BeFalse())
+		Expect(rules.firstAttempt("greylist", "b@sender.example")).To(BeTrue())
+		Expect(rules.firstAttempt("greylist", "c@sender.example")).To(BeTrue())
+		Expect(rules.greylist).To(HaveLen(2))
+		// the oldest sender made room for the newest one
+		Expect(rules.firstAttempt("greylist", "c@sender.example")).To(BeFalse())
+		Expect(rules.greylist).ToNot(HaveKey("greylist\x00a@sender.example"))
+
+		time.Sleep(greylistExpiry)
+		Expect(rules.firstAttempt("greylist", "b@sender.example")).To(BeTrue())
+		Expect(rules.greylist).To(HaveLen(1))
+	})
+
+	It("should delay DATA for a recipient domain", func() {
+		startProxy(Rule{Name: "slow", Stage: DataStage, Address: "@slow.example", Action: DelayAction, Delay: 300 * time.Millisecond})
+
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		started := time.Now()
+		sendTestMail(c)
+		Expect(time.Since(started)).To(BeNumerically("<", 300*time.Millisecond))
+
+		c, err = smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("sender@localhost.local")).To(Succeed())
+		Expect(c.Rcpt("user@slow.example")).To(Succeed())
+		started = time.Now()
+		w, err := c.Data()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(time.Since(started)).To(BeNumerically(">=", 300*time.Millisecond))
+		Expect(w.Close()).To(Succeed())
+	})
+
+	It("should drop the connection after a number of messages", func() {
+		startProxy(Rule{Name: "drop", Stage: MailFromStage, AfterMessages: 2, Action: DisconnectAction})
+
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < 2; i++ {
+			Expect(c.Mail("sender@localhost.local")).To(Succeed())
+			Expect(c.Rcpt("receiver@localhost.local")).To(Succeed())
+			w, err := c.Data()
+			Expect(err).ToNot(HaveOccurred())
+			_, _ = w.Write([]byte("Subject: test\r\n\r\nhello\r\n"))
+			Expect(w.Close()).To(Succeed())
+		}
+		Expect(c.Mail("sender@localhost.local")).To(MatchError(io.EOF))
+		Expect(ruleHits()["drop"]).To(Equal(int64(1)))
+	})
+
+	It("should reject sessions by a connect rule before the greeting", func() {
+		startProxy(Rule{Name: "closed", Stage: ConnectStage, Action: RejectAction, Code: 554, Message: "5.3.2 Service not available"})
+
+		_, err := smtp.Dial(plain)
+		Expect(err).To(Equal(&textproto.Error{Code: 554, Msg: "5.3.2 Service not available"}))
+	})
+})
+
+var _ = Describe("smtp proxy rules", func() {
+	It("should match address patterns", func() {
+		Expect(matchesAny("", []string{"any@where"})).To(BeTrue())
+		Expect(matchesAny("bounce-*@", []string{"a@b", "bounce-1@receiver.example"})).To(BeTrue())
+		Expect(matchesAny("bounce-*@", []string{"nobounce-1@receiver.example"})).To(BeFalse())
+		Expect(matchesAny("@Slow.example", []string{"user@slow.example"})).To(BeTrue())
+		Expect(matchesAny("*@*.internal", []string{"app@mail.corp.internal"})).To(BeTrue())
+		Expect(matchesAny("exact@domain", []string{"exact@domain.org"})).To(BeFalse())
+		Expect(matchesAny("a*a", []string{"a"})).To(BeFalse())
+	})
+
+	It("should refuse relay rules without a known upstream", func() {
+		base := Config{Backend: "mailhog:1025", Listeners: []Listener{{Name: "smtp", Address: ":1026", Mode: PlainMode}}}
+		base.Relay = Relay{
+			Upstreams:   []RelayUpstream{{Name: "corp", Address: "relay.corp:25"}},
+			Rules:       []RelayRule{{Name: "staff", Recipients: []string{"@ourcompany.com"}, Upstream: "other"}},
+			MaxAttempts: 1,
+		}
+		Expect(base.validate()).To(MatchError(errUnknownRelayUpstream))
+		base.Relay.Rules[0].Upstream = "corp"
+		Expect(base.validate()).To(Succeed())
+		base.Relay.Upstreams[0].MinTlsVersion = "1.4"
+		Expect(base.validate()).To(MatchError(errInvalidTlsVersion))
+		base.Relay.Upstreams[0].MinTlsVersion = "1.3"
+		Expect(base.validate()).To(Succeed())
+		base.Relay.Upstreams[0].UsernameFile = "/etc/smtp-proxy/relay/relay-0-username"
+		Expect(base.validate()).To(MatchError(errMissingRelayPassword))
+		base.Relay.Upstreams[0].PasswordFile = "/etc/smtp-proxy/relay/relay-0-password"
+		Expect(base.validate()).To(Succeed())
+		base.Relay.Upstreams[0].Address = "relay.corp"
+		Expect(base.validate()).To(MatchError(errInvalidRelayUpstream))
+	})
+
+	It("should refuse invalid rules", func() {
+		base := Config{Backend: "mailhog:1025", Listeners: []Listener{{Name: "smtp", Address: ":1026", Mode: PlainMode}}}
+		for rule, expected := range map[*Rule]error{
+			{Stage: RcptToStage, Action: DisconnectAction}:                                   errMissingRuleName,
+			{Name: "a", Stage: "helo", Action: DisconnectAction}:                             errUnknownStage,
+			{Name: "a", Stage: ConnectStage, Action: GreylistAction}:                         errInvalidConnectRule,
+			{Name: "a", Stage: RcptToStage, Action: RejectAction, Code: 250}:                 errInvalidRejectCode,
+			{Name: "a", Stage: DataStage, Action: DelayAction}:                               errMissingDelay,
+			{Name: "a", Stage: DataStage, Action: "explode"}:                                 errUnknownAction,
+			{Name: "a", Stage: MailFromStage, Action: RejectAction, Code: 421, Address: "x"}: nil,
+		} {
+			cfg := base
+			cfg.Rules = []Rule{*rule}
+			if expected == nil {
+				Expect(cfg.validate()).To(Succeed())
+				cfg.Rules = append(cfg.Rules, *rule)
+				expected = errDuplicateRuleName
+			}
+			Expect(cfg.validate()).To(MatchError(expected))
+		}
+	})
+})
+
+var _ = Describe("smtp proxy policy", func() {
+	var (
+		cancel  context.CancelFunc
+		backend *fakeBackend
+		plain   string
+		admin   string
+	)
+
+	startProxy := func(policy Policy) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		backend = startFakeBackend()
+		plain, admin = freeAddress(), freeAddress()
+
+		proxy, err := New(Config{
+			Backend:      backend.address,
+			Listeners:    []Listener{{Name: "smtp", Address: plain, Mode: PlainMode}},
+			Policy:       policy,
+			AdminAddress: admin,
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			Expect(proxy.Run(ctx)).To(Succeed())
+		}()
+		Eventually(func() error {
+			_, err := http.Get("http://" + admin + ViolationsPath)
+			return err
+		}).Should(Succeed())
+	}
+
+	violationCounts := func() (counts map[Violation]int64) {
+		response, err := http.Get("http://" + admin + ViolationsPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		Expect(json.NewDecoder(response.Body).Decode(&counts)).To(Succeed())
+		return counts
+	}
+
+	AfterEach(func() {
+		cancel()
+		backend.close()
+	})
+
+	It("should require AUTH with the mounted credentials", func() {
+		credentials, err := os.MkdirTemp("", "smtp-credentials")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(credentials)
+		Expect(os.WriteFile(filepath.Join(credentials, "app"), []byte("s3cret"), 0o600)).To(Succeed())
+		startProxy(Policy{CredentialsDir: credentials})
+
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Hello("tester")).To(Succeed())
+		found, mechanisms := c.Extension("AUTH")
+		Expect(found).To(BeTrue())
+		Expect(mechanisms).To(Equal("PLAIN LOGIN"))
+		Expect(c.Mail("sender@localhost.local")).To(Equal(&textproto.Error{Code: 530, Msg: "5.7.0 Authentication required"}))
+		// net/smtp quits after a failed AUTH, every attempt needs its own session
+		Expect(c.Auth(smtp.PlainAuth("", "app", "wrong", "127.0.0.1"))).To(Equal(&textproto.Error{Code: 535, Msg: "5.7.8 Authentication credentials invalid"}))
+		c, err = smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Auth(smtp.PlainAuth("", "../app", "s3cret", "127.0.0.1"))).ToNot(Succeed())
+		c, err = smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Auth(smtp.PlainAuth("", "app", "s3cret", "127.0.0.1"))).To(Succeed())
+		sendTestMail(c)
+		Eventually(backend.messages).Should(Receive())
+
+		Expect(violationCounts()).To(Equal(map[Violation]int64{
+			AuthViolation: 3, SenderViolation: 0, SizeViolation: 0, RecipientsViolation: 0,
+		}))
+		response, err := http.Get("http://" + admin + MetricsPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		metrics, err := io.ReadAll(response.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(metrics)).To(ContainSubstring(`mailhog_smtp_proxy_policy_violations_total{violation="auth"} 3`))
+	})
+
+	It("should accept AUTH LOGIN with continuations", func() {
+		credentials, err := os.MkdirTemp("", "smtp-credentials")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(credentials)
+		Expect(os.WriteFile(filepath.Join(credentials, "app"), []byte("s3cret"), 0o600)).To(Succeed())
+		startProxy(Policy{CredentialsDir: credentials})
+
+		conn, err := textproto.Dial("tcp", plain)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		_, _, err = conn.ReadResponse(220)
+		Expect(err).ToNot(HaveOccurred())
+		for _, step := range []struct {
+			command string
+			code    int
+		}{
+			{"AUTH LOGIN", 334},
+			{"YXBw", 334},
+			{"czNjcmV0", 235},
+		} {
+			Expect(conn.PrintfLine(step.command)).To(Succeed())
+			_, _, err = conn.ReadResponse(step.code)
+			Expect(err).ToNot(HaveOccurred(), step.command)
+		}
+	})
+
+	It("should reject senders outside of the allowed domains", func() {
+		startProxy(Policy{AllowedSenderDomains: []string{"Sender.example"}})
+
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("app@other.example")).To(Equal(&textproto.Error{Code: 553, Msg: "5.7.1 Sender address rejected: domain not allowed"}))
+		Expect(c.Mail("app@sender.example")).To(Succeed())
+		Expect(violationCounts()[SenderViolation]).To(Equal(int64(1)))
+	})
+
+	It("should limit the recipients of a message", func() {
+		startProxy(Policy{MaxRecipients: 2})
+
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("sender@localhost.local")).To(Succeed())
+		Expect(c.Rcpt("one@receiver.example")).To(Succeed())
+		Expect(c.Rcpt("two@receiver.example")).To(Succeed())
+		Expect(c.Rcpt("three@receiver.example")).To(Equal(&textproto.Error{Code: 452, Msg: "4.5.3 Too many recipients"}))
+		Expect(c.Reset()).To(Succeed())
+		Expect(c.Mail("sender@localhost.local")).To(Succeed())
+		Expect(c.Rcpt("three@receiver.example")).To(Succeed())
+		Expect(violationCounts()[RecipientsViolation]).To(Equal(int64(1)))
+	})
+
+	It("should reject oversized messages without delivering them", func() {
+		startProxy(Policy{MaxMessageSize: 64})
+
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Hello("tester")).To(Succeed())
+		_, size := c.Extension("SIZE")
+		Expect(size).To(Equal("64"))
+
+		Expect(c.Mail("sender@localhost.local")).To(Succeed())
+		Expect(c.Rcpt("receiver@localhost.local")).To(Succeed())
+		w, err := c.Data()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write([]byte("Subject: large\r\n\r\n" + strings.Repeat("attachment\r\n", 10)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Close()).To(Equal(&textproto.Error{Code: 552, Msg: "5.3.4 Message size exceeds fixed maximum message size"}))
+		Consistently(backend.messages, 100*time.Millisecond).ShouldNot(Receive())
+
+		sendTestMail(c)
+		var body string
+		Eventually(backend.messages).Should(Receive(&body))
+		Expect(body).To(ContainSubstring("hello tls"))
+		Expect(violationCounts()[SizeViolation]).To(Equal(int64(1)))
+	})
+
+	It("should reject messages announcing an oversized SIZE", func() {
+		startProxy(Policy{MaxMessageSize: 1024})
+
+		conn, err := textproto.Dial("tcp", plain)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		_, _, err = conn.ReadResponse(220)
+		Expect(err).ToNot(HaveOccurred())
+		for _, step := range []struct {
+			command string
+			code    int
+		}{
+			{"MAIL FROM:<a@sender.example> size=2048", 552},
+			{"MAIL FROM:<a@sender.example> SIZE=1024", 250},
+		} {
+			Expect(conn.PrintfLine(step.command)).To(Succeed())
+			_, _, err = conn.ReadResponse(step.code)
+			Expect(err).ToNot(HaveOccurred(), step.command)
+		}
+		Expect(violationCounts()[SizeViolation]).To(Equal(int64(1)))
+	})
+})
+
+var _ = Describe("smtp proxy transcripts", func() {
+	var (
+		cancel  context.CancelFunc
+		backend *fakeBackend
+		plain   string
+		smtps   string
+		admin   string
+		tempDir string
+	)
+
+	BeforeEach(func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		backend = startFakeBackend()
+		var err error
+		tempDir, err = os.MkdirTemp("", "smtp-transcripts")
+		Expect(err).ToNot(HaveOccurred())
+		certFile, keyFile := writeTestCertificate(tempDir)
+		Expect(os.WriteFile(filepath.Join(tempDir, "app"), []byte("s3cret"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tempDir, "operator.password"), []byte("0perator"), 0o600)).To(Succeed())
+		plain, smtps, admin = freeAddress(), freeAddress(), freeAddress()
+
+		proxy, err := New(Config{
+			Backend:  backend.address,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			Listeners: []Listener{
+				{Name: "smtp", Address: plain, Mode: PlainMode},
+				{Name: "smtps", Address: smtps, Mode: ImplicitTlsMode},
+			},
+			Policy:       Policy{CredentialsDir: tempDir},
+			Transcripts:  2,
+			AdminAddress: admin,
+			Operator:     Operator{User: "operator", PasswordFile: filepath.Join(tempDir, "operator.password")},
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			Expect(proxy.Run(ctx)).To(Succeed())
+		}()
+		Eventually(func() error {
+			_, err := http.Get("http://" + admin + TranscriptsPath)
+			return err
+		}).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+		backend.close()
+		_ = os.RemoveAll(tempDir)
+	})
+
+	transcripts := func() (transcripts []Transcript) {
+		request, err := http.NewRequest(http.MethodGet, "http://"+admin+TranscriptsPath, http.NoBody)
+		Expect(err).ToNot(HaveOccurred())
+		request.SetBasicAuth("operator", "0perator")
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		Expect(json.NewDecoder(response.Body).Decode(&transcripts)).To(Succeed())
+		return transcripts
+	}
+
+	It("should keep the last sessions with redacted credentials", func() {
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Auth(smtp.PlainAuth("", "app", "s3cret", "127.0.0.1"))).To(Succeed())
+		sendTestMail(c)
+
+		c, err = smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("sender@localhost.local")).ToNot(Succeed())
+		Expect(c.Quit()).To(Succeed())
+
+		conn, err := tls.Dial("tcp", smtps, &tls.Config{InsecureSkipVerify: true}) //#nosec G402
+		Expect(err).ToNot(HaveOccurred())
+		c, err = smtp.NewClient(conn, "localhost")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Auth(smtp.PlainAuth("", "app", "s3cret", "localhost"))).To(Succeed())
+		sendTestMail(c)
+
+		Eventually(func() []int64 {
+			ids := []int64{}
+			for _, transcript := range transcripts() {
+				ids = append(ids, transcript.ID)
+			}
+			return ids
+		}).Should(Equal([]int64{2, 3}))
+
+		kept := transcripts()
+		Expect(kept[0].Listener).To(Equal("smtp"))
+		Expect(kept[0].TlsVersion).To(BeEmpty())
+		Expect(kept[0].AuthMechanism).To(BeEmpty())
+		Expect(kept[0].Entries).To(ContainElement(HaveField("Line", "530 5.7.0 Authentication required")))
+
+		Expect(kept[1].Listener).To(Equal("smtps"))
+		Expect(kept[1].TlsVersion).To(Equal("TLSv1.3"))
+		Expect(kept[1].AuthMechanism).To(Equal("PLAIN"))
+		Expect(kept[1].Duration).To(BeNumerically(">", 0))
+		Expect(kept[1].Entries).To(ContainElements(
+			SatisfyAll(HaveField("Kind", CommandEntry), HaveField("Line", "AUTH PLAIN "+redacted)),
+			HaveField("Kind", DataEntry),
+		))
+		raw, err := json.Marshal(kept)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(raw)).ToNot(ContainSubstring("AGFwcABzM2NyZXQ="))
+
+		response, err := http.Get("http://" + admin + MetricsPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		metrics, err := io.ReadAll(response.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(metrics)).To(ContainSubstring(`mailhog_smtp_proxy_responses_total{code="235"} 2`))
+		Expect(string(metrics)).To(ContainSubstring(`mailhog_smtp_proxy_responses_total{code="530"} 1`))
+	})
+
+	It("should only serve the transcripts to the operator", func() {
+		response, err := http.Get("http://" + admin + TranscriptsPath)
+		Expect(err).ToNot(HaveOccurred())
+		_ = response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusUnauthorized))
+
+		request, err := http.NewRequest(http.MethodGet, "http://"+admin+TranscriptsPath, http.NoBody)
+		Expect(err).ToNot(HaveOccurred())
+		request.SetBasicAuth("app", "s3cret")
+		response, err = http.DefaultClient.Do(request)
+		Expect(err).ToNot(HaveOccurred())
+		_ = response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusUnauthorized))
+
+		Expect(transcripts()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("smtp proxy relay", func() {
+	var (
+		cancel   context.CancelFunc
+		backend  *fakeBackend
+		upstream *fakeBackend
+		plain    string
+		admin    string
+	)
+
+	startProxy := func(relay Relay) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		plain, admin = freeAddress(), freeAddress()
+
+		proxy, err := New(Config{
+			Backend:      backend.address,
+			Listeners:    []Listener{{Name: "smtp", Address: plain, Mode: PlainMode}},
+			Relay:        relay,
+			AdminAddress: admin,
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			Expect(proxy.Run(ctx)).To(Succeed())
+		}()
+		Eventually(func() error {
+			_, err := http.Get("http://" + admin + RelayPath)
+			return err
+		}).Should(Succeed())
+	}
+
+	relayStats := func() (stats []RelayStats) {
+		response, err := http.Get("http://" + admin + RelayPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		Expect(json.NewDecoder(response.Body).Decode(&stats)).To(Succeed())
+		return stats
+	}
+
+	send := func(recipients ...string) {
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("app@sender.example")).To(Succeed())
+		for _, recipient := range recipients {
+			Expect(c.Rcpt(recipient)).To(Succeed())
+		}
+		w, err := c.Data()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write([]byte("Subject: relay\r\n\r\n.dotted line\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(c.Quit()).To(Succeed())
+	}
+
+	relayTo := func(address string) Relay {
+		return Relay{
+			Upstreams:     []RelayUpstream{{Name: "corp", Address: address}},
+			Rules:         []RelayRule{{Name: "staff", Recipients: []string{"@ourcompany.com"}, Upstream: "corp"}},
+			MaxAttempts:   3,
+			RetryInterval: 50 * time.Millisecond,
+		}
+	}
+
+	// startTlsUpstream starts another proxy offering STARTTLS in front of the fake upstream
+	startTlsUpstream := func() (address, certFile string, stop func()) {
+		certDir, err := os.MkdirTemp("", "smtp-relay")
+		Expect(err).ToNot(HaveOccurred())
+		certFile, keyFile := writeTestCertificate(certDir)
+		address = freeAddress()
+		tlsUpstream, err := New(Config{
+			Backend:   upstream.address,
+			CertFile:  certFile,
+			KeyFile:   keyFile,
+			Listeners: []Listener{{Name: "starttls", Address: address, Mode: StartTlsMode}},
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancelUpstream := context.WithCancel(context.Background())
+		go func() {
+			defer GinkgoRecover()
+			Expect(tlsUpstream.Run(ctx)).To(Succeed())
+		}()
+		return address, certFile, func() {
+			cancelUpstream()
+			_ = os.RemoveAll(certDir)
+		}
+	}
+
+	BeforeEach(func() {
+		backend = startFakeBackend()
+		upstream = startFakeBackend()
+	})
+
+	AfterEach(func() {
+		cancel()
+		backend.close()
+		upstream.close()
+	})
+
+	It("should authenticate at the upstream with the credentials files", func() {
+		credentials, err := os.MkdirTemp("", "smtp-relay-credentials")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(credentials)
+		Expect(os.WriteFile(filepath.Join(credentials, "app"), []byte("s3cret"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(credentials, "username"), []byte("app"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(credentials, "password"), []byte("s3cret"), 0o600)).To(Succeed())
+
+		// another proxy requiring AUTH in front of the fake upstream
+		address := freeAddress()
+		authUpstream, err := New(Config{
+			Backend:   upstream.address,
+			Listeners: []Listener{{Name: "smtp", Address: address, Mode: PlainMode}},
+			Policy:    Policy{CredentialsDir: credentials},
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+		ctx, cancelUpstream := context.WithCancel(context.Background())
+		defer cancelUpstream()
+		go func() {
+			defer GinkgoRecover()
+			Expect(authUpstream.Run(ctx)).To(Succeed())
+		}()
+
+		relay := relayTo(address)
+		relay.Upstreams[0].UsernameFile = filepath.Join(credentials, "username")
+		relay.Upstreams[0].PasswordFile = filepath.Join(credentials, "password")
+		startProxy(relay)
+
+		send("dev@ourcompany.com")
+		Eventually(backend.messages).Should(Receive())
+		Eventually(upstream.messages).Should(Receive(ContainSubstring("Subject: relay")))
+	})
+
+	It("should give up messages exceeding the queue size", func() {
+		// nothing listens on the upstream address, so the first message waits for its retries
+		relay := relayTo(freeAddress())
+		relay.MaxAttempts = 100
+		relay.RetryInterval = time.Minute
+		relay.MaxQueueBytes = 100
+		startProxy(relay)
+
+		send("dev@ourcompany.com")
+		send("ops@ourcompany.com")
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("app@sender.example")).To(Succeed())
+		Expect(c.Rcpt("dev@ourcompany.com")).To(Succeed())
+		w, err := c.Data()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write([]byte("Subject: large\r\n\r\n" + strings.Repeat("0123456789", 20) + "\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(c.Quit()).To(Succeed())
+
+		// mailhog still stores every message
+		for i := 0; i < 3; i++ {
+			Eventually(backend.messages).Should(Receive())
+		}
+		Eventually(relayStats).Should(Equal([]RelayStats{{Name: "staff", Failed: 2, Retries: 1, Pending: 1}}))
+	})
+
+	It("should capture every message and relay the matching recipients with a header", func() {
+		startProxy(relayTo(upstream.address))
+
+		send("dev@ourcompany.com", "customer@elsewhere.example")
+		Eventually(backend.envelopes).Should(Receive(Equal([]string{"dev@ourcompany.com", "customer@elsewhere.example"})))
+		Eventually(upstream.envelopes).Should(Receive(Equal([]string{"dev@ourcompany.com"})))
+		var relayed string
+		Eventually(upstream.messages).Should(Receive(&relayed))
+		Expect(relayed).To(HavePrefix(RelayHeader + ": staff; upstream=corp\r\nSubject: relay\r\n"))
+		Expect(relayed).To(ContainSubstring("\r\n..dotted line\r\n"))
+
+		send("customer@elsewhere.example")
+		Eventually(backend.messages).Should(Receive())
+		Consistently(upstream.messages, 100*time.Millisecond).ShouldNot(Receive())
+		Expect(relayStats()).To(Equal([]RelayStats{{Name: "staff", Relayed: 1}}))
+	})
+
+	It("should verify the upstream certificate with its ca file and server name", func() {
+		startTls, certFile, stop := startTlsUpstream()
+		defer stop()
+
+		relay := relayTo(startTls)
+		relay.MaxAttempts = 1
+		relay.Upstreams[0].CaFile = certFile
+		relay.Upstreams[0].ServerName = "localhost"
+		relay.Upstreams[0].MinTlsVersion = "1.3"
+		startProxy(relay)
+
+		send("dev@ourcompany.com")
+		Eventually(upstream.envelopes).Should(Receive(Equal([]string{"dev@ourcompany.com"})))
+		Eventually(relayStats).Should(Equal([]RelayStats{{Name: "staff", Relayed: 1}}))
+	})
+
+	It("should give up on upstreams with an untrusted certificate", func() {
+		startTls, _, stop := startTlsUpstream()
+		defer stop()
+
+		relay := relayTo(startTls)
+		relay.MaxAttempts = 1
+		startProxy(relay)
+
+		send("dev@ourcompany.com")
+		Eventually(relayStats).Should(Equal([]RelayStats{{Name: "staff", Failed: 1}}))
+		Consistently(upstream.envelopes, 100*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("should retry temporary failures", func() {
+		upstream.failures = 1
+		startProxy(relayTo(upstream.address))
+
+		send("dev@ourcompany.com")
+		Eventually(upstream.messages).Should(Receive())
+		Eventually(relayStats).Should(Equal([]RelayStats{{Name: "staff", Relayed: 1, Retries: 1}}))
+	})
+
+	It("should give up after the last attempt", func() {
+		startProxy(relayTo(freeAddress()))
+
+		send("dev@ourcompany.com")
+		Eventually(relayStats).Should(Equal([]RelayStats{{Name: "staff", Failed: 1, Retries: 2}}))
+
+		response, err := http.Get("http://" + admin + MetricsPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		metrics, err := io.ReadAll(response.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(metrics)).To(ContainSubstring(`mailhog_smtp_proxy_relayed_total{result="failed",rule="staff"} 1`))
+	})
+
+	It("should limit the relay rate", func() {
+		relay := relayTo(upstream.address)
+		relay.RatePerMinute = 600
+		startProxy(relay)
+
+		started := time.Now()
+		for i := 0; i < 3; i++ {
+			send("dev@ourcompany.com")
+		}
+		for i := 0; i < 3; i++ {
+			Eventually(upstream.messages).Should(Receive())
+		}
+		Expect(time.Since(started)).To(BeNumerically(">=", 200*time.Millisecond))
+	})
+})
+
+var _ = Describe("smtp proxy operator listener", func() {
+	var (
+		cancel   context.CancelFunc
+		backend  *fakeBackend
+		plain    string
+		operator string
+		admin    string
+		tempDir  string
+	)
+
+	BeforeEach(func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		backend = startFakeBackend()
+		var err error
+		tempDir, err = os.MkdirTemp("", "smtp-operator")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(tempDir, "password"), []byte("0perator"), 0o600)).To(Succeed())
+		plain, operator, admin = freeAddress(), freeAddress(), freeAddress()
+
+		proxy, err := New(Config{
+			Backend: backend.address,
+			Listeners: []Listener{
+				{Name: "smtp", Address: plain, Mode: PlainMode},
+				{Name: "smtp-operator", Address: operator, Mode: OperatorMode},
+			},
+			Rules:        []Rule{{Name: "closed", Stage: ConnectStage, Action: RejectAction, Code: 554, Message: "5.3.2 Service not available"}},
+			Policy:       Policy{MaxRecipients: 1},
+			Transcripts:  5,
+			AdminAddress: admin,
+			Operator:     Operator{User: "operator", PasswordFile: filepath.Join(tempDir, "password")},
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			Expect(proxy.Run(ctx)).To(Succeed())
+		}()
+		Eventually(func() error {
+			_, err := http.Get("http://" + admin + MetricsPath)
+			return err
+		}).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+		backend.close()
+		_ = os.RemoveAll(tempDir)
+	})
+
+	It("should refuse mail until the operator authenticated", func() {
+		c, err := smtp.Dial(operator)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("sender@localhost.local")).To(MatchError(ContainSubstring("Authentication required")))
+		// net/smtp quits the session after a refused AUTH
+		Expect(c.Auth(smtp.PlainAuth("", "operator", "wrong", "127.0.0.1"))).To(MatchError(ContainSubstring("credentials invalid")))
+
+		Expect(New(Config{
+			Backend:   backend.address,
+			Listeners: []Listener{{Name: "smtp-operator", Address: operator, Mode: OperatorMode}},
+		}, zap.New(zap.WriteTo(GinkgoWriter)))).Error().To(MatchError(errMissingOperator))
+	})
+
+	It("should pass the operator's sessions by rules, policy and transcripts", func() {
+		_, err := smtp.Dial(plain)
+		Expect(err).To(Equal(&textproto.Error{Code: 554, Msg: "5.3.2 Service not available"}))
+
+		c, err := smtp.Dial(operator)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Auth(smtp.PlainAuth("", "operator", "0perator", "127.0.0.1"))).To(Succeed())
+		Expect(c.Mail("sender@localhost.local")).To(Succeed())
+		Expect(c.Rcpt("a@localhost.local")).To(Succeed())
+		Expect(c.Rcpt("b@localhost.local")).To(Succeed())
+		w, err := c.Data()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write([]byte("Subject: restored\r\n\r\nhello operator\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(c.Quit()).To(Succeed())
+
+		Eventually(backend.messages).Should(Receive(ContainSubstring("hello operator")))
+
+		request, err := http.NewRequest(http.MethodGet, "http://"+admin+TranscriptsPath, http.NoBody)
+		Expect(err).ToNot(HaveOccurred())
+		request.SetBasicAuth("operator", "0perator")
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		transcripts := []Transcript{}
+		Expect(json.NewDecoder(response.Body).Decode(&transcripts)).To(Succeed())
+		for _, transcript := range transcripts {
+			Expect(transcript.Listener).ToNot(Equal("smtp-operator"))
+		}
+	})
+})
+
+var _ = Describe("smtp proxy operator exemption", func() {
+	var (
+		cancel  context.CancelFunc
+		backend *fakeBackend
+		plain   string
+		tempDir string
+	)
+
+	startProxy := func(policy Policy, rules []Rule) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		proxy, err := New(Config{
+			Backend:   backend.address,
+			Listeners: []Listener{{Name: "smtp", Address: plain, Mode: PlainMode}},
+			Rules:     rules,
+			Policy:    policy,
+			Operator:  Operator{User: "operator", PasswordFile: filepath.Join(tempDir, "password")},
+		}, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			Expect(proxy.Run(ctx)).To(Succeed())
+		}()
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", plain)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		backend = startFakeBackend()
+		var err error
+		tempDir, err = os.MkdirTemp("", "smtp-operator")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(tempDir, "password"), []byte("0perator"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tempDir, "app"), []byte("s3cret"), 0o600)).To(Succeed())
+		plain = freeAddress()
+	})
+
+	AfterEach(func() {
+		cancel()
+		backend.close()
+		_ = os.RemoveAll(tempDir)
+	})
+
+	It("should let the operator pass policy and rules on the service listener", func() {
+		startProxy(Policy{CredentialsDir: tempDir, AllowedSenderDomains: []string{"app.example"}, MaxRecipients: 1}, []Rule{
+			{Name: "bounces", Stage: RcptToStage, Address: "@localhost.local", Action: RejectAction, Code: 550, Message: "5.1.1 Mailbox unavailable"},
+		})
+
+		c, err := smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Mail("sender@localhost.local")).To(Equal(&textproto.Error{Code: 530, Msg: "5.7.0 Authentication required"}))
+		Expect(c.Auth(smtp.PlainAuth("", "operator", "0perator", "127.0.0.1"))).To(Succeed())
+		Expect(c.Mail("smtp-verification@mailhog-operator.local")).To(Succeed())
+		Expect(c.Rcpt("a@localhost.local")).To(Succeed())
+		Expect(c.Rcpt("b@localhost.local")).To(Succeed())
+		w, err := c.Data()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write([]byte("Subject: verification\r\n\r\nhello service\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(c.Quit()).To(Succeed())
+		Eventually(backend.messages).Should(Receive(ContainSubstring("hello service")))
+
+		// the exemption is per session
+		c, err = smtp.Dial(plain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Auth(smtp.PlainAuth("", "app", "s3cret", "127.0.0.1"))).To(Succeed())
+		Expect(c.Mail("smtp-verification@mailhog-operator.local")).To(Equal(&textproto.Error{Code: 553, Msg: "5.7.1 Sender address rejected: domain not allowed"}))
+	})
+
+	It("should relay other AUTH commands to the backend if the policy does not require it", func() {
+		startProxy(Policy{}, []Rule{
+			{Name: "unavailable", Stage: MailFromStage, Action: RejectAction, Code: 451, Message: "4.3.2 Try again later"},
+		})
+
+		conn, err := textproto.Dial("tcp", plain)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		_, _, err = conn.ReadResponse(220)
+		Expect(err).ToNot(HaveOccurred())
+		for _, step := range []struct {
+			command string
+			code    int
+		}{
+			{"AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00app\x00anything")), 250},
+			{"MAIL FROM:<sender@localhost.local>", 451},
+			{"AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00operator\x000perator")), 235},
+			{"MAIL FROM:<sender@localhost.local>", 250},
+		} {
+			id, err := conn.Cmd("%s", step.command)
+			Expect(err).ToNot(HaveOccurred())
+			conn.StartResponse(id)
+			code, _, _ := conn.ReadResponse(0)
+			conn.EndResponse(id)
+			Expect(code).To(Equal(step.code), step.command)
+		}
+	})
+})
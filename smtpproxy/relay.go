@@ -0,0 +1,533 @@
+package smtpproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// RelayHeader is added to every relayed message, its value names the rule and the upstream
+	RelayHeader = "X-Mailhog-Relayed"
+
+	// CramMd5Mechanism and PlainMechanism are the upstream auth mechanisms, named like in mailhog's upstream file
+	CramMd5Mechanism = "CRAMMD5"
+	PlainMechanism   = "PLAIN"
+
+	relayQueueSize = 1000
+
+	// relayDefaultQueueBytes bounds the messages waiting to be relayed if the config does not
+	relayDefaultQueueBytes = 8 << 20
+)
+
+// Relay forwards captured messages whose recipients match a rule to an upstream, in addition to mailhog storing them
+type Relay struct {
+	// Upstreams are the servers rules can relay to
+	Upstreams []RelayUpstream `json:"upstreams,omitempty"`
+
+	// Rules select the recipients to relay, the first matching rule of a recipient is applied
+	Rules []RelayRule `json:"rules,omitempty"`
+
+	// MaxAttempts is how often a message is sent before it is given up, temporary failures are retried
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+
+	// RetryInterval is the wait before a failed message is sent again
+	RetryInterval time.Duration `json:"retryInterval,omitempty"`
+
+	// RatePerMinute limits the messages sent to all upstreams, unlimited if 0
+	RatePerMinute int `json:"ratePerMinute,omitempty"`
+
+	// MaxQueueBytes bounds the size of the messages waiting to be relayed or retried, 8MiB if 0
+	// messages that do not fit are given up, so a slow upstream can not grow the proxy's memory
+	MaxQueueBytes int64 `json:"maxQueueBytes,omitempty"`
+}
+
+// RelayUpstream is a server messages are relayed to
+type RelayUpstream struct {
+	Name string `json:"name"`
+
+	// Address is host:port of the server
+	Address string `json:"address"`
+
+	// UsernameFile, PasswordFile and Mechanism authenticate against the server if a username file is set
+	// the files are read for every message, so rotated credentials are picked up without a restart
+	UsernameFile string `json:"usernameFile,omitempty"`
+	PasswordFile string `json:"passwordFile,omitempty"`
+	Mechanism    string `json:"mechanism,omitempty"`
+
+	// CaFile contains PEM encoded ca certificates trusted in addition to the system certificates
+	// it is read for every message, so a rotated bundle is picked up without a restart
+	CaFile string `json:"caFile,omitempty"`
+
+	// ServerName is the name the certificate is verified against instead of the host of the address
+	ServerName string `json:"serverName,omitempty"`
+
+	// MinTlsVersion is the minimum tls version, like "1.3", 1.2 if empty
+	MinTlsVersion string `json:"minTlsVersion,omitempty"`
+}
+
+// RelayRule selects recipients that are relayed to an upstream
+type RelayRule struct {
+	// Name identifies the rule in metrics and stats
+	Name string `json:"name"`
+
+	// Recipients are address patterns like in Rule.Address, eg "@ourcompany.com"
+	Recipients []string `json:"recipients"`
+
+	// Upstream is the name of the upstream matching recipients are relayed to
+	Upstream string `json:"upstream"`
+}
+
+// RelayStats are the counters of a relay rule since the proxy started
+type RelayStats struct {
+	Name string `json:"name"`
+
+	// Relayed counts the messages accepted by the upstream
+	Relayed int64 `json:"relayed"`
+
+	// Failed counts the messages given up after a permanent failure or the last attempt
+	Failed int64 `json:"failed"`
+
+	// Retries counts the attempts that failed temporarily and were scheduled again
+	Retries int64 `json:"retries"`
+
+	// Pending counts the messages waiting to be sent or retried
+	Pending int64 `json:"pending"`
+}
+
+// enabled returns true if any message can be relayed
+func (r Relay) enabled() bool {
+	return len(r.Rules) > 0
+}
+
+// ValidateRelay returns an error if a relay rule can not be applied
+func ValidateRelay(relay Relay) error {
+	return relay.validate()
+}
+
+// validate returns an error if a rule can not be applied
+func (r Relay) validate() error {
+	upstreams := map[string]bool{}
+	for _, upstream := range r.Upstreams {
+		if _, _, err := net.SplitHostPort(upstream.Address); err != nil || upstream.Name == "" {
+			return errInvalidRelayUpstream
+		}
+		if upstream.UsernameFile != "" && upstream.PasswordFile == "" {
+			return errMissingRelayPassword
+		}
+		switch upstream.Mechanism {
+		case "", PlainMechanism, CramMd5Mechanism:
+		default:
+			return errInvalidRelayUpstream
+		}
+		if _, err := ParseTlsVersion(upstream.MinTlsVersion); err != nil {
+			return err
+		}
+		upstreams[upstream.Name] = true
+	}
+
+	names := map[string]bool{}
+	for _, rule := range r.Rules {
+		if rule.Name == "" || names[rule.Name] {
+			return errInvalidRelayRule
+		}
+		names[rule.Name] = true
+		if len(rule.Recipients) == 0 {
+			return errInvalidRelayRule
+		}
+		if !upstreams[rule.Upstream] {
+			return errUnknownRelayUpstream
+		}
+	}
+	if r.enabled() && r.MaxAttempts < 1 {
+		return errInvalidRelayAttempts
+	}
+	if r.RetryInterval < 0 || r.RatePerMinute < 0 || r.MaxQueueBytes < 0 {
+		return errInvalidRelayAttempts
+	}
+	return nil
+}
+
+// relayJob is a captured message on its way to an upstream
+type relayJob struct {
+	rule       int
+	sender     string
+	recipients []string
+	message    []byte
+	attempts   int
+}
+
+// relayRuleCounters are the atomic counters behind RelayStats
+type relayRuleCounters struct {
+	relayed, failed, retries, pending int64
+}
+
+// relayer queues captured messages and sends them to the upstreams of the matching rules
+type relayer struct {
+	cfg       Relay
+	upstreams map[string]RelayUpstream
+	counters  []relayRuleCounters
+	counter   *prometheus.CounterVec
+	logger    logr.Logger
+
+	// queue holds the jobs to send, queuedBytes sums the messages of all unfinished jobs including retries
+	queue       chan *relayJob
+	queuedBytes int64
+	maxBytes    int64
+	interval    time.Duration
+	last        time.Time
+
+	// ctx ends pending retries once the proxy stops
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+// newRelayer returns a relayer counting the outcomes in the given counter, labeled with the rule and the result
+func newRelayer(cfg Relay, counter *prometheus.CounterVec, logger logr.Logger) *relayer {
+	r := &relayer{
+		cfg:       cfg,
+		upstreams: map[string]RelayUpstream{},
+		counters:  make([]relayRuleCounters, len(cfg.Rules)),
+		counter:   counter,
+		logger:    logger.WithName("relay"),
+		queue:     make(chan *relayJob, relayQueueSize),
+		maxBytes:  cfg.MaxQueueBytes,
+		ctx:       context.Background(),
+	}
+	if r.maxBytes == 0 {
+		r.maxBytes = relayDefaultQueueBytes
+	}
+	for _, upstream := range cfg.Upstreams {
+		r.upstreams[upstream.Name] = upstream
+	}
+	for _, rule := range cfg.Rules {
+		for _, result := range []string{"relayed", "failed", "retried"} {
+			counter.WithLabelValues(rule.Name, result)
+		}
+	}
+	if cfg.RatePerMinute > 0 {
+		r.interval = time.Minute / time.Duration(cfg.RatePerMinute)
+	}
+	return r
+}
+
+// wants returns true if any recipient of a message is relayed, so the session keeps the message body
+func (r *relayer) wants(recipients []string) bool {
+	for _, recipient := range recipients {
+		if r.ruleOf(recipient) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleOf returns the index of the first rule matching the recipient, -1 if none matches
+func (r *relayer) ruleOf(recipient string) int {
+	for i, rule := range r.cfg.Rules {
+		for _, pattern := range rule.Recipients {
+			if matchesAny(pattern, []string{recipient}) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// capture queues a message mailhog accepted, once per rule with the recipients matching it
+// the message is the dot stuffed DATA of the session without the terminating line
+func (r *relayer) capture(sender string, recipients []string, data []string) {
+	order, byRule := r.rulesOf(recipients)
+	if len(order) == 0 {
+		return
+	}
+
+	message := unstuff(data)
+	for _, rule := range order {
+		header := RelayHeader + ": " + r.cfg.Rules[rule].Name + "; upstream=" + r.cfg.Rules[rule].Upstream + "\r\n"
+		job := &relayJob{
+			rule:       rule,
+			sender:     sender,
+			recipients: byRule[rule],
+			message:    append([]byte(header), message...),
+		}
+		atomic.AddInt64(&r.counters[rule].pending, 1)
+		if atomic.AddInt64(&r.queuedBytes, int64(len(job.message))) > r.maxBytes {
+			r.logger.Info("relay queue exceeds its size, giving up message", "rule", r.cfg.Rules[rule].Name)
+			r.finish(job, "failed")
+			continue
+		}
+		r.enqueue(job)
+	}
+}
+
+// giveUp counts a message that exceeds the queue size as failed for every rule matching its recipients
+func (r *relayer) giveUp(recipients []string) {
+	order, _ := r.rulesOf(recipients)
+	for _, rule := range order {
+		r.logger.Info("message exceeds the relay queue size, giving it up", "rule", r.cfg.Rules[rule].Name)
+		atomic.AddInt64(&r.counters[rule].pending, 1)
+		r.finish(&relayJob{rule: rule}, "failed")
+	}
+}
+
+// rulesOf returns the rules matching the recipients in the order of their first recipient, with their recipients
+func (r *relayer) rulesOf(recipients []string) (order []int, byRule map[int][]string) {
+	byRule = map[int][]string{}
+	for _, recipient := range recipients {
+		rule := r.ruleOf(recipient)
+		if rule < 0 {
+			continue
+		}
+		if _, found := byRule[rule]; !found {
+			order = append(order, rule)
+		}
+		byRule[rule] = append(byRule[rule], recipient)
+	}
+	return order, byRule
+}
+
+// enqueue adds a job to the queue, a job that does not fit is given up
+func (r *relayer) enqueue(job *relayJob) {
+	select {
+	case r.queue <- job:
+	default:
+		r.logger.Info("relay queue is full, giving up message", "rule", r.cfg.Rules[job.rule].Name)
+		r.finish(job, "failed")
+	}
+}
+
+// run sends the queued jobs until the context is cancelled
+func (r *relayer) run(ctx context.Context) {
+	r.ctx = ctx
+	for {
+		select {
+		case <-ctx.Done():
+			r.wg.Wait()
+			return
+		case job := <-r.queue:
+			r.throttle(ctx)
+			r.attempt(job)
+		}
+	}
+}
+
+// throttle waits until the rate limit allows the next message
+func (r *relayer) throttle(ctx context.Context) {
+	if r.interval == 0 {
+		return
+	}
+	if wait := time.Until(r.last.Add(r.interval)); wait > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+	r.last = time.Now()
+}
+
+// attempt sends a job and schedules a retry for temporary failures
+func (r *relayer) attempt(job *relayJob) {
+	rule := r.cfg.Rules[job.rule]
+	job.attempts++
+	err := r.send(r.upstreams[rule.Upstream], job)
+	switch {
+	case err == nil:
+		r.finish(job, "relayed")
+	case permanent(err) || job.attempts >= r.cfg.MaxAttempts:
+		r.logger.Error(err, "failed to relay message", "rule", rule.Name, "upstream", rule.Upstream, "attempts", job.attempts)
+		r.finish(job, "failed")
+	default:
+		r.logger.V(1).Info("retrying message", "rule", rule.Name, "upstream", rule.Upstream, "error", err.Error())
+		atomic.AddInt64(&r.counters[job.rule].retries, 1)
+		r.counter.WithLabelValues(rule.Name, "retried").Inc()
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			select {
+			case <-r.ctx.Done():
+			case <-time.After(r.cfg.RetryInterval):
+				r.enqueue(job)
+			}
+		}()
+	}
+}
+
+// finish counts the final outcome of a job and releases its message from the queue size
+func (r *relayer) finish(job *relayJob, result string) {
+	atomic.AddInt64(&r.queuedBytes, -int64(len(job.message)))
+	counters := &r.counters[job.rule]
+	atomic.AddInt64(&counters.pending, -1)
+	if result == "relayed" {
+		atomic.AddInt64(&counters.relayed, 1)
+	} else {
+		atomic.AddInt64(&counters.failed, 1)
+	}
+	r.counter.WithLabelValues(r.cfg.Rules[job.rule].Name, result).Inc()
+}
+
+// send delivers a job in a single smtp session, STARTTLS is used if the upstream offers it
+func (r *relayer) send(upstream RelayUpstream, job *relayJob) error {
+	conn, err := net.DialTimeout("tcp", upstream.Address, dialTimeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(sessionTimeout))
+	host, _, _ := net.SplitHostPort(upstream.Address)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		config, err := upstream.tlsConfig(host)
+		if err != nil {
+			return err
+		}
+		if err = c.StartTLS(config); err != nil {
+			return err
+		}
+	}
+	if upstream.UsernameFile != "" {
+		auth, err := upstream.auth(host)
+		if err != nil {
+			return err
+		}
+		if err = c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(job.sender); err != nil {
+		return err
+	}
+	for _, recipient := range job.recipients {
+		if err = c.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(job.message); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// tlsConfig returns the tls settings STARTTLS with the upstream uses
+func (u RelayUpstream) tlsConfig(host string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if u.ServerName != "" {
+		config.ServerName = u.ServerName
+	}
+	if u.MinTlsVersion != "" {
+		config.MinVersion, _ = ParseTlsVersion(u.MinTlsVersion)
+	}
+	if u.CaFile != "" {
+		bundle, err := os.ReadFile(u.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errInvalidRelayCa
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// auth returns the authentication with the credentials read from the upstream's files
+func (u RelayUpstream) auth(host string) (smtp.Auth, error) {
+	username, err := os.ReadFile(u.UsernameFile)
+	if err != nil {
+		return nil, err
+	}
+	password, err := os.ReadFile(u.PasswordFile)
+	if err != nil {
+		return nil, err
+	}
+	if u.Mechanism == CramMd5Mechanism {
+		return smtp.CRAMMD5Auth(string(username), string(password)), nil
+	}
+	return smtp.PlainAuth("", string(username), string(password), host), nil
+}
+
+// ParseTlsVersion returns the tls version of a version number like "1.2", 0 for an empty one
+func ParseTlsVersion(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, errInvalidTlsVersion
+}
+
+// stats returns the counters of every rule
+func (r *relayer) stats() []RelayStats {
+	stats := make([]RelayStats, 0, len(r.cfg.Rules))
+	for i, rule := range r.cfg.Rules {
+		counters := &r.counters[i]
+		stats = append(stats, RelayStats{
+			Name:    rule.Name,
+			Relayed: atomic.LoadInt64(&counters.relayed),
+			Failed:  atomic.LoadInt64(&counters.failed),
+			Retries: atomic.LoadInt64(&counters.retries),
+			Pending: atomic.LoadInt64(&counters.pending),
+		})
+	}
+	return stats
+}
+
+// permanent returns true for 5xx replies, which are not retried
+func permanent(err error) bool {
+	var reply *textproto.Error
+	return errors.As(err, &reply) && reply.Code >= 500
+}
+
+// unstuff removes the dot stuffing of DATA lines, the smtp client adds it again when relaying
+func unstuff(data []string) []byte {
+	message := bytes.Buffer{}
+	for _, line := range data {
+		message.WriteString(strings.TrimPrefix(line, "."))
+	}
+	return message.Bytes()
+}
+
+var (
+	errInvalidRelayUpstream = errors.New("a relay upstream needs a name, a host:port address and a known mechanism")
+	errInvalidRelayRule     = errors.New("relay rules need a unique name and recipient patterns")
+	errUnknownRelayUpstream = errors.New("a relay rule references an unknown upstream")
+	errInvalidRelayAttempts = errors.New("relaying needs at least one attempt and non-negative retry interval, rate and queue size")
+	errInvalidRelayCa       = errors.New("the ca file of a relay upstream does not contain a PEM encoded certificate")
+	errInvalidTlsVersion    = errors.New("tls versions are 1.0, 1.1, 1.2 or 1.3")
+	errMissingRelayPassword = errors.New("a relay upstream with a username file needs a password file")
+)
@@ -0,0 +1,284 @@
+package smtpproxy
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stage is the point of an smtp session a rule is evaluated at
+type Stage string
+
+const (
+	// ConnectStage is evaluated before the greeting is sent
+	ConnectStage Stage = "connect"
+
+	// MailFromStage is evaluated for MAIL FROM, the address is the sender
+	MailFromStage Stage = "mailFrom"
+
+	// RcptToStage is evaluated for every RCPT TO, the address is the recipient
+	RcptToStage Stage = "rcptTo"
+
+	// DataStage is evaluated for DATA, the address matches if any recipient of the message matches
+	DataStage Stage = "data"
+)
+
+// Action is what a matching rule does with the command
+type Action string
+
+const (
+	// RejectAction answers with the code and message of the rule instead of relaying the command
+	RejectAction Action = "reject"
+
+	// GreylistAction answers with a temporary failure the first time a sender hits the rule within the greylist expiry
+	GreylistAction Action = "greylist"
+
+	// DelayAction waits before relaying the command
+	DelayAction Action = "delay"
+
+	// DisconnectAction closes the connection without an answer
+	DisconnectAction Action = "disconnect"
+)
+
+// Rule is a deterministic fault injected into matching smtp sessions, the first matching rule is applied
+type Rule struct {
+	// Name identifies the rule in metrics and hit counts
+	Name string `json:"name"`
+
+	// Stage is the command the rule is evaluated for
+	Stage Stage `json:"stage"`
+
+	// Address is a pattern for the envelope address, * matches any characters,
+	// a trailing @ matches any domain and a leading @ any local part, empty matches every address
+	Address string `json:"address,omitempty"`
+
+	// AfterMessages lets the rule only match once the session delivered this many messages
+	AfterMessages int `json:"afterMessages,omitempty"`
+
+	// Action is what happens to a matching command
+	Action Action `json:"action"`
+
+	// Code is the reply code of reject actions, eg 550
+	Code int `json:"code,omitempty"`
+
+	// Message is the reply text of reject and greylist actions, a default is used if empty
+	Message string `json:"message,omitempty"`
+
+	// Delay is the wait of delay actions
+	Delay time.Duration `json:"delay,omitempty"`
+}
+
+// RuleHits is the count of commands a rule was applied to since the proxy started
+type RuleHits struct {
+	Name string `json:"name"`
+	Hits int64  `json:"hits"`
+}
+
+// ValidateRules returns an error if a rule can not be applied or rule names are not unique
+func ValidateRules(rules []Rule) error {
+	names := map[string]bool{}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return errDuplicateRuleName
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// validate returns an error if the rule can not be applied
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return errMissingRuleName
+	}
+	switch r.Stage {
+	case ConnectStage:
+		if r.Address != "" || r.Action == GreylistAction {
+			return errInvalidConnectRule
+		}
+	case MailFromStage, RcptToStage, DataStage:
+	default:
+		return errUnknownStage
+	}
+	switch r.Action {
+	case RejectAction:
+		if r.Code < 400 || r.Code > 599 {
+			return errInvalidRejectCode
+		}
+	case DelayAction:
+		if r.Delay <= 0 {
+			return errMissingDelay
+		}
+	case GreylistAction, DisconnectAction:
+	default:
+		return errUnknownAction
+	}
+	return nil
+}
+
+// reply returns the smtp response sent by reject and greylist actions
+func (r Rule) reply() string {
+	code, message := r.Code, r.Message
+	if r.Action == GreylistAction {
+		code = 451
+		if message == "" {
+			message = "4.7.1 Greylisted, please try again later"
+		}
+	}
+	if message == "" {
+		message = "5.7.1 Rejected by policy"
+		if code < 500 {
+			message = "4.7.1 Temporarily rejected, try again later"
+		}
+	}
+	return strconv.Itoa(code) + " " + message + "\r\n"
+}
+
+// ruleSet evaluates the rules of the proxy and counts their hits
+type ruleSet struct {
+	rules   []Rule
+	hits    []int64
+	counter *prometheus.CounterVec
+
+	mu       sync.Mutex
+	greylist map[string]time.Time
+}
+
+// greylistExpiry is how long a greylisted sender is remembered, afterwards its next attempt is greylisted again
+var greylistExpiry = time.Hour
+
+// greylistMaxEntries bounds the remembered senders, once reached expired ones and then the oldest ones are forgotten
+var greylistMaxEntries = 10000
+
+// newRuleSet returns a ruleSet counting hits in the given counter, labeled with the rule name
+func newRuleSet(rules []Rule, counter *prometheus.CounterVec) *ruleSet {
+	for _, rule := range rules {
+		counter.WithLabelValues(rule.Name)
+	}
+	return &ruleSet{
+		rules:    rules,
+		hits:     make([]int64, len(rules)),
+		counter:  counter,
+		greylist: map[string]time.Time{},
+	}
+}
+
+// evaluate returns the first rule that applies to the command and counts its hit, nil if no rule applies
+// greylist rules only apply the first time a sender hits them
+func (rs *ruleSet) evaluate(stage Stage, sender string, addresses []string, messages int) *Rule {
+	for i := range rs.rules {
+		rule := &rs.rules[i]
+		if rule.Stage != stage || messages < rule.AfterMessages || !matchesAny(rule.Address, addresses) {
+			continue
+		}
+		if rule.Action == GreylistAction && !rs.firstAttempt(rule.Name, sender) {
+			continue
+		}
+		atomic.AddInt64(&rs.hits[i], 1)
+		rs.counter.WithLabelValues(rule.Name).Inc()
+		return rule
+	}
+	return nil
+}
+
+// firstAttempt records the sender for the greylist rule and returns true if it was not seen within the greylist expiry
+func (rs *ruleSet) firstAttempt(rule, sender string) bool {
+	key := rule + "\x00" + strings.ToLower(sender)
+	now := time.Now()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if seen, found := rs.greylist[key]; found && now.Sub(seen) < greylistExpiry {
+		return false
+	}
+	if len(rs.greylist) >= greylistMaxEntries {
+		rs.pruneGreylist(now)
+	}
+	rs.greylist[key] = now
+	return true
+}
+
+// pruneGreylist forgets the expired senders and, if the greylist is still full, the oldest one
+// the caller has to hold the lock
+func (rs *ruleSet) pruneGreylist(now time.Time) {
+	oldestKey, oldest := "", now
+	for key, seen := range rs.greylist {
+		if now.Sub(seen) >= greylistExpiry {
+			delete(rs.greylist, key)
+			continue
+		}
+		if oldestKey == "" || seen.Before(oldest) {
+			oldestKey, oldest = key, seen
+		}
+	}
+	if len(rs.greylist) >= greylistMaxEntries {
+		delete(rs.greylist, oldestKey)
+	}
+}
+
+// counts returns the hits of every rule
+func (rs *ruleSet) counts() []RuleHits {
+	counts := make([]RuleHits, 0, len(rs.rules))
+	for i, rule := range rs.rules {
+		counts = append(counts, RuleHits{Name: rule.Name, Hits: atomic.LoadInt64(&rs.hits[i])})
+	}
+	return counts
+}
+
+// matchesAny returns true if the pattern is empty or matches one of the addresses
+func matchesAny(pattern string, addresses []string) bool {
+	if pattern == "" {
+		return true
+	}
+	pattern = strings.ToLower(pattern)
+	if strings.HasSuffix(pattern, "@") {
+		pattern += "*"
+	}
+	if strings.HasPrefix(pattern, "@") {
+		pattern = "*" + pattern
+	}
+	for _, address := range addresses {
+		if wildcardMatch(pattern, strings.ToLower(address)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch matches a value against a pattern where * matches any (also empty) sequence of characters
+func wildcardMatch(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		index := strings.Index(value, part)
+		if index < 0 {
+			return false
+		}
+		value = value[index+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
+var (
+	errMissingRuleName    = errors.New("a rule has no name")
+	errDuplicateRuleName  = errors.New("rule names must be unique")
+	errUnknownStage       = errors.New("a rule has an unknown stage")
+	errUnknownAction      = errors.New("a rule has an unknown action")
+	errInvalidConnectRule = errors.New("connect rules can not match addresses or greylist")
+	errInvalidRejectCode  = errors.New("reject rules need a 4xx or 5xx code")
+	errMissingDelay       = errors.New("delay rules need a positive delay")
+	errRuleDisconnect     = errors.New("connection closed by rule")
+)
@@ -0,0 +1,656 @@
+package smtpproxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	replyTlsReady       = "220 2.0.0 Ready to start TLS\r\n"
+	replyTlsRequired    = "530 5.7.0 Must issue a STARTTLS command first\r\n"
+	replyTlsActive      = "503 5.5.1 TLS already active\r\n"
+	replyTlsUnsupported = "502 5.5.1 STARTTLS not supported on this port\r\n"
+	replyLineTooLong    = "500 5.5.2 Line too long\r\n"
+	extensionStartTls   = "STARTTLS"
+
+	// extensionOperatorAuth is offered on operator listeners, the operator authenticates with PLAIN only
+	extensionOperatorAuth = "AUTH PLAIN"
+
+	// maxCommandLine and maxTextLine are the line limits of rfc 5321 including the line break,
+	// longer lines end the session so a single client can not grow the proxy's buffers
+	maxCommandLine = 512
+	maxTextLine    = 1000
+)
+
+// session relays one smtp session between a client and the backend line by line
+type session struct {
+	client   net.Conn
+	clientR  *bufio.Reader
+	backend  net.Conn
+	backendR *bufio.Reader
+
+	mode      ListenerMode
+	tlsConfig *tls.Config
+	tlsActive bool
+
+	// rules and the envelope of the current transaction they are evaluated against
+	rules      *ruleSet
+	sender     string
+	recipients []string
+	messages   int
+
+	// policy and operator check AUTH, exempt is set once the client authenticated as operator so its transactions bypass rules, policy and relay
+	policy        *policyEnforcer
+	operator      Operator
+	authenticated bool
+	exempt        bool
+
+	// responses counts the replies by code, transcript records the session if transcripts are kept
+	responses  *prometheus.CounterVec
+	transcript *Transcript
+	challenged bool
+
+	// relayer receives the accepted messages, data is the body of the current message if the relayer wants it,
+	// oversized is set if the relayer wants it but it exceeds the relay queue
+	relayer   *relayer
+	data      []string
+	oversized bool
+}
+
+// newSession returns a session for the given connections
+func newSession(client, backend net.Conn, mode ListenerMode, p *Proxy) *session {
+	return &session{
+		client:    client,
+		clientR:   bufio.NewReader(client),
+		backend:   backend,
+		backendR:  bufio.NewReader(backend),
+		mode:      mode,
+		tlsConfig: p.tlsConfig,
+		tlsActive: mode == ImplicitTlsMode,
+		rules:     p.rules,
+		policy:    p.policy,
+		operator:  p.cfg.Operator,
+		responses: p.responses,
+		relayer:   p.relayer,
+	}
+}
+
+// close closes both ends of the session
+func (s *session) close() {
+	_ = s.client.Close()
+	_ = s.backend.Close()
+}
+
+// relay runs the session until either side closes the connection
+func (s *session) relay() error {
+	if rule := s.matchingRule("", ""); rule != nil {
+		if relay, err := s.inject(rule); !relay {
+			return ignoreRuleDisconnect(err)
+		}
+	}
+
+	// the backend greeting is passed on as is
+	if _, err := s.relayResponse(); err != nil {
+		return err
+	}
+
+	for {
+		s.extendDeadlines()
+		line, err := s.readCommand()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		verb := commandVerb(line)
+		if verb == "AUTH" {
+			s.transcript.authenticating(line)
+		}
+		switch {
+		case verb == extensionStartTls:
+			if err = s.startTls(); err != nil {
+				return err
+			}
+			continue
+		case s.mode == StartTlsMode && !s.tlsActive && !allowedBeforeTls(verb):
+			if err = s.reply(replyTlsRequired); err != nil {
+				return err
+			}
+			continue
+		}
+
+		enforced, err := s.enforcePolicy(verb, line)
+		if err != nil {
+			return err
+		}
+		if enforced {
+			continue
+		}
+
+		if rule := s.matchingRule(verb, line); rule != nil {
+			relay, err := s.inject(rule)
+			if err != nil {
+				return ignoreRuleDisconnect(err)
+			}
+			if !relay {
+				continue
+			}
+		}
+
+		if verb == "DATA" && s.policy.MaxMessageSize > 0 && !s.exempt {
+			lines, err := s.relayLimitedData(line)
+			if err != nil {
+				return err
+			}
+			s.track(verb, line, responseCode(lines))
+			continue
+		}
+
+		if _, err = io.WriteString(s.backend, line); err != nil {
+			return err
+		}
+
+		lines, err := s.readResponse()
+		if err != nil {
+			return err
+		}
+		if verb == "EHLO" && s.mode == OperatorMode {
+			lines = appendExtension(removeExtension(lines, "AUTH"), extensionOperatorAuth)
+		} else if verb == "EHLO" {
+			lines = s.policy.extensions(lines)
+		}
+		if verb == "EHLO" && s.mode == StartTlsMode && !s.tlsActive {
+			lines = appendExtension(lines, extensionStartTls)
+		}
+		if err = s.writeClient(lines); err != nil {
+			return err
+		}
+
+		switch {
+		case verb == "QUIT":
+			return nil
+		case verb == "DATA" && responseCode(lines) == "354":
+			if lines, err = s.relayData(); err != nil {
+				return err
+			}
+		}
+		s.track(verb, line, responseCode(lines))
+	}
+}
+
+// matchingRule returns the rule applied to a client command or the connection if verb is empty, nil if none applies
+// the operator's sessions are never faulted, on other listeners only the connect stage applies before it authenticated
+func (s *session) matchingRule(verb, line string) *Rule {
+	if s.mode == OperatorMode || s.exempt {
+		return nil
+	}
+	switch verb {
+	case "":
+		return s.rules.evaluate(ConnectStage, "", nil, 0)
+	case "MAIL":
+		sender := envelopeAddress(line)
+		return s.rules.evaluate(MailFromStage, sender, []string{sender}, s.messages)
+	case "RCPT":
+		return s.rules.evaluate(RcptToStage, s.sender, []string{envelopeAddress(line)}, s.messages)
+	case "DATA":
+		return s.rules.evaluate(DataStage, s.sender, s.recipients, s.messages)
+	}
+	return nil
+}
+
+// inject applies a rule, it returns false if the command must not be relayed to the backend
+func (s *session) inject(rule *Rule) (relay bool, err error) {
+	switch rule.Action {
+	case DelayAction:
+		time.Sleep(rule.Delay)
+		s.extendDeadlines()
+		return true, nil
+	case DisconnectAction:
+		return false, errRuleDisconnect
+	}
+	return false, s.reply(rule.reply())
+}
+
+// enforcePolicy answers commands that violate the policy and AUTH commands if the policy requires them,
+// it returns true if the command must not be relayed to the backend
+func (s *session) enforcePolicy(verb, line string) (bool, error) {
+	if s.mode == OperatorMode {
+		return s.enforceOperator(verb, line)
+	}
+	var violation Violation
+	reply := ""
+	switch {
+	case verb == "AUTH" && s.policy.authRequired():
+		return true, s.authenticate(line)
+	case verb == "AUTH":
+		return s.authenticateOperator(line)
+	case s.exempt:
+		return false, nil
+	case verb == "MAIL" && s.policy.authRequired() && !s.authenticated:
+		violation, reply = AuthViolation, replyAuthRequired
+	case verb == "MAIL" && !s.policy.senderAllowed(envelopeAddress(line)):
+		violation, reply = SenderViolation, replySenderDenied
+	case verb == "MAIL" && s.policy.sizeExceeded(line):
+		violation, reply = SizeViolation, replySizeExceeded
+	case verb == "RCPT" && s.policy.MaxRecipients > 0 && len(s.recipients) >= s.policy.MaxRecipients:
+		violation, reply = RecipientsViolation, replyTooManyRecipients
+	default:
+		return false, nil
+	}
+	s.policy.violated(violation)
+	return true, s.reply(reply)
+}
+
+// enforceOperator answers AUTH commands and refuses mail transactions until the client authenticated as operator,
+// it returns true if the command must not be relayed to the backend
+func (s *session) enforceOperator(verb, line string) (bool, error) {
+	switch {
+	case verb == "AUTH":
+		return true, s.authenticate(line)
+	case verb == "MAIL" && !s.exempt:
+		return true, s.reply(replyAuthRequired)
+	}
+	return false, nil
+}
+
+// authenticateOperator answers an AUTH PLAIN command with an initial response if it carries the operator's credentials,
+// other AUTH commands are relayed to the backend as before since the policy does not require authentication
+func (s *session) authenticateOperator(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if s.authenticated || len(fields) != 3 || !strings.EqualFold(fields[1], "PLAIN") {
+		return false, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return false, nil
+	}
+	user, password, ok := plainCredentials(string(decoded))
+	if !ok || !s.operator.authenticate(user, password) {
+		return false, nil
+	}
+	s.exemptOperator()
+	return true, s.reply(replyAuthSuccess)
+}
+
+// exemptOperator marks the session as the operator's, its deliveries like seeded, restored or verification messages
+// are passed to the backend without rules, policy or relay
+func (s *session) exemptOperator() {
+	s.authenticated, s.exempt, s.relayer = true, true, nil
+}
+
+// authenticate runs an AUTH exchange against the credentials of the policy or the operator, the backend never sees it
+func (s *session) authenticate(line string) error {
+	fields := strings.Fields(line)
+	reply := replyAuthSyntax
+	switch {
+	case s.authenticated:
+		reply = replyAuthActive
+	case len(fields) >= 2:
+		user, password, failed, err := s.credentials(fields)
+		if err != nil {
+			return err
+		}
+		switch {
+		case failed != "":
+			reply = failed
+		case s.operator.authenticate(user, password):
+			reply = replyAuthSuccess
+			s.exemptOperator()
+		case s.mode == OperatorMode:
+			reply = replyAuthInvalid
+		case s.policy.authenticate(user, password):
+			reply = replyAuthSuccess
+			s.authenticated = true
+		default:
+			reply = replyAuthInvalid
+			s.policy.violated(AuthViolation)
+		}
+	}
+	return s.reply(reply)
+}
+
+// credentials reads the user and password of an AUTH PLAIN or LOGIN command including its continuations,
+// failed is the reply to send instead if the exchange is not usable
+func (s *session) credentials(fields []string) (user, password, failed string, err error) {
+	mechanism := strings.ToUpper(fields[1])
+	var prompts []string
+	switch mechanism {
+	case "PLAIN":
+		prompts = []string{""}
+	case "LOGIN":
+		prompts = []string{"VXNlcm5hbWU6", "UGFzc3dvcmQ6"}
+	default:
+		return "", "", replyAuthUnsupported, nil
+	}
+
+	initial := fields[2:]
+	answers := make([]string, 0, len(prompts))
+	for _, prompt := range prompts {
+		answer := ""
+		if len(initial) > 0 {
+			answer, initial = initial[0], initial[1:]
+		} else if answer, err = s.challenge(prompt); err != nil {
+			return "", "", "", err
+		}
+		if answer == "*" {
+			return "", "", replyAuthCancelled, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(answer)
+		if err != nil {
+			return "", "", replyAuthSyntax, nil
+		}
+		answers = append(answers, string(decoded))
+	}
+
+	if mechanism == "LOGIN" {
+		return answers[0], answers[1], "", nil
+	}
+	user, password, ok := plainCredentials(answers[0])
+	if !ok {
+		return "", "", replyAuthSyntax, nil
+	}
+	return user, password, "", nil
+}
+
+// plainCredentials splits a decoded PLAIN response "authorization identity \0 user \0 password"
+func plainCredentials(response string) (user, password string, ok bool) {
+	parts := strings.Split(response, "\x00")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// challenge sends a continuation to the client and returns its answer
+func (s *session) challenge(prompt string) (string, error) {
+	if err := s.reply("334 " + prompt + "\r\n"); err != nil {
+		return "", err
+	}
+	line, err := s.readCommand()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// relayLimitedData receives the message body before the backend sees the DATA command,
+// so messages exceeding the maximum size are rejected without being delivered
+func (s *session) relayLimitedData(line string) ([]string, error) {
+	if len(s.recipients) == 0 {
+		lines := []string{replyNeedRecipients}
+		return lines, s.writeClient(lines)
+	}
+	if err := s.reply(replyStartData); err != nil {
+		return nil, err
+	}
+
+	body := make([]string, 0)
+	size := int64(0)
+	for {
+		bodyLine, err := s.readLine(maxTextLine)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimRight(bodyLine, "\r\n") == "." {
+			body = append(body, bodyLine)
+			break
+		}
+		size += int64(len(bodyLine))
+		if size <= s.policy.MaxMessageSize {
+			body = append(body, bodyLine)
+		}
+	}
+	s.transcript.data(size)
+
+	if size > s.policy.MaxMessageSize {
+		s.policy.violated(SizeViolation)
+		// the transaction the backend already knows about is abandoned
+		if _, err := io.WriteString(s.backend, "RSET\r\n"); err != nil {
+			return nil, err
+		}
+		if _, err := s.readResponse(); err != nil {
+			return nil, err
+		}
+		s.track("RSET", "", "")
+		lines := []string{replySizeExceeded}
+		return lines, s.writeClient(lines)
+	}
+
+	if _, err := io.WriteString(s.backend, line); err != nil {
+		return nil, err
+	}
+	lines, err := s.readResponse()
+	if err != nil || responseCode(lines) != "354" {
+		if err == nil {
+			err = s.writeClient(lines)
+		}
+		return lines, err
+	}
+	if _, err = io.WriteString(s.backend, strings.Join(body, "")); err != nil {
+		return nil, err
+	}
+	if s.relayer != nil && s.relayer.wants(s.recipients) {
+		s.data = body[:len(body)-1]
+	}
+	return s.relayResponse()
+}
+
+// track keeps the envelope of the current transaction and counts the delivered messages
+func (s *session) track(verb, line, code string) {
+	switch {
+	case verb == "MAIL" && code == "250":
+		s.sender = envelopeAddress(line)
+		s.recipients = nil
+	case verb == "RCPT" && code == "250":
+		s.recipients = append(s.recipients, envelopeAddress(line))
+	case verb == "DATA" && code == "250":
+		if s.data != nil {
+			s.relayer.capture(s.sender, s.recipients, s.data)
+		} else if s.oversized {
+			s.relayer.giveUp(s.recipients)
+		}
+		s.messages++
+		s.sender, s.recipients, s.data, s.oversized = "", nil, nil, false
+	case verb == "DATA":
+		s.data, s.oversized = nil, false
+	case verb == "RSET", verb == "EHLO", verb == "HELO":
+		s.sender, s.recipients, s.data = "", nil, nil
+	}
+}
+
+// ignoreRuleDisconnect ends a session closed by a rule without error
+func ignoreRuleDisconnect(err error) error {
+	if errors.Is(err, errRuleDisconnect) {
+		return nil
+	}
+	return err
+}
+
+// startTls upgrades the client connection if the listener allows it
+func (s *session) startTls() error {
+	switch {
+	case s.mode != StartTlsMode:
+		return s.reply(replyTlsUnsupported)
+	case s.tlsActive:
+		return s.reply(replyTlsActive)
+	}
+
+	if err := s.reply(replyTlsReady); err != nil {
+		return err
+	}
+	tlsConn := tls.Server(s.client, s.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	s.client = tlsConn
+	s.clientR = bufio.NewReader(tlsConn)
+	s.tlsActive = true
+	return nil
+}
+
+// relayData passes the message body on until the terminating dot line and relays the final response
+func (s *session) relayData() ([]string, error) {
+	size := int64(0)
+	keep := s.relayer != nil && s.relayer.wants(s.recipients)
+	for {
+		line, err := s.readLine(maxTextLine)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(s.backend, line); err != nil {
+			return nil, err
+		}
+		if strings.TrimRight(line, "\r\n") == "." {
+			break
+		}
+		size += int64(len(line))
+		if keep && size > s.relayer.maxBytes {
+			// the message can never fit the relay queue, it is not buffered while it streams to mailhog
+			keep, s.data, s.oversized = false, nil, true
+		}
+		if keep {
+			s.data = append(s.data, line)
+		}
+	}
+	s.transcript.data(size)
+	return s.relayResponse()
+}
+
+// relayResponse reads a (multiline) response from the backend and passes it on to the client
+func (s *session) relayResponse() ([]string, error) {
+	lines, err := s.readResponse()
+	if err != nil {
+		return lines, err
+	}
+	return lines, s.writeClient(lines)
+}
+
+// readResponse reads a (multiline) response from the backend
+func (s *session) readResponse() (lines []string, err error) {
+	for {
+		line, err := s.backendR.ReadString('\n')
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+		if len(line) < 4 || line[3] != '-' {
+			return lines, nil
+		}
+	}
+}
+
+// writeClient writes the response lines to the client
+func (s *session) writeClient(lines []string) error {
+	return s.reply(strings.Join(lines, ""))
+}
+
+// reply writes a (multiline) response to the client, counts its code and records it in the transcript
+func (s *session) reply(response string) error {
+	s.transcript.record(ResponseEntry, response)
+	if len(response) >= 3 {
+		s.responses.WithLabelValues(response[:3]).Inc()
+	}
+	s.challenged = strings.HasPrefix(response, "334")
+	_, err := io.WriteString(s.client, response)
+	return err
+}
+
+// readCommand reads a line from the client and records it in the transcript, credentials are redacted
+func (s *session) readCommand() (string, error) {
+	line, err := s.readLine(maxCommandLine)
+	if err != nil {
+		return line, err
+	}
+	if s.challenged {
+		s.transcript.record(CommandEntry, redacted)
+	} else {
+		s.transcript.record(CommandEntry, redactCommand(line))
+	}
+	return line, nil
+}
+
+// readLine reads a line of the client of at most limit octets including the line break,
+// a longer line is answered and ends the session with errLineTooLong
+func (s *session) readLine(limit int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := s.clientR.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > limit {
+			if replyErr := s.reply(replyLineTooLong); replyErr != nil {
+				return "", replyErr
+			}
+			return "", errLineTooLong
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return string(line), err
+		}
+	}
+}
+
+// extendDeadlines pushes the idle timeout of both connections
+func (s *session) extendDeadlines() {
+	deadline := time.Now().Add(sessionTimeout)
+	_ = s.client.SetDeadline(deadline)
+	_ = s.backend.SetDeadline(deadline)
+}
+
+// commandVerb returns the upper case smtp command of a client line
+func commandVerb(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// envelopeAddress returns the address between the angle brackets of a MAIL FROM or RCPT TO command
+func envelopeAddress(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+// allowedBeforeTls returns true for commands a STARTTLS listener accepts on a plaintext session
+func allowedBeforeTls(verb string) bool {
+	switch verb {
+	case "EHLO", "HELO", "NOOP", "RSET", "QUIT":
+		return true
+	}
+	return false
+}
+
+// responseCode returns the reply code of a response
+func responseCode(lines []string) string {
+	if len(lines) == 0 || len(lines[0]) < 3 {
+		return ""
+	}
+	return lines[0][:3]
+}
+
+// appendExtension adds an extension keyword to a positive EHLO response
+func appendExtension(lines []string, extension string) []string {
+	if responseCode(lines) != "250" {
+		return lines
+	}
+	extended := make([]string, 0, len(lines)+1)
+	for _, line := range lines {
+		extended = append(extended, "250-"+strings.TrimRight(line[4:], "\r\n")+"\r\n")
+	}
+	return append(extended, "250 "+extension+"\r\n")
+}
+
+var errLineTooLong = errors.New("the client sent a line exceeding the smtp line limit")
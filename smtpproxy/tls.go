@@ -0,0 +1,63 @@
+package smtpproxy
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// certLoader keeps the tls certificate in memory and reloads it when the mounted files change
+type certLoader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// newCertLoader returns a certLoader with the initial certificate loaded
+func newCertLoader(certFile, keyFile string) (*certLoader, error) {
+	loader := &certLoader{certFile: certFile, keyFile: keyFile}
+	if _, err := loader.getCertificate(nil); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// getCertificate is used as tls.Config GetCertificate callback
+func (l *certLoader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info, err := os.Stat(l.certFile)
+	if err != nil {
+		if l.cert != nil {
+			return l.cert, nil
+		}
+		return nil, err
+	}
+
+	if l.cert == nil || info.ModTime().After(l.modTime) {
+		cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+		if err != nil {
+			if l.cert != nil {
+				return l.cert, nil
+			}
+			return nil, err
+		}
+		l.cert = &cert
+		l.modTime = info.ModTime()
+	}
+
+	return l.cert, nil
+}
+
+// tlsConfig returns the server side tls config using the loader
+func (l *certLoader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: l.getCertificate,
+	}
+}
@@ -0,0 +1,186 @@
+package smtpproxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EntryKind is the kind of a line in a transcript
+type EntryKind string
+
+const (
+	// CommandEntry is a line sent by the client
+	CommandEntry EntryKind = "command"
+
+	// ResponseEntry is a (multiline) response sent to the client
+	ResponseEntry EntryKind = "response"
+
+	// DataEntry summarises a message body, which is not recorded
+	DataEntry EntryKind = "data"
+
+	redacted = "***"
+
+	// maxTranscriptEntries bounds the memory of long sessions, like load tests sending many messages per connection
+	maxTranscriptEntries = 1000
+)
+
+// Transcript is the record of a single smtp session
+type Transcript struct {
+	// ID counts the sessions since the proxy started
+	ID int64 `json:"id"`
+
+	// Listener is the name of the listener that accepted the session
+	Listener string `json:"listener"`
+
+	// Client is the remote address of the client
+	Client string `json:"client"`
+
+	// Start and End of the session
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Duration of the session
+	Duration time.Duration `json:"duration"`
+
+	// TlsVersion is the negotiated tls version, empty for plaintext sessions
+	TlsVersion string `json:"tlsVersion,omitempty"`
+
+	// AuthMechanism is the mechanism of the last AUTH command, empty if the client did not authenticate
+	AuthMechanism string `json:"authMechanism,omitempty"`
+
+	// Error ended the session, empty if it ended regularly
+	Error string `json:"error,omitempty"`
+
+	// Entries are the commands and responses in the order they were sent
+	Entries []TranscriptEntry `json:"entries"`
+
+	// Truncated is true if the session had more entries than are kept
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// TranscriptEntry is a single command or response, credentials are redacted and message bodies are summarised
+type TranscriptEntry struct {
+	// Elapsed is the time since the start of the session
+	Elapsed time.Duration `json:"elapsed"`
+
+	Kind EntryKind `json:"kind"`
+	Line string    `json:"line"`
+}
+
+// record adds an entry to the transcript, it does nothing for sessions that are not recorded
+func (t *Transcript) record(kind EntryKind, line string) {
+	if t == nil {
+		return
+	}
+	if len(t.Entries) >= maxTranscriptEntries {
+		t.Truncated = true
+		return
+	}
+	t.Entries = append(t.Entries, TranscriptEntry{
+		Elapsed: time.Since(t.Start),
+		Kind:    kind,
+		Line:    strings.TrimRight(line, "\r\n"),
+	})
+}
+
+// authenticating records the mechanism of an AUTH command
+func (t *Transcript) authenticating(line string) {
+	if fields := strings.Fields(line); t != nil && len(fields) > 1 {
+		t.AuthMechanism = strings.ToUpper(fields[1])
+	}
+}
+
+// data summarises a message body by its size, the body itself is not recorded
+func (t *Transcript) data(size int64) {
+	t.record(DataEntry, strconv.FormatInt(size, 10)+" bytes")
+}
+
+// finish records the end and the tls state of the session
+func (t *Transcript) finish(client net.Conn, err error) {
+	if t == nil {
+		return
+	}
+	t.End = time.Now()
+	t.Duration = t.End.Sub(t.Start)
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if conn, ok := client.(*tls.Conn); ok {
+		t.TlsVersion = tlsVersionName(conn.ConnectionState().Version)
+	}
+}
+
+// redactCommand hides the credentials an AUTH command may carry as initial response
+func redactCommand(line string) string {
+	fields := strings.Fields(line)
+	if commandVerb(line) != "AUTH" || len(fields) < 3 {
+		return line
+	}
+	return fields[0] + " " + fields[1] + " " + redacted
+}
+
+// tlsVersionName returns the name of a tls version as used in openssl output
+func tlsVersionName(version uint16) string {
+	switch version {
+	case 0:
+		return ""
+	case tls.VersionTLS10:
+		return "TLSv1.0"
+	case tls.VersionTLS11:
+		return "TLSv1.1"
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	}
+	return "0x" + strconv.FormatUint(uint64(version), 16)
+}
+
+// transcriptRing keeps the transcripts of the last sessions
+type transcriptRing struct {
+	mu          sync.Mutex
+	transcripts []Transcript
+	next        int
+	sessions    int64
+}
+
+// newTranscriptRing returns a ring keeping the given count of transcripts
+func newTranscriptRing(size int) *transcriptRing {
+	return &transcriptRing{transcripts: make([]Transcript, 0, size)}
+}
+
+// start returns the transcript of a new session
+func (r *transcriptRing) start(listener, client string) *Transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions++
+	return &Transcript{ID: r.sessions, Listener: listener, Client: client, Start: time.Now()}
+}
+
+// add keeps a finished transcript, replacing the oldest one if the ring is full
+func (r *transcriptRing) add(t *Transcript) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.transcripts) < cap(r.transcripts) {
+		r.transcripts = append(r.transcripts, *t)
+		return
+	}
+	r.transcripts[r.next] = *t
+	r.next = (r.next + 1) % len(r.transcripts)
+}
+
+// list returns the kept transcripts, oldest first
+func (r *transcriptRing) list() []Transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Transcript, 0, len(r.transcripts))
+	list = append(list, r.transcripts[r.next:]...)
+	return append(list, r.transcripts[:r.next]...)
+}
+
+var errNegativeTranscripts = errors.New("the transcript count can not be negative")